@@ -0,0 +1,103 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// replySeparator goes between the captions of a post's attachments in a reply
+const replySeparator = "\n―\n"
+
+// maxAltTextChars returns max_alt_text_chars, 1500 by default
+func maxAltTextChars() int {
+	if config.Behavior.MaxAltTextChars <= 0 {
+		return 1500
+	}
+	return config.Behavior.MaxAltTextChars
+}
+
+// truncateAltText shortens text to at most limit characters. It cuts after the last full sentence
+// that fits and adds an ellipsis, or at the last word if the sentences are too long for that.
+func truncateAltText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	if limit < 2 {
+		return string(runes[:max(limit, 0)])
+	}
+
+	// Leave room for the " …" added after the cut
+	cut := runes[:limit-2]
+
+	// Only cut at a sentence if that keeps at least half of what fits
+	for i := len(cut) - 1; i >= len(cut)/2; i-- {
+		if isSentenceEnd(cut, i) {
+			return string(cut[:i+1]) + " …"
+		}
+	}
+
+	cut = runes[:limit-1]
+	for i := len(cut) - 1; i > 0; i-- {
+		if unicode.IsSpace(cut[i]) {
+			return strings.TrimRightFunc(string(cut[:i]), unicode.IsSpace) + "…"
+		}
+	}
+	return string(cut) + "…"
+}
+
+// isSentenceEnd reports whether the rune at i ends a sentence. Latin punctuation has to be followed
+// by a space so decimals and abbreviations like "3.5" aren't taken for one.
+func isSentenceEnd(runes []rune, i int) bool {
+	switch runes[i] {
+	case '。', '！', '？':
+		return true
+	case '.', '!', '?':
+		return i+1 < len(runes) && unicode.IsSpace(runes[i+1])
+	}
+	return false
+}
+
+// fitReply joins the captions of a reply with their separators between the mention and attribution in
+// prefix and the notes in suffix, shortening the captions so the whole reply stays within limit
+func fitReply(prefix string, responses []string, suffix string, limit int) string {
+	limit -= len([]rune(prefix)) + len([]rune(suffix))
+	return prefix + strings.Join(fitResponses(responses, limit), replySeparator) + suffix
+}
+
+// fitResponses shortens the captions of a reply so together with their separators they stay within limit.
+// Each caption gets an equal share, captions shorter than their share leave the rest to the longer ones.
+func fitResponses(responses []string, limit int) []string {
+	total := len([]rune(replySeparator)) * (len(responses) - 1)
+	lengths := make([]int, len(responses))
+	for i, response := range responses {
+		lengths[i] = len([]rune(response))
+		total += lengths[i]
+	}
+	if total <= limit || len(responses) == 0 {
+		return responses
+	}
+
+	budget := limit - len([]rune(replySeparator))*(len(responses)-1)
+
+	// Hand out the budget from the shortest caption up
+	order := make([]int, len(responses))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return lengths[order[a]] < lengths[order[b]] })
+
+	fitted := make([]string, len(responses))
+	for n, i := range order {
+		share := budget / (len(order) - n)
+		fitted[i] = truncateAltText(responses[i], share)
+		budget -= len([]rune(fitted[i]))
+	}
+	return fitted
+}
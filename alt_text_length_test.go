@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncateAltText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  string
+	}{
+		{"fits", "A cat.", 20, "A cat."},
+		{"sentence", "A cat sits on a mat. It is asleep in the sun.", 30, "A cat sits on a mat. …"},
+		{"decimal is no sentence end", "A 3.5 inch floppy disk lies on a wooden desk.", 20, "A 3.5 inch floppy…"},
+		{"word", "A very long sentence without any stop in it at all", 20, "A very long…"},
+		{"cjk", "猫がいる。窓の外を見ている。とても静か。", 16, "猫がいる。窓の外を見ている。 …"},
+		{"tiny limit", "Hello there", 1, "H"},
+	}
+	for _, test := range tests {
+		got := truncateAltText(test.text, test.limit)
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+		if len([]rune(got)) > test.limit {
+			t.Errorf("%s: %q is longer than %d", test.name, got, test.limit)
+		}
+	}
+}
+
+func TestFitResponsesSharesTheLimit(t *testing.T) {
+	short := "A dog."
+	long := strings.Repeat("A tree in a field. ", 20)
+
+	fitted := fitResponses([]string{short, long}, 100)
+	if fitted[0] != short {
+		t.Errorf("short caption was changed to %q", fitted[0])
+	}
+	if total := len([]rune(strings.Join(fitted, replySeparator))); total > 100 {
+		t.Errorf("reply is %d characters", total)
+	}
+	if !strings.HasSuffix(fitted[1], ". …") {
+		t.Errorf("long caption wasn't cut at a sentence: %q", fitted[1])
+	}
+}
+
+func TestFitReplyCountsMentionAndNotes(t *testing.T) {
+	prefix := "Generated by a model\n\n@someone@example.social "
+	suffix := "\n\nThis used about 1 Wh."
+	caption := strings.Repeat("A bird on a wire. ", 10)
+
+	reply := fitReply(prefix, []string{caption}, suffix, 120)
+	if len([]rune(reply)) > 120 {
+		t.Errorf("reply is %d characters: %q", len([]rune(reply)), reply)
+	}
+	if !strings.HasPrefix(reply, prefix) || !strings.HasSuffix(reply, suffix) {
+		t.Errorf("mention or notes were cut: %q", reply)
+	}
+
+	if reply := fitReply(prefix, []string{"A bird."}, suffix, 120); reply != prefix+"A bird."+suffix {
+		t.Errorf("short reply changed: %q", reply)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+const altTextCacheFile = "alttext_cache.json"
+
+// AltTextCacheEntry is a generated alt-text stored for media that was already described
+type AltTextCacheEntry struct {
+	AltText   string    `json:"alt_text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AltTextCache maps a SHA-256 of the media bytes and the language to its generated alt-text, so boosted
+// and re-federated posts don't trigger the LLM again
+type AltTextCache struct {
+	mu      sync.Mutex
+	Entries map[string]AltTextCacheEntry
+}
+
+var altTextCache = &AltTextCache{Entries: make(map[string]AltTextCacheEntry)}
+
+// loadAltTextCache loads the cache from disk, dropping entries that expired while the bot was offline
+func loadAltTextCache() error {
+	altTextCache.mu.Lock()
+	defer altTextCache.mu.Unlock()
+
+	if err := readJSONIfExists(altTextCacheFile, &altTextCache.Entries); err != nil {
+		return err
+	}
+	if altTextCache.Entries == nil {
+		altTextCache.Entries = make(map[string]AltTextCacheEntry)
+	}
+
+	for key, entry := range altTextCache.Entries {
+		if altTextCacheExpired(entry) {
+			delete(altTextCache.Entries, key)
+		}
+	}
+	return nil
+}
+
+func altTextCacheKey(mediaData []byte, lang string) string {
+	hash := sha256.Sum256(mediaData)
+	return hex.EncodeToString(hash[:]) + ":" + lang
+}
+
+// altTextCacheExpired checks an entry against ttl_hours (default 168, a week)
+func altTextCacheExpired(entry AltTextCacheEntry) bool {
+	ttl := time.Duration(config.Cache.TTLHours) * time.Hour
+	if ttl <= 0 {
+		ttl = 7 * 24 * time.Hour
+	}
+	return time.Since(entry.CreatedAt) > ttl
+}
+
+// getCachedAltText returns the alt-text previously generated for the same media and language
+func getCachedAltText(mediaData []byte, lang string) (string, bool) {
+	if !config.Cache.Enabled {
+		return "", false
+	}
+
+	altTextCache.mu.Lock()
+	defer altTextCache.mu.Unlock()
+
+	key := altTextCacheKey(mediaData, lang)
+	entry, ok := altTextCache.Entries[key]
+	if !ok {
+		return "", false
+	}
+	if altTextCacheExpired(entry) {
+		delete(altTextCache.Entries, key)
+		return "", false
+	}
+	return entry.AltText, true
+}
+
+// cacheAltText stores a generated alt-text, evicting the oldest entries once max_entries (default 1000) is reached
+func cacheAltText(mediaData []byte, lang string, altText string) {
+	if !config.Cache.Enabled || altText == "" {
+		return
+	}
+
+	maxEntries := config.Cache.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+
+	altTextCache.mu.Lock()
+	defer altTextCache.mu.Unlock()
+
+	for len(altTextCache.Entries) >= maxEntries {
+		var oldestKey string
+		var oldest time.Time
+		for key, entry := range altTextCache.Entries {
+			if oldestKey == "" || entry.CreatedAt.Before(oldest) {
+				oldestKey, oldest = key, entry.CreatedAt
+			}
+		}
+		delete(altTextCache.Entries, oldestKey)
+	}
+
+	altTextCache.Entries[altTextCacheKey(mediaData, lang)] = AltTextCacheEntry{
+		AltText:   altText,
+		CreatedAt: time.Now(),
+	}
+
+	if err := saveAltTextCacheUnlocked(); err != nil {
+		logErrorf("Error saving alt-text cache: %v", err)
+	}
+}
+
+// saveAltTextCacheUnlocked writes the cache to disk, the caller must hold the lock
+func saveAltTextCacheUnlocked() error {
+	data, err := json.Marshal(altTextCache.Entries)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(altTextCacheFile, data, 0644)
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// mediaServer serves data with the given content type at every path
+func mediaServer(t *testing.T, contentType string, data []byte) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// useAltTextCache gives the test an empty, enabled cache in a temporary directory
+func useAltTextCache(t *testing.T) {
+	t.Helper()
+	loadTestLocalizations(t)
+	useConfig(t)
+	t.Chdir(t.TempDir())
+
+	config.Cache.Enabled = true
+	config.ImageProcessing.MaxSizeMB = 10
+
+	previous := altTextCache
+	altTextCache = &AltTextCache{Entries: make(map[string]AltTextCacheEntry)}
+	t.Cleanup(func() { altTextCache = previous })
+}
+
+func TestIdenticalImageIsServedFromCache(t *testing.T) {
+	useAltTextCache(t)
+	provider := newStubProvider(stubResponse{text: "A colourful gradient."})
+	useProvider(t, provider)
+
+	image := testPNG(t)
+	first, second := mediaServer(t, "image/png", image), mediaServer(t, "image/png", image)
+
+	altText, _, err := generateImageAltText(first.URL+"/a.png", "en", "", altTextOptions{})
+	if err != nil || altText != "A colourful gradient." {
+		t.Fatalf("first request: %q, %v", altText, err)
+	}
+
+	// The same bytes from another URL, as when a post is boosted or re-federated
+	altText, servedBy, err := generateImageAltText(second.URL+"/b.png", "en", "", altTextOptions{})
+	if err != nil || altText != "A colourful gradient." || servedBy != "" {
+		t.Errorf("second request: %q by %q, %v", altText, servedBy, err)
+	}
+	if provider.calls() != 1 {
+		t.Errorf("provider called %d times, want once", provider.calls())
+	}
+
+	// Another language is described again
+	if _, _, err := generateImageAltText(first.URL+"/a.png", "de", "", altTextOptions{}); err != nil || provider.calls() != 2 {
+		t.Errorf("other language: %d calls, %v", provider.calls(), err)
+	}
+}
+
+func TestAltTextCacheEvictsOldestAndExpires(t *testing.T) {
+	useAltTextCache(t)
+	config.Cache.MaxEntries = 2
+	config.Cache.TTLHours = 1
+
+	for i := 0; i < 3; i++ {
+		cacheAltText([]byte{byte(i)}, "en", fmt.Sprintf("caption %d", i))
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := getCachedAltText([]byte{0}, "en"); ok {
+		t.Error("oldest entry wasn't evicted")
+	}
+	if altText, ok := getCachedAltText([]byte{2}, "en"); !ok || altText != "caption 2" {
+		t.Errorf("newest entry: %q, %v", altText, ok)
+	}
+
+	altTextCache.Entries[altTextCacheKey([]byte{1}, "en")] = AltTextCacheEntry{AltText: "old", CreatedAt: time.Now().Add(-2 * time.Hour)}
+	if _, ok := getCachedAltText([]byte{1}, "en"); ok {
+		t.Error("expired entry was returned")
+	}
+}
@@ -6,11 +6,18 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"text/tabwriter"
 	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mattn/go-mastodon"
 )
 
 // RunAdminCommand handles admin CLI commands
@@ -36,10 +43,30 @@ func RunAdminCommand(args []string) {
 		handleRevokeKey(args[1:])
 	case "extend-key":
 		handleExtendKey(args[1:])
+	case "set-limit":
+		handleSetLimit(args[1:])
+	case "set-rate-limit":
+		handleSetRateLimit(args[1:])
 	case "lookup":
 		handleLookup(args[1:])
 	case "cleanup":
 		handleCleanup()
+	case "stats":
+		handleStats(args[1:])
+	case "failed-emails":
+		handleFailedEmails()
+	case "export-user":
+		handleExportUser(args[1:])
+	case "forget", "forget-user":
+		handleForget(args[1:])
+	case "hash-image":
+		handleHashImage(args[1:])
+	case "export-corrections":
+		handleExportCorrections(args[1:])
+	case "weekly-summary":
+		handleWeeklySummary(args[1:])
+	case "ratelimit":
+		handleRateLimit(args[1:])
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		printAdminHelp()
@@ -49,9 +76,9 @@ func RunAdminCommand(args []string) {
 func printAdminHelp() {
 	fmt.Println(`Altbot Admin Commands:
  
-   create-key --email <email> [--days <days>] [--note <note>]
+   create-key --email <email> [--days <days>] [--note <note>] [--monthly-limit <n>] [--tier <tier>]
 	   Create a new API key for a user
-	   Default: 30 days
+	   Default: 30 days, the server's monthly limit
  
    list-keys
 	   List all API keys
@@ -62,12 +89,55 @@ func printAdminHelp() {
    extend-key <key> --days <days>
 	   Extend an API key's expiration
  
+   set-limit <key> [--monthly-limit <n>] [--tier <tier>]
+	   Set a key's monthly image limit or its tier from [api] tiers
+	   A monthly limit of 0 and an empty tier ("") restore the server defaults
+ 
+   set-rate-limit <key> [--per-minute <n>] [--per-hour <n>]
+	   Override a key's request rate limits
+	   0 uses the configured limit, -1 removes the limit
+ 
    lookup --email <email>
 	   Find API key by email
  
    cleanup
 	   Remove keys expired more than 30 days ago
  
+   stats [--json]
+	   Show totals, the top 10 keys by usage and keys expiring within 7 days
+ 
+   failed-emails
+	   List key emails that could not be delivered
+ 
+   export-user <userID> [--output <file>]
+	   Write everything stored about a user to a JSON file (GDPR right to access)
+	   Default: user-<userID>.json
+ 
+   forget <userID> (or forget-user <userID>)
+	   Erase a user's consent, rate limit, pending request, metrics and correction data (GDPR erasure)
+	   Refused while the bot is running, mention it with "forget <userID>" from the admin contact account instead
+ 
+   hash-image <file>
+	   Print the perceptual hash of an image for the known images file
+ 
+   export-corrections [--output <file>]
+	   Export the submitted caption corrections as JSON for review
+	   Default: print to stdout
+ 
+   weekly-summary [--preview | --post-now]
+	   Print the weekly summary from the current logs, or post it right away
+	   Default: --preview
+ 
+   ratelimit list-banned
+	   List the shadow banned users with their exceeded limit counts
+ 
+   ratelimit whitelist <userID>
+	   Exempt a user from shadow bans
+ 
+   ratelimit unban <userID>
+	   Lift a user's shadow ban and whitelist them
+	   Stop the bot before changing users, it rewrites ratelimiter.json from memory while running
+ 
  Examples:
    ./altbot admin create-key --email lily@example.com --days 30 --note "Ko-fi purchase"
    ./altbot admin list-keys
@@ -77,9 +147,10 @@ func printAdminHelp() {
 }
 
 func handleCreateKey(args []string) {
-	var email string
+	var email, tier string
 	days := 30
 	note := "Manual creation"
+	monthlyLimit := 0
 
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
@@ -98,6 +169,16 @@ func handleCreateKey(args []string) {
 				note = args[i+1]
 				i++
 			}
+		case "--monthly-limit":
+			if i+1 < len(args) {
+				fmt.Sscanf(args[i+1], "%d", &monthlyLimit)
+				i++
+			}
+		case "--tier":
+			if i+1 < len(args) {
+				tier = args[i+1]
+				i++
+			}
 		}
 	}
 
@@ -121,11 +202,24 @@ func handleCreateKey(args []string) {
 		return
 	}
 
+	if monthlyLimit > 0 || tier != "" {
+		if err := SetAPIKeyLimit(apiKey.Key, &monthlyLimit, &tier); err != nil {
+			fmt.Printf("Error setting key limits: %v\n", err)
+			return
+		}
+	}
+
 	fmt.Printf("\n%s=== API Key Created ===%s\n", Green, Reset)
 	fmt.Printf("Email:   %s\n", apiKey.Email)
 	fmt.Printf("Key:     %s\n", apiKey.Key)
 	fmt.Printf("Expires: %s (%d days)\n", apiKey.ExpiresAt.Format("2006-01-02"), days)
 	fmt.Printf("Note:    %s\n", note)
+	if tier != "" {
+		fmt.Printf("Tier:    %s\n", tier)
+	}
+	if monthlyLimit > 0 {
+		fmt.Printf("Limit:   %d images/month\n", monthlyLimit)
+	}
 	fmt.Printf("%s========================%s\n\n", Green, Reset)
 
 	fmt.Println("Send this key to the user!")
@@ -209,11 +303,94 @@ func handleExtendKey(args []string) {
 	}
 
 	// Get updated info
-	_, daysRemaining, expiresAt, _ := GetAPIKeyUsage(key)
+	_, _, daysRemaining, expiresAt, _ := GetAPIKeyUsage(key, nil)
 	fmt.Printf("API key extended by %d days.\n", days)
 	fmt.Printf("New expiration: %s (%d days remaining)\n", expiresAt.Format("2006-01-02"), daysRemaining)
 }
 
+func handleSetLimit(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Error: API key required")
+		fmt.Println("Usage: set-limit <key> [--monthly-limit <n>] [--tier <tier>]")
+		return
+	}
+
+	key := args[0]
+	var monthlyLimit *int
+	var tier *string
+
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--monthly-limit":
+			if i+1 < len(args) {
+				var n int
+				fmt.Sscanf(args[i+1], "%d", &n)
+				monthlyLimit = &n
+				i++
+			}
+		case "--tier":
+			if i+1 < len(args) {
+				tier = &args[i+1]
+				i++
+			}
+		}
+	}
+
+	if monthlyLimit == nil && tier == nil {
+		fmt.Println("Error: --monthly-limit or --tier required")
+		return
+	}
+
+	if err := SetAPIKeyLimit(key, monthlyLimit, tier); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	fmt.Println("Limits updated.")
+}
+
+func handleSetRateLimit(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Error: API key required")
+		fmt.Println("Usage: set-rate-limit <key> [--per-minute <n>] [--per-hour <n>]")
+		return
+	}
+
+	key := args[0]
+	var perMinute, perHour *int
+
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--per-minute":
+			if i+1 < len(args) {
+				var n int
+				fmt.Sscanf(args[i+1], "%d", &n)
+				perMinute = &n
+				i++
+			}
+		case "--per-hour":
+			if i+1 < len(args) {
+				var n int
+				fmt.Sscanf(args[i+1], "%d", &n)
+				perHour = &n
+				i++
+			}
+		}
+	}
+
+	if perMinute == nil && perHour == nil {
+		fmt.Println("Error: --per-minute or --per-hour required")
+		return
+	}
+
+	if err := SetAPIKeyRateLimit(key, perMinute, perHour); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	fmt.Println("Rate limits updated.")
+}
+
 func handleLookup(args []string) {
 	var email string
 
@@ -250,12 +427,122 @@ func handleLookup(args []string) {
 	fmt.Printf("Created:    %s\n", key.CreatedAt.Format("2006-01-02 15:04"))
 	fmt.Printf("Expires:    %s\n", key.ExpiresAt.Format("2006-01-02 15:04"))
 	fmt.Printf("Usage:      %d this month\n", key.UsageMonth)
+	for mediaType, count := range key.MediaUsage {
+		fmt.Printf("            %d %s this month\n", count, mediaType)
+	}
 	if key.Note != "" {
 		fmt.Printf("Note:       %s\n", key.Note)
 	}
 	fmt.Printf("%s========================%s\n", Cyan, Reset)
 }
 
+// APIUsageStats aggregates usage over all API keys
+type APIUsageStats struct {
+	TotalKeys       int            `json:"total_keys"`
+	ActiveKeys      int            `json:"active_keys"`
+	UsageThisMonth  map[string]int `json:"usage_this_month"` // By media type
+	TopKeys         []KeyUsage     `json:"top_keys"`
+	ExpiringIn7Days int            `json:"expiring_in_7_days"`
+}
+
+// KeyUsage is one key's usage this month over all media types
+type KeyUsage struct {
+	Email     string    `json:"email"`
+	Usage     int       `json:"usage"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// computeAPIUsageStats aggregates the usage of the given keys
+func computeAPIUsageStats(keys []*APIKey, now time.Time) APIUsageStats {
+	stats := APIUsageStats{
+		TotalKeys:      len(keys),
+		UsageThisMonth: make(map[string]int),
+	}
+
+	var usages []KeyUsage
+	for _, key := range keys {
+		active := key.Active && now.Before(key.ExpiresAt)
+		if active {
+			stats.ActiveKeys++
+			if key.ExpiresAt.Before(now.AddDate(0, 0, 7)) {
+				stats.ExpiringIn7Days++
+			}
+		}
+
+		// Counters are only reset on a key's first request of the month, older ones are last month's
+		if key.LastReset.Month() != now.Month() || key.LastReset.Year() != now.Year() {
+			continue
+		}
+
+		total := key.UsageMonth
+		stats.UsageThisMonth["image"] += key.UsageMonth
+		for mediaType, count := range key.MediaUsage {
+			stats.UsageThisMonth[mediaType] += count
+			total += count
+		}
+		if total > 0 {
+			usages = append(usages, KeyUsage{Email: key.Email, Usage: total, ExpiresAt: key.ExpiresAt})
+		}
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].Usage > usages[j].Usage
+	})
+	if len(usages) > 10 {
+		usages = usages[:10]
+	}
+	stats.TopKeys = usages
+
+	return stats
+}
+
+func handleStats(args []string) {
+	asJSON := false
+	for _, arg := range args {
+		if arg == "--json" {
+			asJSON = true
+		}
+	}
+
+	stats := computeAPIUsageStats(ListAPIKeys(), time.Now())
+
+	if asJSON {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("\n%s=== API Usage ===%s\n", Green, Reset)
+	fmt.Printf("%-19s %d (%d active)\n", "Keys:", stats.TotalKeys, stats.ActiveKeys)
+	mediaTypes := make([]string, 0, len(stats.UsageThisMonth))
+	for mediaType := range stats.UsageThisMonth {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+	for _, mediaType := range mediaTypes {
+		fmt.Printf("%-19s %d\n", "Usage ("+mediaType+"):", stats.UsageThisMonth[mediaType])
+	}
+	fmt.Printf("%-19s %d\n", "Expiring in 7 days:", stats.ExpiringIn7Days)
+	fmt.Printf("%s=================%s\n\n", Green, Reset)
+
+	if len(stats.TopKeys) == 0 {
+		fmt.Println("No usage this month.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EMAIL\tUSAGE\tEXPIRES")
+	fmt.Fprintln(w, "-----\t-----\t-------")
+	for _, key := range stats.TopKeys {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", key.Email, key.Usage, key.ExpiresAt.Format("2006-01-02"))
+	}
+	w.Flush()
+}
+
 func handleCleanup() {
 	removed := CleanupExpiredKeys()
 	fmt.Printf("Cleaned up %d expired keys.\n", removed)
@@ -279,3 +566,274 @@ func FormatKeyForEmail(apiKey *APIKey) string {
 
 	return sb.String()
 }
+
+func handleFailedEmails() {
+	failed, err := ListFailedEmails()
+	if err != nil {
+		fmt.Printf("Error reading failed emails: %v\n", err)
+		return
+	}
+
+	if len(failed) == 0 {
+		fmt.Println("No failed emails.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TO\tSUBJECT\tATTEMPTS\tFAILED\tERROR")
+	fmt.Fprintln(w, "--\t-------\t--------\t------\t-----")
+
+	for _, job := range failed {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n",
+			job.Email.To,
+			job.Email.Subject,
+			job.Attempts,
+			job.FailedAt.Format("2006-01-02 15:04"),
+			job.LastError,
+		)
+	}
+
+	w.Flush()
+	fmt.Printf("\nTotal: %d failed emails (retried on the next restart)\n", len(failed))
+}
+
+func handleExportUser(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: export-user <userID> [--output <file>]")
+		return
+	}
+
+	userID := args[0]
+	output := fmt.Sprintf("user-%s.json", userID)
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--output":
+			if i+1 < len(args) {
+				output = args[i+1]
+				i++
+			}
+		}
+	}
+
+	export, err := ExportUser(userID)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	if err := os.WriteFile(output, data, 0600); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("Exported the data of user %s (%d metrics events, %d corrections) to %s\n", userID, len(export.Metrics), len(export.Corrections), output)
+}
+
+func handleForget(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: forget <userID>")
+		return
+	}
+
+	// The running bot would write the user's data back from memory
+	if pid, running := runningBotPID(); running {
+		fmt.Printf("Error: the bot is running (PID %d). Mention it with \"forget %s\" from the admin contact account instead, or stop it first.\n", pid, args[0])
+		return
+	}
+
+	userID := args[0]
+	removed, err := forgetUserOffline(userID)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	if len(removed) == 0 {
+		fmt.Printf("No stored data found for user %s\n", userID)
+		return
+	}
+
+	fmt.Printf("\n%s=== User Data Erased ===%s\n", Green, Reset)
+	for store, count := range removed {
+		fmt.Printf("%-17s %d entries\n", store+":", count)
+	}
+	fmt.Printf("%s========================%s\n\n", Green, Reset)
+	fmt.Printf("Audit entry written to %s\n", gdprAuditLogFile)
+}
+
+func handleRateLimit(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: ratelimit <list-banned | whitelist <userID> | unban <userID>>")
+		return
+	}
+
+	rl := NewRateLimiter()
+	if err := rl.LoadFromFile("ratelimiter.json"); err != nil {
+		fmt.Printf("Error loading rate limiter state: %v\n", err)
+		return
+	}
+
+	switch args[0] {
+	case "list-banned":
+		var banned []string
+		for userID, isBanned := range rl.ShadowBanned {
+			if isBanned {
+				banned = append(banned, userID)
+			}
+		}
+
+		if len(banned) == 0 {
+			fmt.Println("No shadow banned users.")
+			return
+		}
+		sort.Strings(banned)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "USER ID\tEXCEEDED\tACCOUNT CREATED")
+		fmt.Fprintln(w, "-------\t--------\t---------------")
+		for _, userID := range banned {
+			created := "unknown"
+			if date, exists := rl.AccountAges[userID]; exists {
+				created = date.Format("2006-01-02")
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\n", userID, rl.ExceededCounts[userID], created)
+		}
+		w.Flush()
+		fmt.Printf("\nTotal: %d shadow banned users\n", len(banned))
+	case "whitelist":
+		if len(args) < 2 {
+			fmt.Println("Usage: ratelimit whitelist <userID>")
+			return
+		}
+		if err := rl.WhitelistUser(args[1]); err != nil {
+			fmt.Printf("Error saving rate limiter state: %v\n", err)
+			return
+		}
+		fmt.Printf("User %s added to the whitelist.\n", args[1])
+		if rl.ShadowBanned[args[1]] {
+			fmt.Printf("They are still shadow banned, use ratelimit unban %s to lift it\n", args[1])
+		}
+	case "unban":
+		if len(args) < 2 {
+			fmt.Println("Usage: ratelimit unban <userID>")
+			return
+		}
+		if !rl.ShadowBanned[args[1]] {
+			fmt.Printf("User %s is not shadow banned, whitelisting them anyway\n", args[1])
+		}
+		rl.UnbanAndWhitelistUser(args[1])
+		fmt.Printf("User %s unbanned and added to the whitelist.\n", args[1])
+	default:
+		fmt.Printf("Unknown ratelimit command: %s\n", args[0])
+		fmt.Println("Usage: ratelimit <list-banned | whitelist <userID> | unban <userID>>")
+	}
+}
+
+func handleHashImage(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: hash-image <file>")
+		return
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	img, _, err := decodeImage(data)
+	if err != nil {
+		fmt.Printf("Error decoding image: %v\n", err)
+		return
+	}
+
+	fmt.Println(formatPerceptualHash(perceptualHash(img)))
+}
+
+func handleExportCorrections(args []string) {
+	var output string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--output":
+			if i+1 < len(args) {
+				output = args[i+1]
+				i++
+			}
+		}
+	}
+
+	corrections, err := LoadCorrections()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	data, err := json.MarshalIndent(corrections, "", "  ")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	if output == "" {
+		fmt.Println(string(data))
+		return
+	}
+
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("Exported %d corrections to %s\n", len(corrections), output)
+}
+
+func handleWeeklySummary(args []string) {
+	postNow := false
+	for _, arg := range args {
+		switch arg {
+		case "--preview":
+			postNow = false
+		case "--post-now":
+			postNow = true
+		}
+	}
+
+	// The summary is rendered from the bot's configuration and localizations
+	if _, err := toml.DecodeFile("config.toml", &config); err != nil {
+		fmt.Printf("Error loading config.toml: %v\n", err)
+		return
+	}
+	if err := loadLocalizations(); err != nil {
+		fmt.Printf("Error loading localizations: %v\n", err)
+		return
+	}
+
+	message, err := renderWeeklySummary()
+	if errors.Is(err, errQuietWeek) {
+		fmt.Println("There was no activity this week and no quiet_week_message is set, so nothing would be posted")
+		return
+	} else if err != nil {
+		fmt.Printf("Error rendering weekly summary: %v\n", err)
+		return
+	}
+
+	if !postNow {
+		fmt.Println(message)
+		return
+	}
+
+	c := mastodon.NewClient(&mastodon.Config{
+		Server:       config.Server.MastodonServer,
+		ClientSecret: config.Server.ClientSecret,
+		AccessToken:  config.Server.AccessToken,
+	})
+	if err := postWeeklySummary(c, context.Background(), message); err != nil {
+		fmt.Printf("Error posting weekly summary: %v\n", err)
+		return
+	}
+	fmt.Println("Weekly summary posted")
+}
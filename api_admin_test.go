@@ -0,0 +1,92 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComputeAPIUsageStats(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	thisMonth := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	lastMonth := time.Date(2025, 5, 20, 0, 0, 0, 0, time.UTC)
+
+	keys := []*APIKey{
+		{Email: "heavy@example.com", Active: true, ExpiresAt: now.AddDate(0, 1, 0), LastReset: thisMonth, UsageMonth: 40, MediaUsage: map[string]int{"video": 5}},
+		{Email: "light@example.com", Active: true, ExpiresAt: now.AddDate(0, 0, 3), LastReset: thisMonth, UsageMonth: 2, MediaUsage: map[string]int{"audio": 1}},
+		// Last month's counters haven't been reset yet and don't count
+		{Email: "stale@example.com", Active: true, ExpiresAt: now.AddDate(0, 2, 0), LastReset: lastMonth, UsageMonth: 99},
+		{Email: "revoked@example.com", Active: false, ExpiresAt: now.AddDate(0, 1, 0), LastReset: thisMonth, UsageMonth: 7},
+		{Email: "expired@example.com", Active: true, ExpiresAt: now.AddDate(0, 0, -1), LastReset: thisMonth},
+	}
+
+	stats := computeAPIUsageStats(keys, now)
+	if stats.TotalKeys != 5 || stats.ActiveKeys != 3 || stats.ExpiringIn7Days != 1 {
+		t.Errorf("keys: %d total, %d active, %d expiring", stats.TotalKeys, stats.ActiveKeys, stats.ExpiringIn7Days)
+	}
+	if stats.UsageThisMonth["image"] != 49 || stats.UsageThisMonth["video"] != 5 || stats.UsageThisMonth["audio"] != 1 {
+		t.Errorf("usage = %v", stats.UsageThisMonth)
+	}
+	if len(stats.TopKeys) != 3 || stats.TopKeys[0].Email != "heavy@example.com" || stats.TopKeys[0].Usage != 45 {
+		t.Errorf("top keys = %+v", stats.TopKeys)
+	}
+}
+
+// loadRateLimiterFile reads back the rate limiter state the admin commands saved
+func loadRateLimiterFile(t *testing.T) *RateLimiter {
+	t.Helper()
+	rl := NewRateLimiter()
+	if err := rl.LoadFromFile("ratelimiter.json"); err != nil {
+		t.Fatal(err)
+	}
+	return rl
+}
+
+func TestRateLimitAdminCommands(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	rl := NewRateLimiter()
+	rl.ShadowBanned["20"] = true
+	rl.ExceededCounts["20"] = 4
+	rl.AccountAges["20"] = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	rl.ShadowBanned["30"] = true
+	rl.ShadowBanned["40"] = false
+	writeJSONFile(t, "ratelimiter.json", rl)
+
+	output := captureStdout(t, func() { handleRateLimit([]string{"list-banned"}) })
+	var rows []string
+	for _, line := range strings.Split(output, "\n") {
+		rows = append(rows, strings.Join(strings.Fields(line), " "))
+	}
+	if !strings.Contains(strings.Join(rows, "\n"), "20 4 2025-06-01\n30 0 unknown\n\nTotal: 2 shadow banned users") {
+		t.Errorf("list-banned printed:\n%s", output)
+	}
+
+	// Whitelisting doesn't lift a ban
+	output = captureStdout(t, func() { handleRateLimit([]string{"whitelist", "30"}) })
+	if saved := loadRateLimiterFile(t); !saved.Whitelist["30"] || !saved.ShadowBanned["30"] {
+		t.Errorf("after whitelist: whitelisted %v, banned %v", saved.Whitelist["30"], saved.ShadowBanned["30"])
+	}
+	if !strings.Contains(output, "still shadow banned") {
+		t.Errorf("whitelist didn't mention the ban: %q", output)
+	}
+
+	captureStdout(t, func() { handleRateLimit([]string{"unban", "20"}) })
+	saved := loadRateLimiterFile(t)
+	if saved.ShadowBanned["20"] || !saved.Whitelist["20"] {
+		t.Errorf("after unban: banned %v, whitelisted %v", saved.ShadowBanned["20"], saved.Whitelist["20"])
+	}
+	if !saved.ShadowBanned["30"] {
+		t.Error("unban lifted another user's ban")
+	}
+
+	output = captureStdout(t, func() { handleRateLimit([]string{"list-banned"}) })
+	if !strings.Contains(output, "Total: 1 shadow banned users") {
+		t.Errorf("list-banned after unban:\n%s", output)
+	}
+}
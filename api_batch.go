@@ -0,0 +1,381 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultBatchWindow is how long the queue waits to fill a batch when batch_window_ms isn't set
+	defaultBatchWindow = 200 * time.Millisecond
+	// defaultBatchMaxItems is how many images a /api/v1/batch request may have when batch_max_items isn't set
+	defaultBatchMaxItems = 10
+	// batchDeadline is how long a /api/v1/batch request waits for its images, within the server's write timeout
+	batchDeadline = 110 * time.Second
+)
+
+// batchable reports whether a request can be described along with others in a single provider call
+func batchable(request APIRequest) bool {
+	return config.API.BatchSize > 1 && request.MediaType == "image" && llmProvider.Capabilities().MultiImage
+}
+
+// collectBatches waits a short while for more requests after the first one and groups the images of the
+// same language into batches of up to batch_size. Other requests are returned on their own, in the order
+// they came in.
+func collectBatches(first APIRequest) [][]APIRequest {
+	if !batchable(first) {
+		return [][]APIRequest{{first}}
+	}
+
+	window := defaultBatchWindow
+	if config.API.BatchWindowMs > 0 {
+		window = time.Duration(config.API.BatchWindowMs) * time.Millisecond
+	}
+	timer := time.NewTimer(window)
+	defer timer.Stop()
+
+	requests := []APIRequest{first}
+collect:
+	for len(requests) < config.API.BatchSize {
+		select {
+		case request, ok := <-requestQueue:
+			if !ok {
+				break collect
+			}
+			requests = append(requests, request)
+		case <-timer.C:
+			break collect
+		}
+	}
+
+	var batches [][]APIRequest
+	byLanguage := make(map[string]int)
+	for _, request := range requests {
+		if !batchable(request) {
+			batches = append(batches, []APIRequest{request})
+			continue
+		}
+		if i, ok := byLanguage[request.Language]; ok {
+			batches[i] = append(batches[i], request)
+			continue
+		}
+		byLanguage[request.Language] = len(batches)
+		batches = append(batches, []APIRequest{request})
+	}
+	return batches
+}
+
+// processBatch describes the images of several same-language requests in one provider call and sends
+// each request its own description. Requests the model skipped, or all of them if the call fails, are
+// described on their own instead.
+func (s *APIServer) processBatch(requests []APIRequest) {
+	lang := requests[0].Language
+
+	var batch []APIRequest
+	var images [][]byte
+	var formats []string
+	for _, request := range requests {
+		downscaledImg, format, err := downscaleImage(request.MediaData, config.ImageProcessing.DownscaleWidth)
+		if err != nil {
+			requestLog(request.ID).Errorf("Error downscaling image for batched alt-text: %v", err)
+			request.ResultCh <- APIResult{Error: fmt.Errorf("%w: %v", errInvalidImage, err)}
+			continue
+		}
+		batch = append(batch, request)
+		images = append(images, downscaledImg)
+		formats = append(formats, format)
+	}
+
+	if len(batch) < 2 {
+		for _, request := range batch {
+			s.processRequest(request)
+		}
+		return
+	}
+
+	// Unlike the images of one post, the images of a batch come from different people and don't share context
+	ids := make([]string, len(batch))
+	for i, request := range batch {
+		ids[i] = request.ID
+	}
+	logInfof("Processing %d API requests in a batch: %s", len(batch), strings.Join(ids, ", "))
+
+	response, _, err := generateWith(llmProvider, "multi-image", canMultiImage, func(name string, provider LLMProvider) (string, error) {
+		prompt := getPromptForUser(lang, altTextPromptKey(""), "", name) + " " + fmt.Sprintf(getPromptHint(lang, "batchImageInstructions"), len(images))
+		return withLLMRetry(func() (string, error) {
+			return provider.GenerateMultiImageAltText(prompt, images, formats, lang)
+		})
+	})
+	if err != nil {
+		logWarnf("Error generating batched alt-text for %s, describing images separately: %v", strings.Join(ids, ", "), err)
+		for _, request := range batch {
+			s.processRequest(request)
+		}
+		return
+	}
+
+	descriptions := splitNumberedList(response, len(batch))
+	for i, request := range batch {
+		description := postProcessAltText(descriptions[i], lang)
+		if description == "" {
+			requestLog(request.ID).Infof("Batched alt-text skipped the request, describing it separately")
+			s.processRequest(request)
+			continue
+		}
+		if !usableAltText(description, lang) {
+			s.processRequest(request)
+			continue
+		}
+		s.finishRequest(request, description)
+	}
+}
+
+// BatchItemResult is the outcome of one image of a /api/v1/batch request
+type BatchItemResult struct {
+	Index        int    `json:"index"`
+	AltText      string `json:"alt_text,omitempty"`
+	GenerationID string `json:"generation_id,omitempty"`
+	Error        string `json:"error,omitempty"`
+	Code         string `json:"code,omitempty"`
+}
+
+// batchItem is an image of a /api/v1/batch request read from an upload or a URL
+type batchItem struct {
+	data   []byte
+	format string
+	err    error
+	code   string
+}
+
+// batchMaxItems returns how many images a /api/v1/batch request may have
+func batchMaxItems() int {
+	if config.API.BatchMaxItems > 0 {
+		return config.API.BatchMaxItems
+	}
+	return defaultBatchMaxItems
+}
+
+// readBatchItems reads the images of a batch from the "image" parts of a multipart form
+// or the "urls" of a JSON body. Items that can't be read carry their error.
+func (s *APIServer) readBatchItems(w http.ResponseWriter, r *http.Request) ([]batchItem, string, bool) {
+	maxSize := maxMediaSize("image")
+
+	if isJSONRequest(r) {
+		var body struct {
+			URLs     []string `json:"urls"`
+			Language string   `json:"language"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			s.apiError(w, errCodeInvalidRequest, "Invalid JSON body", http.StatusBadRequest)
+			return nil, "", false
+		}
+		if len(body.URLs) == 0 {
+			s.apiError(w, errCodeInvalidRequest, "Missing 'urls' field in JSON body", http.StatusBadRequest)
+			return nil, "", false
+		}
+		if len(body.URLs) > batchMaxItems() {
+			s.apiError(w, errCodeInvalidRequest, fmt.Sprintf("Too many images, a batch can have at most %d", batchMaxItems()), http.StatusBadRequest)
+			return nil, "", false
+		}
+
+		items := make([]batchItem, len(body.URLs))
+		for i, rawURL := range body.URLs {
+			data, format, err := fetchMediaURL(rawURL, "image", maxSize)
+			var tooLarge *mediaTooLargeError
+			switch {
+			case errors.As(err, &tooLarge):
+				items[i] = batchItem{err: err, code: errCodeFileTooLarge}
+			case errors.Is(err, errInvalidMediaURL):
+				items[i] = batchItem{err: err, code: errCodeInvalidURL}
+			case err != nil:
+				items[i] = batchItem{err: err, code: errCodeDownloadFailed}
+			default:
+				items[i] = batchItem{data: data, format: format}
+			}
+		}
+		return items, body.Language, true
+	}
+
+	// Parse multipart form, parts beyond the memory threshold are written to temporary files
+	if err := r.ParseMultipartForm(uploadMemoryThreshold); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			s.uploadTooLarge(w, maxUploadSize())
+			return nil, "", false
+		}
+		s.apiError(w, errCodeInvalidRequest, "Failed to parse form data: "+err.Error(), http.StatusBadRequest)
+		return nil, "", false
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	headers := r.MultipartForm.File["image"]
+	if len(headers) == 0 {
+		s.apiError(w, errCodeInvalidRequest, "Missing 'image' fields in form data", http.StatusBadRequest)
+		return nil, "", false
+	}
+	if len(headers) > batchMaxItems() {
+		s.apiError(w, errCodeInvalidRequest, fmt.Sprintf("Too many images, a batch can have at most %d", batchMaxItems()), http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	items := make([]batchItem, len(headers))
+	for i, header := range headers {
+		if header.Size > maxSize {
+			items[i] = batchItem{err: fmt.Errorf("File too large. Maximum upload size is %d MB", maxSize>>20), code: errCodeFileTooLarge}
+			continue
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			items[i] = batchItem{err: errors.New("Failed to read image data"), code: errCodeInvalidRequest}
+			continue
+		}
+		data, err := readUpload(file, "image", maxSize)
+		file.Close()
+		var tooLarge *mediaTooLargeError
+		if errors.As(err, &tooLarge) {
+			items[i] = batchItem{err: fmt.Errorf("File too large. Maximum upload size is %d MB", maxSize>>20), code: errCodeFileTooLarge}
+			continue
+		}
+		if err != nil {
+			items[i] = batchItem{err: errors.New("Failed to read image data"), code: errCodeInvalidRequest}
+			continue
+		}
+
+		items[i] = batchItem{data: data, format: detectMediaFormat("image", header.Filename, header.Header.Get("Content-Type"), data)}
+	}
+	return items, r.FormValue("language"), true
+}
+
+// handleBatch describes several images in one request and answers with the result of each, once
+// all are done or the deadline passes. Images that fail don't fail the others, each one counts
+// against the key's monthly quota.
+func (s *APIServer) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.apiError(w, errCodeMethodNotAllowed, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	apiKey := extractAPIKey(r)
+	if apiKey == "" {
+		s.apiError(w, errCodeMissingAPIKey, "Missing API key. Use Authorization: Bearer <your-key>", http.StatusUnauthorized)
+		return
+	}
+
+	keyData, err := ValidateAPIKey(apiKey)
+	if err != nil {
+		s.apiError(w, errCodeInvalidAPIKey, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if retryAfter, ok := checkAPIRateLimit(keyData); !ok {
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		s.apiError(w, errCodeRateLimited, fmt.Sprintf("Rate limit exceeded, retry in %d seconds", seconds), http.StatusTooManyRequests)
+		return
+	}
+
+	// A batch may be as large as its images together
+	maxUpload := maxUploadSize() * int64(batchMaxItems())
+	if r.ContentLength > maxUpload {
+		s.uploadTooLarge(w, maxUpload)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+
+	items, language, ok := s.readBatchItems(w, r)
+	if !ok {
+		return
+	}
+	if language == "" {
+		language = "en"
+	}
+
+	deadline := time.Now().Add(batchDeadline)
+	results := make([]BatchItemResult, len(items))
+	resultChs := make([]chan APIResult, len(items))
+	formats := acceptedMediaFormats("image")
+	for i, item := range items {
+		results[i].Index = i
+
+		if item.err == nil && !slices.Contains(formats, item.format) {
+			item.err = fmt.Errorf("Unsupported image format. Supported formats: %s", strings.Join(formats, ", "))
+			item.code = errCodeUnsupportedFormat
+		}
+		if item.err == nil {
+			if err := CheckAndIncrementUsage(apiKey, "image", s.monthlyLimits["image"]); err != nil {
+				item.err, item.code = err, errCodeQuotaExceeded
+			}
+		}
+		if item.err != nil {
+			results[i].Error, results[i].Code = item.err.Error(), item.code
+			continue
+		}
+
+		resultChs[i] = make(chan APIResult, 1)
+		request := APIRequest{
+			ID:        fmt.Sprintf("%s-%d", requestIDFrom(r), i),
+			Email:     keyData.Email,
+			MediaType: "image",
+			MediaData: item.data,
+			Format:    item.format,
+			Language:  language,
+			ResultCh:  resultChs[i],
+		}
+
+		select {
+		case requestQueue <- request:
+		case <-time.After(time.Until(deadline)):
+			resultChs[i] = nil
+			results[i].Error, results[i].Code = "Server busy, please try again later", errCodeServerBusy
+		}
+	}
+
+	// Wait for the queued images, those not done by the deadline time out
+	for i, resultCh := range resultChs {
+		if resultCh == nil {
+			continue
+		}
+
+		var result APIResult
+		select {
+		case result = <-resultCh:
+		case <-time.After(time.Until(deadline)):
+			results[i].Error, results[i].Code = "Request timeout", errCodeTimeout
+			continue
+		}
+
+		if result.Error != nil {
+			results[i].Error, results[i].Code = "Failed to generate alt-text: "+result.Error.Error(), resultErrorCode(result.Error)
+			continue
+		}
+		results[i].AltText, results[i].GenerationID = result.AltText, result.GenerationID
+	}
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Error == "" {
+			succeeded++
+		}
+	}
+
+	s.jsonResponse(w, map[string]interface{}{
+		"results":    results,
+		"succeeded":  succeeded,
+		"failed":     len(results) - succeeded,
+		"media_type": "image",
+		"language":   language,
+	})
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// enqueueImages queues an image request per language and returns their result channels
+func enqueueImages(t *testing.T, languages ...string) []chan APIResult {
+	t.Helper()
+	var results []chan APIResult
+	for i, lang := range languages {
+		resultCh := make(chan APIResult, 1)
+		requestQueue <- APIRequest{
+			ID:        fmt.Sprintf("req-%d", i),
+			Email:     "test@example.com",
+			MediaType: "image",
+			MediaData: testPNG(t),
+			Format:    "png",
+			Language:  lang,
+			ResultCh:  resultCh,
+		}
+		results = append(results, resultCh)
+	}
+	return results
+}
+
+// waitResult waits for the result of a queued request
+func waitResult(t *testing.T, resultCh chan APIResult) APIResult {
+	t.Helper()
+	select {
+	case result := <-resultCh:
+		return result
+	case <-time.After(10 * time.Second):
+		t.Fatal("no result")
+		return APIResult{}
+	}
+}
+
+func TestSameLanguageRequestsAreBatched(t *testing.T) {
+	newTestAPIServer(t, "unused")
+	config.API.BatchSize = 4
+	config.API.BatchWindowMs = 500
+	provider := newStubProvider(stubResponse{text: "1. A cat asleep on a sofa.\n2. A dog running through the snow."})
+	useProvider(t, provider)
+
+	results := enqueueImages(t, "en", "en")
+	for i, want := range []string{"A cat asleep on a sofa.", "A dog running through the snow."} {
+		if result := waitResult(t, results[i]); result.Error != nil || result.AltText != want {
+			t.Errorf("request %d got %q, %v, want %q", i, result.AltText, result.Error, want)
+		}
+	}
+	if provider.calls() != 1 || !strings.Contains(provider.prompts[0], fmt.Sprintf(getPromptHint("en", "batchImageInstructions"), 2)) {
+		t.Errorf("provider called %d times with %q, want a single call for both images", provider.calls(), provider.prompts)
+	}
+}
+
+func TestDifferentLanguageRequestsAreNotBatched(t *testing.T) {
+	newTestAPIServer(t, "unused")
+	config.API.BatchSize = 4
+	config.API.BatchWindowMs = 500
+	provider := newStubProvider(stubResponse{text: "Eine Katze schläft auf dem Sofa."})
+	useProvider(t, provider)
+
+	results := enqueueImages(t, "de", "fr")
+	for i := range results {
+		if result := waitResult(t, results[i]); result.Error != nil || result.AltText == "" {
+			t.Errorf("request %d got %q, %v", i, result.AltText, result.Error)
+		}
+	}
+	if provider.calls() != 2 {
+		t.Errorf("provider called %d times, want once per language", provider.calls())
+	}
+}
+
+func TestBatchingIsOffByDefault(t *testing.T) {
+	newTestAPIServer(t, "unused")
+	provider := newStubProvider(stubResponse{text: "A cat asleep on a sofa."})
+	useProvider(t, provider)
+
+	results := enqueueImages(t, "en", "en")
+	for i := range results {
+		if result := waitResult(t, results[i]); result.AltText != "A cat asleep on a sofa." {
+			t.Errorf("request %d got %q, %v", i, result.AltText, result.Error)
+		}
+	}
+	if provider.calls() != 2 {
+		t.Errorf("provider called %d times, want once per request", provider.calls())
+	}
+}
+
+// postBatch uploads the files as the "image" parts of a /api/v1/batch request and decodes the response
+func postBatch(t *testing.T, server *APIServer, key string, files map[string][]byte, names ...string) (int, batchResponse) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, name := range names {
+		part, err := form.CreateFormFile("image", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(files[name])
+	}
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/batch", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+key)
+	rec := httptest.NewRecorder()
+	server.handleBatch(rec, req)
+
+	var response batchResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	return rec.Code, response
+}
+
+type batchResponse struct {
+	Results   []BatchItemResult `json:"results"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Code      string            `json:"code"`
+}
+
+func TestBatchReportsPartialSuccess(t *testing.T) {
+	server, key := newTestAPIServer(t, "A colourful gradient.")
+
+	files := map[string][]byte{"a.png": testPNG(t), "broken.png": []byte("not an image"), "c.png": testPNG(t)}
+	status, response := postBatch(t, server, key, files, "a.png", "broken.png", "c.png")
+	if status != http.StatusOK || response.Succeeded != 2 || response.Failed != 1 || len(response.Results) != 3 {
+		t.Fatalf("got %d %+v", status, response)
+	}
+	for _, i := range []int{0, 2} {
+		if result := response.Results[i]; result.Index != i || result.AltText != "A colourful gradient." || result.Error != "" {
+			t.Errorf("image %d: %+v", i, result)
+		}
+	}
+	if result := response.Results[1]; result.Index != 1 || result.AltText != "" || result.Code != errCodeInvalidImage {
+		t.Errorf("invalid image: %+v, want code %s", result, errCodeInvalidImage)
+	}
+
+	// Each image counts against the key's usage
+	if usage := FindAPIKeyByEmail("test@example.com").UsageMonth; usage != 3 {
+		t.Errorf("usage %d, want 3", usage)
+	}
+}
+
+func TestBatchURLsReportPartialSuccess(t *testing.T) {
+	server, key := newTestAPIServer(t, "A colourful gradient.")
+	config.API.AllowPrivateURLs = true
+	media := mediaServer(t, "image/png", testPNG(t))
+
+	data, _ := json.Marshal(map[string]interface{}{"urls": []string{media.URL + "/a.png", "file:///etc/passwd"}})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/batch", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	rec := httptest.NewRecorder()
+	server.handleBatch(rec, req)
+
+	var response batchResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if rec.Code != http.StatusOK || response.Succeeded != 1 || len(response.Results) != 2 ||
+		response.Results[0].AltText != "A colourful gradient." || response.Results[1].Code != errCodeInvalidURL {
+		t.Errorf("got %d %+v", rec.Code, response)
+	}
+}
+
+func TestBatchRejectsTooManyImages(t *testing.T) {
+	server, key := newTestAPIServer(t, "unused")
+	config.API.BatchMaxItems = 2
+
+	files := map[string][]byte{"a.png": testPNG(t), "b.png": testPNG(t), "c.png": testPNG(t)}
+	status, response := postBatch(t, server, key, files, "a.png", "b.png", "c.png")
+	if status != http.StatusBadRequest || response.Code != errCodeInvalidRequest {
+		t.Errorf("got %d %+v", status, response)
+	}
+	if usage := FindAPIKeyByEmail("test@example.com").UsageMonth; usage != 0 {
+		t.Errorf("a rejected batch used %d of the quota", usage)
+	}
+}
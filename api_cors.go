@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// corsOrigin returns the Access-Control-Allow-Origin value for a request's origin, "" if it isn't allowed.
+// With "*" any origin is allowed but credentials aren't, listed origins are echoed back.
+func corsOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	if slices.Contains(config.API.AllowedOrigins, "*") {
+		return "*"
+	}
+	for _, allowed := range config.API.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// withCORS lets browser clients on the allowed_origins call the API, answering their OPTIONS preflight
+// requests itself. The webhooks are only called by servers and don't get CORS headers.
+func withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(config.API.AllowedOrigins) == 0 || strings.HasPrefix(r.URL.Path, "/api/webhook/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The answer depends on the origin, caches must not reuse it for another one
+		w.Header().Add("Vary", "Origin")
+
+		allowOrigin := corsOrigin(r.Header.Get("Origin"))
+		if allowOrigin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+			w.Header().Set("Access-Control-Expose-Headers", "Retry-After, X-Request-Id")
+			if allowOrigin != "*" {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allowOrigin != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				w.Header().Set("Access-Control-Max-Age", "600")
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
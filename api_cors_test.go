@@ -0,0 +1,111 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// corsRequest sends a request with an Origin through withCORS, and tells whether it reached the API
+func corsRequest(method, path, origin string, preflight bool) (*httptest.ResponseRecorder, bool) {
+	reached := false
+	handler := withCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if preflight {
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec, reached
+}
+
+func TestCORSPreflightForAllowedOrigin(t *testing.T) {
+	useConfig(t)
+	config.API.AllowedOrigins = []string{"https://client.example/", "https://other.example"}
+
+	rec, reached := corsRequest(http.MethodOptions, "/api/v1/alt-text", "https://client.example", true)
+	if reached || rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight got %d, reached the API %v", rec.Code, reached)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://client.example",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, POST, OPTIONS",
+		"Access-Control-Allow-Headers":     "Authorization, Content-Type",
+		"Vary":                             "Origin",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("%s: %q, want %q", header, got, want)
+		}
+	}
+
+	// The actual request goes through with the origin allowed
+	rec, reached = corsRequest(http.MethodPost, "/api/v1/alt-text", "https://client.example", false)
+	if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "https://client.example" ||
+		rec.Header().Get("Access-Control-Expose-Headers") != "Retry-After, X-Request-Id" {
+		t.Errorf("reached the API %v with headers %v", reached, rec.Header())
+	}
+}
+
+func TestCORSDoesNotEchoDisallowedOrigin(t *testing.T) {
+	useConfig(t)
+	config.API.AllowedOrigins = []string{"https://client.example"}
+
+	rec, reached := corsRequest(http.MethodOptions, "/api/v1/alt-text", "https://evil.example", true)
+	if reached || rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight got %d, reached the API %v", rec.Code, reached)
+	}
+	for _, header := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Credentials", "Access-Control-Allow-Methods"} {
+		if got := rec.Header().Get(header); got != "" {
+			t.Errorf("%s: %q for a disallowed origin", header, got)
+		}
+	}
+
+	rec, _ = corsRequest(http.MethodPost, "/api/v1/alt-text", "https://evil.example", false)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin: %q for a disallowed origin", got)
+	}
+}
+
+func TestCORSWildcardOrigin(t *testing.T) {
+	useConfig(t)
+	config.API.AllowedOrigins = []string{"*"}
+
+	rec, _ := corsRequest(http.MethodOptions, "/api/v1/alt-text", "https://anyone.example", true)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin: %q, want *", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("credentials allowed with a wildcard origin: %q", got)
+	}
+}
+
+func TestCORSDisabledAndWebhooks(t *testing.T) {
+	useConfig(t)
+
+	// Without allowed_origins nothing changes, an OPTIONS request is left to the API
+	config.API.AllowedOrigins = nil
+	rec, reached := corsRequest(http.MethodOptions, "/api/v1/alt-text", "https://client.example", true)
+	if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("reached the API %v with headers %v", reached, rec.Header())
+	}
+
+	// Webhooks are called by servers and get no CORS headers
+	config.API.AllowedOrigins = []string{"*"}
+	rec, reached = corsRequest(http.MethodPost, "/api/webhook/kofi", "https://client.example", false)
+	if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Vary") != "" {
+		t.Errorf("webhook reached %v with headers %v", reached, rec.Header())
+	}
+}
@@ -0,0 +1,330 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIJob is an alt-text request processed in the background, its result is posted to the callback URL
+type APIJob struct {
+	ID            string    `json:"id"`
+	RequestID     string    `json:"request_id,omitempty"` // Of the API call that created the job, for the logs
+	Email         string    `json:"email"`
+	Status        string    `json:"status"` // "queued", "completed" or "failed"
+	CallbackURL   string    `json:"callback_url"`
+	MediaType     string    `json:"media_type"`
+	Format        string    `json:"format"`
+	Language      string    `json:"language"`
+	AltText       string    `json:"alt_text,omitempty"`
+	GenerationID  string    `json:"generation_id,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	Code          string    `json:"code,omitempty"`
+	CallbackError string    `json:"callback_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	CompletedAt   time.Time `json:"completed_at,omitempty"`
+}
+
+const (
+	apiJobsFile = "api_jobs.json"
+	apiJobsDir  = "api_jobs" // Media of queued jobs, so they survive a restart
+
+	// jobRetention is how long finished jobs can still be polled
+	jobRetention = 24 * time.Hour
+
+	callbackMaxAttempts = 3
+)
+
+// errJobQueueFull is returned when a job can't be queued without waiting
+var errJobQueueFull = errors.New("request queue is full")
+
+// callbackRetryDelay is the delay before the second callback attempt, doubling after that
+var callbackRetryDelay = 10 * time.Second
+
+var (
+	apiJobs   = make(map[string]*APIJob)
+	apiJobsMu sync.Mutex
+
+	// apiJobWaiters are the awaitAPIJob goroutines still waiting for or delivering a result
+	apiJobWaiters sync.WaitGroup
+)
+
+// resumeAPIJobs loads the jobs file and requeues jobs that hadn't finished before the last restart
+func resumeAPIJobs() {
+	apiJobsMu.Lock()
+	var jobs map[string]*APIJob
+	if err := readJSONIfExists(apiJobsFile, &jobs); err != nil {
+		logErrorf("Error loading API jobs: %v", err)
+	}
+	if jobs != nil {
+		apiJobs = jobs
+	}
+
+	var resumed []APIJob
+	for _, job := range apiJobs {
+		if job.Status == "queued" {
+			resumed = append(resumed, *job)
+		}
+	}
+	apiJobsMu.Unlock()
+
+	if len(resumed) == 0 {
+		return
+	}
+	logInfof("Resuming %d queued API jobs", len(resumed))
+
+	// One job at a time, so only the media of the job waiting for room in the queue is in memory
+	go func() {
+		for _, job := range resumed {
+			data, err := os.ReadFile(jobMediaPath(job.ID))
+			if err != nil {
+				finishAPIJob(job.ID, "", "", fmt.Errorf("media lost on restart: %v", err))
+				continue
+			}
+			request := jobRequest(job, data)
+			requestQueue <- request
+			apiJobWaiters.Add(1)
+			go awaitAPIJob(job, request.ResultCh)
+		}
+	}()
+}
+
+func jobMediaPath(id string) string {
+	return filepath.Join(apiJobsDir, id+".media")
+}
+
+// createAPIJob stores a job and its media and queues it, returning errJobQueueFull if the queue has no room
+func createAPIJob(request APIRequest, callbackURL string) (*APIJob, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, err
+	}
+
+	job := &APIJob{
+		ID:          hex.EncodeToString(idBytes),
+		RequestID:   request.ID,
+		Email:       request.Email,
+		Status:      "queued",
+		CallbackURL: callbackURL,
+		MediaType:   request.MediaType,
+		Format:      request.Format,
+		Language:    request.Language,
+		CreatedAt:   time.Now(),
+	}
+
+	if err := os.MkdirAll(apiJobsDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(jobMediaPath(job.ID), request.MediaData, 0600); err != nil {
+		return nil, err
+	}
+
+	apiJobsMu.Lock()
+	apiJobs[job.ID] = job
+	err := saveAPIJobsUnlocked()
+	apiJobsMu.Unlock()
+	if err != nil {
+		os.Remove(jobMediaPath(job.ID))
+		return nil, err
+	}
+
+	queued := jobRequest(*job, request.MediaData)
+	select {
+	case requestQueue <- queued:
+	default:
+		apiJobsMu.Lock()
+		delete(apiJobs, job.ID)
+		saveAPIJobsUnlocked()
+		apiJobsMu.Unlock()
+		os.Remove(jobMediaPath(job.ID))
+		return nil, errJobQueueFull
+	}
+
+	// The stored job changes once it finishes, the caller gets it as it was queued
+	queuedJob := *job
+	apiJobWaiters.Add(1)
+	go awaitAPIJob(queuedJob, queued.ResultCh)
+	return &queuedJob, nil
+}
+
+// jobRequest is the queued request of a job
+func jobRequest(job APIJob, data []byte) APIRequest {
+	id := job.RequestID
+	if id == "" {
+		id = job.ID
+	}
+	return APIRequest{
+		ID:        id,
+		Email:     job.Email,
+		MediaType: job.MediaType,
+		MediaData: data,
+		Format:    job.Format,
+		Language:  job.Language,
+		ResultCh:  make(chan APIResult, 1),
+	}
+}
+
+// awaitAPIJob waits for a queued job's result, then records and delivers it
+func awaitAPIJob(job APIJob, resultCh chan APIResult) {
+	defer apiJobWaiters.Done()
+	result := <-resultCh
+
+	finished := finishAPIJob(job.ID, result.AltText, result.GenerationID, result.Error)
+	if finished == nil {
+		return
+	}
+
+	if err := sendJobCallback(*finished); err != nil {
+		logErrorf("Error delivering API job %s to its callback: %v", job.ID, err)
+		apiJobsMu.Lock()
+		if stored, ok := apiJobs[job.ID]; ok {
+			stored.CallbackError = err.Error()
+			saveAPIJobsUnlocked()
+		}
+		apiJobsMu.Unlock()
+	}
+}
+
+// finishAPIJob records a job's result and removes its media, returning a copy of the finished job
+func finishAPIJob(id, altText, generationID string, err error) *APIJob {
+	apiJobsMu.Lock()
+	defer apiJobsMu.Unlock()
+
+	job, ok := apiJobs[id]
+	if !ok {
+		return nil
+	}
+
+	job.CompletedAt = time.Now()
+	if err != nil {
+		job.Status = "failed"
+		job.Error = "Failed to generate alt-text: " + err.Error()
+		job.Code = resultErrorCode(err)
+	} else {
+		job.Status = "completed"
+		job.AltText = altText
+		job.GenerationID = generationID
+	}
+
+	os.Remove(jobMediaPath(id))
+	if err := saveAPIJobsUnlocked(); err != nil {
+		logErrorf("Error saving API jobs: %v", err)
+	}
+
+	finished := *job
+	return &finished
+}
+
+// saveAPIJobsUnlocked writes the jobs file, dropping finished jobs past the retention period
+func saveAPIJobsUnlocked() error {
+	for id, job := range apiJobs {
+		if job.Status != "queued" && time.Since(job.CompletedAt) > jobRetention {
+			delete(apiJobs, id)
+		}
+	}
+
+	data, err := json.MarshalIndent(apiJobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(apiJobsFile, data, 0644)
+}
+
+// getAPIJob looks up a job created with the given key's email
+func getAPIJob(id, email string) (APIJob, bool) {
+	apiJobsMu.Lock()
+	defer apiJobsMu.Unlock()
+
+	job, ok := apiJobs[id]
+	if !ok || job.Email != email {
+		return APIJob{}, false
+	}
+	return *job, true
+}
+
+// jobResponse is the job as sent to clients, both when polled and in the callback
+func jobResponse(job APIJob) map[string]interface{} {
+	response := map[string]interface{}{
+		"job_id":     job.ID,
+		"status":     job.Status,
+		"media_type": job.MediaType,
+		"language":   job.Language,
+	}
+	switch job.Status {
+	case "completed":
+		response["alt_text"] = job.AltText
+		response["generation_id"] = job.GenerationID
+	case "failed":
+		response["error"] = job.Error
+		response["code"] = job.Code
+	}
+	return response
+}
+
+// sendJobCallback posts a finished job to its callback URL, retrying with exponential backoff
+func sendJobCallback(job APIJob) error {
+	body, err := json.Marshal(jobResponse(job))
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second, Transport: publicTransport}
+	for attempt := 1; ; attempt++ {
+		var resp *http.Response
+		resp, err = client.Post(job.CallbackURL, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return nil
+			}
+			err = fmt.Errorf("callback returned status %d", resp.StatusCode)
+		}
+
+		if attempt >= callbackMaxAttempts {
+			return err
+		}
+		time.Sleep(callbackRetryDelay << (attempt - 1))
+	}
+}
+
+// handleJob returns the status of a background job
+func (s *APIServer) handleJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.apiError(w, errCodeMethodNotAllowed, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	apiKey := extractAPIKey(r)
+	if apiKey == "" {
+		s.apiError(w, errCodeMissingAPIKey, "Missing API key", http.StatusUnauthorized)
+		return
+	}
+
+	keyData, err := ValidateAPIKey(apiKey)
+	if err != nil {
+		s.apiError(w, errCodeInvalidAPIKey, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	job, ok := getAPIJob(strings.TrimPrefix(r.URL.Path, "/api/v1/jobs/"), keyData.Email)
+	if !ok {
+		s.apiError(w, errCodeNotFound, "Unknown or expired job", http.StatusNotFound)
+		return
+	}
+
+	s.jsonResponse(w, jobResponse(job))
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestJobResultIsPostedToCallback(t *testing.T) {
+	server, key := newTestAPIServer(t, "A colourful gradient.")
+	config.API.AllowPrivateURLs = true
+
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(testPNG(t))
+	}))
+	defer media.Close()
+
+	callbacks := make(chan map[string]interface{}, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		callbacks <- body
+	}))
+	defer receiver.Close()
+
+	data, _ := json.Marshal(map[string]string{"url": media.URL + "/cat.png", "callback_url": receiver.URL})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/alt-text", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	rec := httptest.NewRecorder()
+	withRequestID(http.HandlerFunc(server.handleAltText)).ServeHTTP(rec, req)
+
+	var accepted map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &accepted)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("got %d %v", rec.Code, accepted)
+	}
+
+	select {
+	case body := <-callbacks:
+		if body["job_id"] != accepted["job_id"] || body["status"] != "completed" || body["alt_text"] != "A colourful gradient." {
+			t.Errorf("callback %v", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the callback wasn't called")
+	}
+
+	job, _ := getAPIJob(accepted["job_id"].(string), "test@example.com")
+	if job.RequestID == "" || job.RequestID != rec.Header().Get("X-Request-Id") {
+		t.Errorf("job request ID %q, call had %q", job.RequestID, rec.Header().Get("X-Request-Id"))
+	}
+}
+
+func TestJobIsRejectedWhenQueueIsFull(t *testing.T) {
+	server, key := newIdleTestAPIServer(t, "unused")
+
+	// Nothing reads the queue, swap it for one without room
+	previous := requestQueue
+	requestQueue = make(chan APIRequest)
+	t.Cleanup(func() { requestQueue = previous })
+
+	job, err := createAPIJob(APIRequest{ID: "abc", Email: "test@example.com", MediaType: "image", MediaData: testPNG(t), Format: "png", Language: "en"}, "https://example.com/callback")
+	if !errors.Is(err, errJobQueueFull) || job != nil {
+		t.Fatalf("got %v, %v", job, err)
+	}
+	if entries, _ := os.ReadDir(apiJobsDir); len(entries) != 0 {
+		t.Errorf("media of the rejected job was kept: %v", entries)
+	}
+
+	config.API.AllowPrivateURLs = true
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(testPNG(t))
+	}))
+	defer media.Close()
+
+	status, response := postJSON(t, server.handleAltText, key, map[string]string{"url": media.URL + "/cat.png", "callback_url": "https://example.com/callback"})
+	if status != http.StatusServiceUnavailable || response["code"] != errCodeServerBusy {
+		t.Errorf("got %d %v", status, response)
+	}
+}
+
+func TestJobCallbackRefusesPrivateAddresses(t *testing.T) {
+	useConfig(t)
+	previous := callbackRetryDelay
+	callbackRetryDelay = time.Millisecond
+	t.Cleanup(func() { callbackRetryDelay = previous })
+
+	requested := false
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer internal.Close()
+
+	err := sendJobCallback(APIJob{ID: "abc", Status: "completed", CallbackURL: internal.URL})
+	if !errors.Is(err, errAddressNotAllowed) {
+		t.Errorf("err = %v", err)
+	}
+	if requested {
+		t.Error("the internal callback was called")
+	}
+}
+
+func TestJobCallbackRetries(t *testing.T) {
+	useConfig(t)
+	config.API.AllowPrivateURLs = true
+	previous := callbackRetryDelay
+	callbackRetryDelay = time.Millisecond
+	t.Cleanup(func() { callbackRetryDelay = previous })
+
+	attempts := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if attempts++; attempts < 2 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer receiver.Close()
+
+	if err := sendJobCallback(APIJob{ID: "abc", Status: "completed", CallbackURL: receiver.URL}); err != nil || attempts != 2 {
+		t.Errorf("err = %v after %d attempts", err, attempts)
+	}
+}
@@ -25,6 +25,67 @@ type APIKey struct {
 	LastReset  time.Time `json:"last_reset"`
 	Active     bool      `json:"active"`
 	Note       string    `json:"note,omitempty"`
+	// MediaUsage counts this month's requests for media types other than images,
+	// which keep using UsageMonth so existing key files stay valid
+	MediaUsage map[string]int `json:"media_usage,omitempty"`
+	// Request rate limits overriding [api] requests_per_minute/requests_per_hour,
+	// 0 uses the configured limit and a negative value disables it
+	RateLimitPerMinute int `json:"rate_limit_per_minute,omitempty"`
+	RateLimitPerHour   int `json:"rate_limit_per_hour,omitempty"`
+	// MonthlyLimit overrides the monthly image quota of the key's tier and of [api] monthly_limit
+	MonthlyLimit int `json:"monthly_limit,omitempty"`
+	// Tier names the [api] tiers entry whose limits apply to this key
+	Tier string `json:"tier,omitempty"`
+}
+
+// APITier is a named set of limits that keys can be assigned to, zero values fall back to the [api] defaults
+type APITier struct {
+	MonthlyLimit      int            `toml:"monthly_limit"`
+	MediaLimits       map[string]int `toml:"media_limits"`
+	RequestsPerMinute int            `toml:"requests_per_minute"`
+	RequestsPerHour   int            `toml:"requests_per_hour"`
+}
+
+// tierLimits returns the limits of the key's tier, all zero if it has none
+func (k *APIKey) tierLimits() APITier {
+	return config.API.Tiers[k.Tier]
+}
+
+// monthlyLimitFor returns the key's quota for a media type: its own limit, its tier's, or the server default
+func (k *APIKey) monthlyLimitFor(mediaType string, defaultLimit int) int {
+	tier := k.tierLimits()
+	if mediaType == "image" {
+		if k.MonthlyLimit > 0 {
+			return k.MonthlyLimit
+		}
+		if tier.MonthlyLimit > 0 {
+			return tier.MonthlyLimit
+		}
+	} else if limit := tier.MediaLimits[mediaType]; limit > 0 {
+		return limit
+	}
+	return defaultLimit
+}
+
+// usageFor returns this month's usage for the given media type
+func (k *APIKey) usageFor(mediaType string) int {
+	if mediaType == "image" {
+		return k.UsageMonth
+	}
+	return k.MediaUsage[mediaType]
+}
+
+// incrementUsage bumps the counter for the given media type and returns the new value
+func (k *APIKey) incrementUsage(mediaType string) int {
+	if mediaType == "image" {
+		k.UsageMonth++
+		return k.UsageMonth
+	}
+	if k.MediaUsage == nil {
+		k.MediaUsage = make(map[string]int)
+	}
+	k.MediaUsage[mediaType]++
+	return k.MediaUsage[mediaType]
 }
 
 // APIKeyStore manages all API keys
@@ -46,13 +107,13 @@ func InitAPIKeyStore(filePath string) error {
 
 	if err := apiKeyStore.LoadFromFile(); err != nil {
 		if os.IsNotExist(err) {
-			fmt.Println("No API keys file found. Starting fresh.")
+			logInfof("No API keys file found. Starting fresh.")
 			return apiKeyStore.SaveToFile()
 		}
 		return err
 	}
 
-	fmt.Printf("Loaded %d API keys\n", len(apiKeyStore.Keys))
+	logInfof("Loaded %d API keys", len(apiKeyStore.Keys))
 	return nil
 }
 
@@ -148,8 +209,8 @@ func ValidateAPIKey(key string) (*APIKey, error) {
 	return apiKey, nil
 }
 
-// CheckAndIncrementUsage checks if user is within limits and increments usage
-func CheckAndIncrementUsage(key string, monthlyLimit int) error {
+// CheckAndIncrementUsage checks if user is within the limit for a media type and increments usage
+func CheckAndIncrementUsage(key string, mediaType string, monthlyLimit int) error {
 	apiKeyStore.mu.Lock()
 	defer apiKeyStore.mu.Unlock()
 
@@ -162,17 +223,17 @@ func CheckAndIncrementUsage(key string, monthlyLimit int) error {
 	now := time.Now()
 	if now.Month() != apiKey.LastReset.Month() || now.Year() != apiKey.LastReset.Year() {
 		apiKey.UsageMonth = 0
+		apiKey.MediaUsage = nil
 		apiKey.LastReset = now
 	}
 
-	if apiKey.UsageMonth >= monthlyLimit {
-		return fmt.Errorf("monthly usage limit exceeded (%d/%d)", apiKey.UsageMonth, monthlyLimit)
+	monthlyLimit = apiKey.monthlyLimitFor(mediaType, monthlyLimit)
+	if usage := apiKey.usageFor(mediaType); usage >= monthlyLimit {
+		return fmt.Errorf("monthly %s usage limit exceeded (%d/%d)", mediaType, usage, monthlyLimit)
 	}
 
-	apiKey.UsageMonth++
-
 	// Save periodically (every 10 requests)
-	if apiKey.UsageMonth%10 == 0 {
+	if apiKey.incrementUsage(mediaType)%10 == 0 {
 		go func() {
 			apiKeyStore.mu.Lock()
 			apiKeyStore.saveToFileUnlocked()
@@ -183,14 +244,30 @@ func CheckAndIncrementUsage(key string, monthlyLimit int) error {
 	return nil
 }
 
-// GetAPIKeyUsage returns usage info for an API key
-func GetAPIKeyUsage(key string) (int, int, time.Time, error) {
+// GetAPIKeyUsage returns usage info for an API key, with this month's usage and the key's monthly
+// limits keyed by media type. monthlyLimits are the server defaults the key's own limits replace.
+func GetAPIKeyUsage(key string, monthlyLimits map[string]int) (map[string]int, map[string]int, int, time.Time, error) {
 	apiKeyStore.mu.RLock()
 	defer apiKeyStore.mu.RUnlock()
 
 	apiKey, exists := apiKeyStore.Keys[key]
 	if !exists {
-		return 0, 0, time.Time{}, fmt.Errorf("invalid API key")
+		return nil, nil, 0, time.Time{}, fmt.Errorf("invalid API key")
+	}
+
+	usage := map[string]int{"image": apiKey.UsageMonth}
+	for mediaType, count := range apiKey.MediaUsage {
+		usage[mediaType] = count
+	}
+
+	limits := make(map[string]int)
+	for mediaType, defaultLimit := range monthlyLimits {
+		limits[mediaType] = apiKey.monthlyLimitFor(mediaType, defaultLimit)
+	}
+	for mediaType, limit := range apiKey.tierLimits().MediaLimits {
+		if _, ok := limits[mediaType]; !ok && limit > 0 {
+			limits[mediaType] = limit
+		}
 	}
 
 	daysRemaining := int(time.Until(apiKey.ExpiresAt).Hours() / 24)
@@ -198,7 +275,7 @@ func GetAPIKeyUsage(key string) (int, int, time.Time, error) {
 		daysRemaining = 0
 	}
 
-	return apiKey.UsageMonth, daysRemaining, apiKey.ExpiresAt, nil
+	return usage, limits, daysRemaining, apiKey.ExpiresAt, nil
 }
 
 // RevokeAPIKey deactivates an API key
@@ -238,6 +315,46 @@ func ExtendAPIKey(key string, additionalDays int) error {
 	return apiKeyStore.saveToFileUnlocked() // Use unlocked version!
 }
 
+// SetAPIKeyRateLimit overrides a key's request rate limits, nil leaves a limit unchanged
+func SetAPIKeyRateLimit(key string, perMinute, perHour *int) error {
+	apiKeyStore.mu.Lock()
+	defer apiKeyStore.mu.Unlock()
+
+	apiKey, exists := apiKeyStore.Keys[key]
+	if !exists {
+		return fmt.Errorf("API key not found")
+	}
+
+	if perMinute != nil {
+		apiKey.RateLimitPerMinute = *perMinute
+	}
+	if perHour != nil {
+		apiKey.RateLimitPerHour = *perHour
+	}
+
+	return apiKeyStore.saveToFileUnlocked()
+}
+
+// SetAPIKeyLimit sets a key's monthly image limit and tier, nil leaves a value unchanged
+func SetAPIKeyLimit(key string, monthlyLimit *int, tier *string) error {
+	apiKeyStore.mu.Lock()
+	defer apiKeyStore.mu.Unlock()
+
+	apiKey, exists := apiKeyStore.Keys[key]
+	if !exists {
+		return fmt.Errorf("API key not found")
+	}
+
+	if monthlyLimit != nil {
+		apiKey.MonthlyLimit = *monthlyLimit
+	}
+	if tier != nil {
+		apiKey.Tier = *tier
+	}
+
+	return apiKeyStore.saveToFileUnlocked()
+}
+
 // ListAPIKeys returns all API keys (for admin purposes)
 func ListAPIKeys() []*APIKey {
 	apiKeyStore.mu.RLock()
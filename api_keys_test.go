@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import "testing"
+
+// useAPIKeyStore gives the test an empty key store in a temporary directory
+func useAPIKeyStore(t *testing.T) {
+	t.Helper()
+	previous := apiKeyStore
+	t.Cleanup(func() { apiKeyStore = previous })
+
+	t.Chdir(t.TempDir())
+	if err := InitAPIKeyStore("api_keys.json"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateKeyWithMonthlyLimit(t *testing.T) {
+	useConfig(t)
+	useAPIKeyStore(t)
+
+	handleCreateKey([]string{"--email", "limited@example.com", "--monthly-limit", "2"})
+	apiKey := FindAPIKeyByEmail("limited@example.com")
+	if apiKey == nil || apiKey.MonthlyLimit != 2 {
+		t.Fatalf("created key %+v, want a monthly limit of 2", apiKey)
+	}
+
+	// The key's own limit is enforced instead of the server's
+	for i := 0; i < 2; i++ {
+		if err := CheckAndIncrementUsage(apiKey.Key, "image", 100); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+	if err := CheckAndIncrementUsage(apiKey.Key, "image", 100); err == nil {
+		t.Error("third request within a limit of 2 was allowed")
+	}
+
+	_, limits, _, _, err := GetAPIKeyUsage(apiKey.Key, map[string]int{"image": 100, "video": 10})
+	if err != nil || limits["image"] != 2 || limits["video"] != 10 {
+		t.Errorf("reported limits %v (%v)", limits, err)
+	}
+}
+
+func TestKeyTierLimits(t *testing.T) {
+	useConfig(t)
+	useAPIKeyStore(t)
+	config.API.Tiers = map[string]APITier{"pro": {MonthlyLimit: 3, MediaLimits: map[string]int{"video": 1}}}
+
+	apiKey, err := GenerateAPIKey("pro@example.com", 30, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handleSetLimit([]string{apiKey.Key, "--tier", "pro"})
+
+	_, limits, _, _, err := GetAPIKeyUsage(apiKey.Key, map[string]int{"image": 100, "audio": 10})
+	if err != nil || limits["image"] != 3 || limits["video"] != 1 || limits["audio"] != 10 {
+		t.Errorf("reported limits %v (%v)", limits, err)
+	}
+
+	if err := CheckAndIncrementUsage(apiKey.Key, "video", 50); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckAndIncrementUsage(apiKey.Key, "video", 50); err == nil {
+		t.Error("second video within the tier's limit of 1 was allowed")
+	}
+
+	// The key's own limit takes precedence over its tier's
+	limit := 5
+	SetAPIKeyLimit(apiKey.Key, &limit, nil)
+	if _, limits, _, _, _ := GetAPIKeyUsage(apiKey.Key, map[string]int{"image": 100}); limits["image"] != 5 {
+		t.Errorf("image limit %d, want the key's 5", limits["image"])
+	}
+}
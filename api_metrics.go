@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// metricsExportDefaultDays is how many days an export covers when no from date is given
+const metricsExportDefaultDays = 30
+
+// MetricsDayCounts is the number of events of each type logged on one day
+type MetricsDayCounts struct {
+	Date   string         `json:"date"`
+	Counts map[string]int `json:"counts"`
+}
+
+// isAdminRequest reports whether the request carries the configured admin token
+func isAdminRequest(r *http.Request) bool {
+	token := extractAPIKey(r)
+	return config.API.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(config.API.AdminToken)) == 1
+}
+
+// parseExportDay parses a YYYY-MM-DD date of an export range in local time, like the metrics days
+func parseExportDay(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+// countMetricsByDay counts the events of each type per day from one day to another (both included).
+// Every day of the range is listed, days without events have no counts.
+func countMetricsByDay(events []MetricEvent, from, to time.Time) ([]MetricsDayCounts, []string) {
+	byDay := make(map[string]map[string]int)
+	types := make(map[string]bool)
+	for _, event := range events {
+		day := metricsDay(event.Timestamp)
+		if byDay[day] == nil {
+			byDay[day] = make(map[string]int)
+		}
+		byDay[day][event.EventType]++
+		types[event.EventType] = true
+	}
+
+	var days []MetricsDayCounts
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		day := metricsDay(d)
+		counts := byDay[day]
+		if counts == nil {
+			counts = map[string]int{}
+		}
+		days = append(days, MetricsDayCounts{Date: day, Counts: counts})
+	}
+
+	eventTypes := make([]string, 0, len(types))
+	for eventType := range types {
+		eventTypes = append(eventTypes, eventType)
+	}
+	sort.Strings(eventTypes)
+
+	return days, eventTypes
+}
+
+// handleMetricsExport returns the per-day event counts between the from and to dates as JSON or CSV.
+// It's only available with the admin token, and not at all when none is configured.
+func (s *APIServer) handleMetricsExport(w http.ResponseWriter, r *http.Request) {
+	if config.API.AdminToken == "" {
+		s.jsonError(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		s.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !isAdminRequest(r) {
+		s.jsonError(w, "Invalid admin token", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	today, _ := time.ParseInLocation("2006-01-02", metricsDay(time.Now()), time.Local)
+
+	to, err := parseExportDay(query.Get("to"), today)
+	if err != nil {
+		s.jsonError(w, "Invalid to date, use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	from, err := parseExportDay(query.Get("from"), to.AddDate(0, 0, -(metricsExportDefaultDays-1)))
+	if err != nil {
+		s.jsonError(w, "Invalid from date, use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if from.After(to) {
+		s.jsonError(w, "The from date is after the to date", http.StatusBadRequest)
+		return
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		s.jsonError(w, "Invalid format, use json or csv", http.StatusBadRequest)
+		return
+	}
+
+	events, err := readMetricsEvents("metrics.json", from)
+	if err != nil {
+		s.jsonError(w, fmt.Sprintf("Error reading metrics: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Events from after the end of the range are in the later files, drop them
+	end := to.AddDate(0, 0, 1)
+	inRange := events[:0]
+	for _, event := range events {
+		if event.Timestamp.Before(end) {
+			inRange = append(inRange, event)
+		}
+	}
+
+	days, eventTypes := countMetricsByDay(inRange, from, to)
+
+	if format == "json" {
+		s.jsonResponse(w, map[string]interface{}{
+			"from":        metricsDay(from),
+			"to":          metricsDay(to),
+			"event_types": eventTypes,
+			"days":        days,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="metrics-%s-%s.csv"`, metricsDay(from), metricsDay(to)))
+
+	writer := csv.NewWriter(w)
+	writer.Write(append([]string{"date"}, eventTypes...))
+	for _, day := range days {
+		row := []string{day.Date}
+		for _, eventType := range eventTypes {
+			row = append(row, strconv.Itoa(day.Counts[eventType]))
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+}
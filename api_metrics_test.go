@@ -0,0 +1,125 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// seedMetricsFiles rotates three days of events into their files and logs one event today
+func seedMetricsFiles(t *testing.T) {
+	t.Helper()
+	at := func(day int, hour int) time.Time { return time.Date(2025, 6, day, hour, 0, 0, 0, time.Local) }
+
+	writeJSONFile(t, rotatedMetricsPath("metrics.json", "2025-06-10"), []MetricEvent{
+		{Timestamp: at(10, 9), EventType: "request"},
+	})
+	writeJSONFile(t, rotatedMetricsPath("metrics.json", "2025-06-11"), []MetricEvent{
+		{Timestamp: at(11, 0), EventType: "request"},
+		{Timestamp: at(11, 8), EventType: "request"},
+		{Timestamp: at(11, 8), EventType: "successful_generation"},
+	})
+	writeJSONFile(t, rotatedMetricsPath("metrics.json", "2025-06-13"), []MetricEvent{
+		{Timestamp: at(13, 23), EventType: "follow"},
+	})
+	writeJSONFile(t, "metrics.json", []MetricEvent{{Timestamp: time.Now(), EventType: "request"}})
+}
+
+// exportMetrics requests a metrics export with the admin token
+func exportMetrics(t *testing.T, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/metrics/export?"+query, nil)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	rec := httptest.NewRecorder()
+	(&APIServer{}).handleMetricsExport(rec, req)
+	return rec
+}
+
+func TestMetricsExportJSON(t *testing.T) {
+	useConfig(t)
+	t.Chdir(t.TempDir())
+	config.API.AdminToken = "admin-token"
+	seedMetricsFiles(t)
+
+	rec := exportMetrics(t, "from=2025-06-11&to=2025-06-12")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d %s", rec.Code, rec.Body)
+	}
+	var export struct {
+		From       string             `json:"from"`
+		To         string             `json:"to"`
+		EventTypes []string           `json:"event_types"`
+		Days       []MetricsDayCounts `json:"days"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []MetricsDayCounts{
+		{Date: "2025-06-11", Counts: map[string]int{"request": 2, "successful_generation": 1}},
+		// Days without events are still listed
+		{Date: "2025-06-12", Counts: map[string]int{}},
+	}
+	if export.From != "2025-06-11" || export.To != "2025-06-12" || !reflect.DeepEqual(export.Days, want) {
+		t.Errorf("got %+v, want the days %+v", export, want)
+	}
+	if !reflect.DeepEqual(export.EventTypes, []string{"request", "successful_generation"}) {
+		t.Errorf("event types = %v", export.EventTypes)
+	}
+}
+
+func TestMetricsExportCSV(t *testing.T) {
+	useConfig(t)
+	t.Chdir(t.TempDir())
+	config.API.AdminToken = "admin-token"
+	seedMetricsFiles(t)
+
+	rec := exportMetrics(t, "from=2025-06-10&to=2025-06-13&format=csv")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("got %d %q %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"date", "follow", "request", "successful_generation"},
+		{"2025-06-10", "0", "1", "0"},
+		{"2025-06-11", "0", "2", "1"},
+		{"2025-06-12", "0", "0", "0"},
+		{"2025-06-13", "1", "0", "0"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %v, want %v", rows, want)
+	}
+}
+
+func TestMetricsExportRequiresAdminToken(t *testing.T) {
+	useConfig(t)
+	t.Chdir(t.TempDir())
+
+	if rec := exportMetrics(t, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("without an admin token configured: got %d, want 404", rec.Code)
+	}
+
+	config.API.AdminToken = "other-token"
+	if rec := exportMetrics(t, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("with the wrong token: got %d, want 401", rec.Code)
+	}
+
+	config.API.AdminToken = "admin-token"
+	for _, query := range []string{"from=yesterday", "from=2025-06-12&to=2025-06-11", "format=xml"} {
+		if rec := exportMetrics(t, query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// apiRequestTimes holds each key's requests of the last hour for the sliding-window rate limits
+var (
+	apiRequestTimes   = make(map[string][]time.Time)
+	apiRequestTimesMu sync.Mutex
+)
+
+// rateLimitFor returns a key's or tier's own limit if it has one, the configured default otherwise.
+// Negative limits disable the limit.
+func rateLimitFor(override, defaultLimit int) int {
+	if override != 0 {
+		return override
+	}
+	return defaultLimit
+}
+
+// checkAPIRateLimit records a request for the key if it is within the per-minute and per-hour limits,
+// otherwise it returns how long the client has to wait
+func checkAPIRateLimit(apiKey *APIKey) (time.Duration, bool) {
+	tier := apiKey.tierLimits()
+	perMinute := rateLimitFor(apiKey.RateLimitPerMinute, rateLimitFor(tier.RequestsPerMinute, config.API.RequestsPerMinute))
+	perHour := rateLimitFor(apiKey.RateLimitPerHour, rateLimitFor(tier.RequestsPerHour, config.API.RequestsPerHour))
+	if perMinute <= 0 && perHour <= 0 {
+		return 0, true
+	}
+
+	apiRequestTimesMu.Lock()
+	defer apiRequestTimesMu.Unlock()
+
+	now := time.Now()
+
+	// Drop requests that left the longest window
+	times := apiRequestTimes[apiKey.Key]
+	cutoff := now.Add(-time.Hour)
+	for len(times) > 0 && times[0].Before(cutoff) {
+		times = times[1:]
+	}
+
+	var retryAfter time.Duration
+	for _, limit := range []struct {
+		max    int
+		window time.Duration
+	}{{perMinute, time.Minute}, {perHour, time.Hour}} {
+		if limit.max <= 0 {
+			continue
+		}
+
+		// Requests are in order, so the one limit.max back from the newest decides when a slot frees up
+		inWindow := 0
+		for i := len(times) - 1; i >= 0 && now.Sub(times[i]) < limit.window; i-- {
+			inWindow++
+		}
+		if inWindow >= limit.max {
+			wait := times[len(times)-limit.max].Add(limit.window).Sub(now)
+			retryAfter = max(retryAfter, wait)
+		}
+	}
+
+	if retryAfter > 0 {
+		apiRequestTimes[apiKey.Key] = times
+		return retryAfter, false
+	}
+
+	apiRequestTimes[apiKey.Key] = append(times, now)
+	return 0, true
+}
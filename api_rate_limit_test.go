@@ -0,0 +1,80 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// useRateLimits starts the test with no recorded API requests
+func useRateLimits(t *testing.T) {
+	t.Helper()
+	apiRequestTimesMu.Lock()
+	apiRequestTimes = make(map[string][]time.Time)
+	apiRequestTimesMu.Unlock()
+}
+
+func TestAPIRateLimitReturns429WithRetryAfter(t *testing.T) {
+	server, key := newTestAPIServer(t, "unused")
+	useRateLimits(t)
+	config.API.RequestsPerMinute = 3
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/alt-text", bytes.NewReader([]byte(`{`)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		server.handleAltText(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		if rec := send(); rec.Code == http.StatusTooManyRequests {
+			t.Fatalf("request %d was rate limited", i+1)
+		}
+	}
+
+	rec := send()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("fourth request got %d, want 429", rec.Code)
+	}
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 59 || retryAfter > 60 {
+		t.Errorf("Retry-After = %q, want about a minute", rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestAPIRateLimitPerKeyOverride(t *testing.T) {
+	useConfig(t)
+	useRateLimits(t)
+	config.API.RequestsPerMinute = 1
+
+	limited := &APIKey{Key: "limited"}
+	unlimited := &APIKey{Key: "unlimited", RateLimitPerMinute: -1}
+	raised := &APIKey{Key: "raised", RateLimitPerMinute: 5}
+
+	for i := 0; i < 5; i++ {
+		if _, ok := checkAPIRateLimit(unlimited); !ok {
+			t.Fatal("key with the limit disabled was limited")
+		}
+		if _, ok := checkAPIRateLimit(raised); !ok {
+			t.Fatalf("key with a raised limit was limited after %d requests", i)
+		}
+	}
+	if _, ok := checkAPIRateLimit(raised); ok {
+		t.Error("key with a raised limit wasn't limited past it")
+	}
+
+	checkAPIRateLimit(limited)
+	if _, ok := checkAPIRateLimit(limited); ok {
+		t.Error("second request within the default limit of 1 was allowed")
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestRequestIDHeaderMatchesLogs(t *testing.T) {
+	server, key := newTestAPIServer(t, "A colourful gradient.")
+	logs := captureLogs(t, slog.LevelDebug)
+	handler := withRequestID(http.HandlerFunc(server.handleAltText))
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		logs.Reset()
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("image", "gradient.png")
+		part.Write(testPNG(t))
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/alt-text", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got %d %s", rec.Code, rec.Body)
+		}
+		id := rec.Header().Get("X-Request-Id")
+		if !regexp.MustCompile(`^[0-9a-f]{16}$`).MatchString(id) {
+			t.Fatalf("X-Request-Id %q, want 16 hex characters", id)
+		}
+		ids = append(ids, id)
+
+		// Everything logged for the call, through the queue, carries its ID
+		logged := regexp.MustCompile(`request_id=(\S+)`).FindAllStringSubmatch(logs.String(), -1)
+		if len(logged) == 0 {
+			t.Fatalf("nothing logged with a request ID: %q", logs.String())
+		}
+		for _, match := range logged {
+			if match[1] != id {
+				t.Errorf("logged request ID %s, the response had %s", match[1], id)
+			}
+		}
+	}
+	if ids[0] == ids[1] {
+		t.Errorf("both calls got the request ID %s", ids[0])
+	}
+}
+
+func TestRequestIDFromWithoutMiddleware(t *testing.T) {
+	if id := requestIDFrom(httptest.NewRequest(http.MethodGet, "/api/v1/usage", nil)); id != "" {
+		t.Errorf("got %q for a request without an ID", id)
+	}
+}
@@ -6,11 +6,18 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"math"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,15 +25,18 @@ import (
 
 // APIServer handles the REST API
 type APIServer struct {
-	port         int
-	monthlyLimit int
-	server       *http.Server
+	port          int
+	monthlyLimits map[string]int // Per media type, "image" is always present
+	server        *http.Server
+	stop          chan struct{} // Closing it stops processQueue, the bot's own queue runs as long as it does
 }
 
 // APIRequest represents the request queue item
 type APIRequest struct {
 	ID        string
-	ImageData []byte
+	Email     string
+	MediaType string
+	MediaData []byte
 	Format    string
 	Language  string
 	ResultCh  chan APIResult
@@ -34,10 +44,39 @@ type APIRequest struct {
 
 // APIResult represents the result of processing
 type APIResult struct {
-	AltText string
-	Error   error
+	AltText      string
+	GenerationID string
+	Error        error
 }
 
+// Error codes sent in the "code" field of error responses. They are part of the API:
+// clients match on them, so existing codes must not change.
+const (
+	errCodeInvalidRequest       = "invalid_request"
+	errCodeMethodNotAllowed     = "method_not_allowed"
+	errCodeMissingAPIKey        = "missing_api_key"
+	errCodeInvalidAPIKey        = "invalid_api_key"
+	errCodeInvalidURL           = "invalid_url"
+	errCodeDownloadFailed       = "download_failed"
+	errCodeFileTooLarge         = "file_too_large"
+	errCodeUnsupportedMediaType = "unsupported_media_type"
+	errCodeUnsupportedFormat    = "unsupported_format"
+	errCodeInvalidImage         = "invalid_image"
+	errCodeQuotaExceeded        = "quota_exceeded"
+	errCodeRateLimited          = "rate_limited"
+	errCodeServerBusy           = "server_busy"
+	errCodeProviderError        = "provider_error"
+	errCodeTimeout              = "timeout"
+	errCodeNotFound             = "not_found"
+	errCodeNotImplemented       = "not_implemented"
+	errCodeInternal             = "internal_error"
+)
+
+var (
+	errInvalidMediaURL = errors.New("Invalid 'url', only http and https URLs are supported")
+	errInvalidImage    = errors.New("failed to process image")
+)
+
 // Request queue for batch processing
 var (
 	requestQueue = make(chan APIRequest, 100)
@@ -45,30 +84,36 @@ var (
 )
 
 // StartAPIServer starts the REST API server
-func StartAPIServer(port int, monthlyLimit int) {
+func StartAPIServer(port int, monthlyLimits map[string]int) {
 	apiServer := &APIServer{
-		port:         port,
-		monthlyLimit: monthlyLimit,
+		port:          port,
+		monthlyLimits: monthlyLimits,
 	}
 
 	// Start the request processor
 	queueOnce.Do(func() {
 		go apiServer.processQueue()
+		resumeAPIJobs()
 	})
 
 	mux := http.NewServeMux()
 
 	// API endpoints
 	mux.HandleFunc("/api/v1/alt-text", apiServer.handleAltText)
+	mux.HandleFunc("/api/v1/batch", apiServer.handleBatch)
 	mux.HandleFunc("/api/v1/usage", apiServer.handleUsage)
 	mux.HandleFunc("/api/v1/health", apiServer.handleHealth)
+	mux.HandleFunc("/api/v1/corrections", apiServer.handleCorrection)
+	mux.HandleFunc("/api/v1/jobs/", apiServer.handleJob)
+	mux.HandleFunc("/api/v1/metrics/export", apiServer.handleMetricsExport)
 
-	// Webhook endpoint for Ko-fi (for future automation)
+	// Webhook endpoints for Ko-fi and Patreon supporters
 	mux.HandleFunc("/api/webhook/kofi", apiServer.handleKofiWebhook)
+	mux.HandleFunc("/api/webhook/patreon", apiServer.handlePatreonWebhook)
 
 	apiServer.server = &http.Server{
 		Addr:         fmt.Sprintf(":%d", port),
-		Handler:      mux,
+		Handler:      withCORS(withRequestID(mux)),
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 120 * time.Second, // Longer for processing
 		IdleTimeout:  60 * time.Second,
@@ -78,11 +123,27 @@ func StartAPIServer(port int, monthlyLimit int) {
 
 	go func() {
 		if err := apiServer.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("API Server error: %v", err)
+			logErrorf("API Server error: %v", err)
 		}
 	}()
 }
 
+// uploadMemoryThreshold is how much of a multipart upload is kept in memory before spilling to disk
+const uploadMemoryThreshold = 10 << 20
+
+// maxUploadSize returns the configured maximum API upload size in bytes (default 50 MB)
+func maxUploadSize() int64 {
+	if config.API.MaxUploadMB > 0 {
+		return int64(config.API.MaxUploadMB) << 20
+	}
+	return 50 << 20
+}
+
+// uploadTooLarge responds with a 413 stating the upload limit
+func (s *APIServer) uploadTooLarge(w http.ResponseWriter, limit int64) {
+	s.apiError(w, errCodeFileTooLarge, fmt.Sprintf("File too large. Maximum upload size is %d MB", limit>>20), http.StatusRequestEntityTooLarge)
+}
+
 // extractAPIKey extracts the API key from the Authorization header
 func extractAPIKey(r *http.Request) string {
 	auth := r.Header.Get("Authorization")
@@ -98,83 +159,317 @@ func extractAPIKey(r *http.Request) string {
 	return auth
 }
 
+// isJSONRequest reports whether the request body is JSON rather than a multipart upload
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// apiMediaTypes are the media types the API describes, uploads use a form field of the same name
+var apiMediaTypes = []string{"image", "video", "audio"}
+
+// mediaTypeSupported reports whether the configured provider can describe a media type
+func mediaTypeSupported(mediaType string) bool {
+	switch mediaType {
+	case "image":
+		return true
+	case "video":
+		return llmProvider.Capabilities().Video
+	case "audio":
+		return llmProvider.Capabilities().Audio
+	}
+	return false
+}
+
+// maxMediaSize returns the size limit for a media type in bytes: the upload limit, lowered to the
+// video or audio size cap the bot uses for the same media when that is smaller
+func maxMediaSize(mediaType string) int64 {
+	limit := maxUploadSize()
+
+	var capMB uint
+	switch mediaType {
+	case "video":
+		capMB = config.VideoProcessing.MaxSizeMB
+	case "audio":
+		capMB = config.ImageProcessing.MaxSizeMB
+	}
+	if capMB > 0 && int64(capMB)<<20 < limit {
+		limit = int64(capMB) << 20
+	}
+	return limit
+}
+
+// readUpload reads an uploaded file into memory, stopping at maxSize bytes whatever size the upload claimed
+func readUpload(file io.Reader, mediaType string, maxSize int64) ([]byte, error) {
+	// Read one byte past the limit to tell a file of exactly the maximum size from a larger one
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, &mediaTooLargeError{kind: mediaType, maxSizeMB: uint(maxSize >> 20)}
+	}
+	return data, nil
+}
+
+// isHTTPURL reports whether a URL is an absolute http or https URL
+func isHTTPURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// fetchMediaURL downloads the media of a URL request, with the same download timeout as the bot
+// and the same size limit as uploads. The format comes from the response's content type, falling
+// back to sniffing the data and the URL's extension.
+func fetchMediaURL(rawURL string, mediaType string, maxSize int64) ([]byte, string, error) {
+	if !isHTTPURL(rawURL) {
+		return nil, "", errInvalidMediaURL
+	}
+	parsed, _ := url.Parse(rawURL)
+
+	// Only the status code and the kind of failure are passed on, not what the server or the network said
+	resp, err := getMediaWith(publicTransport, rawURL)
+	if err != nil {
+		logDebugf("Error downloading %s from %s: %v", mediaType, rawURL, err)
+		if errors.Is(err, errAddressNotAllowed) {
+			return nil, "", fmt.Errorf("Failed to download %s: the URL's address is not allowed", mediaType)
+		}
+		return nil, "", fmt.Errorf("Failed to download %s: the server could not be reached", mediaType)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("Failed to download %s: server returned status %d", mediaType, resp.StatusCode)
+	}
+
+	data, err := readMediaBody(resp, uint(maxSize>>20), mediaType)
+	if err != nil {
+		var tooLarge *mediaTooLargeError
+		if errors.As(err, &tooLarge) {
+			return nil, "", err
+		}
+		return nil, "", fmt.Errorf("Failed to download %s: %v", mediaType, err)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	format := detectMediaFormat(mediaType, "", contentType, data)
+	if format == "" {
+		format = detectMediaFormat(mediaType, parsed.Path, "", nil)
+	}
+
+	return data, format, nil
+}
+
 // handleAltText processes alt-text generation requests
 func (s *APIServer) handleAltText(w http.ResponseWriter, r *http.Request) {
 	// Only accept POST
 	if r.Method != http.MethodPost {
-		s.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		s.apiError(w, errCodeMethodNotAllowed, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Extract and validate API key
 	apiKey := extractAPIKey(r)
 	if apiKey == "" {
-		s.jsonError(w, "Missing API key. Use Authorization: Bearer <your-key>", http.StatusUnauthorized)
+		s.apiError(w, errCodeMissingAPIKey, "Missing API key. Use Authorization: Bearer <your-key>", http.StatusUnauthorized)
 		return
 	}
 
 	keyData, err := ValidateAPIKey(apiKey)
 	if err != nil {
-		s.jsonError(w, err.Error(), http.StatusUnauthorized)
+		s.apiError(w, errCodeInvalidAPIKey, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	// Check usage limits
-	if err := CheckAndIncrementUsage(apiKey, s.monthlyLimit); err != nil {
-		s.jsonError(w, err.Error(), http.StatusTooManyRequests)
+	// Per-key request rate, separate from the monthly quota
+	if retryAfter, ok := checkAPIRateLimit(keyData); !ok {
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		s.apiError(w, errCodeRateLimited, fmt.Sprintf("Rate limit exceeded, retry in %d seconds", seconds), http.StatusTooManyRequests)
 		return
 	}
 
-	// Parse multipart form (max 50MB)
-	if err := r.ParseMultipartForm(50 << 20); err != nil {
-		s.jsonError(w, "Failed to parse form data: "+err.Error(), http.StatusBadRequest)
+	// Reject oversize uploads before they are read or counted against the key
+	maxUpload := maxUploadSize()
+	if r.ContentLength > maxUpload {
+		s.uploadTooLarge(w, maxUpload)
 		return
 	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+
+	var mediaData []byte
+	var mediaType, format, language, callbackURL string
+
+	if isJSONRequest(r) {
+		// JSON body with the URL of media that is already hosted somewhere
+		var body struct {
+			URL         string `json:"url"`
+			MediaType   string `json:"media_type"`
+			Language    string `json:"language"`
+			CallbackURL string `json:"callback_url"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			s.apiError(w, errCodeInvalidRequest, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
 
-	// Get the uploaded file
-	file, header, err := r.FormFile("image")
-	if err != nil {
-		s.jsonError(w, "Missing 'image' field in form data", http.StatusBadRequest)
-		return
+		mediaType = body.MediaType
+		if mediaType == "" {
+			mediaType = "image"
+		}
+		if !slices.Contains(apiMediaTypes, mediaType) {
+			s.apiError(w, errCodeInvalidRequest, "Invalid 'media_type', must be one of: "+strings.Join(apiMediaTypes, ", "), http.StatusBadRequest)
+			return
+		}
+		if !mediaTypeSupported(mediaType) {
+			s.unsupportedMediaType(w, mediaType)
+			return
+		}
+
+		if body.URL == "" {
+			s.apiError(w, errCodeInvalidRequest, "Missing 'url' field in JSON body", http.StatusBadRequest)
+			return
+		}
+
+		var tooLarge *mediaTooLargeError
+		maxSize := maxMediaSize(mediaType)
+		mediaData, format, err = fetchMediaURL(body.URL, mediaType, maxSize)
+		if errors.As(err, &tooLarge) {
+			s.uploadTooLarge(w, maxSize)
+			return
+		}
+		if errors.Is(err, errInvalidMediaURL) {
+			s.apiError(w, errCodeInvalidURL, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			s.apiError(w, errCodeDownloadFailed, err.Error(), http.StatusBadRequest)
+			return
+		}
+		language = body.Language
+		callbackURL = body.CallbackURL
+	} else {
+		// Parse multipart form, parts beyond the memory threshold are written to temporary files
+		if err := r.ParseMultipartForm(uploadMemoryThreshold); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				s.uploadTooLarge(w, maxUpload)
+				return
+			}
+			s.apiError(w, errCodeInvalidRequest, "Failed to parse form data: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		// Get the uploaded file, the field it was sent in gives the media type
+		var file multipart.File
+		var header *multipart.FileHeader
+		for _, field := range apiMediaTypes {
+			if file, header, err = r.FormFile(field); err == nil {
+				mediaType = field
+				break
+			}
+		}
+		if mediaType == "" {
+			s.apiError(w, errCodeInvalidRequest, "Missing 'image', 'video' or 'audio' field in form data", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		if !mediaTypeSupported(mediaType) {
+			s.unsupportedMediaType(w, mediaType)
+			return
+		}
+		if maxSize := maxMediaSize(mediaType); header.Size > maxSize {
+			s.uploadTooLarge(w, maxSize)
+			return
+		}
+
+		// Read file data
+		var tooLarge *mediaTooLargeError
+		mediaData, err = readUpload(file, mediaType, maxMediaSize(mediaType))
+		if errors.As(err, &tooLarge) {
+			s.uploadTooLarge(w, maxMediaSize(mediaType))
+			return
+		}
+		if err != nil {
+			s.apiError(w, errCodeInvalidRequest, "Failed to read "+mediaType+" data", http.StatusBadRequest)
+			return
+		}
+
+		// Determine format from filename or content-type, falling back to sniffing the data
+		format = detectMediaFormat(mediaType, header.Filename, header.Header.Get("Content-Type"), mediaData)
+
+		language = r.FormValue("language")
+		callbackURL = r.FormValue("callback_url")
 	}
-	defer file.Close()
 
-	// Read file data
-	imageData, err := io.ReadAll(file)
-	if err != nil {
-		s.jsonError(w, "Failed to read image data", http.StatusBadRequest)
+	if callbackURL != "" && !isHTTPURL(callbackURL) {
+		s.apiError(w, errCodeInvalidURL, "Invalid 'callback_url', only http and https URLs are supported", http.StatusBadRequest)
 		return
 	}
 
-	// Determine format from filename or content-type
-	format := getImageFormat(header.Filename, header.Header.Get("Content-Type"))
-	if format == "" {
-		s.jsonError(w, "Unsupported image format", http.StatusBadRequest)
+	if formats := acceptedMediaFormats(mediaType); !slices.Contains(formats, format) {
+		s.apiError(w, errCodeUnsupportedFormat, fmt.Sprintf("Unsupported %s format. Supported formats: %s", mediaType, strings.Join(formats, ", ")), http.StatusBadRequest)
 		return
 	}
 
-	// Get optional language parameter (default to English)
-	language := r.FormValue("language")
+	// Language is optional (default to English)
 	if language == "" {
 		language = "en"
 	}
 
+	// Check usage limits
+	if err := CheckAndIncrementUsage(apiKey, mediaType, s.monthlyLimits[mediaType]); err != nil {
+		s.apiError(w, errCodeQuotaExceeded, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
 	// Create request and add to queue
 	resultCh := make(chan APIResult, 1)
 	request := APIRequest{
-		ID:        fmt.Sprintf("%s-%d", keyData.Email, time.Now().UnixNano()),
-		ImageData: imageData,
+		ID:        requestIDFrom(r),
+		Email:     keyData.Email,
+		MediaType: mediaType,
+		MediaData: mediaData,
 		Format:    format,
 		Language:  language,
 		ResultCh:  resultCh,
 	}
 
+	// With a callback URL the request is processed in the background and the result posted there
+	if callbackURL != "" {
+		job, err := createAPIJob(request, callbackURL)
+		if errors.Is(err, errJobQueueFull) {
+			requestLog(request.ID).Warnf("Queue full, rejecting %s job", mediaType)
+			s.apiError(w, errCodeServerBusy, "Server busy, please try again later", http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			requestLog(request.ID).Errorf("Error creating API job: %v", err)
+			s.apiError(w, errCodeInternal, "Failed to queue job", http.StatusInternalServerError)
+			return
+		}
+
+		requestLog(request.ID).Infof("Queued %s request as job %s", mediaType, job.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":     job.ID,
+			"status":     job.Status,
+			"status_url": "/api/v1/jobs/" + job.ID,
+		})
+		return
+	}
+
 	// Add to queue with timeout
 	select {
 	case requestQueue <- request:
 		// Request queued
 	case <-time.After(10 * time.Second):
-		s.jsonError(w, "Server busy, please try again later", http.StatusServiceUnavailable)
+		requestLog(request.ID).Warnf("Queue full, rejecting %s request", mediaType)
+		s.apiError(w, errCodeServerBusy, "Server busy, please try again later", http.StatusServiceUnavailable)
 		return
 	}
 
@@ -182,49 +477,134 @@ func (s *APIServer) handleAltText(w http.ResponseWriter, r *http.Request) {
 	select {
 	case result := <-resultCh:
 		if result.Error != nil {
-			s.jsonError(w, "Failed to generate alt-text: "+result.Error.Error(), http.StatusInternalServerError)
+			s.apiError(w, resultErrorCode(result.Error), "Failed to generate alt-text: "+result.Error.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// Success response
 		s.jsonResponse(w, map[string]interface{}{
-			"alt_text":   result.AltText,
-			"media_type": "image",
-			"language":   language,
+			"alt_text":      result.AltText,
+			"media_type":    mediaType,
+			"language":      language,
+			"generation_id": result.GenerationID,
 		})
 
 	case <-time.After(120 * time.Second):
-		s.jsonError(w, "Request timeout", http.StatusGatewayTimeout)
+		requestLog(request.ID).Warnf("Timed out waiting for the %s request's alt-text", mediaType)
+		s.apiError(w, errCodeTimeout, "Request timeout", http.StatusGatewayTimeout)
 	}
 }
 
-// processQueue processes requests from the queue
-func (s *APIServer) processQueue() {
-	for request := range requestQueue {
-		// Downscale image
-		downscaledImg, format, err := downscaleImage(request.ImageData, config.ImageProcessing.DownscaleWidth)
-		if err != nil {
-			request.ResultCh <- APIResult{Error: fmt.Errorf("failed to process image: %v", err)}
-			continue
+// resultErrorCode is the error code of a failed generation
+func resultErrorCode(err error) string {
+	if errors.Is(err, errInvalidImage) {
+		return errCodeInvalidImage
+	}
+	return errCodeProviderError
+}
+
+// unsupportedMediaType responds with a 415 for media the configured provider can't describe
+func (s *APIServer) unsupportedMediaType(w http.ResponseWriter, mediaType string) {
+	s.apiError(w, errCodeUnsupportedMediaType, fmt.Sprintf("This server's model can't describe %s, only %s", mediaType, strings.Join(supportedAPIMediaTypes(), ", ")), http.StatusUnsupportedMediaType)
+}
+
+// supportedAPIMediaTypes lists the media types the configured provider can describe
+func supportedAPIMediaTypes() []string {
+	var supported []string
+	for _, mediaType := range apiMediaTypes {
+		if mediaTypeSupported(mediaType) {
+			supported = append(supported, mediaType)
 		}
+	}
+	return supported
+}
 
-		// Get prompt
-		prompt := getLocalizedString(request.Language, "generateAltText", "prompt")
+// processQueue processes requests from the queue until the server is stopped
+func (s *APIServer) processQueue() {
+	for {
+		var request APIRequest
+		select {
+		case request = <-requestQueue:
+		case <-s.stop:
+			return
+		}
 
-		// Generate alt-text using the LLM provider
-		altText, err := llmProvider.GenerateAltText(prompt, downscaledImg, format, request.Language)
-		if err != nil {
-			request.ResultCh <- APIResult{Error: err}
-			continue
+		for _, batch := range collectBatches(request) {
+			if len(batch) > 1 {
+				s.processBatch(batch)
+			} else {
+				s.processRequest(batch[0])
+			}
 		}
+	}
+}
 
-		// Post-process and send result
-		altText = postProcessAltText(altText)
-		request.ResultCh <- APIResult{AltText: altText}
+// processRequest generates the alt-text of a single request
+func (s *APIServer) processRequest(request APIRequest) {
+	start := time.Now()
+	requestLog(request.ID).Debugf("Generating %s alt-text with %s (%s, %d bytes)", request.MediaType, config.LLM.Provider, request.Format, len(request.MediaData))
 
-		// Log for metrics
-		LogEvent("api_alt_text_generated")
+	altText, err := generateAPIAltText(llmProvider, request)
+	if err == nil {
+		// Refusals and other unusable output get one more try, with more variety
+		altText, err = guardAltText(postProcessAltText(altText, request.Language), "", request.Language, func() (string, error) {
+			return generateAPIAltText(llmProvider.WithTemperatureBoost(regenerateTemperatureBoost), request)
+		})
+	}
+	if err != nil {
+		requestLog(request.ID).Errorf("Error generating %s alt-text: %v", request.MediaType, err)
+		request.ResultCh <- APIResult{Error: err}
+		return
 	}
+	requestLog(request.ID).Infof("Generated %s alt-text in %v", request.MediaType, time.Since(start).Round(time.Millisecond))
+	s.finishRequest(request, altText)
+}
+
+// finishRequest sends a post-processed alt-text as the request's result
+func (s *APIServer) finishRequest(request APIRequest, altText string) {
+	generationID := rememberGeneration(request.MediaData, altText, request.Language, request.Email)
+	request.ResultCh <- APIResult{AltText: altText, GenerationID: generationID}
+
+	archiveCaption("api", request.MediaType, request.MediaData, request.Language, altText)
+
+	// Log for metrics
+	LogEvent("api_alt_text_generated")
+}
+
+// generateAPIAltText runs a queued request through provider for its media type
+func generateAPIAltText(provider LLMProvider, request APIRequest) (string, error) {
+	switch request.MediaType {
+	case "video":
+		prompt := getLocalizedString(request.Language, "generateVideoAltText", "prompt")
+		altText, _, err := generateWith(provider, "video", canVideo, func(_ string, provider LLMProvider) (string, error) {
+			return withLLMRetry(func() (string, error) {
+				return provider.GenerateVideoAltText(prompt, request.MediaData, request.Format, request.Language)
+			})
+		})
+		return altText, err
+
+	case "audio":
+		prompt := getLocalizedString(request.Language, "generateAudioAltText", "prompt")
+		altText, _, err := generateWith(provider, "audio", canAudio, func(_ string, provider LLMProvider) (string, error) {
+			return withLLMRetry(func() (string, error) {
+				return provider.GenerateAudioAltText(prompt, request.MediaData, request.Format, request.Language)
+			})
+		})
+		return altText, err
+	}
+
+	// Downscale image
+	downscaledImg, format, err := downscaleImage(request.MediaData, config.ImageProcessing.DownscaleWidth)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errInvalidImage, err)
+	}
+
+	// Generate alt-text using the LLM provider, with the prompt for the provider it's sent to
+	altText, _, err := generateWith(provider, "image", canImage, func(name string, provider LLMProvider) (string, error) {
+		prompt := imageAltTextPrompt(downscaledImg, format, request.Language, "", "", name)
+		return provider.GenerateAltText(prompt, downscaledImg, format, request.Language)
+	})
+	return altText, err
 }
 
 // handleUsage returns usage information for an API key
@@ -240,48 +620,155 @@ func (s *APIServer) handleUsage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	usageMonth, daysRemaining, expiresAt, err := GetAPIKeyUsage(apiKey)
+	usage, limits, daysRemaining, expiresAt, err := GetAPIKeyUsage(apiKey, s.monthlyLimits)
 	if err != nil {
 		s.jsonError(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
+	media := make(map[string]interface{})
+	for mediaType, limit := range limits {
+		media[mediaType] = map[string]int{
+			"usage_this_month": usage[mediaType],
+			"monthly_limit":    limit,
+			"remaining":        limit - usage[mediaType],
+		}
+	}
+
+	// Top-level usage fields describe images, as before per-type limits existed
 	s.jsonResponse(w, map[string]interface{}{
-		"usage_this_month": usageMonth,
-		"monthly_limit":    s.monthlyLimit,
-		"remaining":        s.monthlyLimit - usageMonth,
+		"usage_this_month": usage["image"],
+		"monthly_limit":    limits["image"],
+		"remaining":        limits["image"] - usage["image"],
+		"media":            media,
 		"days_remaining":   daysRemaining,
 		"expires_at":       expiresAt.Format(time.RFC3339),
 	})
 }
 
-// handleHealth returns API health status
+// handleCorrection records a corrected caption for an earlier generation in the review queue
+func (s *APIServer) handleCorrection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	apiKey := extractAPIKey(r)
+	if apiKey == "" {
+		s.jsonError(w, "Missing API key", http.StatusUnauthorized)
+		return
+	}
+
+	keyData, err := ValidateAPIKey(apiKey)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var body struct {
+		GenerationID string `json:"generation_id"`
+		Correction   string `json:"correction"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&body); err != nil {
+		s.jsonError(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	body.Correction = strings.TrimSpace(body.Correction)
+	if body.GenerationID == "" || body.Correction == "" {
+		s.jsonError(w, "Both 'generation_id' and 'correction' are required", http.StatusBadRequest)
+		return
+	}
+
+	generation, ok := getGeneration(body.GenerationID, keyData.Email)
+	if !ok {
+		s.apiError(w, errCodeNotFound, "Unknown or expired generation_id", http.StatusNotFound)
+		return
+	}
+
+	err = recordCorrection(Correction{
+		Timestamp:   time.Now(),
+		Source:      "api",
+		Submitter:   keyData.Email,
+		MediaHashes: []string{generation.MediaHash},
+		ModelOutput: generation.AltText,
+		Correction:  body.Correction,
+		Language:    generation.Language,
+	})
+	if err != nil {
+		logErrorf("Error recording correction: %v", err)
+		s.jsonError(w, "Failed to record correction", http.StatusInternalServerError)
+		return
+	}
+
+	LogEvent("api_correction_submitted")
+
+	s.jsonResponse(w, map[string]interface{}{
+		"status": "received",
+	})
+}
+
+// handleHealth returns API health status, including whether the LLM provider can be reached
 func (s *APIServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	provider := map[string]interface{}{
+		"name":      config.LLM.Provider,
+		"reachable": true,
+	}
+	status := "healthy"
+	if err := pingLLMProvider(); err != nil {
+		logWarnf("Health check: LLM provider unreachable: %v", err)
+		provider["reachable"] = false
+		status = "unhealthy"
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
 	s.jsonResponse(w, map[string]interface{}{
-		"status":  "healthy",
-		"version": Version,
+		"status":   status,
+		"version":  Version,
+		"provider": provider,
 	})
 }
 
 // handleKofiWebhook handles Ko-fi webhook for automatic key generation
 func (s *APIServer) handleKofiWebhook(w http.ResponseWriter, r *http.Request) {
-	log.Printf("Ko-fi webhook received: %s %s", r.Method, r.URL.Path)
+	logInfof("Ko-fi webhook received: %s %s", r.Method, r.URL.Path)
 
 	if r.Method != http.MethodPost {
-		log.Printf("Ko-fi webhook: wrong method %s", r.Method)
+		logWarnf("Ko-fi webhook: wrong method %s", r.Method)
 		s.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Verify this is from Ko-fi (they send a verification_token)
 	if config.API.KofiVerificationToken == "" {
-		log.Printf("Ko-fi webhook: no verification token configured")
+		logWarnf("Ko-fi webhook: no verification token configured")
 		s.jsonError(w, "Webhook not configured", http.StatusNotImplemented)
 		return
 	}
 
+	// Read the raw body first, the signature covers it exactly as sent
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		logErrorf("Ko-fi webhook: failed to read body: %v", err)
+		s.jsonError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if config.API.KofiWebhookSecret != "" {
+		header := config.API.KofiSignatureHeader
+		if header == "" {
+			header = "X-Kofi-Signature"
+		}
+		if !verifyKofiSignature(body, r.Header.Get(header), config.API.KofiWebhookSecret) {
+			logWarnf("Ko-fi webhook: invalid signature")
+			s.jsonError(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
 	if err := r.ParseForm(); err != nil {
-		log.Printf("Ko-fi webhook: failed to parse form: %v", err)
+		logErrorf("Ko-fi webhook: failed to parse form: %v", err)
 		s.jsonError(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
@@ -289,13 +776,11 @@ func (s *APIServer) handleKofiWebhook(w http.ResponseWriter, r *http.Request) {
 	// Ko-fi sends data as form-encoded with a "data" field containing JSON
 	dataStr := r.FormValue("data")
 	if dataStr == "" {
-		log.Printf("Ko-fi webhook: missing 'data' field")
+		logWarnf("Ko-fi webhook: missing 'data' field")
 		s.jsonError(w, "Missing data", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("Ko-fi webhook data: %s", dataStr)
-
 	var kofiData struct {
 		VerificationToken string `json:"verification_token"`
 		MessageID         string `json:"message_id"`
@@ -316,33 +801,40 @@ func (s *APIServer) handleKofiWebhook(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.Unmarshal([]byte(dataStr), &kofiData); err != nil {
-		log.Printf("Ko-fi webhook: failed to parse JSON: %v", err)
+		logErrorf("Ko-fi webhook: failed to parse JSON: %v", err)
 		s.jsonError(w, "Invalid JSON data", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("Ko-fi webhook parsed: type=%s, from=%s, email=%s, amount=%s %s, tier=%s, shop_items=%d",
+	logInfof("Ko-fi webhook parsed: type=%s, from=%s, email=%s, amount=%s %s, tier=%s, shop_items=%d",
 		kofiData.Type, kofiData.FromName, kofiData.Email, kofiData.Amount, kofiData.Currency,
 		kofiData.TierName, len(kofiData.ShopItems))
 
 	// Verify token
 	if kofiData.VerificationToken != config.API.KofiVerificationToken {
-		log.Printf("Ko-fi webhook: invalid verification token")
+		logWarnf("Ko-fi webhook: invalid verification token")
 		s.jsonError(w, "Invalid verification token", http.StatusUnauthorized)
 		return
 	}
 
+	// A replayed or retried message must not extend a key twice
+	if !claimWebhookMessage("kofi", kofiData.MessageID) {
+		logInfof("Ko-fi webhook: message %s already processed - ignoring", kofiData.MessageID)
+		s.jsonResponse(w, map[string]string{"status": "ok", "action": "duplicate"})
+		return
+	}
+
 	// Check if this is an API key related purchase
 	isAPIKeyPurchase := false
 
 	// Check for Shop Order with the API key product
 	if kofiData.Type == "Shop Order" && len(kofiData.ShopItems) > 0 {
 		for _, item := range kofiData.ShopItems {
-			log.Printf("Ko-fi webhook: checking shop item code '%s' against config '%s'",
+			logDebugf("Ko-fi webhook: checking shop item code '%s' against config '%s'",
 				item.DirectLinkCode, config.API.KofiShopItemCode)
 			if item.DirectLinkCode == config.API.KofiShopItemCode {
 				isAPIKeyPurchase = true
-				log.Printf("Ko-fi webhook: matched shop item!")
+				logDebugf("Ko-fi webhook: matched shop item!")
 				break
 			}
 		}
@@ -350,16 +842,16 @@ func (s *APIServer) handleKofiWebhook(w http.ResponseWriter, r *http.Request) {
 
 	// Check for Subscription with the API key tier
 	if kofiData.Type == "Subscription" && kofiData.TierName != "" {
-		log.Printf("Ko-fi webhook: checking tier name '%s' against config '%s'",
+		logDebugf("Ko-fi webhook: checking tier name '%s' against config '%s'",
 			kofiData.TierName, config.API.KofiTierName)
 		if kofiData.TierName == config.API.KofiTierName {
 			isAPIKeyPurchase = true
-			log.Printf("Ko-fi webhook: matched subscription tier!")
+			logDebugf("Ko-fi webhook: matched subscription tier!")
 		}
 	}
 
 	if !isAPIKeyPurchase {
-		log.Printf("Ko-fi webhook: not an API key purchase - ignoring")
+		logInfof("Ko-fi webhook: not an API key purchase - ignoring")
 		// Still return 200 OK - Ko-fi doesn't need to retry for non-API purchases
 		s.jsonResponse(w, map[string]string{"status": "ok", "action": "ignored"})
 		return
@@ -371,48 +863,12 @@ func (s *APIServer) handleKofiWebhook(w http.ResponseWriter, r *http.Request) {
 		duration = 31 // Slightly longer for subscriptions to handle billing timing
 	}
 
-	// Check if user already has a key
-	existingKey := FindAPIKeyByEmail(kofiData.Email)
-	if existingKey != nil && existingKey.Active {
-		// Extend existing key instead of creating new one
-		if err := ExtendAPIKey(existingKey.Key, duration); err != nil {
-			log.Printf("Ko-fi webhook: error extending API key for %s: %v", kofiData.Email, err)
-			s.jsonError(w, "Failed to extend key", http.StatusInternalServerError)
-			return
-		}
-		log.Printf("Ko-fi webhook: extended API key for %s by %d days", kofiData.Email, duration)
-
-		fmt.Printf("\n%s=== API KEY EXTENDED ===%s\n", Cyan, Reset)
-		fmt.Printf("Email: %s\n", kofiData.Email)
-		fmt.Printf("Key: %s\n", existingKey.Key)
-		fmt.Printf("Extended by: %d days\n", duration)
-		fmt.Printf("%s=========================%s\n\n", Cyan, Reset)
-
-		go func() {
-			SendAPIKeyExtendedEmail(kofiData.Email, existingKey, duration)
-		}()
-	} else {
-		// Create new key
-		note := fmt.Sprintf("Ko-fi %s from %s (%s %s)", kofiData.Type, kofiData.FromName, kofiData.Amount, kofiData.Currency)
-		apiKey, err := GenerateAPIKey(kofiData.Email, duration, note)
-		if err != nil {
-			log.Printf("Ko-fi webhook: error generating API key for %s: %v", kofiData.Email, err)
-			s.jsonError(w, "Failed to generate key", http.StatusInternalServerError)
-			return
-		}
-		log.Printf("Ko-fi webhook: generated new API key for %s", kofiData.Email)
-
-		fmt.Printf("\n%s=== NEW API KEY PURCHASE ===%s\n", Green, Reset)
-		fmt.Printf("Email: %s\n", kofiData.Email)
-		fmt.Printf("From: %s\n", kofiData.FromName)
-		fmt.Printf("Amount: %s %s\n", kofiData.Amount, kofiData.Currency)
-		fmt.Printf("Key: %s\n", apiKey.Key)
-		fmt.Printf("Expires: %s\n", apiKey.ExpiresAt.Format("2006-01-02"))
-		fmt.Printf("%s=============================%s\n\n", Green, Reset)
-
-		go func() {
-			SendAPIKeyEmail(kofiData.Email, apiKey)
-		}()
+	note := fmt.Sprintf("Ko-fi %s from %s (%s %s)", kofiData.Type, kofiData.FromName, kofiData.Amount, kofiData.Currency)
+	if err := grantAPIKey(kofiData.Email, duration, note); err != nil {
+		logErrorf("Ko-fi webhook: %v", err)
+		releaseWebhookMessage("kofi", kofiData.MessageID)
+		s.jsonError(w, "Failed to generate key", http.StatusInternalServerError)
+		return
 	}
 
 	s.jsonResponse(w, map[string]string{"status": "ok", "action": "key_generated"})
@@ -425,15 +881,61 @@ func (s *APIServer) jsonResponse(w http.ResponseWriter, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// jsonError responds with an error whose code is derived from the HTTP status
 func (s *APIServer) jsonError(w http.ResponseWriter, message string, status int) {
+	s.apiError(w, errorCodeForStatus(status), message, status)
+}
+
+// apiError responds with an error carrying a machine-readable code next to the message
+func (s *APIServer) apiError(w http.ResponseWriter, code string, message string, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"error":  message,
+		"code":   code,
 		"status": status,
 	})
 }
 
+// errorCodeForStatus is the generic code for errors without a more specific one
+func errorCodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return errCodeInvalidRequest
+	case http.StatusUnauthorized:
+		return errCodeInvalidAPIKey
+	case http.StatusNotFound:
+		return errCodeNotFound
+	case http.StatusMethodNotAllowed:
+		return errCodeMethodNotAllowed
+	case http.StatusNotImplemented:
+		return errCodeNotImplemented
+	}
+	return errCodeInternal
+}
+
+// supportedImageFormats lists the formats decodeImage can read
+var supportedImageFormats = []string{"jpeg", "png", "gif", "webp", "bmp", "tiff"}
+
+// acceptedImageFormats returns the formats the API accepts, all supported formats unless configured
+func acceptedImageFormats() []string {
+	if len(config.API.AcceptedFormats) == 0 {
+		return supportedImageFormats
+	}
+	return config.API.AcceptedFormats
+}
+
+// acceptedMediaFormats returns the formats the API accepts for a media type
+func acceptedMediaFormats(mediaType string) []string {
+	switch mediaType {
+	case "video":
+		return videoFormats
+	case "audio":
+		return audioFormats
+	}
+	return acceptedImageFormats()
+}
+
 func getImageFormat(filename, contentType string) string {
 	// Try to get format from filename extension
 	if filename != "" {
@@ -453,6 +955,8 @@ func getImageFormat(filename, contentType string) string {
 				return "bmp"
 			case "tiff", "tif":
 				return "tiff"
+			case "heic", "heif":
+				return "heic"
 			}
 		}
 	}
@@ -471,7 +975,76 @@ func getImageFormat(filename, contentType string) string {
 		return "bmp"
 	case "image/tiff":
 		return "tiff"
+	case "image/heic", "image/heif":
+		return "heic"
+	}
+
+	return ""
+}
+
+// detectMediaFormat determines the format of a file from its name or content type, falling back to sniffing the data
+func detectMediaFormat(mediaType, filename, contentType string, data []byte) string {
+	getFormat := getImageFormat
+	if mediaType != "image" {
+		getFormat = func(filename, contentType string) string {
+			return getVideoAudioFormat(mediaType, filename, contentType)
+		}
+	}
+
+	if format := getFormat(filename, contentType); format != "" {
+		return format
+	}
+	if len(data) > 0 {
+		return getFormat("", http.DetectContentType(data))
 	}
+	return ""
+}
 
+func getVideoAudioFormat(mediaType, filename, contentType string) string {
+	// Try to get format from filename extension
+	if filename != "" {
+		parts := strings.Split(strings.ToLower(filename), ".")
+		if ext := parts[len(parts)-1]; len(parts) > 1 && slices.Contains(acceptedMediaFormats(mediaType), ext) {
+			return ext
+		}
+	}
+
+	// Try content type
+	var format string
+	switch contentType {
+	case "video/mp4":
+		format = "mp4"
+	case "video/webm":
+		format = "webm"
+	case "video/quicktime":
+		format = "mov"
+	case "video/x-msvideo", "video/avi":
+		format = "avi"
+	case "video/x-matroska":
+		format = "mkv"
+	case "video/x-m4v":
+		format = "m4v"
+	case "video/3gpp":
+		format = "3gp"
+	case "audio/mpeg":
+		format = "mp3"
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		format = "wav"
+	case "audio/flac":
+		format = "flac"
+	case "audio/ogg", "application/ogg":
+		format = "ogg"
+	case "audio/aac":
+		format = "aac"
+	case "audio/mp4":
+		format = "m4a"
+	case "audio/opus":
+		format = "opus"
+	}
+
+	// Only return formats of the requested media type
+	if slices.Contains(acceptedMediaFormats(mediaType), format) {
+		return format
+	}
 	return ""
 }
@@ -0,0 +1,350 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestAPIServer sets up an API server in a temporary directory with a key for it, a stub provider
+// answering with altText and the queue running. It returns the server and the key.
+func newTestAPIServer(t *testing.T, altText string) (*APIServer, string) {
+	t.Helper()
+	server, key := newIdleTestAPIServer(t, altText)
+	startTestQueue(t, server)
+	return server, key
+}
+
+// newIdleTestAPIServer is newTestAPIServer without anything reading the queue
+func newIdleTestAPIServer(t *testing.T, altText string) (*APIServer, string) {
+	t.Helper()
+	loadTestLocalizations(t)
+	useConfig(t)
+	useAPIKeyStore(t)
+
+	key, err := GenerateAPIKey("test@example.com", 30, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	useProvider(t, newStubProvider(stubResponse{text: altText}))
+	server := &APIServer{monthlyLimits: map[string]int{"image": 100, "video": 100, "audio": 100}, stop: make(chan struct{})}
+	return server, key.Key
+}
+
+// startTestQueue processes the queue with server until the test ends. The cleanup is registered after
+// the config and provider ones, so the queue and the jobs have stopped before they are restored.
+func startTestQueue(t *testing.T, server *APIServer) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		server.processQueue()
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		close(server.stop)
+		<-done
+
+		// Requests the test left queued would be answered by the next test's server
+		for drained := false; !drained; {
+			select {
+			case request := <-requestQueue:
+				request.ResultCh <- APIResult{Error: errors.New("test server stopped")}
+			default:
+				drained = true
+			}
+		}
+
+		// Jobs deliver their results in the background, they read the config too
+		apiJobWaiters.Wait()
+	})
+}
+
+// testPNG is a small image with some detail, so it isn't skipped as decorative
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), uint8((x + y) * 2), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// postJSON sends a JSON body to a handler with an API key and decodes the response
+func postJSON(t *testing.T, handler http.HandlerFunc, key string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/alt-text", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	var response map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	return rec.Code, response
+}
+
+// postUpload sends a file as a multipart upload in the given field and decodes the response
+func postUpload(t *testing.T, handler http.HandlerFunc, key, field, filename string, data []byte) (int, map[string]interface{}) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile(field, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/alt-text", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+key)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	var response map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	return rec.Code, response
+}
+
+func TestAltTextUpload(t *testing.T) {
+	server, key := newTestAPIServer(t, "A colourful gradient.")
+
+	status, response := postUpload(t, server.handleAltText, key, "image", "cat.png", testPNG(t))
+	if status != http.StatusOK || response["alt_text"] != "A colourful gradient." {
+		t.Errorf("got %d %v", status, response)
+	}
+}
+
+func TestUploadOverMediaCapIsRejected(t *testing.T) {
+	server, key := newTestAPIServer(t, "unused")
+	config.VideoProcessing.MaxSizeMB = 1
+
+	status, response := postUpload(t, server.handleAltText, key, "video", "clip.mp4", make([]byte, 2<<20))
+	if status != http.StatusRequestEntityTooLarge || response["code"] != errCodeFileTooLarge {
+		t.Errorf("got %d %v", status, response)
+	}
+}
+
+func TestReadUploadStopsAtLimit(t *testing.T) {
+	var tooLarge *mediaTooLargeError
+	if _, err := readUpload(bytes.NewReader(make([]byte, 1<<20+1)), "video", 1<<20); !errors.As(err, &tooLarge) {
+		t.Errorf("err = %v, want a size error", err)
+	}
+	if data, err := readUpload(bytes.NewReader(make([]byte, 1<<20)), "video", 1<<20); err != nil || len(data) != 1<<20 {
+		t.Errorf("file of exactly the limit: %d bytes, %v", len(data), err)
+	}
+}
+
+func TestAltTextFromURL(t *testing.T) {
+	server, key := newTestAPIServer(t, "A colourful gradient.")
+	config.API.AllowPrivateURLs = true
+
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(testPNG(t))
+	}))
+	defer media.Close()
+
+	status, response := postJSON(t, server.handleAltText, key, map[string]string{"url": media.URL + "/cat.png"})
+	if status != http.StatusOK || response["alt_text"] != "A colourful gradient." || response["media_type"] != "image" {
+		t.Errorf("got %d %v", status, response)
+	}
+}
+
+func TestAltTextRejectsBadScheme(t *testing.T) {
+	server, key := newTestAPIServer(t, "unused")
+
+	status, response := postJSON(t, server.handleAltText, key, map[string]string{"url": "file:///etc/passwd"})
+	if status != http.StatusBadRequest || response["code"] != errCodeInvalidURL {
+		t.Errorf("got %d %v", status, response)
+	}
+}
+
+func TestAltTextRejectsPrivateAddresses(t *testing.T) {
+	server, key := newTestAPIServer(t, "unused")
+
+	requested := false
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer internal.Close()
+
+	status, response := postJSON(t, server.handleAltText, key, map[string]string{"url": internal.URL})
+	if status != http.StatusBadRequest || response["code"] != errCodeDownloadFailed {
+		t.Errorf("got %d %v", status, response)
+	}
+	if message, _ := response["error"].(string); !strings.Contains(message, "not allowed") || strings.Contains(message, "127.0.0.1") {
+		t.Errorf("error %q", message)
+	}
+	if requested {
+		t.Error("the internal server was requested")
+	}
+}
+
+func TestAltTextDoesNotEchoUpstreamStatus(t *testing.T) {
+	server, key := newTestAPIServer(t, "unused")
+	config.API.AllowPrivateURLs = true
+
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "secret internal detail", http.StatusForbidden)
+	}))
+	defer media.Close()
+
+	_, response := postJSON(t, server.handleAltText, key, map[string]string{"url": media.URL})
+	if message, _ := response["error"].(string); message != "Failed to download image: server returned status 403" {
+		t.Errorf("error %q", message)
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	for address, want := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		if got := isPublicIP(net.ParseIP(address)); got != want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", address, got, want)
+		}
+	}
+}
+
+func TestVideoAndAudioUploads(t *testing.T) {
+	server, key := newTestAPIServer(t, "A person waves at the camera.")
+
+	for field, filename := range map[string]string{"video": "clip.mp4", "audio": "voice.mp3"} {
+		status, response := postUpload(t, server.handleAltText, key, field, filename, []byte("media"))
+		if status != http.StatusOK || response["media_type"] != field || response["alt_text"] != "A person waves at the camera." {
+			t.Errorf("%s: got %d %v", field, status, response)
+		}
+	}
+}
+
+func TestUnsupportedMediaTypeIsRejected(t *testing.T) {
+	server, key := newTestAPIServer(t, "unused")
+	imagesOnly := newStubProvider(stubResponse{text: "unused"})
+	imagesOnly.capabilities = ProviderCapabilities{Image: true}
+	useProvider(t, imagesOnly)
+
+	status, response := postUpload(t, server.handleAltText, key, "video", "clip.mp4", []byte("media"))
+	if status != http.StatusUnsupportedMediaType || response["code"] != errCodeUnsupportedMediaType {
+		t.Errorf("video upload: got %d %v", status, response)
+	}
+	if message, _ := response["error"].(string); !strings.Contains(message, "only image") {
+		t.Errorf("error %q doesn't list the supported media", message)
+	}
+
+	status, response = postJSON(t, server.handleAltText, key, map[string]string{"url": "https://example.com/a.mp3", "media_type": "audio"})
+	if status != http.StatusUnsupportedMediaType || response["code"] != errCodeUnsupportedMediaType {
+		t.Errorf("audio URL: got %d %v", status, response)
+	}
+	if imagesOnly.calls() != 0 {
+		t.Error("the provider was called")
+	}
+}
+
+func TestAltTextErrorCodes(t *testing.T) {
+	server, key := newTestAPIServer(t, "unused")
+
+	request := func(method, contentType, auth string, body []byte) *http.Request {
+		req := httptest.NewRequest(method, "/api/v1/alt-text", bytes.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+		return req
+	}
+	upload := func(field, filename string, data []byte, values ...string) *http.Request {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile(field, filename)
+		part.Write(data)
+		for i := 0; i+1 < len(values); i += 2 {
+			form.WriteField(values[i], values[i+1])
+		}
+		form.Close()
+		return request(http.MethodPost, form.FormDataContentType(), key, body.Bytes())
+	}
+
+	tests := []struct {
+		name   string
+		server *APIServer
+		req    *http.Request
+		status int
+		code   string
+	}{
+		{"method", server, request(http.MethodGet, "", key, nil), http.StatusMethodNotAllowed, errCodeMethodNotAllowed},
+		{"missing key", server, request(http.MethodPost, "application/json", "", []byte(`{}`)), http.StatusUnauthorized, errCodeMissingAPIKey},
+		{"unknown key", server, request(http.MethodPost, "application/json", "nope", []byte(`{}`)), http.StatusUnauthorized, errCodeInvalidAPIKey},
+		{"invalid JSON", server, request(http.MethodPost, "application/json", key, []byte(`{`)), http.StatusBadRequest, errCodeInvalidRequest},
+		{"missing url", server, request(http.MethodPost, "application/json", key, []byte(`{}`)), http.StatusBadRequest, errCodeInvalidRequest},
+		{"unknown media type", server, request(http.MethodPost, "application/json", key, []byte(`{"url":"https://example.com/a","media_type":"text"}`)), http.StatusBadRequest, errCodeInvalidRequest},
+		{"bad URL", server, request(http.MethodPost, "application/json", key, []byte(`{"url":"ftp://example.com/a.png"}`)), http.StatusBadRequest, errCodeInvalidURL},
+		{"bad callback URL", server, upload("image", "cat.png", testPNG(t), "callback_url", "javascript:alert(1)"), http.StatusBadRequest, errCodeInvalidURL},
+		{"missing file", server, upload("document", "cat.png", testPNG(t)), http.StatusBadRequest, errCodeInvalidRequest},
+		{"unsupported format", server, upload("image", "notes.txt", []byte("just some text")), http.StatusBadRequest, errCodeUnsupportedFormat},
+		{"quota", &APIServer{monthlyLimits: map[string]int{"image": 0}}, upload("image", "cat.png", testPNG(t)), http.StatusTooManyRequests, errCodeQuotaExceeded},
+		{"invalid image", server, upload("image", "cat.png", []byte("\x89PNG\r\n\x1a\nbroken")), http.StatusInternalServerError, errCodeInvalidImage},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		test.server.handleAltText(rec, test.req)
+
+		var response map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		if rec.Code != test.status || response["code"] != test.code {
+			t.Errorf("%s: got %d %v, want %d %s", test.name, rec.Code, response, test.status, test.code)
+		}
+		if status, _ := response["status"].(float64); int(status) != rec.Code || response["error"] == "" {
+			t.Errorf("%s: missing the error or status field: %v", test.name, response)
+		}
+	}
+}
+
+func TestProviderFailureErrorCode(t *testing.T) {
+	server, key := newTestAPIServer(t, "unused")
+	useProvider(t, newStubProvider(stubResponse{err: errors.New("model crashed")}))
+
+	status, response := postUpload(t, server.handleAltText, key, "video", "clip.mp4", []byte("media"))
+	if status != http.StatusInternalServerError || response["code"] != errCodeProviderError {
+		t.Errorf("got %d %v", status, response)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ArchiveRecord is the payload sent to the archive webhook for every generated caption
+type ArchiveRecord struct {
+	Source    string    `json:"source"` // "bot" or "api"
+	MediaType string    `json:"media_type"`
+	MediaHash string    `json:"media_hash"` // SHA-256 of the original media
+	Language  string    `json:"language"`
+	Provider  string    `json:"provider"`
+	Caption   string    `json:"caption,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+var archiveClient = &http.Client{Timeout: 10 * time.Second}
+
+// archiveCaption sends a copy of a generated caption to the archive webhook, if enabled
+func archiveCaption(source, mediaType string, mediaData []byte, lang, caption string) {
+	if !config.Archive.Enabled || config.Archive.WebhookURL == "" {
+		return
+	}
+
+	hash := sha256.Sum256(mediaData)
+	record := ArchiveRecord{
+		Source:    source,
+		MediaType: mediaType,
+		MediaHash: hex.EncodeToString(hash[:]),
+		Language:  lang,
+		Provider:  config.LLM.Provider,
+		Timestamp: time.Now(),
+	}
+
+	// Privacy-sensitive instances can archive the metadata only
+	if config.Archive.IncludeCaption {
+		record.Caption = caption
+	}
+
+	// Dev mode: print to terminal instead of sending
+	if devMode {
+		fmt.Printf("\n%s[DEV MODE - Would archive caption]%s\n", Yellow, Reset)
+		fmt.Printf("  To: %s\n", config.Archive.WebhookURL)
+		fmt.Printf("  Media: %s (%s)\n", record.MediaType, record.MediaHash)
+		fmt.Println("---")
+		return
+	}
+
+	go func() {
+		if err := sendArchiveRecord(record); err != nil {
+			logErrorf("Error sending caption to archive webhook: %v", err)
+		}
+	}()
+}
+
+func sendArchiveRecord(record ArchiveRecord) error {
+	jsonData, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal archive record: %v", err)
+	}
+
+	resp, err := archiveClient.Post(config.Archive.WebhookURL, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to send archive record: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("archive webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"image"
+	"strings"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// Caption styles besides the standard one, each has its own image prompt
+const (
+	styleSimple     = "simple"     // Plain language: short sentences and common words
+	styleTranscribe = "transcribe" // Faithful transcription of the text in the image
+)
+
+// captionStyle returns the style the captions for an account are written in, "" for the standard one.
+// A style is used when the account's bio has one of its tags, or the mention asking for the captions
+// (if any) has one of its keywords. Transcription is checked before plain language.
+func captionStyle(account *mastodon.Account, mention *mastodon.Status) string {
+	optIns := []struct {
+		style    string
+		tags     []string
+		keywords []string
+	}{
+		{styleTranscribe, config.Transcription.Tags, config.Transcription.Keywords},
+		{styleSimple, config.SimpleLanguage.Tags, config.SimpleLanguage.Keywords},
+	}
+
+	var content string
+	if mention != nil {
+		content = strings.ToLower(stripHTMLTags(mention.Content))
+	}
+
+	for _, optIn := range optIns {
+		for _, tag := range optIn.tags {
+			if strings.Contains(account.Note, tag) {
+				return optIn.style
+			}
+		}
+		for _, keyword := range optIn.keywords {
+			if content != "" && keyword != "" && strings.Contains(content, strings.ToLower(keyword)) {
+				return optIn.style
+			}
+		}
+	}
+
+	return ""
+}
+
+// altTextPromptKey returns the localized image prompt for a caption style
+func altTextPromptKey(style string) string {
+	switch style {
+	case styleSimple:
+		return "generateAltTextSimple"
+	case styleTranscribe:
+		return "generateAltTextTranscribe"
+	}
+	return "generateAltText"
+}
+
+// Thresholds of isTextHeavy: how different in brightness two neighboring pixels have to be to count as
+// an edge, and how much of the image has to be a single background shade
+const (
+	textEdgeContrast     = 96
+	textBackgroundShare  = 0.5
+	textSampleResolution = 512
+)
+
+// isTextHeavy reports whether an image looks like a screenshot of text: a mostly flat background, and rows
+// crossing many glyphs. Photos and drawings have few sharp edges per row, lines of text have one or more
+// per letter. Rows with any edges need transcription.min_edges_per_row of them on average (default 10),
+// counted on at most 512 pixels across.
+func isTextHeavy(img image.Image) bool {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return false
+	}
+
+	minEdgesPerRow := config.Transcription.MinEdgesPerRow
+	if minEdgesPerRow <= 0 {
+		minEdgesPerRow = 10
+	}
+
+	stepX := max(bounds.Dx()/textSampleResolution, 1)
+	stepY := max(bounds.Dy()/textSampleResolution, 1)
+
+	var histogram [16]int
+	var edges, edgeRows, samples int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+		previous, rowEdges := -1, 0
+		for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			luma := int((299*r + 587*g + 114*b) / 1000 >> 8)
+
+			histogram[luma/16]++
+			samples++
+
+			if d := luma - previous; previous >= 0 && (d >= textEdgeContrast || d <= -textEdgeContrast) {
+				rowEdges++
+			}
+			previous = luma
+		}
+		if rowEdges > 0 {
+			edges += rowEdges
+			edgeRows++
+		}
+	}
+	if edgeRows == 0 {
+		return false
+	}
+
+	background := 0
+	for _, count := range histogram {
+		background = max(background, count)
+	}
+
+	return float64(edges)/float64(edgeRows) >= minEdgesPerRow && float64(background)/float64(samples) >= textBackgroundShare
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestSimpleLanguageOptIn(t *testing.T) {
+	useConfig(t)
+	config.SimpleLanguage.Tags = []string{"#AltbotSimple"}
+	config.SimpleLanguage.Keywords = []string{"simple"}
+
+	tests := []struct {
+		name    string
+		note    string
+		mention string
+		want    string
+	}{
+		{"tag in bio", "<p>I like plain words #AltbotSimple</p>", "", styleSimple},
+		{"keyword in mention", "", "<p>@altbot in SIMPLE words please</p>", styleSimple},
+		{"default user", "<p>Photographer</p>", "<p>@altbot please</p>", ""},
+		{"no mention", "<p>Photographer</p>", "", ""},
+	}
+	for _, test := range tests {
+		var mention *mastodon.Status
+		if test.mention != "" {
+			mention = &mastodon.Status{Content: test.mention}
+		}
+		if got := captionStyle(&mastodon.Account{Note: test.note}, mention); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSimpleStyleUsesPlainLanguagePrompt(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	config.ImageProcessing.MaxSizeMB = 10
+	provider := newStubProvider(stubResponse{text: "A cat. It sleeps."}, stubResponse{text: "A grey cat asleep on a sofa."})
+	useProvider(t, provider)
+	media := mediaServer(t, "image/png", testPNG(t))
+
+	if _, _, err := generateImageAltText(media.URL+"/cat.png", "en", "", altTextOptions{Style: styleSimple}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := generateImageAltText(media.URL+"/cat.png", "en", "", altTextOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	simplePrompt := getLocalizedString("en", "generateAltTextSimple", "prompt")
+	standardPrompt := getLocalizedString("en", "generateAltText", "prompt")
+	if simplePrompt == "" || simplePrompt == standardPrompt {
+		t.Fatal("no plain-language prompt in localizations.json")
+	}
+	if !strings.HasPrefix(provider.prompts[0], simplePrompt) {
+		t.Errorf("simple style got %q", provider.prompts[0])
+	}
+	if !strings.HasPrefix(provider.prompts[1], standardPrompt) {
+		t.Errorf("default style got %q", provider.prompts[1])
+	}
+}
+
+// textScreenshot draws a white image with lines of dark glyph-like strokes, as a screenshot of a post would be
+func textScreenshot() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	for line := 20; line+12 < 200; line += 30 {
+		for x := 10; x < 390; x += 6 {
+			draw.Draw(img, image.Rect(x, line, x+2, line+12), image.Black, image.Point{}, draw.Src)
+		}
+	}
+	return img
+}
+
+func TestIsTextHeavy(t *testing.T) {
+	useConfig(t)
+
+	photo, err := png.Decode(bytes.NewReader(testPNG(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !isTextHeavy(textScreenshot()) {
+		t.Error("screenshot of text wasn't detected")
+	}
+	if isTextHeavy(photo) {
+		t.Error("gradient photo was taken for text")
+	}
+	if isTextHeavy(&image.RGBA{Rect: image.Rect(0, 0, 0, 0)}) || isTextHeavy(image.NewRGBA(image.Rect(0, 0, 64, 64))) {
+		t.Error("empty image was taken for text")
+	}
+}
+
+func TestTranscriptionModeSelection(t *testing.T) {
+	useConfig(t)
+	config.Transcription.Tags = []string{"#AltbotTranscribe"}
+	config.Transcription.Keywords = []string{"transcribe"}
+	config.SimpleLanguage.Tags = []string{"#AltbotSimple"}
+
+	if got := captionStyle(&mastodon.Account{Note: "#AltbotSimple #AltbotTranscribe"}, nil); got != styleTranscribe {
+		t.Errorf("both tags: got %q, want transcription first", got)
+	}
+	if got := captionStyle(&mastodon.Account{}, &mastodon.Status{Content: "<p>@altbot please transcribe this</p>"}); got != styleTranscribe {
+		t.Errorf("keyword: got %q", got)
+	}
+	if altTextPromptKey(styleTranscribe) != "generateAltTextTranscribe" || altTextPromptKey("") != "generateAltText" {
+		t.Error("wrong prompt keys")
+	}
+}
+
+func TestTextHeavyImagesAreTranscribed(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	config.ImageProcessing.MaxSizeMB = 10
+	config.Transcription.AutoDetect = true
+	provider := newStubProvider(stubResponse{text: "Toot: hello world"}, stubResponse{text: "A cat. It sleeps."}, stubResponse{text: "A gradient."})
+	useProvider(t, provider)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, textScreenshot()); err != nil {
+		t.Fatal(err)
+	}
+	screenshot := mediaServer(t, "image/png", buf.Bytes())
+	photo := mediaServer(t, "image/png", testPNG(t))
+
+	for _, request := range []struct {
+		url   string
+		style string
+	}{
+		{screenshot.URL + "/screenshot.png", ""},
+		// A style the user asked for isn't replaced
+		{screenshot.URL + "/screenshot.png", styleSimple},
+		{photo.URL + "/photo.png", ""},
+	} {
+		if _, _, err := generateImageAltText(request.url, "en", "", altTextOptions{Style: request.style}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	transcribePrompt := getLocalizedString("en", "generateAltTextTranscribe", "prompt")
+	if !strings.HasPrefix(provider.prompts[0], transcribePrompt) {
+		t.Errorf("screenshot got %q", provider.prompts[0])
+	}
+	if !strings.HasPrefix(provider.prompts[1], getLocalizedString("en", "generateAltTextSimple", "prompt")) {
+		t.Errorf("screenshot in simple style got %q", provider.prompts[1])
+	}
+	if strings.HasPrefix(provider.prompts[2], transcribePrompt) {
+		t.Errorf("photo got the transcription prompt")
+	}
+}
@@ -0,0 +1,253 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+const correctionsFile = "corrections.json"
+
+// Correction is a human-corrected caption for a generation, kept for operators to review
+// and turn into prompt improvements or few-shot examples
+type Correction struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Source      string    `json:"source"`       // "api" or "dm"
+	Submitter   string    `json:"submitter"`    // Hashed user ID or API key email
+	MediaHashes []string  `json:"media_hashes"` // SHA-256 of the original media
+	ModelOutput string    `json:"model_output"`
+	Correction  string    `json:"correction"`
+	Language    string    `json:"language"`
+}
+
+// Generation is an API result remembered for a while so a correction can be submitted for it
+type Generation struct {
+	MediaHash string
+	AltText   string
+	Language  string
+	Email     string
+	CreatedAt time.Time
+}
+
+var (
+	correctionsMu sync.Mutex
+
+	recentGenerations   = make(map[string]Generation)
+	recentGenerationsMu sync.Mutex
+)
+
+// generationRetention is how long a correction can be submitted for an API generation
+const generationRetention = 24 * time.Hour
+
+// correctionCommand matches "correction: <caption>" in a reply to the bot
+var correctionCommand = regexp.MustCompile(`(?is)\bcorrection:\s*(.+)`)
+
+// LoadCorrections reads the review queue
+func LoadCorrections() ([]Correction, error) {
+	var corrections []Correction
+	if err := readJSONIfExists(correctionsFile, &corrections); err != nil {
+		return nil, err
+	}
+	return corrections, nil
+}
+
+// recordCorrection appends a correction to the review queue
+func recordCorrection(correction Correction) error {
+	correctionsMu.Lock()
+	defer correctionsMu.Unlock()
+
+	corrections, err := LoadCorrections()
+	if err != nil {
+		return err
+	}
+	corrections = append(corrections, correction)
+
+	data, err := json.MarshalIndent(corrections, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(correctionsFile, data, 0644)
+}
+
+// deleteCorrectionsBy removes the corrections of a submitter from the review queue, returning how many there were
+func deleteCorrectionsBy(source, submitter string) (int, error) {
+	correctionsMu.Lock()
+	defer correctionsMu.Unlock()
+
+	corrections, err := LoadCorrections()
+	if err != nil {
+		return 0, err
+	}
+
+	kept := corrections[:0]
+	for _, correction := range corrections {
+		if correction.Source != source || correction.Submitter != submitter {
+			kept = append(kept, correction)
+		}
+	}
+	removed := len(corrections) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	data, err := json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	return removed, os.WriteFile(correctionsFile, data, 0644)
+}
+
+// rememberGeneration stores an API result and returns the generation ID the client can correct it with
+func rememberGeneration(mediaData []byte, altText, lang, email string) string {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		logErrorf("Error generating generation ID: %v", err)
+		return ""
+	}
+	id := hex.EncodeToString(idBytes)
+	hash := sha256.Sum256(mediaData)
+
+	recentGenerationsMu.Lock()
+	defer recentGenerationsMu.Unlock()
+
+	for key, generation := range recentGenerations {
+		if time.Since(generation.CreatedAt) > generationRetention {
+			delete(recentGenerations, key)
+		}
+	}
+	recentGenerations[id] = Generation{
+		MediaHash: hex.EncodeToString(hash[:]),
+		AltText:   altText,
+		Language:  lang,
+		Email:     email,
+		CreatedAt: time.Now(),
+	}
+
+	return id
+}
+
+// getGeneration looks up a recent API generation made with the given key's email
+func getGeneration(id, email string) (Generation, bool) {
+	recentGenerationsMu.Lock()
+	defer recentGenerationsMu.Unlock()
+
+	generation, ok := recentGenerations[id]
+	if !ok || generation.Email != email || time.Since(generation.CreatedAt) > generationRetention {
+		return Generation{}, false
+	}
+	return generation, true
+}
+
+// handleCorrectionReply records a "correction: ..." reply to one of the bot's captions.
+// It returns false if the status isn't a correction.
+func handleCorrectionReply(c *mastodon.Client, status *mastodon.Status, botReply *mastodon.Status) bool {
+	if botReply.Account.ID != botAcct.ID {
+		return false
+	}
+
+	match := correctionCommand.FindStringSubmatch(stripHTMLTags(status.Content))
+	if match == nil {
+		return false
+	}
+	correctionText := strings.TrimSpace(match[1])
+	if correctionText == "" {
+		return false
+	}
+
+	userID := string(status.Account.ID)
+	if !HasUserConsent(userID) {
+		_, err := RequestGDPRConsent(c, userID, status.Account.Acct, status.Language, status.ID, false)
+		if err != nil {
+			logErrorf("Error requesting GDPR consent: %v", err)
+		}
+		return true
+	}
+
+	// Hash the media of the post the bot described
+	var mediaHashes []string
+	if botReply.InReplyToID != nil {
+		var originalID mastodon.ID
+		switch id := botReply.InReplyToID.(type) {
+		case string:
+			originalID = mastodon.ID(id)
+		case mastodon.ID:
+			originalID = id
+		}
+
+		// The bot replies to the mention, the described post is the one before it if it has no media itself
+		original, err := c.GetStatus(ctx, originalID)
+		if err == nil && len(original.MediaAttachments) == 0 && original.InReplyToID != nil {
+			if parentID, ok := original.InReplyToID.(string); ok {
+				original, err = c.GetStatus(ctx, mastodon.ID(parentID))
+			}
+		}
+		if err != nil {
+			logErrorf("Error fetching described post for correction: %v", err)
+		} else {
+			for _, attachment := range original.MediaAttachments {
+				data, err := fetchImage(attachment.URL)
+				if err != nil {
+					continue
+				}
+				hash := sha256.Sum256(data)
+				mediaHashes = append(mediaHashes, hex.EncodeToString(hash[:]))
+			}
+		}
+	}
+
+	err := recordCorrection(Correction{
+		Timestamp:   time.Now(),
+		Source:      "dm",
+		Submitter:   hashUserID(userID),
+		MediaHashes: mediaHashes,
+		ModelOutput: stripHTMLTags(botReply.Content),
+		Correction:  correctionText,
+		Language:    status.Language,
+	})
+	if err != nil {
+		logErrorf("Error recording correction: %v", err)
+		return true
+	}
+	logInfof("Recorded caption correction from %s", status.Account.Acct)
+
+	message := fmt.Sprintf("@%s %s", status.Account.Acct, getLocalizedString(status.Language, "correctionReceived", "response"))
+
+	// Dev mode: print to terminal instead of posting
+	if devMode {
+		fmt.Printf("\n%s[DEV MODE - Would post correction confirmation]%s\n", Yellow, Reset)
+		fmt.Printf("  To: @%s\n", status.Account.Acct)
+		fmt.Printf("  Content: %s\n", message)
+		fmt.Println("---")
+		return true
+	}
+
+	if !allowReply(c) {
+		return true
+	}
+
+	_, err = c.PostStatus(ctx, &mastodon.Toot{
+		Status:      message,
+		InReplyToID: status.ID,
+		Visibility:  "direct",
+		Language:    status.Language,
+	})
+	if err != nil {
+		logErrorf("Error posting correction confirmation: %v", err)
+	}
+
+	return true
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// DailyReplyCounter is a global safety valve against the bot flooding its instance, e.g. through a reply loop
+type DailyReplyCounter struct {
+	mu       sync.Mutex
+	day      string
+	count    int
+	exceeded bool
+}
+
+var dailyReplies = &DailyReplyCounter{}
+
+// allowReply counts a reply towards today's max_daily_replies and reports whether it may be posted.
+// The first refused reply of the day is logged loudly and, if enabled, reported to the admin.
+func allowReply(c *mastodon.Client) bool {
+	limit := config.RateLimit.MaxDailyReplies
+	if limit <= 0 {
+		return true
+	}
+
+	dailyReplies.mu.Lock()
+	today := time.Now().Format("2006-01-02")
+	if dailyReplies.day != today {
+		dailyReplies.day = today
+		dailyReplies.count = 0
+		dailyReplies.exceeded = false
+	}
+
+	if dailyReplies.count < limit {
+		dailyReplies.count++
+		dailyReplies.mu.Unlock()
+		return true
+	}
+
+	firstRefusal := !dailyReplies.exceeded
+	dailyReplies.exceeded = true
+	dailyReplies.mu.Unlock()
+
+	if firstRefusal {
+		logWarnf("%s!!! Daily reply limit of %d reached, the bot will not post again until tomorrow !!!%s", Red, limit, Reset)
+		if config.RateLimit.DailyLimitNotifyAdmin {
+			notifyAdminOfDailyLimit(c, limit)
+		}
+	}
+
+	return false
+}
+
+// notifyAdminOfDailyLimit sends the admin a DM, this message doesn't count towards the limit
+func notifyAdminOfDailyLimit(c *mastodon.Client, limit int) {
+	if c == nil || config.RateLimit.AdminContactHandle == "" {
+		return
+	}
+
+	message := fmt.Sprintf("%s The daily reply limit of %d was reached, replies are paused until tomorrow. If this is unexpected, check the logs for a reply loop.",
+		config.RateLimit.AdminContactHandle, limit)
+
+	_, err := c.PostStatus(ctx, &mastodon.Toot{
+		Status:     message,
+		Visibility: "direct",
+	})
+	if err != nil {
+		logErrorf("Error posting daily limit notification: %v", err)
+	}
+}
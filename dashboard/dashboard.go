@@ -7,6 +7,7 @@ package dashboard
 
 import (
 	"embed"
+	"encoding/json"
 	"html/template"
 	"net/http"
 	"os"
@@ -24,7 +25,8 @@ type MetricEvent struct {
 	Details   map[string]interface{} `json:"Details"`
 }
 
-func StartDashboard(metricsPath string, port int) {
+// StartDashboard serves the dashboard for the metrics file. Each of stats is served as JSON at /api/<name>.
+func StartDashboard(metricsPath string, port int, stats map[string]func() interface{}) {
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		tmpl, err := template.ParseFS(content, "templates/dashboard.html")
 		if err != nil {
@@ -46,5 +48,12 @@ func StartDashboard(metricsPath string, port int) {
 		w.Write(data)
 	})
 
+	for name, stat := range stats {
+		http.HandleFunc("/api/"+name, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(stat())
+		})
+	}
+
 	go http.ListenAndServe(":"+strconv.Itoa(port), nil)
 }
@@ -11,6 +11,26 @@ async function fetchMetrics() {
     return data;
 }
 
+// Captions and average response time per language, over all the days the metrics are kept
+async function fetchLanguageStats() {
+    const response = await fetch('/api/languages');
+    return response.json();
+}
+
+// Estimated energy used by a local model, over all the days the metrics are kept
+async function fetchEnergyStats() {
+    const response = await fetch('/api/energy');
+    return response.json();
+}
+
+// Formats watt-hours, switching to kWh for larger amounts
+function formatEnergy(wattHours) {
+    if (wattHours >= 1000) {
+        return `${(wattHours / 1000).toFixed(2)} kWh`;
+    }
+    return `${wattHours.toFixed(wattHours < 1 ? 3 : 1)} Wh`;
+}
+
 // Add this helper function at the top of your charts.js
 function isDarkMode() {
     return window.matchMedia && window.matchMedia('(prefers-color-scheme: dark)').matches;
@@ -37,7 +57,6 @@ function getChartDefaults() {
 function processMetrics(data) {
     const eventCounts = {};
     const mediaTypeCounts = {};
-    const languageCounts = {};  // Add language counts
     const userSet = new Set();
     const imageResponseTimes = [];
     const hourlyActivity = Array(24).fill(0);
@@ -55,13 +74,6 @@ function processMetrics(data) {
             const mediaType = event.Details.mediaType;
             mediaTypeCounts[mediaType] = (mediaTypeCounts[mediaType] || 0) + 1;
 
-            // Count languages
-            if (event.Details.lang) {
-                // Convert language code to full name
-                const lang = getLanguageName(event.Details.lang);
-                languageCounts[lang] = (languageCounts[lang] || 0) + 1;
-            }
-
             if (mediaType === 'image' && event.Details.responseTime) {
                 imageResponseTimes.push({
                     timestamp: new Date(event.Timestamp),
@@ -112,7 +124,6 @@ function processMetrics(data) {
     return {
         eventCounts,
         mediaTypeCounts,
-        languageCounts, 
         uniqueUsers: userSet.size,
         imageResponseTimes,
         hourlyActivity,
@@ -191,24 +202,31 @@ function updateCharts(metrics) {
     if (charts.languagePie) charts.languagePie.destroy();
 
     // Get top languages (limit to top 8 for readability)
-    const sortedLanguages = Object.entries(metrics.languageCounts)
+    const sortedLanguages = Object.entries(metrics.languageStats)
+        .map(([code, stat]) => [getLanguageName(code), stat.count, stat.average_response_time_ms])
         .sort((a, b) => b[1] - a[1]);
 
     // If we have more than 8 languages, group the rest as "Other"
     let languageLabels = [];
     let languageData = [];
+    let languageResponseTimes = [];
 
     if (sortedLanguages.length > 8) {
         languageLabels = sortedLanguages.slice(0, 7).map(item => item[0]);
         languageData = sortedLanguages.slice(0, 7).map(item => item[1]);
+        languageResponseTimes = sortedLanguages.slice(0, 7).map(item => item[2]);
 
-        // Add "Other" category
-        const otherCount = sortedLanguages.slice(7).reduce((acc, curr) => acc + curr[1], 0);
+        // Add "Other" category, averaging its response times weighted by count
+        const others = sortedLanguages.slice(7);
+        const otherCount = others.reduce((acc, curr) => acc + curr[1], 0);
+        const otherTime = others.reduce((acc, curr) => acc + curr[1] * curr[2], 0);
         languageLabels.push('Other');
         languageData.push(otherCount);
+        languageResponseTimes.push(otherCount > 0 ? Math.round(otherTime / otherCount) : 0);
     } else {
         languageLabels = sortedLanguages.map(item => item[0]);
         languageData = sortedLanguages.map(item => item[1]);
+        languageResponseTimes = sortedLanguages.map(item => item[2]);
     }
 
     charts.languagePie = new Chart(ctx2, {
@@ -237,7 +255,8 @@ function updateCharts(metrics) {
                             const value = context.raw || 0;
                             const total = context.dataset.data.reduce((a, b) => a + b, 0);
                             const percentage = Math.round((value / total) * 100);
-                            return `${label}: ${value} (${percentage}%)`;
+                            const responseTime = languageResponseTimes[context.dataIndex];
+                            return `${label}: ${value} (${percentage}%), avg ${responseTime}ms`;
                         }
                     }
                 }
@@ -343,6 +362,9 @@ function updateCharts(metrics) {
     document.getElementById('avgResponseTime').textContent = `${metrics.avgResponseTime}ms`;
     document.getElementById('rateLimits').textContent =
         metrics.eventCounts.rate_limit_hit || 0;
+    document.getElementById('energyTotal').textContent = formatEnergy(metrics.energyStats.total_wh);
+    document.getElementById('energyPerRequest').textContent =
+        `Energy Used (${formatEnergy(metrics.energyStats.per_request_wh)} per request)`;
 }
 
 // Add dark mode listener
@@ -353,6 +375,8 @@ window.matchMedia('(prefers-color-scheme: dark)').addEventListener('change', ()
 async function updateDashboard() {
     const metrics = await fetchMetrics();
     const processed = processMetrics(metrics);
+    processed.languageStats = await fetchLanguageStats();
+    processed.energyStats = await fetchEnergyStats();
     updateCharts(processed);
 }
 
@@ -360,6 +384,8 @@ document.addEventListener('DOMContentLoaded', async () => {
     // Initial load of both charts and timeline
     const metrics = await fetchMetrics();
     const processed = processMetrics(metrics);
+    processed.languageStats = await fetchLanguageStats();
+    processed.energyStats = await fetchEnergyStats();
     updateCharts(processed);
     updateTimeline(metrics);
 
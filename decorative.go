@@ -0,0 +1,66 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+// errDecorativeImage is returned for images that carry no content worth describing
+var errDecorativeImage = errors.New("image is decorative")
+
+// decorativeSampleGrid is how many pixels are sampled along each axis to measure color variation
+const decorativeSampleGrid = 64
+
+// isDecorativeImage reports whether an image is a tracking pixel, spacer or other image with nothing
+// to describe: smaller than decorative_min_size in either dimension, or effectively a single flat color
+func isDecorativeImage(img image.Image) bool {
+	bounds := img.Bounds()
+
+	minSize := config.ImageProcessing.DecorativeMinSize
+	if minSize > 0 && (bounds.Dx() < minSize || bounds.Dy() < minSize) {
+		return true
+	}
+
+	maxDeviation := config.ImageProcessing.DecorativeMaxDeviation
+	return maxDeviation > 0 && colorDeviation(img) <= maxDeviation
+}
+
+// colorDeviation returns the largest standard deviation of the red, green, blue and alpha channels
+// over a grid of sampled pixels, on a 0-255 scale
+func colorDeviation(img image.Image) float64 {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return 0
+	}
+
+	stepX := max(bounds.Dx()/decorativeSampleGrid, 1)
+	stepY := max(bounds.Dy()/decorativeSampleGrid, 1)
+
+	var sum, sumSquares [4]float64
+	var n float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+		for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+			r, g, b, a := img.At(x, y).RGBA()
+			for i, v := range [4]uint32{r, g, b, a} {
+				c := float64(v >> 8)
+				sum[i] += c
+				sumSquares[i] += c * c
+			}
+			n++
+		}
+	}
+
+	var deviation float64
+	for i := range sum {
+		mean := sum[i] / n
+		variance := sumSquares[i]/n - mean*mean
+		deviation = math.Max(deviation, math.Sqrt(math.Max(variance, 0)))
+	}
+	return deviation
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+)
+
+// solidPNG encodes a single-colour image of the given size
+func solidPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{200, 30, 30, 255}}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecorativeImagesAreSkipped(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	config.ImageProcessing.MaxSizeMB = 10
+	config.ImageProcessing.DecorativeMinSize = 16
+	config.ImageProcessing.DecorativeMaxDeviation = 2
+	provider := newStubProvider(stubResponse{text: "A colourful gradient."})
+	useProvider(t, provider)
+
+	for name, data := range map[string][]byte{
+		"solid colour":   solidPNG(t, 200, 200),
+		"tracking pixel": solidPNG(t, 1, 1),
+	} {
+		server := mediaServer(t, "image/png", data)
+		if _, _, err := generateImageAltText(server.URL+"/image.png", "en", "", altTextOptions{}); !errors.Is(err, errDecorativeImage) {
+			t.Errorf("%s: err = %v, want it skipped", name, err)
+		}
+	}
+	if provider.calls() != 0 {
+		t.Fatalf("provider called %d times for decorative images", provider.calls())
+	}
+
+	server := mediaServer(t, "image/png", testPNG(t))
+	if altText, _, err := generateImageAltText(server.URL+"/photo.png", "en", "", altTextOptions{}); err != nil || altText != "A colourful gradient." {
+		t.Errorf("photo: %q, %v", altText, err)
+	}
+}
+
+func TestDecorativeChecksAreOffByDefault(t *testing.T) {
+	useConfig(t)
+	config.ImageProcessing.DecorativeMinSize = 0
+	config.ImageProcessing.DecorativeMaxDeviation = 0
+
+	if isDecorativeImage(image.NewRGBA(image.Rect(0, 0, 1, 1))) {
+		t.Error("a 1x1 image was skipped with the checks disabled")
+	}
+}
@@ -6,91 +6,234 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
+	"os"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/mattn/go-mastodon"
 )
 
-// PostmarkEmail represents the email payload for Postmark API
-type PostmarkEmail struct {
-	From          string `json:"From"`
-	To            string `json:"To"`
-	Subject       string `json:"Subject"`
-	HtmlBody      string `json:"HtmlBody"`
-	TextBody      string `json:"TextBody"`
-	MessageStream string `json:"MessageStream"`
+// EmailMessage is an email waiting to be sent. The JSON names are Postmark's, which
+// failed_emails.json used before other providers were supported.
+type EmailMessage struct {
+	To       string `json:"To"`
+	Subject  string `json:"Subject"`
+	HtmlBody string `json:"HtmlBody"`
+	TextBody string `json:"TextBody"`
 }
 
-// SendAPIKeyEmail sends the API key to the user via Postmark
+// SendAPIKeyEmail sends the API key to the user via the configured email provider
 func SendAPIKeyEmail(toEmail string, apiKey *APIKey) error {
-	if config.API.PostmarkToken == "" {
-		log.Printf("Postmark token not configured, skipping email to %s", toEmail)
+	if emailSender() == nil {
+		logWarnf("Email provider not configured, skipping email to %s", toEmail)
 		return nil
 	}
 
-	email := PostmarkEmail{
-		From:          config.API.PostmarkFromEmail,
-		To:            toEmail,
-		Subject:       "Your Altbot API Key",
-		MessageStream: "outbound",
-		HtmlBody:      generateAPIKeyEmailHTML(apiKey),
-		TextBody:      generateAPIKeyEmailText(apiKey),
+	email := EmailMessage{
+		To:       toEmail,
+		Subject:  "Your Altbot API Key",
+		HtmlBody: generateAPIKeyEmailHTML(apiKey),
+		TextBody: generateAPIKeyEmailText(apiKey),
 	}
 
-	return sendPostmarkEmail(email)
+	return queueEmail(email)
 }
 
 // SendAPIKeyExtendedEmail notifies user their key was extended
 func SendAPIKeyExtendedEmail(toEmail string, apiKey *APIKey, daysAdded int) error {
-	if config.API.PostmarkToken == "" {
-		log.Printf("Postmark token not configured, skipping email to %s", toEmail)
+	if emailSender() == nil {
+		logWarnf("Email provider not configured, skipping email to %s", toEmail)
 		return nil
 	}
 
-	email := PostmarkEmail{
-		From:          config.API.PostmarkFromEmail,
-		To:            toEmail,
-		Subject:       "Your Altbot API Key Has Been Extended",
-		MessageStream: "outbound",
-		HtmlBody:      generateAPIKeyExtendedEmailHTML(apiKey, daysAdded),
-		TextBody:      generateAPIKeyExtendedEmailText(apiKey, daysAdded),
+	email := EmailMessage{
+		To:       toEmail,
+		Subject:  "Your Altbot API Key Has Been Extended",
+		HtmlBody: generateAPIKeyExtendedEmailHTML(apiKey, daysAdded),
+		TextBody: generateAPIKeyExtendedEmailText(apiKey, daysAdded),
+	}
+
+	return queueEmail(email)
+}
+
+// EmailJob is an email waiting to be sent, or one that ran out of retries
+type EmailJob struct {
+	Email     EmailMessage `json:"email"`
+	Attempts  int          `json:"attempts"`
+	LastError string       `json:"last_error,omitempty"`
+	FailedAt  time.Time    `json:"failed_at,omitempty"`
+}
+
+const failedEmailsFile = "failed_emails.json"
+
+var (
+	emailQueue       chan EmailJob
+	emailAdminClient *mastodon.Client
+	failedEmailsMu   sync.Mutex
+)
+
+// StartEmailQueue starts the email workers and requeues sends that failed before the last restart
+func StartEmailQueue(c *mastodon.Client) {
+	emailAdminClient = c
+	emailQueue = make(chan EmailJob, 100)
+
+	workers := config.API.EmailWorkers
+	if workers <= 0 {
+		workers = 2
+	}
+	for i := 0; i < workers; i++ {
+		go emailWorker()
+	}
+
+	failedEmailsMu.Lock()
+	failed, err := loadFailedEmailsUnlocked()
+	if err == nil && len(failed) > 0 {
+		os.Remove(failedEmailsFile)
+	}
+	failedEmailsMu.Unlock()
+
+	if err != nil && !os.IsNotExist(err) {
+		logErrorf("Error loading failed emails: %v", err)
+	}
+	for _, job := range failed {
+		job.Attempts = 0
+		queueEmailJob(job)
+	}
+	if len(failed) > 0 {
+		logInfof("Requeued %d previously failed emails", len(failed))
+	}
+}
+
+// queueEmail hands an email to the workers, sending it directly if the queue isn't running
+func queueEmail(email EmailMessage) error {
+	if emailQueue == nil {
+		return sendEmail(email)
+	}
+	queueEmailJob(EmailJob{Email: email})
+	return nil
+}
+
+func queueEmailJob(job EmailJob) {
+	select {
+	case emailQueue <- job:
+	default:
+		job.LastError = "email queue full"
+		recordFailedEmail(job)
+	}
+}
+
+// emailWorker sends queued emails, retrying with exponential backoff
+func emailWorker() {
+	maxAttempts := config.API.EmailMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+
+	for job := range emailQueue {
+		for {
+			err := sendEmail(job.Email)
+			if err == nil {
+				break
+			}
+
+			job.Attempts++
+			job.LastError = err.Error()
+			if job.Attempts >= maxAttempts {
+				recordFailedEmail(job)
+				break
+			}
+
+			backoff := time.Duration(1<<job.Attempts) * 5 * time.Second
+			logWarnf("Email to %s failed (attempt %d/%d), retrying in %v: %v", job.Email.To, job.Attempts, maxAttempts, backoff, err)
+			time.Sleep(backoff)
+		}
+	}
+}
+
+// recordFailedEmail persists an email that couldn't be sent so it's retried on restart
+func recordFailedEmail(job EmailJob) {
+	job.FailedAt = time.Now()
+	logErrorf("Giving up on email to %s (%s): %s", job.Email.To, job.Email.Subject, job.LastError)
+
+	failedEmailsMu.Lock()
+	failed, err := loadFailedEmailsUnlocked()
+	if err != nil && !os.IsNotExist(err) {
+		logErrorf("Error loading failed emails: %v", err)
 	}
+	failed = append(failed, job)
+	if err := saveFailedEmailsUnlocked(failed); err != nil {
+		logErrorf("Error saving failed emails: %v", err)
+	}
+	failedEmailsMu.Unlock()
 
-	return sendPostmarkEmail(email)
+	if config.API.EmailFailureNotifyAdmin {
+		notifyAdminOfFailedEmail(job)
+	}
 }
 
-func sendPostmarkEmail(email PostmarkEmail) error {
-	jsonData, err := json.Marshal(email)
+// ListFailedEmails returns the emails that ran out of retries (for admin purposes)
+func ListFailedEmails() ([]EmailJob, error) {
+	failedEmailsMu.Lock()
+	defer failedEmailsMu.Unlock()
+
+	failed, err := loadFailedEmailsUnlocked()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return failed, err
+}
+
+func loadFailedEmailsUnlocked() ([]EmailJob, error) {
+	data, err := os.ReadFile(failedEmailsFile)
 	if err != nil {
-		return fmt.Errorf("failed to marshal email: %v", err)
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", "https://api.postmarkapp.com/email", bytes.NewBuffer(jsonData))
+	var failed []EmailJob
+	err = json.Unmarshal(data, &failed)
+	return failed, err
+}
+
+func saveFailedEmailsUnlocked(failed []EmailJob) error {
+	data, err := json.MarshalIndent(failed, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return err
 	}
+	return os.WriteFile(failedEmailsFile, data, 0644)
+}
 
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Postmark-Server-Token", config.API.PostmarkToken)
+func notifyAdminOfFailedEmail(job EmailJob) {
+	if emailAdminClient == nil || config.RateLimit.AdminContactHandle == "" {
+		return
+	}
+
+	message := fmt.Sprintf("%s Failed to send \"%s\" to %s after %d attempts: %s\nIt will be retried on the next restart.",
+		config.RateLimit.AdminContactHandle, job.Email.Subject, job.Email.To, job.Attempts, job.LastError)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	_, err := emailAdminClient.PostStatus(ctx, &mastodon.Toot{
+		Status:     message,
+		Visibility: "direct",
+	})
 	if err != nil {
-		return fmt.Errorf("failed to send email: %v", err)
+		logErrorf("Error posting failed email notification: %v", err)
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("postmark returned status %d: %s", resp.StatusCode, string(body))
+// sendEmail sends an email through the configured provider
+func sendEmail(email EmailMessage) error {
+	sender := emailSender()
+	if sender == nil {
+		return fmt.Errorf("email provider not configured")
 	}
 
-	log.Printf("Email sent successfully to %s", email.To)
+	if err := sender.Send(email.To, email.Subject, email.HtmlBody, email.TextBody); err != nil {
+		return err
+	}
+
+	logInfof("Email sent successfully to %s", email.To)
 	return nil
 }
 
@@ -131,7 +274,7 @@ func generateAPIKeyEmailHTML(apiKey *APIKey) string {
 			 </div>
 			 <div class="info-row">
 				 <span class="label">Monthly Limit</span>
-				 <span class="value">5,000 images</span>
+				 <span class="value">%s images</span>
 			 </div>
 		 </div>
 		 
@@ -150,7 +293,7 @@ func generateAPIKeyEmailHTML(apiKey *APIKey) string {
 	 </div>
  </body>
  </html>
- `, apiKey.Key, apiKey.ExpiresAt.Format("January 2, 2006"), apiKey.Key)
+ `, apiKey.Key, apiKey.ExpiresAt.Format("January 2, 2006"), formatThousands(apiKey.monthlyLimitFor("image", config.API.MonthlyLimit)), apiKey.Key)
 }
 
 func generateAPIKeyEmailText(apiKey *APIKey) string {
@@ -161,7 +304,7 @@ func generateAPIKeyEmailText(apiKey *APIKey) string {
  %s
  
  Valid Until: %s
- Monthly Limit: 5,000 images
+ Monthly Limit: %s images
  
  Quick Start
  -----------
@@ -174,7 +317,7 @@ func generateAPIKeyEmailText(apiKey *APIKey) string {
  Questions? Reply to this email!
  
  Made with love by micr0
- `, apiKey.Key, apiKey.ExpiresAt.Format("January 2, 2006"), apiKey.Key)
+ `, apiKey.Key, apiKey.ExpiresAt.Format("January 2, 2006"), formatThousands(apiKey.monthlyLimitFor("image", config.API.MonthlyLimit)), apiKey.Key)
 }
 
 func generateAPIKeyExtendedEmailHTML(apiKey *APIKey, daysAdded int) string {
@@ -214,7 +357,7 @@ func generateAPIKeyExtendedEmailHTML(apiKey *APIKey, daysAdded int) string {
 			 </div>
 			 <div class="info-row">
 				 <span class="label">Usage This Month</span>
-				 <span class="value">%d / 5,000 images</span>
+				 <span class="value">%d / %s images</span>
 			 </div>
 		 </div>
 		 
@@ -227,7 +370,7 @@ func generateAPIKeyExtendedEmailHTML(apiKey *APIKey, daysAdded int) string {
 	 </div>
  </body>
  </html>
- `, daysAdded, apiKey.Key[:12], apiKey.Key[len(apiKey.Key)-6:], apiKey.ExpiresAt.Format("January 2, 2006"), apiKey.UsageMonth)
+ `, daysAdded, apiKey.Key[:12], apiKey.Key[len(apiKey.Key)-6:], apiKey.ExpiresAt.Format("January 2, 2006"), apiKey.UsageMonth, formatThousands(apiKey.monthlyLimitFor("image", config.API.MonthlyLimit)))
 }
 
 func generateAPIKeyExtendedEmailText(apiKey *APIKey, daysAdded int) string {
@@ -237,12 +380,24 @@ func generateAPIKeyExtendedEmailText(apiKey *APIKey, daysAdded int) string {
  
  Your API Key: %s...%s
  New Expiration: %s
- Usage This Month: %d / 5,000 images
+ Usage This Month: %d / %s images
  
  Your existing API key continues to work - no changes needed on your end!
  
  Questions? Reply to this email!
  
  Made with love by micr0
- `, daysAdded, apiKey.Key[:12], apiKey.Key[len(apiKey.Key)-6:], apiKey.ExpiresAt.Format("January 2, 2006"), apiKey.UsageMonth)
+ `, daysAdded, apiKey.Key[:12], apiKey.Key[len(apiKey.Key)-6:], apiKey.ExpiresAt.Format("January 2, 2006"), apiKey.UsageMonth, formatThousands(apiKey.monthlyLimitFor("image", config.API.MonthlyLimit)))
+}
+
+// formatThousands formats a count with comma thousands separators, like 5,000
+func formatThousands(n int) string {
+	if n < 0 {
+		return "-" + formatThousands(-n)
+	}
+	digits := strconv.Itoa(n)
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return digits
 }
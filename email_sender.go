@@ -0,0 +1,212 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// EmailSender delivers emails through a provider
+type EmailSender interface {
+	Send(to, subject, html, text string) error
+}
+
+// emailSender returns the sender for the configured email_provider, nil if it isn't configured
+func emailSender() EmailSender {
+	from := config.API.EmailFrom
+	if from == "" {
+		from = config.API.PostmarkFromEmail
+	}
+
+	switch config.API.EmailProvider {
+	case "", "postmark":
+		if config.API.PostmarkToken == "" {
+			return nil
+		}
+		return &PostmarkSender{Token: config.API.PostmarkToken, From: from, Timeout: emailTimeout()}
+	case "smtp":
+		if config.API.SMTPHost == "" {
+			return nil
+		}
+		return &SMTPSender{
+			Host:     config.API.SMTPHost,
+			Port:     config.API.SMTPPort,
+			Username: config.API.SMTPUsername,
+			Password: config.API.SMTPPassword,
+			From:     from,
+			Timeout:  emailTimeout(),
+		}
+	}
+	return nil
+}
+
+func emailTimeout() time.Duration {
+	timeout := config.API.EmailTimeoutSeconds
+	if timeout <= 0 {
+		timeout = 10
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// PostmarkSender sends emails with Postmark's API
+type PostmarkSender struct {
+	Token   string
+	From    string
+	Timeout time.Duration
+}
+
+func (p *PostmarkSender) Send(to, subject, html, text string) error {
+	jsonData, err := json.Marshal(map[string]string{
+		"From":          p.From,
+		"To":            to,
+		"Subject":       subject,
+		"HtmlBody":      html,
+		"TextBody":      text,
+		"MessageStream": "outbound",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal email: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", "https://api.postmarkapp.com/email", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Postmark-Server-Token", p.Token)
+
+	client := &http.Client{Timeout: p.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("postmark returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// SMTPSender sends emails through an SMTP server, using STARTTLS when the server offers it
+type SMTPSender struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	Timeout  time.Duration
+}
+
+func (s *SMTPSender) Send(to, subject, html, text string) error {
+	message, err := buildMIMEMessage(s.From, to, subject, html, text)
+	if err != nil {
+		return fmt.Errorf("failed to build email: %v", err)
+	}
+
+	port := s.Port
+	if port == 0 {
+		port = 587
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(s.Host, strconv.Itoa(port)), s.Timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(s.Timeout))
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %v", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return fmt.Errorf("STARTTLS failed: %v", err)
+		}
+	}
+
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %v", err)
+		}
+	}
+
+	if err := client.Mail(s.From); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM failed: %v", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("SMTP RCPT TO failed: %v", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA failed: %v", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+
+	return client.Quit()
+}
+
+// buildMIMEMessage builds a multipart/alternative email with a plain text and an HTML part
+func buildMIMEMessage(from, to, subject, html, text string) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{{"text/plain", text}, {"text/html", html}} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		qp.Close()
+	}
+	parts.Close()
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", to)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
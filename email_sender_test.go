@@ -0,0 +1,151 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpServer is a minimal SMTP server that keeps the messages it receives
+type smtpServer struct {
+	port     int
+	messages chan string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &smtpServer{port: listener.Addr().(*net.TCPAddr).Port, messages: make(chan string, 10)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		switch command := strings.ToUpper(strings.Fields(line + " ")[0]); command {
+		case "EHLO", "HELO":
+			text.PrintfLine("250 localhost")
+		case "MAIL", "RCPT":
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+			data, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			s.messages <- string(data)
+			text.PrintfLine("250 Queued")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Unsupported")
+		}
+	}
+}
+
+func TestSendAPIKeyEmailUsesConfiguredSMTPServer(t *testing.T) {
+	useConfig(t)
+	server := newSMTPServer(t)
+	config.API.EmailProvider = "smtp"
+	config.API.SMTPHost = "127.0.0.1"
+	config.API.SMTPPort = server.port
+	config.API.EmailFrom = "altbot@example.com"
+	config.API.MonthlyLimit = 5000
+
+	apiKey := &APIKey{Key: "alt_0123456789abcdef", ExpiresAt: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)}
+	if err := SendAPIKeyEmail("supporter@example.com", apiKey); err != nil {
+		t.Fatal(err)
+	}
+
+	var raw string
+	select {
+	case raw = <-server.messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email was received")
+	}
+
+	message, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if subject != "Your Altbot API Key" || message.Header.Get("To") != "supporter@example.com" || message.Header.Get("From") != "altbot@example.com" {
+		t.Errorf("headers: subject %q, to %q, from %q", subject, message.Header.Get("To"), message.Header.Get("From"))
+	}
+
+	_, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := multipart.NewReader(message.Body, params["boundary"])
+	bodies := make(map[string]string)
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			break
+		}
+		// The multipart reader undoes the quoted-printable encoding
+		content, _ := io.ReadAll(part)
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		bodies[mediaType] = string(content)
+	}
+	for _, mediaType := range []string{"text/plain", "text/html"} {
+		if !strings.Contains(bodies[mediaType], apiKey.Key) || !strings.Contains(bodies[mediaType], "July 1, 2025") || !strings.Contains(bodies[mediaType], "5,000") {
+			t.Errorf("%s part doesn't carry the key details: %q", mediaType, bodies[mediaType])
+		}
+	}
+}
+
+func TestEmailSenderFollowsConfig(t *testing.T) {
+	useConfig(t)
+
+	config.API.EmailProvider = "smtp"
+	if emailSender() != nil {
+		t.Error("SMTP without a host is configured")
+	}
+	config.API.SMTPHost = "mail.example.com"
+	if _, ok := emailSender().(*SMTPSender); !ok {
+		t.Errorf("smtp provider gave %T", emailSender())
+	}
+
+	config.API.EmailProvider = ""
+	config.API.PostmarkToken = "token"
+	config.API.PostmarkFromEmail = "altbot@example.com"
+	if sender, ok := emailSender().(*PostmarkSender); !ok || sender.From != "altbot@example.com" || sender.Timeout != 10*time.Second {
+		t.Errorf("default provider gave %#v", emailSender())
+	}
+}
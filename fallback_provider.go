@@ -0,0 +1,165 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// namedProvider is a provider of a CompositeProvider with the name it's configured by
+type namedProvider struct {
+	name     string
+	provider LLMProvider
+}
+
+// CompositeProvider implements LLMProvider over the configured provider followed by [llm] fallback_providers.
+// Every generation goes to the first provider that can describe the media and falls through to the next when
+// it fails, so a local server being down degrades to a cloud provider or the other way around.
+type CompositeProvider struct {
+	providers []namedProvider
+}
+
+// newCompositeProvider chains providers in the order they are tried
+func newCompositeProvider(providers []namedProvider) *CompositeProvider {
+	return &CompositeProvider{providers: providers}
+}
+
+// Capability checks for the kinds of generation
+func canImage(c ProviderCapabilities) bool      { return c.Image }
+func canVideo(c ProviderCapabilities) bool      { return c.Video }
+func canAudio(c ProviderCapabilities) bool      { return c.Audio }
+func canMultiImage(c ProviderCapabilities) bool { return c.MultiImage }
+
+// generateFunc runs a generation on provider, name is what the provider is configured by
+type generateFunc func(name string, provider LLMProvider) (string, error)
+
+// namedGenerator is implemented by providers that can tell which configured provider served a generation
+type namedGenerator interface {
+	generateNamed(kind string, can func(ProviderCapabilities) bool, generate generateFunc) (string, string, error)
+}
+
+// generateWith runs a generation on provider and returns the name of the provider that served it,
+// for the attribution of the reply. With fallback providers that's the first one of the chain that succeeds.
+func generateWith(provider LLMProvider, kind string, can func(ProviderCapabilities) bool, generate generateFunc) (string, string, error) {
+	if named, ok := provider.(namedGenerator); ok {
+		return named.generateNamed(kind, can, generate)
+	}
+	result, err := generate(config.LLM.Provider, provider)
+	return result, config.LLM.Provider, err
+}
+
+func (p *CompositeProvider) generateNamed(kind string, can func(ProviderCapabilities) bool, generate generateFunc) (string, string, error) {
+	return p.try(kind, can, generate)
+}
+
+// try runs a generation on each provider that can do it in turn until one succeeds, returning the name
+// of that provider, or the last provider's error when all of them fail
+func (p *CompositeProvider) try(kind string, can func(ProviderCapabilities) bool, generate generateFunc) (string, string, error) {
+	var lastErr error
+	for i, named := range p.providers {
+		if !can(named.provider.Capabilities()) {
+			continue
+		}
+		result, err := generate(named.name, named.provider)
+		if err == nil {
+			if i > 0 {
+				logInfof("Fallback provider %s served the %s request", named.name, kind)
+			} else {
+				logDebugf("Provider %s served the %s request", named.name, kind)
+			}
+			return result, named.name, nil
+		}
+		logWarnf("Provider %s failed the %s request: %v", named.name, kind, err)
+		lastErr = err
+	}
+	if lastErr == nil {
+		return "", "", fmt.Errorf("no configured provider supports %s requests", kind)
+	}
+	return "", "", lastErr
+}
+
+func (p *CompositeProvider) GenerateAltText(prompt string, imageData []byte, format string, targetLanguage string) (string, error) {
+	result, _, err := p.try("image", canImage, func(_ string, provider LLMProvider) (string, error) {
+		return provider.GenerateAltText(prompt, imageData, format, targetLanguage)
+	})
+	return result, err
+}
+
+func (p *CompositeProvider) GenerateVideoAltText(prompt string, videoData []byte, format string, targetLanguage string) (string, error) {
+	result, _, err := p.try("video", canVideo, func(_ string, provider LLMProvider) (string, error) {
+		return provider.GenerateVideoAltText(prompt, videoData, format, targetLanguage)
+	})
+	return result, err
+}
+
+func (p *CompositeProvider) GenerateAudioAltText(prompt string, audioData []byte, format string, targetLanguage string) (string, error) {
+	result, _, err := p.try("audio", canAudio, func(_ string, provider LLMProvider) (string, error) {
+		return provider.GenerateAudioAltText(prompt, audioData, format, targetLanguage)
+	})
+	return result, err
+}
+
+func (p *CompositeProvider) CategorizeImage(imageData []byte, format string) (string, error) {
+	result, _, err := p.try("categorization", canImage, func(_ string, provider LLMProvider) (string, error) {
+		return provider.CategorizeImage(imageData, format)
+	})
+	return result, err
+}
+
+func (p *CompositeProvider) GenerateMultiImageAltText(prompt string, images [][]byte, formats []string, targetLanguage string) (string, error) {
+	result, _, err := p.try("multi-image", canMultiImage, func(_ string, provider LLMProvider) (string, error) {
+		return provider.GenerateMultiImageAltText(prompt, images, formats, targetLanguage)
+	})
+	return result, err
+}
+
+// Ping succeeds when any of the providers is reachable
+func (p *CompositeProvider) Ping(ctx context.Context) error {
+	var errs []error
+	for _, named := range p.providers {
+		err := named.provider.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", named.name, err))
+	}
+	return errors.Join(errs...)
+}
+
+// Capabilities of the chain are everything any of its providers can describe
+func (p *CompositeProvider) Capabilities() ProviderCapabilities {
+	var capabilities ProviderCapabilities
+	for _, named := range p.providers {
+		c := named.provider.Capabilities()
+		capabilities.Image = capabilities.Image || c.Image
+		capabilities.Video = capabilities.Video || c.Video
+		capabilities.Audio = capabilities.Audio || c.Audio
+		capabilities.MultiImage = capabilities.MultiImage || c.MultiImage
+		capabilities.Context = capabilities.Context || c.Context
+	}
+	return capabilities
+}
+
+// WithTemperatureBoost boosts every provider of the chain
+func (p *CompositeProvider) WithTemperatureBoost(boost float32) LLMProvider {
+	boosted := &CompositeProvider{}
+	for _, named := range p.providers {
+		boosted.providers = append(boosted.providers, namedProvider{name: named.name, provider: named.provider.WithTemperatureBoost(boost)})
+	}
+	return boosted
+}
+
+func (p *CompositeProvider) Close() error {
+	var errs []error
+	for _, named := range p.providers {
+		if err := named.provider.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", named.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
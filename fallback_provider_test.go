@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompositeProviderFallsThroughAndReportsServer(t *testing.T) {
+	useConfig(t)
+	config.LLM.Provider = "ollama"
+
+	primary := newStubProvider(stubResponse{err: errors.New("connection refused")})
+	fallback := newStubProvider(stubResponse{text: "A cat on a windowsill"})
+	chain := limitConcurrentGenerations(newCompositeProvider([]namedProvider{
+		{name: "ollama", provider: primary},
+		{name: "gemini", provider: fallback},
+	}), 1)
+
+	text, servedBy, err := generateWith(chain, "image", canImage, func(_ string, provider LLMProvider) (string, error) {
+		return provider.GenerateAltText("prompt", nil, "png", "en")
+	})
+	if err != nil {
+		t.Fatalf("generateWith: %v", err)
+	}
+	if text != "A cat on a windowsill" || servedBy != "gemini" {
+		t.Errorf("got %q served by %q, want the fallback's caption served by gemini", text, servedBy)
+	}
+	if primary.calls() != 1 || fallback.calls() != 1 {
+		t.Errorf("calls = %d, %d, want 1 each", primary.calls(), fallback.calls())
+	}
+}
+
+func TestCompositeProviderSkipsIncapableProviders(t *testing.T) {
+	video := newStubProvider(stubResponse{text: "unused"})
+	video.capabilities = ProviderCapabilities{Image: true}
+	capable := newStubProvider(stubResponse{text: "A person waves"})
+
+	chain := newCompositeProvider([]namedProvider{{name: "transformers", provider: video}, {name: "gemini", provider: capable}})
+	_, servedBy, err := generateWith(chain, "video", canVideo, func(_ string, provider LLMProvider) (string, error) {
+		return provider.GenerateVideoAltText("prompt", nil, "mp4", "en")
+	})
+	if err != nil || servedBy != "gemini" {
+		t.Errorf("served by %q (%v), want gemini", servedBy, err)
+	}
+	if video.calls() != 0 {
+		t.Error("provider without video support was asked for a video")
+	}
+}
+
+func TestCompositeProviderReturnsLastError(t *testing.T) {
+	chain := newCompositeProvider([]namedProvider{
+		{name: "ollama", provider: newStubProvider(stubResponse{err: errors.New("first")})},
+		{name: "gemini", provider: newStubProvider(stubResponse{err: errors.New("second")})},
+	})
+	if _, _, err := generateWith(chain, "image", canImage, func(_ string, provider LLMProvider) (string, error) {
+		return provider.GenerateAltText("prompt", nil, "png", "en")
+	}); err == nil || err.Error() != "second" {
+		t.Errorf("err = %v, want the last provider's error", err)
+	}
+}
+
+func TestProviderAttributionCreditsServingProvider(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	config.LLM.Provider = "ollama"
+	config.Server.Username = "altbot"
+
+	attribution := getProviderAttribution(config, "en", "gemini")
+	if !strings.Contains(attribution, "Gemini") {
+		t.Errorf("attribution %q doesn't credit Gemini", attribution)
+	}
+}
@@ -8,11 +8,14 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/mattn/go-mastodon"
 )
@@ -28,6 +31,22 @@ type ConsentRecord struct {
 	UserID        string    `json:"user_id"`
 	Timestamp     time.Time `json:"timestamp"`
 	ConsentMethod string    `json:"consent_method"`
+	MentionsOnly  bool      `json:"mentions_only,omitempty"`  // Don't caption the user's posts unless they mention the bot
+	ReconfirmedAt time.Time `json:"reconfirmed_at,omitempty"` // When the consent was last given again after expiring
+	Stale         bool      `json:"stale,omitempty"`          // The consent expired and has to be given again
+}
+
+// consentExpired reports whether a record is older than consent_ttl_days, counting from its last confirmation
+func consentExpired(record ConsentRecord, now time.Time) bool {
+	if config.Behavior.ConsentTTLDays <= 0 {
+		return false
+	}
+
+	confirmed := record.Timestamp
+	if record.ReconfirmedAt.After(confirmed) {
+		confirmed = record.ReconfirmedAt
+	}
+	return now.Sub(confirmed) > time.Duration(config.Behavior.ConsentTTLDays)*24*time.Hour
 }
 
 var consentDB ConsentDatabase
@@ -53,12 +72,12 @@ func InitializeConsentDatabase() error {
 	if err != nil {
 		if os.IsNotExist(err) {
 			// File doesn't exist, that's okay - we'll create it when we save
-			fmt.Println("No consent database found. Creating a new one.")
+			logInfof("No consent database found. Creating a new one.")
 			return saveConsentDatabase("consent_database.json")
 		}
 		return err
 	}
-	fmt.Printf("Database loaded with %d users\n", len(consentDB.Users))
+	logInfof("Consent database loaded with %d users", len(consentDB.Users))
 	return nil
 }
 
@@ -85,23 +104,49 @@ func saveConsentDatabase(filePath string) error {
 	return os.WriteFile(filePath, data, 0644)
 }
 
-// HasUserConsent checks if a user has provided consent
+// HasUserConsent checks if a user has provided consent that hasn't expired. An expired record is
+// kept but marked stale, so the next interaction asks for consent again.
 func HasUserConsent(userID string) bool {
 	consentDB.mu.Lock()
-	defer consentDB.mu.Unlock()
 
-	_, exists := consentDB.Users[userID]
-	return exists
+	record, exists := consentDB.Users[userID]
+	if !exists || record.Stale {
+		consentDB.mu.Unlock()
+		return false
+	}
+
+	if !consentExpired(record, time.Now()) {
+		consentDB.mu.Unlock()
+		return true
+	}
+
+	record.Stale = true
+	consentDB.Users[userID] = record
+	consentDB.mu.Unlock()
+
+	logInfof("Consent of user %s expired, asking for it again on the next interaction", userID)
+	if err := saveConsentDatabase("consent_database.json"); err != nil {
+		logErrorf("Error saving consent database: %v", err)
+	}
+	return false
 }
 
-// RecordUserConsent adds a user to the consent database
+// RecordUserConsent adds a user to the consent database. Giving consent again after it expired
+// keeps the original record and notes when it was reconfirmed.
 func RecordUserConsent(userID string, method string) error {
 	consentDB.mu.Lock()
 
-	consentDB.Users[userID] = ConsentRecord{
-		UserID:        userID,
-		Timestamp:     time.Now(),
-		ConsentMethod: method,
+	if record, exists := consentDB.Users[userID]; exists {
+		record.ReconfirmedAt = time.Now()
+		record.ConsentMethod = method
+		record.Stale = false
+		consentDB.Users[userID] = record
+	} else {
+		consentDB.Users[userID] = ConsentRecord{
+			UserID:        userID,
+			Timestamp:     time.Now(),
+			ConsentMethod: method,
+		}
 	}
 
 	consentDB.mu.Unlock()
@@ -109,12 +154,37 @@ func RecordUserConsent(userID string, method string) error {
 	return saveConsentDatabase("consent_database.json")
 }
 
-// RemoveUserConsent removes a user from the consent database
-func RemoveUserConsent(userID string) error {
+// IsMentionsOnly checks if a user opted out of proactive captions on their own posts
+func IsMentionsOnly(userID string) bool {
 	consentDB.mu.Lock()
 	defer consentDB.mu.Unlock()
 
+	return consentDB.Users[userID].MentionsOnly
+}
+
+// SetMentionsOnly updates a consenting user's proactive caption preference
+func SetMentionsOnly(userID string, mentionsOnly bool) error {
+	consentDB.mu.Lock()
+
+	record, exists := consentDB.Users[userID]
+	if !exists {
+		consentDB.mu.Unlock()
+		return fmt.Errorf("user %s has not given consent", userID)
+	}
+	record.MentionsOnly = mentionsOnly
+	consentDB.Users[userID] = record
+
+	consentDB.mu.Unlock()
+
+	return saveConsentDatabase("consent_database.json")
+}
+
+// RemoveUserConsent removes a user from the consent database
+func RemoveUserConsent(userID string) error {
+	consentDB.mu.Lock()
 	delete(consentDB.Users, userID)
+	consentDB.mu.Unlock()
+
 	return saveConsentDatabase("consent_database.json")
 }
 
@@ -147,7 +217,7 @@ func InitializePendingGDPRRequests() error {
 	}
 
 	if len(pendingGDPRRequests) > 0 {
-		fmt.Printf("Loaded %d pending GDPR requests\n", len(pendingGDPRRequests))
+		logInfof("Loaded %d pending GDPR requests", len(pendingGDPRRequests))
 	}
 	return nil
 }
@@ -176,7 +246,7 @@ func AddPendingGDPRRequest(userID string, requestStatusID mastodon.ID) {
 	pendingGDPRMutex.Unlock()
 
 	if err := savePendingGDPRRequests(); err != nil {
-		log.Printf("Error saving pending GDPR requests: %v", err)
+		logErrorf("Error saving pending GDPR requests: %v", err)
 	}
 }
 
@@ -206,7 +276,7 @@ func RemovePendingGDPRRequest(userID string) {
 	pendingGDPRMutex.Unlock()
 
 	if err := savePendingGDPRRequests(); err != nil {
-		log.Printf("Error saving pending GDPR requests: %v", err)
+		logErrorf("Error saving pending GDPR requests: %v", err)
 	}
 }
 
@@ -225,9 +295,9 @@ func CleanupExpiredGDPRRequests() {
 	}
 
 	if removed > 0 {
-		log.Printf("Cleaned up %d expired GDPR requests", removed)
+		logInfof("Cleaned up %d expired GDPR requests", removed)
 		if err := savePendingGDPRRequests(); err != nil {
-			log.Printf("Error saving pending GDPR requests: %v", err)
+			logErrorf("Error saving pending GDPR requests: %v", err)
 		}
 	}
 }
@@ -242,11 +312,28 @@ func StartGDPRCleanupRoutine() {
 	}()
 }
 
+// gdprLanguage picks the language for GDPR messages: the configured gdpr_language, or with "auto"
+// the user's post language when a full translation of the consent messages exists, otherwise English
+func gdprLanguage(language string) string {
+	if config.Localization.GDPRLanguage != "" && config.Localization.GDPRLanguage != "auto" {
+		return config.Localization.GDPRLanguage
+	}
+
+	if localization, ok := localizations[language]; ok {
+		_, hasRequest := localization.Responses["gdprConsentRequest"]
+		_, hasConfirmation := localization.Responses["gdprConsentConfirmation"]
+		_, hasWelcome := localization.Responses["gdprWelcomeMessage"]
+		if hasRequest && hasConfirmation && hasWelcome {
+			return language
+		}
+	}
+
+	return "en"
+}
+
 // RequestGDPRConsent sends a consent request message to a user
 func RequestGDPRConsent(c *mastodon.Client, userID string, username string, language string, replyToID mastodon.ID, isStandaloneMsg bool) (mastodon.ID, error) {
-	// Always use English for GDPR messages for now, regardless of user language
-	// We'll use "en" as the language code for consistency
-	consentLanguage := "en"
+	consentLanguage := gdprLanguage(language)
 
 	// Prepare the consent message with localization support
 	var message string
@@ -266,6 +353,10 @@ func RequestGDPRConsent(c *mastodon.Client, userID string, username string, lang
 		return "", nil
 	}
 
+	if !allowReply(c) {
+		return "", fmt.Errorf("daily reply limit reached")
+	}
+
 	// Post the consent request
 	status, err := c.PostStatus(ctx, &mastodon.Toot{
 		Status:      message,
@@ -275,19 +366,23 @@ func RequestGDPRConsent(c *mastodon.Client, userID string, username string, lang
 	})
 
 	if err != nil {
-		log.Printf("Error sending GDPR consent request: %v", err)
+		logErrorf("Error sending GDPR consent request: %v", err)
 		return "", err
 	}
 
 	// Track this pending request (for PixelFed and other platforms that don't use reply threading)
 	AddPendingGDPRRequest(userID, status.ID)
 
-	log.Printf("Sent GDPR consent request to %s", username)
+	logInfof("Sent GDPR consent request to %s", username)
 	return status.ID, nil
 }
 
 // HandleGDPRConsentResponse processes a user's response to a consent request
 func HandleGDPRConsentResponse(c *mastodon.Client, status *mastodon.Status) bool {
+	if isSelf(&status.Account) {
+		return false
+	}
+
 	userID := string(status.Account.ID)
 
 	// Case 1: Reply-based response (standard Mastodon flow)
@@ -314,13 +409,13 @@ func handleReplyBasedConsent(c *mastodon.Client, status *mastodon.Status, userID
 	case mastodon.ID:
 		originalStatusID = id
 	default:
-		log.Printf("Unexpected type for InReplyToID: %T", status.InReplyToID)
+		logWarnf("Unexpected type for InReplyToID: %T", status.InReplyToID)
 		return false
 	}
 
 	parentStatus, err := c.GetStatus(ctx, originalStatusID)
 	if err != nil {
-		log.Printf("Error fetching original status: %v", err)
+		logErrorf("Error fetching original status: %v", err)
 		return false
 	}
 
@@ -352,7 +447,7 @@ func handleNonReplyConsent(c *mastodon.Client, status *mastodon.Status, userID s
 	if checkAndRecordConsent(c, status, userID) {
 		// Remove the pending request
 		RemovePendingGDPRRequest(userID)
-		log.Printf("Accepted GDPR consent from %s via non-reply DM (PixelFed flow)", status.Account.Acct)
+		logInfof("Accepted GDPR consent from %s via non-reply DM (PixelFed flow)", status.Account.Acct)
 		return true
 	}
 
@@ -371,7 +466,8 @@ func checkAndRecordConsent(c *mastodon.Client, status *mastodon.Status, userID s
 	responseText := strings.ToLower(plainTextContent)
 
 	// Check for various affirmative responses (must be whole words, not substrings)
-	affirmativeResponses := []string{"yes", "agree", "i agree", "consent", "i consent", "ok", "okay", "ja", "oui", "si"}
+	// Includes the localized answers suggested by each translated consent request
+	affirmativeResponses := []string{"yes", "agree", "i agree", "consent", "i consent", "ok", "okay", "ja", "oui", "si", "sí", "sì", "sim", "tak", "bai", "да", "так", "はい", "同意", "동의"}
 	consent := false
 	for _, response := range affirmativeResponses {
 		if containsWholeWord(responseText, response) {
@@ -387,22 +483,23 @@ func checkAndRecordConsent(c *mastodon.Client, status *mastodon.Status, userID s
 	// Record the user's consent
 	err := RecordUserConsent(userID, "explicit")
 	if err != nil {
-		log.Printf("Error recording consent for user %s: %v", status.Account.Acct, err)
+		logErrorf("Error recording consent for user %s: %v", status.Account.Acct, err)
 		return false
 	}
 
-	log.Printf("User %s provided explicit consent", status.Account.Acct)
+	logInfof("User %s provided explicit consent", status.Account.Acct)
 
 	// Send confirmation message
 	sendConsentConfirmation(c, status)
 
+	followBackAfterConsent(c, &status.Account)
+
 	return true
 }
 
 // sendConsentConfirmation sends a confirmation message to the user
 func sendConsentConfirmation(c *mastodon.Client, status *mastodon.Status) {
-	// Always use English for GDPR messages
-	consentLanguage := "en"
+	consentLanguage := gdprLanguage(status.Language)
 	confirmationMsg := fmt.Sprintf("@%s %s", status.Account.Acct, getLocalizedString(consentLanguage, "gdprConsentConfirmation", "response"))
 
 	// Dev mode: print to terminal instead of posting
@@ -415,6 +512,10 @@ func sendConsentConfirmation(c *mastodon.Client, status *mastodon.Status) {
 		return
 	}
 
+	if !allowReply(c) {
+		return
+	}
+
 	_, err := c.PostStatus(ctx, &mastodon.Toot{
 		Status:      confirmationMsg,
 		InReplyToID: status.ID,
@@ -423,20 +524,121 @@ func sendConsentConfirmation(c *mastodon.Client, status *mastodon.Status) {
 	})
 
 	if err != nil {
-		log.Printf("Error sending consent confirmation: %v", err)
+		logErrorf("Error sending consent confirmation: %v", err)
 	}
 }
 
 // Handle user blocking events (consent revocation)
 func HandleBlockEvent(userID string) {
-	if HasUserConsent(userID) {
+	// Stale records are removed too, HasUserConsent would skip them
+	consentDB.mu.Lock()
+	_, exists := consentDB.Users[userID]
+	consentDB.mu.Unlock()
+
+	if exists {
 		err := RemoveUserConsent(userID)
 		if err != nil {
-			log.Printf("Error removing consent for user %s: %v", userID, err)
+			logErrorf("Error removing consent for user %s: %v", userID, err)
 		} else {
-			log.Printf("User %s revoked consent by blocking", userID)
+			logInfof("User %s revoked consent by blocking", userID)
+		}
+	}
+
+	if GetPendingGDPRRequest(userID) != nil {
+		RemovePendingGDPRRequest(userID)
+	}
+}
+
+// blockCheckInterval is how often the consenting users are checked for having blocked the bot
+const blockCheckInterval = 6 * time.Hour
+
+// relationshipsBatchSize is how many accounts are looked up per relationships request
+const relationshipsBatchSize = 40
+
+// StartBlockCheckRoutine periodically revokes the consent of users who blocked the bot. Mastodon
+// doesn't tell an account when it gets blocked, so the relationships have to be polled.
+func StartBlockCheckRoutine(c *mastodon.Client) {
+	go func() {
+		ticker := time.NewTicker(blockCheckInterval)
+		for range ticker.C {
+			checkConsentingUsersForBlocks(c)
+		}
+	}()
+}
+
+// checkConsentingUsersForBlocks looks up the relationships of everyone who gave or was asked for consent,
+// and handles those who blocked the bot
+func checkConsentingUsersForBlocks(c *mastodon.Client) {
+	seen := make(map[string]bool)
+	var userIDs []string
+
+	consentDB.mu.Lock()
+	for userID := range consentDB.Users {
+		seen[userID] = true
+		userIDs = append(userIDs, userID)
+	}
+	consentDB.mu.Unlock()
+
+	pendingGDPRMutex.Lock()
+	for userID := range pendingGDPRRequests {
+		if !seen[userID] {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	pendingGDPRMutex.Unlock()
+
+	for start := 0; start < len(userIDs); start += relationshipsBatchSize {
+		batch := userIDs[start:min(start+relationshipsBatchSize, len(userIDs))]
+		blockedBy, err := fetchBlockedBy(c, batch)
+		if err != nil {
+			logErrorf("Error checking for blocks: %v", err)
+			return
+		}
+		for _, userID := range blockedBy {
+			HandleBlockEvent(userID)
+		}
+	}
+}
+
+// fetchBlockedBy returns which of the accounts block the bot. The relationships are requested directly,
+// as go-mastodon's Relationship lacks the blocked_by field.
+func fetchBlockedBy(c *mastodon.Client, userIDs []string) ([]string, error) {
+	params := url.Values{}
+	for _, userID := range userIDs {
+		params.Add("id[]", userID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(c.Config.Server, "/")+"/api/v1/accounts/relationships?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Config.AccessToken)
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("relationships request failed with status %d", resp.StatusCode)
+	}
+
+	var relationships []struct {
+		ID        string `json:"id"`
+		BlockedBy bool   `json:"blocked_by"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&relationships); err != nil {
+		return nil, err
+	}
+
+	var blockedBy []string
+	for _, relationship := range relationships {
+		if relationship.BlockedBy {
+			blockedBy = append(blockedBy, relationship.ID)
 		}
 	}
+	return blockedBy, nil
 }
 
 // containsWord checks if a string contains a specific substring
@@ -463,10 +665,12 @@ func containsWholeWord(text, word string) bool {
 		}
 
 		// Check left boundary (start of string or non-letter)
-		leftOk := i == 0 || !isLetter(text[i-1])
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		leftOk := i == 0 || !unicode.IsLetter(before)
 
 		// Check right boundary (end of string or non-letter)
-		rightOk := i+wordLen == textLen || !isLetter(text[i+wordLen])
+		after, _ := utf8.DecodeRuneInString(text[i+wordLen:])
+		rightOk := i+wordLen == textLen || !unicode.IsLetter(after)
 
 		if leftOk && rightOk {
 			return true
@@ -475,7 +679,45 @@ func containsWholeWord(text, word string) bool {
 	return false
 }
 
-// isLetter checks if a byte is a letter (a-z, A-Z)
-func isLetter(b byte) bool {
-	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+// --- GDPR Erasure ---
+
+const gdprAuditLogFile = "gdpr_audit.json"
+
+// readJSONIfExists decodes a JSON file into v, leaving v untouched if the file doesn't exist
+func readJSONIfExists(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// logGDPRAudit appends an entry to the GDPR audit log, storing only the hashed user ID
+func logGDPRAudit(action string, hashedUserID string, details map[string]int) {
+	entry := map[string]interface{}{
+		"timestamp": time.Now(),
+		"action":    action,
+		"user_hash": hashedUserID,
+		"removed":   details,
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		logErrorf("Error encoding GDPR audit entry: %v", err)
+		return
+	}
+
+	file, err := os.OpenFile(gdprAuditLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logErrorf("Error opening GDPR audit log: %v", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		logErrorf("Error writing GDPR audit entry: %v", err)
+	}
 }
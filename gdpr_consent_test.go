@@ -0,0 +1,172 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// useConsentDB starts the test without any consent records or pending requests
+func useConsentDB(t *testing.T) {
+	t.Helper()
+	consentDB.mu.Lock()
+	previousUsers := consentDB.Users
+	consentDB.Users = make(map[string]ConsentRecord)
+	consentDB.mu.Unlock()
+
+	pendingGDPRMutex.Lock()
+	previousPending := pendingGDPRRequests
+	pendingGDPRRequests = make(map[string]PendingGDPRRequest)
+	pendingGDPRMutex.Unlock()
+
+	t.Cleanup(func() {
+		consentDB.mu.Lock()
+		consentDB.Users = previousUsers
+		consentDB.mu.Unlock()
+
+		pendingGDPRMutex.Lock()
+		pendingGDPRRequests = previousPending
+		pendingGDPRMutex.Unlock()
+	})
+}
+
+func TestBlockRevokesConsent(t *testing.T) {
+	t.Chdir(t.TempDir())
+	useConsentDB(t)
+
+	for _, userID := range []string{"20", "30"} {
+		if err := RecordUserConsent(userID, "reply"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	AddPendingGDPRRequest("20", "100")
+	AddPendingGDPRRequest("40", "101")
+
+	var asked []string
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/accounts/relationships" {
+			http.NotFound(w, r)
+			return
+		}
+		asked = r.URL.Query()["id[]"]
+		w.Header().Set("Content-Type", "application/json")
+		var relationships []string
+		for _, id := range asked {
+			relationships = append(relationships, fmt.Sprintf(`{"id":%q,"blocked_by":%v}`, id, id != "30"))
+		}
+		io.WriteString(w, "["+strings.Join(relationships, ",")+"]")
+	})
+
+	checkConsentingUsersForBlocks(c)
+
+	sort.Strings(asked)
+	if strings.Join(asked, ",") != "20,30,40" {
+		t.Errorf("looked up %v, want everyone who gave or was asked for consent", asked)
+	}
+	if HasUserConsent("20") || !HasUserConsent("30") {
+		t.Errorf("consent after the block: 20 %v, 30 %v, want only 30's kept", HasUserConsent("20"), HasUserConsent("30"))
+	}
+	if GetPendingGDPRRequest("20") != nil || GetPendingGDPRRequest("40") != nil {
+		t.Error("pending consent requests of blocking users kept")
+	}
+
+	// The revocation is saved
+	saved := make(map[string]ConsentRecord)
+	readJSONFile(t, "consent_database.json", &saved)
+	if _, exists := saved["20"]; exists || len(saved) != 1 {
+		t.Errorf("saved consent records %v, want only 30's", saved)
+	}
+}
+
+func TestConsentExpiresAfterTTL(t *testing.T) {
+	useConfig(t)
+	t.Chdir(t.TempDir())
+	useConsentDB(t)
+
+	now := time.Now()
+	consentDB.Users["fresh"] = ConsentRecord{UserID: "fresh", Timestamp: now.AddDate(0, 0, -29)}
+	consentDB.Users["old"] = ConsentRecord{UserID: "old", Timestamp: now.AddDate(0, 0, -31)}
+	consentDB.Users["reconfirmed"] = ConsentRecord{UserID: "reconfirmed", Timestamp: now.AddDate(-1, 0, 0), ReconfirmedAt: now.AddDate(0, 0, -1)}
+
+	// Without a TTL consent doesn't expire
+	if !HasUserConsent("old") {
+		t.Error("consent expired without consent_ttl_days")
+	}
+
+	config.Behavior.ConsentTTLDays = 30
+	if !HasUserConsent("fresh") || !HasUserConsent("reconfirmed") {
+		t.Error("consent within the TTL expired")
+	}
+	if HasUserConsent("old") {
+		t.Fatal("consent older than the TTL didn't expire")
+	}
+
+	// The expired record is kept, marked stale
+	saved := make(map[string]ConsentRecord)
+	readJSONFile(t, "consent_database.json", &saved)
+	if record, exists := saved["old"]; !exists || !record.Stale {
+		t.Errorf("saved record %+v, want it kept and stale", record)
+	}
+
+	// Giving consent again keeps when it was first given
+	if err := RecordUserConsent("old", "reply"); err != nil {
+		t.Fatal(err)
+	}
+	record := consentDB.Users["old"]
+	if !HasUserConsent("old") || record.Stale || !record.Timestamp.Equal(now.AddDate(0, 0, -31)) || time.Since(record.ReconfirmedAt) > time.Minute {
+		t.Errorf("reconfirmed record %+v", record)
+	}
+}
+
+func TestExpiredConsentIsRequestedAgain(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	useBotState(t)
+	useConsentDB(t)
+	useProvider(t, newStubProvider())
+	config.Behavior.ConsentTTLDays = 30
+	consentDB.Users["20"] = ConsentRecord{UserID: "20", Timestamp: time.Now().AddDate(0, 0, -31)}
+
+	posts := make(chan url.Values, 1)
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost && r.URL.Path == "/api/v1/statuses" {
+			r.ParseForm()
+			posts <- r.Form
+			io.WriteString(w, `{"id":"3"}`)
+			return
+		}
+		http.NotFound(w, r)
+	})
+
+	handleUpdate(c, &mastodon.Status{
+		ID:               "2",
+		Language:         "en",
+		Account:          mastodon.Account{ID: "20", Acct: "bob"},
+		MediaAttachments: []mastodon.Attachment{{ID: "m1", Type: "image"}},
+	})
+
+	if len(posts) != 1 {
+		t.Fatal("no consent request was sent")
+	}
+	form := <-posts
+	if form.Get("visibility") != "direct" || form.Get("in_reply_to_id") != "2" ||
+		!strings.Contains(form.Get("status"), getLocalizedString("en", "gdprConsentRequest", "response")) {
+		t.Errorf("posted %v, want a direct consent request", form)
+	}
+	if GetPendingGDPRRequest("20") == nil {
+		t.Error("the consent request isn't pending")
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	genai "google.golang.org/genai"
+)
+
+// fakeGeminiFiles is a Files API whose uploads stay processing for a number of checks, then end in state
+type fakeGeminiFiles struct {
+	mu         sync.Mutex
+	processing int
+	state      genai.FileState
+	gets       []time.Time
+	deleted    []string
+}
+
+func (f *fakeGeminiFiles) Upload(ctx context.Context, r io.Reader, config *genai.UploadFileConfig) (*genai.File, error) {
+	io.Copy(io.Discard, r)
+	return &genai.File{Name: "files/upload-1", MIMEType: config.MIMEType, State: genai.FileStateProcessing}, nil
+}
+
+func (f *fakeGeminiFiles) Get(ctx context.Context, name string, config *genai.GetFileConfig) (*genai.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gets = append(f.gets, time.Now())
+	state := genai.FileStateProcessing
+	if f.processing >= 0 && len(f.gets) > f.processing {
+		state = f.state
+	}
+	return &genai.File{Name: name, URI: "https://example.com/" + name, State: state}, nil
+}
+
+func (f *fakeGeminiFiles) Delete(ctx context.Context, name string, config *genai.DeleteFileConfig) (*genai.DeleteFileResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, name)
+	return &genai.DeleteFileResponse{}, nil
+}
+
+func TestGeminiUploadStuckProcessingTimesOut(t *testing.T) {
+	useConfig(t)
+	config.Gemini.UploadMaxWaitSeconds = 1
+	files := &fakeGeminiFiles{processing: -1}
+
+	start := time.Now()
+	_, err := uploadGeminiFile(files, strings.NewReader("video"), "Video", "video/mp4", 10*time.Millisecond)
+	if !errors.Is(err, errGeminiFileTimeout) {
+		t.Fatalf("err = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 3*time.Second {
+		t.Errorf("gave up after %v, want about a second", elapsed)
+	}
+	if len(files.deleted) != 1 || files.deleted[0] != "files/upload-1" {
+		t.Errorf("deleted %v, want the stuck upload", files.deleted)
+	}
+
+	// The checks back off, rather than polling at the initial interval the whole time
+	if len(files.gets) < 3 || len(files.gets) > 10 {
+		t.Fatalf("checked %d times", len(files.gets))
+	}
+	if first, last := files.gets[1].Sub(files.gets[0]), files.gets[len(files.gets)-1].Sub(files.gets[len(files.gets)-2]); last <= first {
+		t.Errorf("interval went from %v to %v", first, last)
+	}
+}
+
+func TestGeminiUploadWaitsUntilActive(t *testing.T) {
+	useConfig(t)
+	files := &fakeGeminiFiles{processing: 2, state: genai.FileStateActive}
+
+	file, err := uploadGeminiFile(files, strings.NewReader("audio"), "Audio", "audio/mpeg", time.Millisecond)
+	if err != nil || file.State != genai.FileStateActive || file.URI == "" {
+		t.Fatalf("got %+v, %v", file, err)
+	}
+	if len(files.gets) != 3 || len(files.deleted) != 0 {
+		t.Errorf("%d checks, deleted %v", len(files.gets), files.deleted)
+	}
+
+	// The caller deletes the file once the caption is generated
+	deleteGeminiFile(files, file.Name)
+	if len(files.deleted) != 1 {
+		t.Errorf("deleted %v", files.deleted)
+	}
+}
+
+func TestGeminiUploadFailedProcessingIsDeleted(t *testing.T) {
+	useConfig(t)
+	files := &fakeGeminiFiles{processing: 0, state: genai.FileStateFailed}
+
+	if _, err := uploadGeminiFile(files, strings.NewReader("video"), "Video", "video/mp4", time.Millisecond); err == nil || !strings.Contains(err.Error(), "failed to process") {
+		t.Errorf("err = %v", err)
+	}
+	if len(files.deleted) != 1 {
+		t.Errorf("deleted %v, want the failed upload", files.deleted)
+	}
+}
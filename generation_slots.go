@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+// slotLimitedProvider makes every generation of the provider it wraps wait for one of a fixed number of
+// slots, so mentions, posts and API requests together never run more than that many at once.
+// Ping, Capabilities and Close go straight to the wrapped provider.
+type slotLimitedProvider struct {
+	LLMProvider
+	slots chan struct{}
+}
+
+// limitConcurrentGenerations wraps a provider so at most max generations run at once, max <= 0 means no limit
+func limitConcurrentGenerations(provider LLMProvider, max int) LLMProvider {
+	if max <= 0 {
+		return provider
+	}
+	return &slotLimitedProvider{LLMProvider: provider, slots: make(chan struct{}, max)}
+}
+
+// acquire waits for a free slot and returns the function that frees it again
+func (p *slotLimitedProvider) acquire() func() {
+	p.slots <- struct{}{}
+	return func() { <-p.slots }
+}
+
+func (p *slotLimitedProvider) GenerateAltText(prompt string, imageData []byte, format string, targetLanguage string) (string, error) {
+	defer p.acquire()()
+	return p.LLMProvider.GenerateAltText(prompt, imageData, format, targetLanguage)
+}
+
+func (p *slotLimitedProvider) GenerateVideoAltText(prompt string, videoData []byte, format string, targetLanguage string) (string, error) {
+	defer p.acquire()()
+	return p.LLMProvider.GenerateVideoAltText(prompt, videoData, format, targetLanguage)
+}
+
+func (p *slotLimitedProvider) GenerateAudioAltText(prompt string, audioData []byte, format string, targetLanguage string) (string, error) {
+	defer p.acquire()()
+	return p.LLMProvider.GenerateAudioAltText(prompt, audioData, format, targetLanguage)
+}
+
+func (p *slotLimitedProvider) CategorizeImage(imageData []byte, format string) (string, error) {
+	defer p.acquire()()
+	return p.LLMProvider.CategorizeImage(imageData, format)
+}
+
+func (p *slotLimitedProvider) GenerateMultiImageAltText(prompt string, images [][]byte, formats []string, targetLanguage string) (string, error) {
+	defer p.acquire()()
+	return p.LLMProvider.GenerateMultiImageAltText(prompt, images, formats, targetLanguage)
+}
+
+func (p *slotLimitedProvider) generateNamed(kind string, can func(ProviderCapabilities) bool, generate generateFunc) (string, string, error) {
+	defer p.acquire()()
+	return generateWith(p.LLMProvider, kind, can, generate)
+}
+
+// WithTemperatureBoost keeps the boosted copy on the same slots
+func (p *slotLimitedProvider) WithTemperatureBoost(boost float32) LLMProvider {
+	return &slotLimitedProvider{LLMProvider: p.LLMProvider.WithTemperatureBoost(boost), slots: p.slots}
+}
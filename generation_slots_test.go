@@ -0,0 +1,107 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// gatedProvider is a stub whose image generations each wait to be released, and tell when they started
+type gatedProvider struct {
+	*stubProvider
+	started chan string
+	release chan struct{}
+}
+
+func newGatedProvider() *gatedProvider {
+	return &gatedProvider{stubProvider: newStubProvider(), started: make(chan string, 10), release: make(chan struct{})}
+}
+
+func (p *gatedProvider) GenerateAltText(prompt string, imageData []byte, format string, targetLanguage string) (string, error) {
+	p.started <- prompt
+	<-p.release
+	return p.next(prompt)
+}
+
+func (p *gatedProvider) WithTemperatureBoost(boost float32) LLMProvider { return p }
+
+// waitStarted returns the prompt of the next generation to start, or "" if none does for a while
+func (p *gatedProvider) waitStarted(wait time.Duration) string {
+	select {
+	case prompt := <-p.started:
+		return prompt
+	case <-time.After(wait):
+		return ""
+	}
+}
+
+func TestLimitConcurrentGenerationsSerializes(t *testing.T) {
+	gated := newGatedProvider()
+	provider := limitConcurrentGenerations(gated, 1)
+
+	done := make(chan struct{}, 2)
+	for _, prompt := range []string{"first", "second"} {
+		go func(prompt string) {
+			provider.GenerateAltText(prompt, nil, "png", "en")
+			done <- struct{}{}
+		}(prompt)
+	}
+
+	first := gated.waitStarted(5 * time.Second)
+	if first == "" {
+		t.Fatal("no generation started")
+	}
+	if second := gated.waitStarted(100 * time.Millisecond); second != "" {
+		t.Fatalf("%q started while %q held the only slot", second, first)
+	}
+
+	gated.release <- struct{}{}
+	<-done
+	if gated.waitStarted(5*time.Second) == "" {
+		t.Fatal("the second generation didn't start once the slot was free")
+	}
+	gated.release <- struct{}{}
+	<-done
+}
+
+func TestLimitConcurrentGenerationsAllowsMax(t *testing.T) {
+	gated := newGatedProvider()
+	provider := limitConcurrentGenerations(gated, 2)
+
+	for _, prompt := range []string{"first", "second"} {
+		go provider.GenerateAltText(prompt, nil, "png", "en")
+	}
+	for i := 0; i < 2; i++ {
+		if gated.waitStarted(5*time.Second) == "" {
+			t.Fatalf("only %d of 2 generations started with 2 slots", i)
+		}
+	}
+	close(gated.release)
+}
+
+func TestTemperatureBoostSharesSlots(t *testing.T) {
+	gated := newGatedProvider()
+	provider := limitConcurrentGenerations(gated, 1)
+	boosted := provider.WithTemperatureBoost(0.3)
+
+	go provider.GenerateAltText("first", nil, "png", "en")
+	if gated.waitStarted(5*time.Second) == "" {
+		t.Fatal("no generation started")
+	}
+	go boosted.GenerateAltText("boosted", nil, "png", "en")
+	if gated.waitStarted(100*time.Millisecond) != "" {
+		t.Error("the boosted copy didn't wait for the slot")
+	}
+	close(gated.release)
+}
+
+func TestLimitConcurrentGenerationsUnlimited(t *testing.T) {
+	stub := newStubProvider()
+	if limitConcurrentGenerations(stub, 0) != LLMProvider(stub) {
+		t.Error("a limit of 0 wrapped the provider")
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+
+	"github.com/nfnt/resize"
+)
+
+// extractGIFFrames samples up to maxFrames evenly spaced frames of an animated GIF as downscaled PNGs.
+// Frames are composited like a viewer would show them, as GIF frames often only contain the changed area.
+// Static GIFs return no frames.
+func extractGIFFrames(data []byte, maxFrames int) ([][]byte, error) {
+	animation, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(animation.Image) < 2 || maxFrames < 2 {
+		return nil, nil
+	}
+
+	// Pick the frame indices to sample, always including the first and the last frame
+	count := min(maxFrames, len(animation.Image))
+	sampled := make(map[int]bool, count)
+	for i := 0; i < count; i++ {
+		sampled[i*(len(animation.Image)-1)/(count-1)] = true
+	}
+
+	bounds := image.Rect(0, 0, animation.Config.Width, animation.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	var frames [][]byte
+
+	for i, frame := range animation.Image {
+		var previous *image.RGBA
+		disposal := byte(0)
+		if i < len(animation.Disposal) {
+			disposal = animation.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			draw.Draw(previous, bounds, canvas, image.Point{}, draw.Src)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		if sampled[i] {
+			resized := resize.Resize(config.ImageProcessing.DownscaleWidth, 0, canvas, resizeAlgorithms[config.ImageProcessing.ResizeAlgorithm])
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, resized); err != nil {
+				return nil, err
+			}
+			frames = append(frames, buf.Bytes())
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return frames, nil
+}
+
+// generateAnimatedGIFAltText describes an animated GIF from several of its frames when animate_gif_frames
+// is set, along with the name of the provider that described it. It returns "" without an error for
+// anything that isn't an animated GIF.
+func generateAnimatedGIFAltText(data []byte, lang string, acct string, style string) (string, string, error) {
+	if config.ImageProcessing.AnimateGIFFrames < 2 || !llmProvider.Capabilities().MultiImage {
+		return "", "", nil
+	}
+
+	frames, err := extractGIFFrames(data, config.ImageProcessing.AnimateGIFFrames)
+	if err != nil || len(frames) == 0 {
+		return "", "", nil
+	}
+
+	formats := make([]string, len(frames))
+	for i := range formats {
+		formats[i] = "png"
+	}
+
+	logInfof("Processing animated GIF from %d frames", len(frames))
+
+	altText, servedBy, err := generateWith(llmProvider, "multi-image", canMultiImage, func(name string, provider LLMProvider) (string, error) {
+		prompt := getPromptForUser(lang, altTextPromptKey(style), acct, name) + " " + fmt.Sprintf(getPromptHint(lang, "animatedGifInstructions"), len(frames))
+		return withLLMRetry(func() (string, error) {
+			return provider.GenerateMultiImageAltText(prompt, frames, formats, lang)
+		})
+	})
+	if err != nil {
+		logWarnf("Error describing animated GIF, using its first frame instead: %v", err)
+		return "", "", err
+	}
+
+	return altText, servedBy, nil
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// testGIF builds an animation of count frames. The first frame fills the canvas, the following ones
+// only redraw its left half, each in another colour.
+func testGIF(t *testing.T, count int) []byte {
+	t.Helper()
+	palette := color.Palette{color.Black, color.White}
+	for i := 0; i < count; i++ {
+		palette = append(palette, color.RGBA{uint8(i * 20), 100, 200, 255})
+	}
+
+	animation := &gif.GIF{Config: image.Config{Width: 16, Height: 16, ColorModel: palette}}
+	for i := 0; i < count; i++ {
+		bounds := image.Rect(0, 0, 16, 16)
+		if i > 0 {
+			bounds = image.Rect(0, 0, 8, 16)
+		}
+		frame := image.NewPaletted(bounds, palette)
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				frame.SetColorIndex(x, y, uint8(i+2))
+			}
+		}
+		animation.Image = append(animation.Image, frame)
+		animation.Delay = append(animation.Delay, 10)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, animation); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractGIFFrames(t *testing.T) {
+	useConfig(t)
+
+	tests := []struct {
+		frames, maxFrames, want int
+	}{
+		{10, 4, 4},
+		{3, 4, 3},
+		{10, 2, 2},
+		{1, 4, 0},
+		{10, 1, 0},
+	}
+	for _, test := range tests {
+		frames, err := extractGIFFrames(testGIF(t, test.frames), test.maxFrames)
+		if err != nil || len(frames) != test.want {
+			t.Errorf("%d frames, at most %d: got %d (%v), want %d", test.frames, test.maxFrames, len(frames), err, test.want)
+		}
+	}
+}
+
+func TestExtractGIFFramesComposites(t *testing.T) {
+	useConfig(t)
+
+	frames, err := extractGIFFrames(testGIF(t, 3), 3)
+	if err != nil || len(frames) != 3 {
+		t.Fatalf("got %d frames, %v", len(frames), err)
+	}
+
+	last, err := png.Decode(bytes.NewReader(frames[2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The right half was only drawn by the first frame, the left half by the last one
+	if r, _, _, _ := last.At(12, 8).RGBA(); r>>8 != 0 {
+		t.Errorf("right half has red %d, want the first frame's 0", r>>8)
+	}
+	if r, _, _, _ := last.At(4, 8).RGBA(); r>>8 != 40 {
+		t.Errorf("left half has red %d, want the last frame's 40", r>>8)
+	}
+}
+
+func TestAnimatedGIFIsDescribedFromFrames(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	config.ImageProcessing.AnimateGIFFrames = 4
+	provider := newStubProvider(stubResponse{text: "A dot moves across the screen."})
+	useProvider(t, provider)
+
+	altText, _, err := generateAnimatedGIFAltText(testGIF(t, 8), "en", "", "")
+	if err != nil || altText != "A dot moves across the screen." {
+		t.Fatalf("got %q, %v", altText, err)
+	}
+	if !strings.Contains(provider.prompts[0], "4 frames") {
+		t.Errorf("prompt doesn't give the frame count: %q", provider.prompts[0])
+	}
+
+	// Static GIFs fall back to the single image path
+	if altText, _, err := generateAnimatedGIFAltText(testGIF(t, 1), "en", "", ""); altText != "" || err != nil {
+		t.Errorf("static GIF: %q, %v", altText, err)
+	}
+	if provider.calls() != 1 {
+		t.Errorf("provider called %d times, want once", provider.calls())
+	}
+}
@@ -6,13 +6,13 @@ require (
 	github.com/BurntSushi/toml v1.5.0
 	github.com/mattn/go-mastodon v0.0.10
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
+	github.com/sashabaranov/go-openai v1.41.2
 	golang.org/x/image v0.31.0
 	google.golang.org/genai v1.27.0
 )
 
 require (
 	github.com/google/go-cmp v0.7.0 // indirect
-	github.com/sashabaranov/go-openai v1.41.2 // indirect
 	go.opentelemetry.io/auto/sdk v1.2.1 // indirect
 )
 
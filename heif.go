@@ -0,0 +1,84 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// heifConverterPath is the heif-convert binary used to decode HEIC/HEIF images, "" when unavailable
+var heifConverterPath string
+
+// heifBrands are the ISO-BMFF brands used by HEIC/HEIF still images
+var heifBrands = [][]byte{[]byte("heic"), []byte("heix"), []byte("heim"), []byte("heis"), []byte("hevc"), []byte("mif1"), []byte("msf1")}
+
+// detectHEIFSupport looks for heif-convert (from libheif) as configured by heif_convert_path,
+// which is "" to search the PATH or "none" to disable HEIC/HEIF support
+func detectHEIFSupport() bool {
+	name := config.ImageProcessing.HEIFConvertPath
+	if name == "none" {
+		return false
+	}
+	if name == "" {
+		name = "heif-convert"
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return false
+	}
+
+	heifConverterPath = path
+	supportedImageFormats = append(supportedImageFormats, "heic")
+	return true
+}
+
+// isHEIF checks the file type box of the data for a HEIC/HEIF brand
+func isHEIF(data []byte) bool {
+	if len(data) < 12 || !bytes.Equal(data[4:8], []byte("ftyp")) {
+		return false
+	}
+	for _, brand := range heifBrands {
+		if bytes.Equal(data[8:12], brand) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeHEIF converts a HEIC/HEIF image to JPEG with heif-convert and decodes the result
+func decodeHEIF(data []byte) (image.Image, error) {
+	tmpDir, err := createTempDir("heif-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.heic")
+	outputPath := filepath.Join(tmpDir, "output.jpg")
+	if err := os.WriteFile(inputPath, data, 0600); err != nil {
+		return nil, err
+	}
+
+	output, err := exec.Command(heifConverterPath, "-q", "90", inputPath, outputPath).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("heif-convert failed: %v: %s", err, bytes.TrimSpace(output))
+	}
+
+	converted, err := os.Open(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer converted.Close()
+
+	return jpeg.Decode(converted)
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+)
+
+// imageCategories are the categories the first-pass categorizer can return, each has a
+// matching "categoryHint_<category>" prompt that is added to the alt-text prompt
+var imageCategories = []string{"photo", "screenshot", "chart", "meme"}
+
+const categorizeImagePrompt = "Classify this image into exactly one of these categories: photo, screenshot, chart, meme, other. A chart includes graphs, diagrams and maps; a screenshot includes any captured screen or app interface; a meme is an image with overlaid joke text. Answer with the single category word only."
+
+// maxCategoryCacheEntries bounds the categorization cache, it is cleared once full
+const maxCategoryCacheEntries = 1000
+
+var (
+	categoryCache      = make(map[string]string)
+	categoryCacheMutex sync.Mutex
+)
+
+// categorizeWithProvider runs the categorization prompt through a provider's regular image pipeline.
+// The prompt is always sent in English so the answer can be parsed.
+func categorizeWithProvider(p LLMProvider, imageData []byte, format string) (string, error) {
+	response, err := p.GenerateAltText(categorizeImagePrompt, imageData, format, "en")
+	if err != nil {
+		return "", err
+	}
+	return parseImageCategory(response), nil
+}
+
+// parseImageCategory extracts a known category from the model's answer, "" if there is none
+func parseImageCategory(response string) string {
+	response = strings.ToLower(response)
+	for _, category := range imageCategories {
+		if strings.Contains(response, category) {
+			return category
+		}
+	}
+	return ""
+}
+
+// getImageCategory returns the category of an image, using the cache when the same media was seen before
+func getImageCategory(imageData []byte, format string) string {
+	hash := sha256.Sum256(imageData)
+	key := hex.EncodeToString(hash[:])
+
+	categoryCacheMutex.Lock()
+	category, ok := categoryCache[key]
+	categoryCacheMutex.Unlock()
+	if ok {
+		return category
+	}
+
+	category, err := llmProvider.CategorizeImage(imageData, format)
+	if err != nil {
+		// Categorization is only an optimization, fall back to the generic prompt
+		logErrorf("Error categorizing image: %v", err)
+		return ""
+	}
+
+	categoryCacheMutex.Lock()
+	if len(categoryCache) >= maxCategoryCacheEntries {
+		categoryCache = make(map[string]string)
+	}
+	categoryCache[key] = category
+	categoryCacheMutex.Unlock()
+
+	return category
+}
+
+// imageAltTextPrompt builds the alt-text prompt for an image in the given style for the named provider,
+// adding the category-specific instructions when categorization is enabled
+func imageAltTextPrompt(imageData []byte, format string, lang string, acct string, style string, provider string) string {
+	prompt := getPromptForUser(lang, altTextPromptKey(style), acct, provider)
+	if !config.LLM.CategorizeImages {
+		return prompt
+	}
+
+	category := getImageCategory(imageData, format)
+	if category == "" {
+		return prompt
+	}
+
+	if hint := getPromptHint(lang, "categoryHint_"+category); hint != "" {
+		prompt += " " + hint
+	}
+	return prompt
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// Mentions shorter than minInlineContextChars without the accounts in them ("please", "thanks!") aren't
+// taken as context, longer ones are cut to maxInlineContextChars
+const (
+	minInlineContextChars = 10
+	maxInlineContextChars = 500
+)
+
+// mentionPattern matches local and remote account mentions like @altbot or @altbot@fuzzies.wtf
+var mentionPattern = regexp.MustCompile(`@[\w.-]+(@[\w.-]+)?`)
+
+// mentionContext returns what a mention says about the media besides the accounts it mentions,
+// like "this is my cat Mittens at the vet", or "" if inline_context is off or there's too little of it
+func mentionContext(status *mastodon.Status) string {
+	if !config.Behavior.InlineContext {
+		return ""
+	}
+
+	text := mentionPattern.ReplaceAllString(stripHTMLTags(status.Content), " ")
+	text = strings.Join(strings.Fields(text), " ")
+	if len([]rune(text)) < minInlineContextChars {
+		return ""
+	}
+
+	return truncateAltText(text, maxInlineContextChars)
+}
+
+// withUserContext adds the context the poster gave about the media to a prompt
+func withUserContext(prompt string, lang string, userContext string) string {
+	if userContext == "" {
+		return prompt
+	}
+	return prompt + " " + fmt.Sprintf(getPromptHint(lang, "userContext"), userContext)
+}
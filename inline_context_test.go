@@ -0,0 +1,66 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestMentionContext(t *testing.T) {
+	useConfig(t)
+	config.Behavior.InlineContext = true
+
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{`<p><span class="h-card"><a href="https://example.social/@altbot">@<span>altbot</span></a></span></p>`, ""},
+		{"<p>@altbot@example.social please</p>", ""},
+		{"<p>@altbot this is a photo of my cat Mittens at the vet</p>", "this is a photo of my cat Mittens at the vet"},
+		{"<p>@altbot @friend@other.social my cat   Mittens,\n asleep</p>", "my cat Mittens, asleep"},
+	}
+	for _, test := range tests {
+		if got := mentionContext(&mastodon.Status{Content: test.content}); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.content, got, test.want)
+		}
+	}
+
+	long := mentionContext(&mastodon.Status{Content: "@altbot " + strings.Repeat("Mittens ", 100)})
+	if len([]rune(long)) > maxInlineContextChars {
+		t.Errorf("context of %d characters wasn't cut", len([]rune(long)))
+	}
+
+	config.Behavior.InlineContext = false
+	if got := mentionContext(&mastodon.Status{Content: "<p>@altbot this is my cat Mittens at the vet</p>"}); got != "" {
+		t.Errorf("inline_context off: got %q", got)
+	}
+}
+
+func TestUserContextReachesPrompt(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	config.ImageProcessing.MaxSizeMB = 10
+	provider := newStubProvider(stubResponse{text: "A cat on an examination table."}, stubResponse{text: "A cat."})
+	useProvider(t, provider)
+	media := mediaServer(t, "image/png", testPNG(t))
+
+	if _, _, err := generateImageAltText(media.URL+"/cat.png", "en", "", altTextOptions{UserContext: "my cat Mittens at the vet"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := generateImageAltText(media.URL+"/cat.png", "en", "", altTextOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(provider.prompts[0], "my cat Mittens at the vet") {
+		t.Errorf("context prompt: %q", provider.prompts[0])
+	}
+	if strings.Contains(provider.prompts[1], "Mittens") {
+		t.Errorf("plain prompt: %q", provider.prompts[1])
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"math/bits"
+	"os"
+	"strconv"
+)
+
+// KnownImage is an operator-curated caption for an image that is posted often, like a logo or a recurring meme
+type KnownImage struct {
+	Hash         string            `json:"hash"` // Perceptual hash, see "./altbot admin hash-image"
+	Caption      string            `json:"caption"`
+	Translations map[string]string `json:"translations,omitempty"`
+	hash         uint64
+}
+
+var knownImages []KnownImage
+
+// loadKnownImages loads the known image captions from the operator's mapping file
+func loadKnownImages(filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	var images []KnownImage
+	if err := json.Unmarshal(data, &images); err != nil {
+		return err
+	}
+
+	for i := range images {
+		hash, err := strconv.ParseUint(images[i].Hash, 16, 64)
+		if err != nil {
+			return fmt.Errorf("invalid hash %q: %v", images[i].Hash, err)
+		}
+		images[i].hash = hash
+	}
+
+	knownImages = images
+	return nil
+}
+
+// lookupKnownImage returns the curated caption of the closest known image within max_distance, if any
+func lookupKnownImage(imgData []byte, lang string) (string, bool) {
+	if !config.KnownImages.Enabled || len(knownImages) == 0 {
+		return "", false
+	}
+
+	img, _, err := decodeImage(imgData)
+	if err != nil {
+		return "", false
+	}
+	hash := perceptualHash(img)
+
+	var match *KnownImage
+	bestDistance := config.KnownImages.MaxDistance + 1
+	for i := range knownImages {
+		if distance := bits.OnesCount64(hash ^ knownImages[i].hash); distance < bestDistance {
+			match = &knownImages[i]
+			bestDistance = distance
+		}
+	}
+	if match == nil {
+		return "", false
+	}
+
+	if caption, ok := match.Translations[lang]; ok {
+		return caption, true
+	}
+	return match.Caption, true
+}
+
+// perceptualHash computes a 64-bit difference hash: the image is reduced to 9x8 grayscale cells and
+// each bit records whether a cell is brighter than its right neighbour. Resized or recompressed
+// copies of an image end up with the same or a very close hash.
+func perceptualHash(img image.Image) uint64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	var cells [8][9]float64
+	var counts [8][9]int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := (y - bounds.Min.Y) * 8 / height
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			col := (x - bounds.Min.X) * 9 / width
+			r, g, b, _ := img.At(x, y).RGBA()
+			cells[row][col] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			counts[row][col]++
+		}
+	}
+
+	var hash uint64
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			hash <<= 1
+			if cellAverage(cells[row][col], counts[row][col]) > cellAverage(cells[row][col+1], counts[row][col+1]) {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+func cellAverage(sum float64, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+// formatPerceptualHash formats a hash the way it is written in the known images file
+func formatPerceptualHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// minGuessLetters is how many letters a text needs before its language is guessed
+const minGuessLetters = 10
+
+// languageStopwords are common short words of the languages written in the Latin script
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "is", "of", "to", "this", "that", "it", "with", "for", "my", "you", "are", "was", "at"},
+	"es": {"el", "los", "las", "que", "y", "es", "un", "una", "por", "con", "para", "del", "mi", "muy", "esto", "pero"},
+	"fr": {"le", "les", "des", "et", "est", "une", "du", "pour", "dans", "avec", "je", "sur", "pas", "ce", "mon", "au"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "ich", "mit", "auf", "den", "zu", "von", "mein", "im"},
+	"it": {"il", "lo", "di", "che", "e", "è", "per", "con", "non", "sono", "della", "questo", "mio", "gli", "anche"},
+	"pt": {"o", "os", "que", "e", "é", "um", "uma", "não", "do", "da", "em", "com", "para", "meu", "minha", "isso"},
+	"pl": {"i", "w", "nie", "na", "to", "jest", "się", "z", "że", "do", "jak", "ale", "mój", "moja", "tak", "ten"},
+	"eu": {"eta", "da", "ez", "bat", "hau", "du", "dut", "nire", "baina", "ere", "oso", "zer", "dira", "naiz"},
+}
+
+// languageLetters are letters that are (nearly) only used by one of the languages, they count double
+var languageLetters = map[rune]string{
+	'ñ': "es", '¿': "es", '¡': "es",
+	'ç': "fr", 'œ': "fr", 'ê': "fr", 'è': "fr", 'û': "fr",
+	'ß': "de", 'ä': "de", 'ö': "de", 'ü': "de",
+	'ã': "pt", 'õ': "pt",
+	'ą': "pl", 'ę': "pl", 'ł': "pl", 'ś': "pl", 'ż': "pl", 'ź': "pl", 'ć': "pl", 'ń': "pl",
+}
+
+// guessLanguage guesses the language of a text from its script, or for the Latin script from its
+// common words and letters. It returns "" when the text is too short or the guess is too close to call.
+func guessLanguage(text string) string {
+	var letters, latin, cyrillic, kana, hangul, han int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	// Chinese and Japanese need far fewer characters to say as much
+	switch {
+	case kana > 0 && kana+han >= letters/2:
+		return "ja"
+	case hangul > 0 && hangul >= letters/2:
+		return "ko"
+	case han > 0 && han >= letters/2:
+		return "zh"
+	case letters < minGuessLetters:
+		return ""
+	case cyrillic >= letters/2:
+		lower := strings.ToLower(text)
+		if strings.ContainsAny(lower, "ўі") {
+			return "be"
+		}
+		return "ru"
+	case latin >= letters/2:
+		return guessLatinLanguage(text)
+	}
+	return ""
+}
+
+// guessLatinLanguage scores the languages written in the Latin script by their words and letters in the text
+func guessLatinLanguage(text string) string {
+	scores := make(map[string]int)
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, word := range words {
+		for lang, stopwords := range languageStopwords {
+			for _, stopword := range stopwords {
+				if word == stopword {
+					scores[lang]++
+				}
+			}
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		if lang, ok := languageLetters[r]; ok {
+			scores[lang] += 2
+		}
+	}
+
+	best, bestScore, runnerUp := "", 0, 0
+	for lang, score := range scores {
+		if score > bestScore {
+			best, bestScore, runnerUp = lang, score, bestScore
+		} else if score > runnerUp {
+			runnerUp = score
+		}
+	}
+
+	// A guess needs a couple of hits and a clear lead over the next language
+	if bestScore < 2 || bestScore <= runnerUp {
+		return ""
+	}
+	return best
+}
+
+// fillStatusLanguage sets the language of a status that has none to the one guessed from its text, or
+// failing that from the text of the other statuses given (like the post a mention replies to)
+func fillStatusLanguage(status *mastodon.Status, others ...*mastodon.Status) {
+	if status.Language != "" {
+		return
+	}
+
+	for _, s := range append([]*mastodon.Status{status}, others...) {
+		text := mentionPattern.ReplaceAllString(stripHTMLTags(s.Content), " ")
+		if lang := guessLanguage(text); lang != "" {
+			status.Language = lang
+			return
+		}
+	}
+}
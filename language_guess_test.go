@@ -0,0 +1,97 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestGuessLanguage(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Mi perro durmiendo en el sofá, ¿no es muy bonito?", "es"},
+		{"Esta es la vista desde mi ventana por la mañana", "es"},
+		{"うちの猫が窓の外を見ています", "ja"},
+		{"今日は富士山がよく見えました", "ja"},
+		{"Look at the sunset from my balcony, it was beautiful", "en"},
+		{"Der Hund schläft auf dem Sofa und ist müde", "de"},
+		// Too short, or not clear enough to guess
+		{"¡Hola!", ""},
+		{"Bonjour hello hola", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := guessLanguage(test.text); got != test.want {
+			t.Errorf("guessLanguage(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestFillStatusLanguage(t *testing.T) {
+	// A declared language is kept
+	declared := &mastodon.Status{Language: "fr", Content: "<p>Mi perro durmiendo en el sofá</p>"}
+	fillStatusLanguage(declared)
+	if declared.Language != "fr" {
+		t.Errorf("declared language replaced by %q", declared.Language)
+	}
+
+	// The mention itself says too little, the post it replies to is in Spanish
+	mention := &mastodon.Status{Content: `<p><span class="h-card"><a href="https://example.com/@altbot">@altbot</a></span> ¿puedes?</p>`}
+	original := &mastodon.Status{Content: "<p>Esta es la vista desde mi ventana por la mañana</p>"}
+	fillStatusLanguage(mention, original)
+	if mention.Language != "es" {
+		t.Errorf("got %q from the original post, want es", mention.Language)
+	}
+
+	japanese := &mastodon.Status{Content: "<p>@altbot うちの猫が窓の外を見ています</p>"}
+	fillStatusLanguage(japanese, original)
+	if japanese.Language != "ja" {
+		t.Errorf("got %q, want the mention's own ja", japanese.Language)
+	}
+}
+
+func TestReplyUsesGuessedLanguage(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	useBotState(t)
+	config.ImageProcessing.MaxSizeMB = 10
+	useProvider(t, newStubProvider(stubResponse{text: "Un perro durmiendo en un sofá."}))
+	media := mediaServer(t, "image/png", testPNG(t))
+
+	posts := make(chan url.Values, 1)
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			r.ParseForm()
+			posts <- r.Form
+			io.WriteString(w, `{"id":"3"}`)
+			return
+		}
+		// The mention has no language set
+		io.WriteString(w, `{"id":"2","visibility":"public","language":"","content":"<p>@altbot</p>","account":{"id":"10","acct":"alice"}}`)
+	})
+
+	status := &mastodon.Status{
+		ID:               "1",
+		Content:          "<p>Mi perro durmiendo en el sofá, ¿no es muy bonito?</p>",
+		Account:          mastodon.Account{ID: "20", Acct: "bob"},
+		MediaAttachments: []mastodon.Attachment{{ID: "m1", Type: "image", URL: media.URL + "/perro.png"}},
+	}
+	generateAndPostAltText(c, status, "2", altTextOptions{})
+
+	if len(posts) != 1 {
+		t.Fatal("nothing was posted")
+	}
+	if form := <-posts; form.Get("language") != "es" {
+		t.Errorf("reply posted in %q, want es", form.Get("language"))
+	}
+}
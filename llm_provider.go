@@ -6,14 +6,20 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"mime"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	genai "google.golang.org/genai"
@@ -24,9 +30,37 @@ import (
 type LLMProvider interface {
 	GenerateAltText(prompt string, imageData []byte, format string, targetLanguage string) (string, error)
 	GenerateVideoAltText(prompt string, videoData []byte, format string, targetLanguage string) (string, error)
+	GenerateAudioAltText(prompt string, audioData []byte, format string, targetLanguage string) (string, error)
+	CategorizeImage(imageData []byte, format string) (string, error)
+	GenerateMultiImageAltText(prompt string, images [][]byte, formats []string, targetLanguage string) (string, error)
+	Ping(ctx context.Context) error
+	Capabilities() ProviderCapabilities
+	WithTemperatureBoost(boost float32) LLMProvider
 	Close() error
 }
 
+// ProviderCapabilities lists what a provider can describe
+type ProviderCapabilities struct {
+	Image      bool
+	Video      bool
+	Audio      bool
+	MultiImage bool // Several images in one prompt, for combined captions and animated GIFs
+	Context    bool // Text-only prompts, such as translations and audio transcripts
+}
+
+// canDescribe reports whether media of a Mastodon attachment type can be described
+func (c ProviderCapabilities) canDescribe(attachmentType string) bool {
+	switch attachmentType {
+	case "image":
+		return c.Image
+	case "video", "gifv":
+		return c.Video
+	case "audio":
+		return c.Audio
+	}
+	return false
+}
+
 // GeminiProvider implements LLMProvider for Google's Gemini
 type GeminiProvider struct {
 	client           *genai.Client
@@ -40,6 +74,9 @@ type OllamaProvider struct {
 	keepAlive            string
 	translationModel     string
 	translationKeepAlive string
+	serverURL            string
+	timeout              time.Duration
+	temperature          float32 // 0 uses the model's default
 }
 
 // TransformersProvider implements LLMProvider for Hugging Face Transformers
@@ -50,18 +87,40 @@ type TransformersProvider struct {
 	serverProcess *os.Process
 	monitoring    bool
 	stopMonitor   chan bool
+	temperature   float32 // 0 decodes greedily
 }
 
 // OpenAIProvider implements LLMProvider for OpenAI and compatibles
 type OpenAIProvider struct {
     client   *openai.Client
     model    string
-    baseURL  string
+    baseURL     string
+    timeout     time.Duration
+    temperature float32 // 0 uses the API's default
 }
 
-// NewLLMProvider creates a new LLM provider based on the configuration
+// NewLLMProvider creates a new LLM provider based on the configuration, chained with the
+// fallback providers when there are any
 func NewLLMProvider(config Config) (LLMProvider, error) {
-	switch config.LLM.Provider {
+	provider, err := newNamedProvider(config, config.LLM.Provider)
+	if err != nil || len(config.LLM.FallbackProviders) == 0 {
+		return provider, err
+	}
+
+	providers := []namedProvider{{name: config.LLM.Provider, provider: provider}}
+	for _, name := range config.LLM.FallbackProviders {
+		fallback, err := newNamedProvider(config, name)
+		if err != nil {
+			return nil, fmt.Errorf("fallback provider %s: %v", name, err)
+		}
+		providers = append(providers, namedProvider{name: name, provider: fallback})
+	}
+	return newCompositeProvider(providers), nil
+}
+
+// newNamedProvider sets up a single provider by its name in the configuration
+func newNamedProvider(config Config, name string) (LLMProvider, error) {
+	switch name {
 	case "gemini":
 		return setupGeminiProvider(config)
 	case "ollama":
@@ -71,7 +130,7 @@ func NewLLMProvider(config Config) (LLMProvider, error) {
 	case "openai":
 		return setupOpenAIProvider(config)
 	default:
-		return nil, fmt.Errorf("unsupported LLM provider: %s", config.LLM.Provider)
+		return nil, fmt.Errorf("unsupported LLM provider: %s", name)
 	}
 }
 
@@ -90,13 +149,15 @@ func setupGeminiProvider(config Config) (*GeminiProvider, error) {
 		return nil, err
 	}
 
+	generationConfig, err := newGeminiGenerationConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
 	provider := &GeminiProvider{
-		client:    geminiClient,
-		modelName: config.Gemini.Model,
-		generationConfig: &genai.GenerateContentConfig{
-			Temperature: genai.Ptr(config.Gemini.Temperature),
-			TopK:        genai.Ptr(float32(config.Gemini.TopK)),
-		},
+		client:           geminiClient,
+		modelName:        config.Gemini.Model,
+		generationConfig: generationConfig,
 	}
 
 	client = provider.client
@@ -138,7 +199,17 @@ func setupOllamaProvider(config Config) (*OllamaProvider, error) {
 			return nil, fmt.Errorf("ollama translation model %s not found. Install it with: ollama pull %s",
 				translationModel, translationModel)
 		}
-		fmt.Printf("Using separate translation model: %s\n", translationModel)
+		logInfof("Using separate translation model: %s", translationModel)
+	}
+
+	serverURL := strings.TrimSuffix(config.LLM.OllamaURL, "/")
+	if serverURL == "" {
+		serverURL = "http://localhost:11434"
+	}
+
+	timeout := time.Duration(config.LLM.OllamaTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
 	}
 
 	provider := &OllamaProvider{
@@ -146,27 +217,29 @@ func setupOllamaProvider(config Config) (*OllamaProvider, error) {
 		keepAlive:            keepAlive,
 		translationModel:     translationModel,
 		translationKeepAlive: translationKeepAlive,
+		serverURL:            serverURL,
+		timeout:              timeout,
 	}
 
 	// If persistent serving is enabled, pre-load the model
 	if keepAlive == "-1" {
-		fmt.Println("Pre-loading Ollama model for persistent serving...")
+		logInfof("Pre-loading Ollama model for persistent serving...")
 		cmd := exec.Command("ollama", "run", provider.model, "--keepalive", keepAlive, "echo", "Model loaded")
 		if err := cmd.Run(); err != nil {
-			fmt.Printf("Warning: Failed to pre-load model: %v\n", err)
+			logWarnf("Failed to pre-load model: %v", err)
 		} else {
-			fmt.Println("Ollama model loaded and will remain in RAM")
+			logInfof("Ollama model loaded and will remain in RAM")
 		}
 	}
 
 	// Pre-load translation model if different and persistent serving is enabled
 	if translationModel != "" && translationModel != config.LLM.OllamaModel && translationKeepAlive == "-1" {
-		fmt.Println("Pre-loading Ollama translation model for persistent serving...")
+		logInfof("Pre-loading Ollama translation model for persistent serving...")
 		cmd := exec.Command("ollama", "run", translationModel, "--keepalive", translationKeepAlive, "echo", "Model loaded")
 		if err := cmd.Run(); err != nil {
-			fmt.Printf("Warning: Failed to pre-load translation model: %v\n", err)
+			logWarnf("Failed to pre-load translation model: %v", err)
 		} else {
-			fmt.Println("Ollama translation model loaded and will remain in RAM")
+			logInfof("Ollama translation model loaded and will remain in RAM")
 		}
 	}
 
@@ -174,8 +247,8 @@ func setupOllamaProvider(config Config) (*OllamaProvider, error) {
 }
 
 func setupOpenAIProvider(config Config) (*OpenAIProvider, error) {
-    // Validate required fields
-    if config.Openai.APIKey == "" {
+    // Local gateways often don't check the key, OpenAI itself does
+    if config.Openai.APIKey == "" && config.Openai.BaseURL == "" {
         return nil, fmt.Errorf("OpenAI API key is required for OpenAI provider")
     }
 
@@ -193,6 +266,11 @@ func setupOpenAIProvider(config Config) (*OpenAIProvider, error) {
         model = config.Openai.Model
     }
 
+    timeout := time.Duration(config.Openai.TimeoutSeconds) * time.Second
+    if timeout <= 0 {
+        timeout = 5 * time.Minute
+    }
+
     // Create client
     client := openai.NewClientWithConfig(openaiConfig)
 
@@ -200,6 +278,7 @@ func setupOpenAIProvider(config Config) (*OpenAIProvider, error) {
         client:   client,
 		model:    model,
 		baseURL:  openaiConfig.BaseURL,
+		timeout:  timeout,
     }
 
     return provider, nil
@@ -226,7 +305,7 @@ func (p *GeminiProvider) GenerateAltText(prompt string, imageData []byte, format
 
 func (p *GeminiProvider) GenerateVideoAltText(prompt string, videoData []byte, format string, targetLanguage string) (string, error) {
 	// Create a temporary file for the video
-	tmpFile, err := os.CreateTemp("", "video-*."+format)
+	tmpFile, err := createTempFile("video-*." + format)
 	if err != nil {
 		return "", fmt.Errorf("failed to create temp file: %v", err)
 	}
@@ -260,36 +339,197 @@ func (p *GeminiProvider) generateContent(parts []*genai.Part) (*genai.GenerateCo
 func (p *OllamaProvider) GenerateAltText(prompt string, imageData []byte, format string, targetLanguage string) (string, error) {
 	if config.LLM.UseTranslationLayer && targetLanguage != "en" {
 		// Use translation layer
-		translationLayer := NewTranslationLayer(p)
+		translationLayer := NewTranslationLayer(p, "ollama")
 		return translationLayer.GenerateAndTranslateAltText(prompt, imageData, format, targetLanguage)
 	}
 
-	// Create a temporary file for the image
-	tmpFile, err := os.CreateTemp("", "image.*."+format)
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	return p.GenerateAltTextStream(ctx, prompt, imageData, nil)
+}
+
+// GenerateAltTextStream generates alt-text through the Ollama HTTP API, calling onToken (if set)
+// with every chunk as it arrives. Generation is aborted as soon as ctx is done.
+func (p *OllamaProvider) GenerateAltTextStream(ctx context.Context, prompt string, imageData []byte, onToken func(string)) (string, error) {
+	return p.generate(ctx, p.model, p.keepAlive, prompt, [][]byte{imageData}, onToken)
+}
+
+// generate streams a completion from the Ollama /api/generate endpoint and assembles the tokens
+func (p *OllamaProvider) generate(ctx context.Context, model string, keepAlive string, prompt string, images [][]byte, onToken func(string)) (string, error) {
+	payload := map[string]interface{}{
+		"model":      model,
+		"prompt":     prompt,
+		"stream":     true,
+		"keep_alive": ollamaKeepAlive(keepAlive),
+		// Reasoning models would otherwise think before every caption, which only costs time here
+		"think": false,
+	}
+	if p.temperature > 0 {
+		payload["options"] = map[string]interface{}{"temperature": p.temperature}
+	}
+	if len(images) > 0 {
+		encoded := make([]string, len(images))
+		for i, image := range images {
+			encoded[i] = base64.StdEncoding.EncodeToString(image)
+		}
+		payload["images"] = encoded
+	}
+
+	body, err := json.Marshal(payload)
 	if err != nil {
 		return "", err
 	}
-	defer os.Remove(tmpFile.Name())
 
-	if _, err := tmpFile.Write(imageData); err != nil {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.serverURL+"/api/generate", bytes.NewReader(body))
+	if err != nil {
 		return "", err
 	}
-	if err := tmpFile.Close(); err != nil {
-		return "", err
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error calling Ollama: %w", err)
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorBody, _ := io.ReadAll(resp.Body)
+		return "", &providerStatusError{StatusCode: resp.StatusCode, message: fmt.Sprintf("ollama returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(errorBody)))}
+	}
+
+	// The response is one JSON object per line
+	var result strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var chunk struct {
+			Response string `json:"response"`
+			Done     bool   `json:"done"`
+			Error    string `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			return "", fmt.Errorf("error decoding Ollama response: %v", err)
+		}
+		if chunk.Error != "" {
+			return "", fmt.Errorf("ollama error: %s", chunk.Error)
+		}
 
-	// Prepare the Ollama command
-	cmd := exec.Command("ollama", "run", p.model, "--hidethinking", "--keepalive", p.keepAlive, fmt.Sprintf("%s %s", prompt, tmpFile.Name()))
+		result.WriteString(chunk.Response)
+		if onToken != nil && chunk.Response != "" {
+			onToken(chunk.Response)
+		}
 
-	var out bytes.Buffer
-	cmd.Stdout = &out
+		if chunk.Done {
+			return stripThinking(result.String()), nil
+		}
+	}
 
-	err = cmd.Run()
-	if err != nil {
+	// Reading fails with the context's error when the generation was cancelled or timed out
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading Ollama response: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
 		return "", err
 	}
+	return "", fmt.Errorf("ollama response ended before generation finished")
+}
+
+// stripThinking removes the <think> block that Ollama versions without the "think" option pass through
+// from reasoning models
+func stripThinking(response string) string {
+	trimmed := strings.TrimSpace(response)
+	if !strings.HasPrefix(trimmed, "<think>") {
+		return response
+	}
+	if end := strings.Index(trimmed, "</think>"); end >= 0 {
+		return strings.TrimSpace(trimmed[end+len("</think>"):])
+	}
+	return response
+}
+
+// withLLMRetry runs an LLM call, retrying transient failures up to max_retries times with
+// exponential backoff (retry_base_delay, doubled each attempt) and jitter. It's run inside the
+// generateWith callback, so each provider of a fallback chain is retried before the next one is tried.
+func withLLMRetry(generate func() (string, error)) (string, error) {
+	baseDelay := time.Second
+	if config.LLM.RetryBaseDelay != "" {
+		if delay, err := time.ParseDuration(config.LLM.RetryBaseDelay); err == nil && delay > 0 {
+			baseDelay = delay
+		}
+	}
+
+	result, err := generate()
+	for attempt := 0; err != nil && attempt < config.LLM.MaxRetries && isTransientLLMError(err); attempt++ {
+		delay := baseDelay << attempt
+		delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+		logWarnf("Transient LLM error, retrying in %v (%d/%d): %v", delay.Round(time.Millisecond), attempt+1, config.LLM.MaxRetries, err)
+		time.Sleep(delay)
 
-	return out.String(), nil
+		result, err = generate()
+	}
+
+	return result, err
+}
+
+// transientStatusCodes are the HTTP statuses of timeouts, rate limits and overloaded or unreachable servers
+var transientStatusCodes = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// providerStatusError is a response other than 200 OK from the HTTP API of a local provider
+type providerStatusError struct {
+	StatusCode int
+	message    string
+}
+
+func (e *providerStatusError) Error() string {
+	return e.message
+}
+
+// isTransientLLMError reports whether an LLM error is worth retrying: rate limits, server errors and dropped or
+// refused connections. Validation errors like unsupported formats fail the same way every time, and a generation
+// that ran out of time would only do so again, so those are returned right away.
+func isTransientLLMError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *providerStatusError
+	var geminiErr genai.APIError
+	var openAIErr *openai.APIError
+	var openAIRequestErr *openai.RequestError
+	switch {
+	case errors.As(err, &statusErr):
+		return transientStatusCodes[statusErr.StatusCode]
+	case errors.As(err, &geminiErr):
+		return transientStatusCodes[geminiErr.Code]
+	case errors.As(err, &openAIErr):
+		return transientStatusCodes[openAIErr.HTTPStatusCode]
+	case errors.As(err, &openAIRequestErr):
+		return transientStatusCodes[openAIRequestErr.HTTPStatusCode]
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// ollamaKeepAlive converts the keep-alive setting to what the Ollama API expects:
+// plain numbers like "-1" or "0" are seconds, anything else is a duration string
+func ollamaKeepAlive(keepAlive string) interface{} {
+	if seconds, err := strconv.Atoi(keepAlive); err == nil {
+		return seconds
+	}
+	return keepAlive
 }
 
 func (p *OllamaProvider) GenerateVideoAltText(prompt string, videoData []byte, format string, targetLanguage string) (string, error) {
@@ -322,23 +562,54 @@ func (p *OpenAIProvider) GenerateAltText(prompt string, imageData []byte, format
         },
     }
 
-    // Create request
-    req := openai.ChatCompletionRequest{
-        Model:    p.model,
-        Messages: messages,
-    }
+    ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+    defer cancel()
 
-    // Call OpenAI API
-    resp, err := p.client.CreateChatCompletion(ctx, req)
-    if err != nil {
-        return "", fmt.Errorf("error calling OpenAI API: %v", err)
-    }
+    return p.complete(ctx, messages, nil)
+}
 
-    if len(resp.Choices) == 0 {
-        return "", fmt.Errorf("no choices in response")
-    }
+// complete streams a chat completion from the API and assembles the tokens, calling onToken (if set)
+// with every chunk as it arrives. When ctx is done the text received so far is returned with its error.
+func (p *OpenAIProvider) complete(ctx context.Context, messages []openai.ChatCompletionMessage, onToken func(string)) (string, error) {
+	stream, err := p.client.CreateChatCompletionStream(ctx, openai.ChatCompletionRequest{
+		Model:       p.model,
+		Messages:    messages,
+		Stream:      true,
+		Temperature: p.temperature,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error calling OpenAI API: %w", err)
+	}
+	defer stream.Close()
+
+	var result strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return result.String(), ctxErr
+			}
+			return "", fmt.Errorf("error reading OpenAI response: %w", err)
+		}
+
+		// Some gateways send chunks without choices, e.g. for usage statistics
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		token := chunk.Choices[0].Delta.Content
+		result.WriteString(token)
+		if onToken != nil && token != "" {
+			onToken(token)
+		}
+	}
 
-    return resp.Choices[0].Message.Content, nil
+	if result.Len() == 0 {
+		return "", fmt.Errorf("no content in response")
+	}
+	return result.String(), nil
 }
 
 // GenerateVideoAltText for OpenAI compatible provider
@@ -347,10 +618,147 @@ func (p *OpenAIProvider) GenerateVideoAltText(prompt string, videoData []byte, f
     return "", fmt.Errorf("video processing not yet supported by OpenAI compatible provider")
 }
 
+func (p *GeminiProvider) GenerateAudioAltText(prompt string, audioData []byte, format string, targetLanguage string) (string, error) {
+	// Audio is uploaded to Gemini from a file, the extension gives its MIME type
+	tmpFile, err := createTempFile("audio-*." + format)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(audioData)
+	tmpFile.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to write audio to temp file: %v", err)
+	}
+
+	return GenerateAudioAltWithGemini(prompt, tmpFile.Name())
+}
+
+func (p *OllamaProvider) GenerateAudioAltText(prompt string, audioData []byte, format string, targetLanguage string) (string, error) {
+	return "", fmt.Errorf("audio processing not supported by Ollama provider")
+}
+
+func (p *OpenAIProvider) GenerateAudioAltText(prompt string, audioData []byte, format string, targetLanguage string) (string, error) {
+	return "", fmt.Errorf("audio processing not yet supported by OpenAI compatible provider")
+}
+
+// GenerateAudioAltText transcribes the audio with the server's speech recognition model,
+// then has the model describe the audio from the transcript
+func (p *TransformersProvider) GenerateAudioAltText(prompt string, audioData []byte, format string, targetLanguage string) (string, error) {
+	if p.Config.TransformersServerArgs.WhisperModel == "" {
+		return "", fmt.Errorf("audio processing needs a whisper_model for the Transformers provider")
+	}
+
+	transcript, err := p.transcribe(audioData, format)
+	if err != nil {
+		return "", fmt.Errorf("error transcribing audio: %w", err)
+	}
+	if strings.TrimSpace(transcript) == "" {
+		transcript = "(no speech detected)"
+	}
+
+	return p.generateText(fmt.Sprintf("%s\n\nTranscript of the audio:\n%s", prompt, transcript))
+}
+
+// generateText runs a text-only prompt through the Transformers model
+func (p *TransformersProvider) generateText(prompt string) (string, error) {
+	// Prepare the request payload for text-only input
+	payload := map[string]interface{}{
+		"model": p.Model,
+		"messages": []map[string]interface{}{
+			{
+				"role": "user",
+				"content": []map[string]interface{}{
+					{
+						"type": "text",
+						"text": prompt,
+					},
+				},
+			},
+		},
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling JSON: %v", err)
+	}
+
+	fullURL := fmt.Sprintf("%s/v1/chat/completions", p.ServerURL)
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	resp, err := client.Post(fullURL, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("error making request to server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &providerStatusError{StatusCode: resp.StatusCode, message: fmt.Sprintf("server returned status %d: %s", resp.StatusCode, string(body))}
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("error parsing JSON response: %s", string(body))
+	}
+
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("no choices in response: %s", string(body))
+	}
+
+	return result.Choices[0].Message.Content, nil
+}
+
+// transcribe sends audio to the server's speech recognition route
+func (p *TransformersProvider) transcribe(audioData []byte, format string) (string, error) {
+	jsonData, err := json.Marshal(map[string]string{
+		"audio":  base64.StdEncoding.EncodeToString(audioData),
+		"format": format,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error marshaling JSON: %v", err)
+	}
+
+	client := &http.Client{Timeout: 120 * time.Second}
+	resp, err := client.Post(p.ServerURL+"/v1/audio/transcriptions", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("error making request to server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &providerStatusError{StatusCode: resp.StatusCode, message: fmt.Sprintf("server returned status %d: %s", resp.StatusCode, string(body))}
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("error parsing JSON response: %s", string(body))
+	}
+	return result.Text, nil
+}
+
 func (p *TransformersProvider) GenerateAltText(prompt string, imageData []byte, format string, targetLanguage string) (string, error) {
 	if config.LLM.UseTranslationLayer && targetLanguage != "en" {
 		// Use translation layer
-		translationLayer := NewTranslationLayer(p)
+		translationLayer := NewTranslationLayer(p, "transformers")
 		return translationLayer.GenerateAndTranslateAltText(prompt, imageData, format, targetLanguage)
 	}
 
@@ -378,6 +786,9 @@ func (p *TransformersProvider) GenerateAltText(prompt string, imageData []byte,
 			},
 		},
 	}
+	if p.temperature > 0 {
+		payload["temperature"] = p.temperature
+	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
@@ -398,19 +809,19 @@ func (p *TransformersProvider) GenerateAltText(prompt string, imageData []byte,
 		bytes.NewBuffer(jsonData),
 	)
 	if err != nil {
-		return "", fmt.Errorf("error making request to server: %v", err)
+		return "", fmt.Errorf("error making request to server: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read the entire response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("error reading response body: %v", err)
+		return "", fmt.Errorf("error reading response body: %w", err)
 	}
 
 	// Check if response is successful
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
+		return "", &providerStatusError{StatusCode: resp.StatusCode, message: fmt.Sprintf("server returned status %d: %s", resp.StatusCode, string(body))}
 	}
 
 	// Try to parse as JSON
@@ -438,7 +849,7 @@ func (p *TransformersProvider) GenerateAltText(prompt string, imageData []byte,
 func (p *TransformersProvider) GenerateVideoAltText(prompt string, videoData []byte, format string, targetLanguage string) (string, error) {
 	if config.LLM.UseTranslationLayer && targetLanguage != "en" {
 		// Use translation layer
-		translationLayer := NewTranslationLayer(p)
+		translationLayer := NewTranslationLayer(p, "transformers")
 		return translationLayer.GenerateAndTranslateVideoAltText(prompt, videoData, format, targetLanguage)
 	}
 
@@ -494,19 +905,19 @@ func (p *TransformersProvider) GenerateVideoAltText(prompt string, videoData []b
 		bytes.NewBuffer(jsonData),
 	)
 	if err != nil {
-		return "", fmt.Errorf("error making request to server: %v", err)
+		return "", fmt.Errorf("error making request to server: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read the entire response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("error reading response body: %v", err)
+		return "", fmt.Errorf("error reading response body: %w", err)
 	}
 
 	// Check if response is successful
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
+		return "", &providerStatusError{StatusCode: resp.StatusCode, message: fmt.Sprintf("server returned status %d: %s", resp.StatusCode, string(body))}
 	}
 
 	// Parse JSON response
@@ -529,6 +940,244 @@ func (p *TransformersProvider) GenerateVideoAltText(prompt string, videoData []b
 	return result.Choices[0].Message.Content, nil
 }
 
+// GenerateMultiImageAltText sends several images with a single prompt, the prompt asks for one description per image
+func (p *GeminiProvider) GenerateMultiImageAltText(prompt string, images [][]byte, formats []string, targetLanguage string) (string, error) {
+	parts := []*genai.Part{{Text: prompt}}
+	for i, imageData := range images {
+		mimeType, err := inferImageMIME(formats[i])
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, &genai.Part{InlineData: &genai.Blob{Data: imageData, MIMEType: mimeType}})
+	}
+
+	resp, err := p.generateContent(parts)
+	if err != nil {
+		return "", err
+	}
+
+	return getResponse(resp), nil
+}
+
+func (p *OllamaProvider) GenerateMultiImageAltText(prompt string, images [][]byte, formats []string, targetLanguage string) (string, error) {
+	if config.LLM.UseTranslationLayer && targetLanguage != "en" {
+		return "", fmt.Errorf("combined multi-image prompts are not supported with the translation layer")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	return p.generate(ctx, p.model, p.keepAlive, prompt, images, nil)
+}
+
+func (p *OpenAIProvider) GenerateMultiImageAltText(prompt string, images [][]byte, formats []string, targetLanguage string) (string, error) {
+	parts := []openai.ChatMessagePart{{Type: openai.ChatMessagePartTypeText, Text: prompt}}
+	for i, imageData := range images {
+		parts = append(parts, openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{
+				URL: fmt.Sprintf("data:image/%s;base64,%s", formats[i], base64.StdEncoding.EncodeToString(imageData)),
+			},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	return p.complete(ctx, []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, MultiContent: parts}}, nil)
+}
+
+func (p *TransformersProvider) GenerateMultiImageAltText(prompt string, images [][]byte, formats []string, targetLanguage string) (string, error) {
+	return "", fmt.Errorf("combined multi-image prompts are not supported by the Transformers provider")
+}
+
+func (p *GeminiProvider) CategorizeImage(imageData []byte, format string) (string, error) {
+	return categorizeWithProvider(p, imageData, format)
+}
+
+func (p *OllamaProvider) CategorizeImage(imageData []byte, format string) (string, error) {
+	return categorizeWithProvider(p, imageData, format)
+}
+
+func (p *OpenAIProvider) CategorizeImage(imageData []byte, format string) (string, error) {
+	return categorizeWithProvider(p, imageData, format)
+}
+
+func (p *TransformersProvider) CategorizeImage(imageData []byte, format string) (string, error) {
+	return categorizeWithProvider(p, imageData, format)
+}
+
+// providerPingInterval is how long a provider check is reused, so health checks don't reach the provider on every request
+const providerPingInterval = 30 * time.Second
+
+var (
+	providerPingMu  sync.Mutex
+	providerPingAt  time.Time
+	providerPingErr error
+)
+
+// pingLLMProvider checks that the configured provider is reachable, reusing a recent result
+func pingLLMProvider() error {
+	providerPingMu.Lock()
+	defer providerPingMu.Unlock()
+
+	if !providerPingAt.IsZero() && time.Since(providerPingAt) < providerPingInterval {
+		return providerPingErr
+	}
+
+	baseCtx := ctx
+	if baseCtx == nil {
+		baseCtx = context.Background()
+	}
+	pingCtx, cancel := context.WithTimeout(baseCtx, 10*time.Second)
+	defer cancel()
+
+	providerPingErr = llmProvider.Ping(pingCtx)
+	providerPingAt = time.Now()
+	return providerPingErr
+}
+
+// Ping checks that the model can be reached without generating anything
+func (p *GeminiProvider) Ping(ctx context.Context) error {
+	if _, err := p.client.Models.Get(ctx, p.modelName, nil); err != nil {
+		return fmt.Errorf("gemini model %s unavailable: %v", p.modelName, err)
+	}
+	return nil
+}
+
+// Ping checks that the Ollama server is up and still has the model
+func (p *OllamaProvider) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.serverURL+"/api/tags", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ollama server unreachable: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama server returned status %d", resp.StatusCode)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return fmt.Errorf("error decoding ollama model list: %v", err)
+	}
+	for _, model := range tags.Models {
+		if model.Name == p.model || strings.TrimSuffix(model.Name, ":latest") == p.model {
+			return nil
+		}
+	}
+	return fmt.Errorf("ollama model %s not found", p.model)
+}
+
+// Ping lists the models, which checks both the server and the API key
+func (p *OpenAIProvider) Ping(ctx context.Context) error {
+	if _, err := p.client.ListModels(ctx); err != nil {
+		return fmt.Errorf("openai server unavailable: %v", err)
+	}
+	return nil
+}
+
+// Ping checks the Transformers server's health endpoint
+func (p *TransformersProvider) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.ServerURL+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("transformers server unreachable: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("transformers server returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (p *GeminiProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{Image: true, Video: true, Audio: true, MultiImage: true, Context: true}
+}
+
+func (p *OllamaProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{Image: true, MultiImage: true, Context: true}
+}
+
+func (p *OpenAIProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{Image: true, MultiImage: true, Context: true}
+}
+
+// Capabilities of the Transformers provider, video is described from extracted frames
+// and audio from a transcript when a speech recognition model is set
+func (p *TransformersProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{
+		Image:   true,
+		Video:   true,
+		Audio:   p.Config.TransformersServerArgs.WhisperModel != "",
+		Context: true,
+	}
+}
+
+// Defaults the temperature boosts of WithTemperatureBoost start from, when no temperature is configured
+const (
+	ollamaDefaultTemperature = 0.8
+	openaiDefaultTemperature = 1.0
+	// The Transformers server decodes greedily, boosted generations sample from this temperature instead
+	transformersSampleTemperature = 0.7
+)
+
+// WithTemperatureBoost returns a copy of the provider that samples with a higher temperature,
+// used when a user asks for another attempt at a caption
+func (p *GeminiProvider) WithTemperatureBoost(boost float32) LLMProvider {
+	boosted := *p
+	boosted.generationConfig = cloneGenerateContentConfig(p.generationConfig)
+	if boosted.generationConfig == nil {
+		boosted.generationConfig = &genai.GenerateContentConfig{}
+	}
+	temperature := float32(1.0)
+	if boosted.generationConfig.Temperature != nil {
+		temperature = *boosted.generationConfig.Temperature
+	}
+	boosted.generationConfig.Temperature = genai.Ptr(min(temperature+boost, 2))
+	return &boosted
+}
+
+func (p *OllamaProvider) WithTemperatureBoost(boost float32) LLMProvider {
+	boosted := *p
+	if boosted.temperature <= 0 {
+		boosted.temperature = ollamaDefaultTemperature
+	}
+	boosted.temperature += boost
+	return &boosted
+}
+
+func (p *OpenAIProvider) WithTemperatureBoost(boost float32) LLMProvider {
+	boosted := *p
+	if boosted.temperature <= 0 {
+		boosted.temperature = openaiDefaultTemperature
+	}
+	boosted.temperature = min(boosted.temperature+boost, 2)
+	return &boosted
+}
+
+// The copy shares the server process of the provider and must not be closed
+func (p *TransformersProvider) WithTemperatureBoost(boost float32) LLMProvider {
+	boosted := *p
+	if boosted.temperature <= 0 {
+		boosted.temperature = transformersSampleTemperature
+	}
+	boosted.temperature += boost
+	return &boosted
+}
+
 // Close implementations for each provider
 func (p *GeminiProvider) Close() error {
 	return nil
@@ -624,9 +1273,74 @@ func cloneGenerateContentConfig(cfg *genai.GenerateContentConfig) *genai.Generat
 		return nil
 	}
 	clone := *cfg
+	clone.SafetySettings = slices.Clone(cfg.SafetySettings)
 	return &clone
 }
 
+// geminiHarmThresholds maps the [gemini] *_threshold values to the lowest harm probability Gemini blocks
+var geminiHarmThresholds = map[string]genai.HarmBlockThreshold{
+	"none":   genai.HarmBlockThresholdBlockNone,
+	"low":    genai.HarmBlockThresholdBlockLowAndAbove,
+	"medium": genai.HarmBlockThresholdBlockMediumAndAbove,
+	"high":   genai.HarmBlockThresholdBlockOnlyHigh,
+}
+
+// geminiSafetySettings turns the configured harm thresholds into Gemini safety settings.
+// Categories left empty keep Gemini's default threshold.
+func geminiSafetySettings(config Config) ([]*genai.SafetySetting, error) {
+	thresholds := []struct {
+		category genai.HarmCategory
+		key      string
+		value    string
+	}{
+		{genai.HarmCategoryHarassment, "harassment_threshold", config.Gemini.HarassmentThreshold},
+		{genai.HarmCategoryHateSpeech, "hate_speech_threshold", config.Gemini.HateSpeechThreshold},
+		{genai.HarmCategorySexuallyExplicit, "sexually_explicit_threshold", config.Gemini.SexuallyExplicitThreshold},
+		{genai.HarmCategoryDangerousContent, "dangerous_content_threshold", config.Gemini.DangerousContentThreshold},
+	}
+
+	var settings []*genai.SafetySetting
+	for _, t := range thresholds {
+		if t.value == "" {
+			continue
+		}
+		threshold, ok := geminiHarmThresholds[strings.ToLower(t.value)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported gemini %s: %q (use \"none\", \"low\", \"medium\" or \"high\")", t.key, t.value)
+		}
+		settings = append(settings, &genai.SafetySetting{Category: t.category, Threshold: threshold})
+	}
+	return settings, nil
+}
+
+// newGeminiGenerationConfig builds the generation config of Gemini requests from the [gemini] section
+func newGeminiGenerationConfig(config Config) (*genai.GenerateContentConfig, error) {
+	safetySettings, err := geminiSafetySettings(config)
+	if err != nil {
+		return nil, err
+	}
+
+	generationConfig := &genai.GenerateContentConfig{
+		Temperature:    genai.Ptr(config.Gemini.Temperature),
+		TopK:           genai.Ptr(float32(config.Gemini.TopK)),
+		SafetySettings: safetySettings,
+	}
+
+	// Zero leaves top_p and max_output_tokens to the model's defaults
+	if topP := config.Gemini.TopP; topP < 0 || topP > 1 {
+		return nil, fmt.Errorf("unsupported gemini top_p: %v (use a value between 0 and 1)", topP)
+	} else if topP > 0 {
+		generationConfig.TopP = genai.Ptr(topP)
+	}
+	if maxTokens := config.Gemini.MaxOutputTokens; maxTokens < 0 {
+		return nil, fmt.Errorf("unsupported gemini max_output_tokens: %d (use a positive number, or 0 for no limit)", maxTokens)
+	} else if maxTokens > 0 {
+		generationConfig.MaxOutputTokens = maxTokens
+	}
+
+	return generationConfig, nil
+}
+
 func setupTransformersProvider(config Config) (*TransformersProvider, error) {
 	serverURL := fmt.Sprintf("http://localhost:%d", config.TransformersServerArgs.Port)
 	provider := &TransformersProvider{
@@ -676,7 +1390,7 @@ func (p *TransformersProvider) monitorServer() {
 			return
 		case <-ticker.C:
 			if !checkTransformersServer(p.ServerURL) {
-				fmt.Printf("Transformers server is not responding. Attempting restart (attempt %d/%d)...\n", retryCount+1, maxRetries)
+				logWarnf("Transformers server is not responding. Attempting restart (attempt %d/%d)...", retryCount+1, maxRetries)
 
 				// Kill existing process if any
 				if p.serverProcess != nil {
@@ -687,16 +1401,16 @@ func (p *TransformersProvider) monitorServer() {
 				// Restart the server
 				err := p.startServer()
 				if err != nil {
-					fmt.Printf("Failed to restart Transformers server: %v\n", err)
+					logErrorf("Failed to restart Transformers server: %v", err)
 					retryCount++
 
 					if retryCount >= maxRetries {
-						fmt.Println("Maximum retry attempts reached. Will try again in 5 minutes.")
+						logErrorf("Maximum retry attempts reached. Will try again in 5 minutes.")
 						retryCount = 0
 						time.Sleep(5*time.Minute - 30*time.Second) // Adjust for ticker
 					}
 				} else {
-					fmt.Println("Transformers server restarted successfully!")
+					logInfof("Transformers server restarted successfully!")
 					retryCount = 0
 				}
 			} else {
@@ -716,6 +1430,9 @@ func (p *TransformersProvider) startServer() error {
 		"--max-memory", fmt.Sprintf("%.2f", p.Config.TransformersServerArgs.MaxMemory),
 		"--torch-dtype", p.Config.TransformersServerArgs.TorchDtype,
 	}
+	if p.Config.TransformersServerArgs.WhisperModel != "" {
+		args = append(args, "--whisper-model", p.Config.TransformersServerArgs.WhisperModel)
+	}
 
 	cmd := exec.Command("python3", args...)
 
@@ -746,7 +1463,7 @@ func (p *TransformersProvider) startServer() error {
 		scanner := bufio.NewScanner(stdout)
 		for scanner.Scan() {
 			line := scanner.Text()
-			fmt.Printf("Transformers stdout: %s\n", line)
+			logDebugf("Transformers stdout: %s", line)
 		}
 	}()
 
@@ -755,7 +1472,7 @@ func (p *TransformersProvider) startServer() error {
 		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
 			line := scanner.Text()
-			fmt.Printf("Transformers stderr: %s\n", line)
+			logInfof("Transformers stderr: %s", line)
 			if strings.Contains(line, "Running on all addresses") {
 				// Give the server a moment to fully initialize
 				time.Sleep(1 * time.Second)
@@ -768,12 +1485,12 @@ func (p *TransformersProvider) startServer() error {
 		}
 	}()
 
-	fmt.Println("Waiting for Transformers server to start...")
+	logInfof("Waiting for Transformers server to start...")
 
 	// Wait for either ready signal or error with a timeout
 	select {
 	case <-ready:
-		fmt.Println("Transformers server is ready!")
+		logInfof("Transformers server is ready")
 		return nil
 	case err := <-errorChan:
 		return fmt.Errorf("server failed to start: %v", err)
@@ -0,0 +1,686 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	genai "google.golang.org/genai"
+)
+
+// stubResponse is what a stubProvider returns for one generation
+type stubResponse struct {
+	text string
+	err  error
+}
+
+// stubProvider is an LLMProvider that returns its responses in turn, repeating the last one,
+// and records the prompts it was called with
+type stubProvider struct {
+	mu           sync.Mutex
+	responses    []stubResponse
+	prompts      []string
+	capabilities ProviderCapabilities
+	pingErr      error
+}
+
+// newStubProvider returns a provider that can describe everything and answers with responses
+func newStubProvider(responses ...stubResponse) *stubProvider {
+	return &stubProvider{
+		responses:    responses,
+		capabilities: ProviderCapabilities{Image: true, Video: true, Audio: true, MultiImage: true, Context: true},
+	}
+}
+
+func (p *stubProvider) next(prompt string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prompts = append(p.prompts, prompt)
+	if len(p.responses) == 0 {
+		return "", nil
+	}
+	response := p.responses[0]
+	if len(p.responses) > 1 {
+		p.responses = p.responses[1:]
+	}
+	return response.text, response.err
+}
+
+// calls is how many generations the provider was asked for
+func (p *stubProvider) calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.prompts)
+}
+
+func (p *stubProvider) GenerateAltText(prompt string, imageData []byte, format string, targetLanguage string) (string, error) {
+	return p.next(prompt)
+}
+
+func (p *stubProvider) GenerateVideoAltText(prompt string, videoData []byte, format string, targetLanguage string) (string, error) {
+	return p.next(prompt)
+}
+
+func (p *stubProvider) GenerateAudioAltText(prompt string, audioData []byte, format string, targetLanguage string) (string, error) {
+	return p.next(prompt)
+}
+
+func (p *stubProvider) CategorizeImage(imageData []byte, format string) (string, error) {
+	return p.next("")
+}
+
+func (p *stubProvider) GenerateMultiImageAltText(prompt string, images [][]byte, formats []string, targetLanguage string) (string, error) {
+	return p.next(prompt)
+}
+
+func (p *stubProvider) Ping(ctx context.Context) error                 { return p.pingErr }
+func (p *stubProvider) Capabilities() ProviderCapabilities             { return p.capabilities }
+func (p *stubProvider) WithTemperatureBoost(boost float32) LLMProvider { return p }
+func (p *stubProvider) Close() error                                   { return nil }
+
+// useProvider makes provider the bot's provider for the rest of the test
+func useProvider(t *testing.T, provider LLMProvider) {
+	t.Helper()
+	previous := llmProvider
+	llmProvider = provider
+	t.Cleanup(func() { llmProvider = previous })
+}
+
+// useConfig lets a test change the configuration, restoring it afterwards
+func useConfig(t *testing.T) {
+	t.Helper()
+	previous := config
+	t.Cleanup(func() { config = previous })
+}
+
+// loadTestLocalizations loads localizations.json, before a test changes into another directory
+func loadTestLocalizations(t *testing.T) {
+	t.Helper()
+	if err := loadLocalizations(); err != nil {
+		t.Fatalf("loading localizations: %v", err)
+	}
+}
+
+func TestLLMRetryRecoversFromTransientErrors(t *testing.T) {
+	useConfig(t)
+	config.LLM.MaxRetries = 3
+	config.LLM.RetryBaseDelay = "1ms"
+
+	unavailable := &providerStatusError{StatusCode: http.StatusServiceUnavailable, message: "server returned status 503"}
+	provider := newStubProvider(stubResponse{err: unavailable}, stubResponse{err: unavailable}, stubResponse{text: "A bridge at night"})
+
+	text, err := withLLMRetry(func() (string, error) {
+		return provider.GenerateAltText("prompt", nil, "png", "en")
+	})
+	if err != nil || text != "A bridge at night" {
+		t.Fatalf("got %q, %v", text, err)
+	}
+	if provider.calls() != 3 {
+		t.Errorf("calls = %d, want 3", provider.calls())
+	}
+}
+
+func TestLLMRetryGivesUpOnPermanentErrors(t *testing.T) {
+	useConfig(t)
+	config.LLM.MaxRetries = 3
+	config.LLM.RetryBaseDelay = "1ms"
+
+	for name, err := range map[string]error{
+		"bad request": &providerStatusError{StatusCode: http.StatusBadRequest, message: "server returned status 400"},
+		"timeout":     fmt.Errorf("error reading Ollama response: %w", context.DeadlineExceeded),
+		"status text": errors.New("image of 500 pixels is too small"),
+	} {
+		provider := newStubProvider(stubResponse{err: err})
+		withLLMRetry(func() (string, error) {
+			return provider.GenerateAltText("prompt", nil, "png", "en")
+		})
+		if provider.calls() != 1 {
+			t.Errorf("%s: calls = %d, want 1", name, provider.calls())
+		}
+	}
+}
+
+func TestIsTransientLLMError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&providerStatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{fmt.Errorf("error calling Ollama: %w", &providerStatusError{StatusCode: http.StatusBadGateway}), true},
+		{&providerStatusError{StatusCode: http.StatusNotFound}, false},
+		{genai.APIError{Code: http.StatusServiceUnavailable}, true},
+		{genai.APIError{Code: http.StatusBadRequest}, false},
+		{fmt.Errorf("error calling OpenAI API: %w", &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}), true},
+		{fmt.Errorf("error making request to server: %w", syscall.ECONNREFUSED), true},
+		{fmt.Errorf("error reading response body: %w", io.ErrUnexpectedEOF), true},
+		{context.DeadlineExceeded, false},
+		{errors.New("unsupported format: eof"), false},
+	}
+	for _, test := range tests {
+		if got := isTransientLLMError(test.err); got != test.want {
+			t.Errorf("isTransientLLMError(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+// ollamaServer answers /api/generate with the given lines, recording the request's payload
+func ollamaServer(t *testing.T, payload *map[string]interface{}, lines ...string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(payload)
+		for _, line := range lines {
+			io.WriteString(w, line+"\n")
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOllamaStreamsTokensWithoutThinking(t *testing.T) {
+	var payload map[string]interface{}
+	server := ollamaServer(t, &payload,
+		`{"response":"A red ","done":false}`,
+		`{"response":"bicycle.","done":false}`,
+		`{"response":"","done":true}`,
+	)
+	provider := &OllamaProvider{model: "llava", keepAlive: "5m", serverURL: server.URL, timeout: time.Minute}
+
+	var tokens []string
+	text, err := provider.GenerateAltTextStream(context.Background(), "Describe", []byte("image"), func(token string) {
+		tokens = append(tokens, token)
+	})
+	if err != nil || text != "A red bicycle." {
+		t.Fatalf("got %q, %v", text, err)
+	}
+	if len(tokens) != 2 {
+		t.Errorf("tokens = %q", tokens)
+	}
+	if think, ok := payload["think"].(bool); !ok || think {
+		t.Errorf("think = %v, want false", payload["think"])
+	}
+}
+
+func TestOllamaStripsThinkBlock(t *testing.T) {
+	var payload map[string]interface{}
+	server := ollamaServer(t, &payload,
+		`{"response":"<think>It looks like a bike.</think>\n\n","done":false}`,
+		`{"response":"A red bicycle.","done":true}`,
+	)
+	provider := &OllamaProvider{model: "qwen3", serverURL: server.URL, timeout: time.Minute}
+
+	if text, err := provider.GenerateAltTextStream(context.Background(), "Describe", []byte("image"), nil); err != nil || text != "A red bicycle." {
+		t.Errorf("got %q, %v", text, err)
+	}
+}
+
+func TestOllamaErrorStatusIsTransient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model is loading", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	provider := &OllamaProvider{model: "llava", serverURL: server.URL, timeout: time.Minute}
+
+	_, err := provider.GenerateAltTextStream(context.Background(), "Describe", []byte("image"), nil)
+	if !isTransientLLMError(err) {
+		t.Errorf("err = %v, want a transient error", err)
+	}
+}
+
+// usePingCache starts the test without a cached provider check
+func usePingCache(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		providerPingMu.Lock()
+		providerPingAt, providerPingErr = time.Time{}, nil
+		providerPingMu.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestHealthReportsUnreachableProvider(t *testing.T) {
+	useConfig(t)
+	usePingCache(t)
+	config.LLM.Provider = "ollama"
+	provider := newStubProvider()
+	provider.pingErr = errors.New("ollama server unreachable: connection refused")
+	useProvider(t, provider)
+
+	rec := httptest.NewRecorder()
+	(&APIServer{}).handleHealth(rec, httptest.NewRequest(http.MethodGet, "/api/v1/health", nil))
+
+	var body struct {
+		Status   string `json:"status"`
+		Provider struct {
+			Name      string `json:"name"`
+			Reachable bool   `json:"reachable"`
+		} `json:"provider"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || body.Status != "unhealthy" || body.Provider.Reachable || body.Provider.Name != "ollama" {
+		t.Errorf("got %d %+v", rec.Code, body)
+	}
+}
+
+func TestHealthReportsReachableProvider(t *testing.T) {
+	useConfig(t)
+	usePingCache(t)
+	useProvider(t, newStubProvider())
+
+	rec := httptest.NewRecorder()
+	(&APIServer{}).handleHealth(rec, httptest.NewRequest(http.MethodGet, "/api/v1/health", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"reachable":true`) {
+		t.Errorf("got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestProviderPingIsCached(t *testing.T) {
+	usePingCache(t)
+	provider := newStubProvider()
+	provider.pingErr = errors.New("down")
+	useProvider(t, provider)
+
+	if err := pingLLMProvider(); err == nil {
+		t.Fatal("failing provider pinged fine")
+	}
+	// A recent result is reused rather than reaching the provider again
+	provider.pingErr = nil
+	if err := pingLLMProvider(); err == nil {
+		t.Error("cached failure wasn't reused")
+	}
+}
+
+func TestCompositePingNeedsOneReachableProvider(t *testing.T) {
+	down, up := newStubProvider(), newStubProvider()
+	down.pingErr = errors.New("connection refused")
+
+	if err := newCompositeProvider([]namedProvider{{name: "ollama", provider: down}, {name: "gemini", provider: up}}).Ping(context.Background()); err != nil {
+		t.Errorf("chain with a reachable fallback: %v", err)
+	}
+	err := newCompositeProvider([]namedProvider{{name: "ollama", provider: down}, {name: "gemini", provider: down}}).Ping(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "ollama: connection refused") || !strings.Contains(err.Error(), "gemini: connection refused") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestOllamaPingChecksModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"models":[{"name":"llava:latest"},{"name":"qwen3:8b"}]}`)
+	}))
+	t.Cleanup(server.Close)
+
+	if err := (&OllamaProvider{model: "llava", serverURL: server.URL}).Ping(context.Background()); err != nil {
+		t.Errorf("installed model: %v", err)
+	}
+	if err := (&OllamaProvider{model: "gemma3", serverURL: server.URL}).Ping(context.Background()); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("missing model: %v", err)
+	}
+
+	server.Close()
+	if err := (&OllamaProvider{model: "llava", serverURL: server.URL}).Ping(context.Background()); err == nil || !strings.Contains(err.Error(), "unreachable") {
+		t.Errorf("stopped server: %v", err)
+	}
+}
+
+// transformersServer answers transcriptions with transcript and chat completions with reply,
+// recording the requests it was sent
+func transformersServer(t *testing.T, transcript, reply string, requests *[]map[string]interface{}) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		payload["path"] = r.URL.Path
+		mu.Lock()
+		*requests = append(*requests, payload)
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/v1/audio/transcriptions":
+			json.NewEncoder(w).Encode(map[string]string{"text": transcript})
+		case "/v1/chat/completions":
+			fmt.Fprintf(w, `{"choices":[{"message":{"content":%q}}]}`, reply)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTransformersDescribesAudioFromTranscript(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	config.ImageProcessing.MaxSizeMB = 10
+	config.LLM.Provider = "transformers"
+
+	var requests []map[string]interface{}
+	server := transformersServer(t, "Good morning, this is the news.", "A news presenter greets the listeners.", &requests)
+	provider := &TransformersProvider{ServerURL: server.URL, Model: "model", Config: &Config{}}
+	provider.Config.TransformersServerArgs.WhisperModel = "openai/whisper-small"
+	if !provider.Capabilities().Audio {
+		t.Fatal("provider with a speech recognition model can't describe audio")
+	}
+	useProvider(t, provider)
+
+	// Audio posts go through the provider interface like any other media
+	media := mediaServer(t, "audio/mpeg", []byte("ID3 audio"))
+	altText, servedBy, err := generateAudioAltText(media.URL+"/clip.mp3", "en")
+	if err != nil || altText != "A news presenter greets the listeners." || servedBy != "transformers" {
+		t.Fatalf("got %q by %q, %v", altText, servedBy, err)
+	}
+
+	if len(requests) != 2 || requests[0]["path"] != "/v1/audio/transcriptions" || requests[0]["format"] != "mp3" {
+		t.Fatalf("requests: %v", requests)
+	}
+	if audio, _ := base64.StdEncoding.DecodeString(requests[0]["audio"].(string)); string(audio) != "ID3 audio" {
+		t.Errorf("transcribed audio %q", audio)
+	}
+	chat, _ := json.Marshal(requests[1]["messages"])
+	if !strings.Contains(string(chat), "Good morning, this is the news.") {
+		t.Errorf("description prompt doesn't carry the transcript: %s", chat)
+	}
+}
+
+func TestAudioUnsupportedPaths(t *testing.T) {
+	transformers := &TransformersProvider{Config: &Config{}}
+	if transformers.Capabilities().Audio {
+		t.Error("transformers without a speech recognition model reports audio")
+	}
+	if _, err := transformers.GenerateAudioAltText("Describe", []byte("audio"), "mp3", "en"); err == nil || !strings.Contains(err.Error(), "whisper_model") {
+		t.Errorf("transformers without whisper_model: %v", err)
+	}
+
+	ollama := &OllamaProvider{}
+	if ollama.Capabilities().Audio {
+		t.Error("ollama reports audio")
+	}
+	if _, err := ollama.GenerateAudioAltText("Describe", []byte("audio"), "mp3", "en"); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("ollama: %v", err)
+	}
+}
+
+func TestProvidersReportTheirCapabilities(t *testing.T) {
+	whisper := &Config{}
+	whisper.TransformersServerArgs.WhisperModel = "openai/whisper-small"
+
+	tests := []struct {
+		name     string
+		provider LLMProvider
+		want     ProviderCapabilities
+	}{
+		{"gemini", &GeminiProvider{}, ProviderCapabilities{Image: true, Video: true, Audio: true, MultiImage: true, Context: true}},
+		{"ollama", &OllamaProvider{}, ProviderCapabilities{Image: true, MultiImage: true, Context: true}},
+		{"openai", &OpenAIProvider{}, ProviderCapabilities{Image: true, MultiImage: true, Context: true}},
+		{"transformers", &TransformersProvider{Config: &Config{}}, ProviderCapabilities{Image: true, Video: true, Context: true}},
+		{"transformers with whisper", &TransformersProvider{Config: whisper}, ProviderCapabilities{Image: true, Video: true, Audio: true, Context: true}},
+		{"slot limited ollama", limitConcurrentGenerations(&OllamaProvider{}, 1), ProviderCapabilities{Image: true, MultiImage: true, Context: true}},
+	}
+	for _, test := range tests {
+		if got := test.provider.Capabilities(); got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+
+	// A fallback chain can describe what any of its providers can
+	chain := newCompositeProvider([]namedProvider{{name: "ollama", provider: &OllamaProvider{}}, {name: "transformers", provider: &TransformersProvider{Config: &Config{}}}})
+	if got := chain.Capabilities(); !got.Video || got.Audio || !got.MultiImage {
+		t.Errorf("chain: %+v", got)
+	}
+}
+
+// openAIServer streams the chunks as chat completion events, recording the request's headers and payload
+func openAIServer(t *testing.T, header *http.Header, payload *map[string]interface{}, chunks ...string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		*header = r.Header.Clone()
+		json.NewDecoder(r.Body).Decode(payload)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", chunk)
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenAICompatibleProvider(t *testing.T) {
+	var header http.Header
+	var payload map[string]interface{}
+	server := openAIServer(t, &header, &payload, "A cat asleep on a keyboard.")
+
+	var cfg Config
+	cfg.Openai.BaseURL = server.URL + "/v1"
+	cfg.Openai.APIKey = "gateway-key"
+	cfg.Openai.Model = "llava-v1.6"
+	provider, err := setupOpenAIProvider(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	altText, err := provider.GenerateAltText("Describe this image.", []byte("png data"), "png", "en")
+	if err != nil || altText != "A cat asleep on a keyboard." {
+		t.Fatalf("got %q, %v", altText, err)
+	}
+
+	if header.Get("Authorization") != "Bearer gateway-key" {
+		t.Errorf("Authorization = %q", header.Get("Authorization"))
+	}
+	if payload["model"] != "llava-v1.6" {
+		t.Errorf("model = %v, want it passed through", payload["model"])
+	}
+	content, _ := json.Marshal(payload["messages"])
+	for _, want := range []string{`"type":"image_url"`, "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("png data")), "Describe this image."} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("messages are missing %s: %s", want, content)
+		}
+	}
+}
+
+func TestOpenAIProviderSetup(t *testing.T) {
+	if _, err := setupOpenAIProvider(Config{}); err == nil {
+		t.Error("provider without an API key or base URL was set up")
+	}
+
+	// Local gateways often run without a key
+	var cfg Config
+	cfg.Openai.BaseURL = "http://localhost:4000/v1"
+	provider, err := setupOpenAIProvider(cfg)
+	if err != nil || provider.model != "gpt-4o-mini" || provider.timeout != 5*time.Minute {
+		t.Errorf("got %+v, %v", provider, err)
+	}
+}
+
+// openaiTestConfig points an OpenAI client at a test server
+func openaiTestConfig(serverURL string) openai.ClientConfig {
+	cfg := openai.DefaultConfig("key")
+	cfg.BaseURL = serverURL + "/v1"
+	return cfg
+}
+
+func TestOpenAIStreamIsReassembled(t *testing.T) {
+	var header http.Header
+	var payload map[string]interface{}
+	server := openAIServer(t, &header, &payload, "A red ", "", "bicycle leaning ", "against a wall.")
+	provider := &OpenAIProvider{client: openai.NewClientWithConfig(openaiTestConfig(server.URL)), model: "model", timeout: time.Minute}
+
+	var tokens []string
+	text, err := provider.complete(context.Background(), []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Describe"}}, func(token string) {
+		tokens = append(tokens, token)
+	})
+	if err != nil || text != "A red bicycle leaning against a wall." {
+		t.Fatalf("got %q, %v", text, err)
+	}
+	if len(tokens) != 3 {
+		t.Errorf("got tokens %q, want the three non-empty chunks", tokens)
+	}
+	if payload["stream"] != true {
+		t.Errorf("stream = %v", payload["stream"])
+	}
+}
+
+func TestOpenAIStreamReturnsPartialOnCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"A red bicycle\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	provider := &OpenAIProvider{client: openai.NewClientWithConfig(openaiTestConfig(server.URL)), model: "model"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	text, err := provider.complete(ctx, []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Describe"}}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) || text != "A red bicycle" {
+		t.Errorf("got %q, %v, want the partial text and the deadline", text, err)
+	}
+}
+
+func TestOpenAIEmptyStreamIsAnError(t *testing.T) {
+	var header http.Header
+	var payload map[string]interface{}
+	server := openAIServer(t, &header, &payload)
+	provider := &OpenAIProvider{client: openai.NewClientWithConfig(openaiTestConfig(server.URL)), model: "model"}
+
+	if _, err := provider.complete(context.Background(), []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Describe"}}, nil); err == nil {
+		t.Error("stream without content succeeded")
+	}
+}
+
+func TestGeminiSafetySettingsMapThresholds(t *testing.T) {
+	for value, want := range map[string]genai.HarmBlockThreshold{
+		"none":   genai.HarmBlockThresholdBlockNone,
+		"low":    genai.HarmBlockThresholdBlockLowAndAbove,
+		"medium": genai.HarmBlockThresholdBlockMediumAndAbove,
+		"HIGH":   genai.HarmBlockThresholdBlockOnlyHigh,
+	} {
+		var cfg Config
+		cfg.Gemini.HarassmentThreshold = value
+		settings, err := geminiSafetySettings(cfg)
+		if err != nil || len(settings) != 1 || settings[0].Category != genai.HarmCategoryHarassment || settings[0].Threshold != want {
+			t.Errorf("%q: got %+v, %v, want harassment at %v", value, settings, err, want)
+		}
+	}
+
+	// Categories left empty keep Gemini's default
+	if settings, err := geminiSafetySettings(Config{}); err != nil || len(settings) != 0 {
+		t.Errorf("no thresholds: got %+v, %v", settings, err)
+	}
+
+	var invalid Config
+	invalid.Gemini.DangerousContentThreshold = "strict"
+	if _, err := geminiSafetySettings(invalid); err == nil || !strings.Contains(err.Error(), "dangerous_content_threshold") {
+		t.Errorf("got %v, want an error naming dangerous_content_threshold", err)
+	}
+}
+
+func TestGeminiGenerationConfigHasSafetySettings(t *testing.T) {
+	var cfg Config
+	cfg.Gemini.HarassmentThreshold = "low"
+	cfg.Gemini.HateSpeechThreshold = "medium"
+	cfg.Gemini.SexuallyExplicitThreshold = "high"
+	cfg.Gemini.DangerousContentThreshold = "none"
+
+	generationConfig, err := newGeminiGenerationConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []genai.SafetySetting{
+		{Category: genai.HarmCategoryHarassment, Threshold: genai.HarmBlockThresholdBlockLowAndAbove},
+		{Category: genai.HarmCategoryHateSpeech, Threshold: genai.HarmBlockThresholdBlockMediumAndAbove},
+		{Category: genai.HarmCategorySexuallyExplicit, Threshold: genai.HarmBlockThresholdBlockOnlyHigh},
+		{Category: genai.HarmCategoryDangerousContent, Threshold: genai.HarmBlockThresholdBlockNone},
+	}
+	if len(generationConfig.SafetySettings) != len(want) {
+		t.Fatalf("got %d safety settings, want %d", len(generationConfig.SafetySettings), len(want))
+	}
+	for i, setting := range generationConfig.SafetySettings {
+		if *setting != want[i] {
+			t.Errorf("safety setting %d: got %+v, want %+v", i, *setting, want[i])
+		}
+	}
+
+	// Requests work on a clone, which keeps the settings without sharing the slice
+	clone := cloneGenerateContentConfig(generationConfig)
+	if len(clone.SafetySettings) != len(want) {
+		t.Errorf("clone has %d safety settings, want %d", len(clone.SafetySettings), len(want))
+	}
+	clone.SafetySettings[0] = nil
+	if generationConfig.SafetySettings[0] == nil {
+		t.Error("the clone shares its safety settings with the original")
+	}
+
+	cfg.Gemini.HateSpeechThreshold = "everything"
+	if _, err := newGeminiGenerationConfig(cfg); err == nil {
+		t.Error("an invalid threshold was accepted")
+	}
+}
+
+func TestGeminiGenerationConfigSampling(t *testing.T) {
+	var cfg Config
+	cfg.Gemini.Temperature = 0.7
+	cfg.Gemini.TopK = 40
+	cfg.Gemini.TopP = 0.9
+	cfg.Gemini.MaxOutputTokens = 300
+
+	generationConfig, err := newGeminiGenerationConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := cloneGenerateContentConfig(generationConfig)
+	if clone.TopP == nil || *clone.TopP != 0.9 || clone.MaxOutputTokens != 300 || *clone.Temperature != 0.7 || *clone.TopK != 40 {
+		t.Errorf("cloned config %+v, want top_p 0.9 and max_output_tokens 300", clone)
+	}
+
+	// Zero leaves them to the model
+	cfg.Gemini.TopP, cfg.Gemini.MaxOutputTokens = 0, 0
+	if generationConfig, err = newGeminiGenerationConfig(cfg); err != nil || generationConfig.TopP != nil || generationConfig.MaxOutputTokens != 0 {
+		t.Errorf("got %+v, %v, want top_p and max_output_tokens unset", generationConfig, err)
+	}
+
+	for _, invalid := range []struct {
+		topP      float32
+		maxTokens int32
+		key       string
+	}{
+		{1.5, 0, "top_p"},
+		{-0.1, 0, "top_p"},
+		{0, -1, "max_output_tokens"},
+	} {
+		cfg.Gemini.TopP, cfg.Gemini.MaxOutputTokens = invalid.topP, invalid.maxTokens
+		if _, err := newGeminiGenerationConfig(cfg); err == nil || !strings.Contains(err.Error(), invalid.key) {
+			t.Errorf("top_p %v, max_output_tokens %d: got %v, want an error naming %s", invalid.topP, invalid.maxTokens, err, invalid.key)
+		}
+	}
+}
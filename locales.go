@@ -2,21 +2,54 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/url"
 	"os"
+	"regexp"
 	"strings"
 )
 
 // Localization holds the localized strings for different languages
 type Localization struct {
-	Prompts   map[string]string `json:"prompts"`
-	Responses map[string]string `json:"responses"`
+	Prompts             map[string]string `json:"prompts"`
+	Responses           map[string]string `json:"responses"`
+	IntroStripPatterns  []string          `json:"intro_strip_patterns"`
+	RegeneratePatterns  []string          `json:"regenerate_patterns"`
+	ForgetPatterns      []string          `json:"forget_patterns"`
+	DataRequestPatterns []string          `json:"data_request_patterns"`
+	RefusalPatterns     []string          `json:"refusal_patterns"`
 }
 
 var localizations map[string]Localization
 
+// introStripPatterns are the compiled intro_strip_patterns of each language
+var introStripPatterns map[string][]*regexp.Regexp
+
+// regeneratePatterns are the compiled regenerate_patterns of each language
+var regeneratePatterns map[string][]*regexp.Regexp
+
+// forgetPatterns are the compiled forget_patterns of each language
+var forgetPatterns map[string][]*regexp.Regexp
+
+// dataRequestPatterns are the compiled data_request_patterns of each language
+var dataRequestPatterns map[string][]*regexp.Regexp
+
+// refusalPatterns are the compiled refusal_patterns of each language
+var refusalPatterns map[string][]*regexp.Regexp
+
 var PromptOverrideState bool
 var PromptAdditionState bool
 
+// glossaryTerms is the operator's glossary as added to prompts, "" when there is none
+var glossaryTerms string
+
+// narrationPromptKeys maps each narration voice to the prompt instruction appended for it
+var narrationPromptKeys = map[string]string{
+	"":       "", // Leave the prompt as-is
+	"third":  "thirdPersonNarration",
+	"second": "secondPersonNarration",
+}
+
 func loadLocalizations() error {
 	data, err := os.ReadFile("localizations.json")
 	if err != nil {
@@ -28,35 +61,258 @@ func loadLocalizations() error {
 		return err
 	}
 
+	introStripPatterns = make(map[string][]*regexp.Regexp)
+	for lang, localization := range localizations {
+		for _, pattern := range localization.IntroStripPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid intro_strip_patterns entry for %s: %v", lang, err)
+			}
+			introStripPatterns[lang] = append(introStripPatterns[lang], re)
+		}
+	}
+
+	regeneratePatterns = make(map[string][]*regexp.Regexp)
+	for lang, localization := range localizations {
+		for _, pattern := range localization.RegeneratePatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid regenerate_patterns entry for %s: %v", lang, err)
+			}
+			regeneratePatterns[lang] = append(regeneratePatterns[lang], re)
+		}
+	}
+
+	forgetPatterns = make(map[string][]*regexp.Regexp)
+	for lang, localization := range localizations {
+		for _, pattern := range localization.ForgetPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid forget_patterns entry for %s: %v", lang, err)
+			}
+			forgetPatterns[lang] = append(forgetPatterns[lang], re)
+		}
+	}
+
+	dataRequestPatterns = make(map[string][]*regexp.Regexp)
+	for lang, localization := range localizations {
+		for _, pattern := range localization.DataRequestPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid data_request_patterns entry for %s: %v", lang, err)
+			}
+			dataRequestPatterns[lang] = append(dataRequestPatterns[lang], re)
+		}
+	}
+
+	refusalPatterns = make(map[string][]*regexp.Regexp)
+	for lang, localization := range localizations {
+		for _, pattern := range localization.RefusalPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid refusal_patterns entry for %s: %v", lang, err)
+			}
+			refusalPatterns[lang] = append(refusalPatterns[lang], re)
+		}
+	}
+
 	return nil
 }
 
-func getLocalizedString(lang, key string, category string) string {
-	localization := localizations[config.Localization.DefaultLanguage]
+// stripIntroPhrases removes preambles like "Here's alt text for the image:" that models put before
+// the description, using the patterns of the language, or the English ones if it has none
+func stripIntroPhrases(altText string, lang string) string {
+	patterns, ok := introStripPatterns[lang]
+	if !ok {
+		patterns = introStripPatterns["en"]
+	}
+	for _, re := range patterns {
+		altText = re.ReplaceAllString(altText, "")
+	}
+	return altText
+}
 
+// localizationFor returns the localization of a language, or of the default language if it has none
+func localizationFor(lang string) Localization {
 	if value, ok := localizations[lang]; ok {
-		localization = value
+		return value
 	}
+	return localizations[config.Localization.DefaultLanguage]
+}
+
+func getLocalizedString(lang, key string, category string) string {
+	localization := localizationFor(lang)
 
 	switch category {
 	case "prompt":
-		var prompt string
-		if PromptOverrideState {
-			prompt = config.LLM.PromptOverride
-		}
-		if value, ok := localization.Prompts[key]; ok {
-			prompt = value
+		return buildPrompt(localization, key, "")
+	case "response":
+		if value, ok := localization.Responses[key]; ok {
+			return strings.ReplaceAll(value, defaultPrivacyPolicyURL, getPrivacyPolicyURL())
 		}
+	}
+	return ""
+}
 
-		if PromptAdditionState {
-			prompt += " " + config.LLM.PromptAddition
+// getPromptForUser returns a prompt for a post by the given account, to be sent to the named provider.
+// The image prompt is replaced by the one set for the account's instance in [prompt_overrides],
+// or else by the provider's in [llm] provider_prompts, if there is one.
+func getPromptForUser(lang, key, acct, provider string) string {
+	if key == "generateAltText" {
+		if instancePrompt, ok := instancePromptOverride(acct); ok {
+			return buildPrompt(localizationFor(lang), key, instancePrompt)
 		}
 
-		return prompt
-	case "response":
-		if value, ok := localization.Responses[key]; ok {
-			return strings.ReplaceAll(value, defaultPrivacyPolicyURL, getPrivacyPolicyURL())
+		// Operators can tune the image prompt per backend without editing the localization files
+		if providerPrompt := config.LLM.ProviderPrompts[provider]; providerPrompt != "" {
+			return buildPrompt(localizationFor(lang), key, providerPrompt)
 		}
 	}
+	return getLocalizedString(lang, key, "prompt")
+}
+
+// getFormattedPrompt fills the placeholders of a localized prompt with args before the narration,
+// additional instructions and glossary are added, so a % in those can't garble the prompt
+func getFormattedPrompt(lang, key string, args ...interface{}) string {
+	localization := localizationFor(lang)
+	template, ok := localization.Prompts[key]
+	if !ok {
+		return buildPrompt(localization, key, "")
+	}
+	return buildPrompt(localization, key, fmt.Sprintf(template, args...))
+}
+
+// instancePromptOverride returns the image prompt configured for the instance of an account
+func instancePromptOverride(acct string) (string, bool) {
+	if acct == "" || len(config.PromptOverrides) == 0 {
+		return "", false
+	}
+	prompt, ok := config.PromptOverrides[instanceDomain(acct)]
+	return prompt, ok && prompt != ""
+}
+
+// instanceDomain returns the instance domain of an account, local accounts have no domain in their acct
+func instanceDomain(acct string) string {
+	if _, domain, ok := strings.Cut(strings.TrimPrefix(acct, "@"), "@"); ok {
+		return strings.ToLower(domain)
+	}
+	if u, err := url.Parse(config.Server.MastodonServer); err == nil {
+		return strings.ToLower(u.Hostname())
+	}
 	return ""
 }
+
+// buildPrompt assembles a prompt from the localized prompt, or basePrompt if set, and the operator's additions
+func buildPrompt(localization Localization, key, basePrompt string) string {
+	var prompt string
+	if PromptOverrideState {
+		prompt = config.LLM.PromptOverride
+	}
+	if value, ok := localization.Prompts[key]; ok {
+		prompt = value
+	}
+
+	if basePrompt != "" {
+		prompt = basePrompt
+	}
+
+	if narrationKey := narrationPromptKeys[config.LLM.Narration]; narrationKey != "" {
+		if value, ok := localization.Prompts[narrationKey]; ok {
+			prompt += " " + value
+		}
+	}
+
+	if PromptAdditionState {
+		prompt += " " + config.LLM.PromptAddition
+	}
+
+	if glossaryTerms != "" {
+		prompt += " " + localization.Prompts["glossaryIntro"] + " " + glossaryTerms
+	}
+
+	return prompt
+}
+
+// buildGlossary joins the glossary terms for the prompt, stopping before maxChars (default 1000)
+// is exceeded so a long glossary can't crowd out the actual instructions. It returns how many terms fit.
+func buildGlossary(terms []string, maxChars int) (string, int) {
+	if maxChars <= 0 {
+		maxChars = 1000
+	}
+
+	var glossary []string
+	length := 0
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		if length+len(term)+2 > maxChars {
+			break
+		}
+		glossary = append(glossary, term)
+		length += len(term) + 2
+	}
+
+	return strings.Join(glossary, "; "), len(glossary)
+}
+
+// getPromptHint returns a prompt fragment without the override, narration and additional
+// instructions that getLocalizedString adds to full prompts, "" if the key doesn't exist
+func getPromptHint(lang, key string) string {
+	localization := localizations[config.Localization.DefaultLanguage]
+
+	if value, ok := localizations[lang]; ok {
+		localization = value
+	}
+
+	return localization.Prompts[key]
+}
+
+// pluralCategory returns the CLDR plural category of n for the given language,
+// limited to the categories used by the languages in localizations.json
+func pluralCategory(lang string, n int) string {
+	mod10, mod100 := n%10, n%100
+
+	switch lang {
+	case "ja", "zh", "ko":
+		return "other"
+	case "fr", "pt":
+		if n == 0 || n == 1 {
+			return "one"
+		}
+	case "ru", "be":
+		if mod10 == 1 && mod100 != 11 {
+			return "one"
+		}
+		if mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14) {
+			return "few"
+		}
+		return "many"
+	case "pl":
+		if n == 1 {
+			return "one"
+		}
+		if mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14) {
+			return "few"
+		}
+		return "many"
+	default:
+		if n == 1 {
+			return "one"
+		}
+	}
+	return "other"
+}
+
+// getLocalizedPluralString returns the response string matching the plural form of count,
+// stored as key_one, key_few, key_many or key_other, and falls back to the plain key
+func getLocalizedPluralString(lang, key string, count int) string {
+	if value := getLocalizedString(lang, key+"_"+pluralCategory(lang, count), "response"); value != "" {
+		return value
+	}
+	if value := getLocalizedString(lang, key+"_other", "response"); value != "" {
+		return value
+	}
+	return getLocalizedString(lang, key, "response")
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProviderPromptFollowsServingProvider(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	config.LLM.Provider = "ollama"
+	config.LLM.ProviderPrompts = map[string]string{"ollama": "Prompt for Ollama.", "gemini": "Prompt for Gemini."}
+
+	primary := newStubProvider(stubResponse{err: errors.New("connection refused")})
+	fallback := newStubProvider(stubResponse{text: "A dog"})
+	chain := newCompositeProvider([]namedProvider{{name: "ollama", provider: primary}, {name: "gemini", provider: fallback}})
+
+	if _, _, err := generateWith(chain, "image", canImage, func(name string, provider LLMProvider) (string, error) {
+		return provider.GenerateAltText(getPromptForUser("en", "generateAltText", "", name), nil, "png", "en")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(primary.prompts[0], "Prompt for Ollama.") {
+		t.Errorf("primary got %q", primary.prompts[0])
+	}
+	if !strings.HasPrefix(fallback.prompts[0], "Prompt for Gemini.") {
+		t.Errorf("fallback got %q", fallback.prompts[0])
+	}
+}
+
+func TestInstancePromptTakesPrecedenceOverProviderPrompt(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	config.LLM.ProviderPrompts = map[string]string{"gemini": "Prompt for Gemini."}
+	config.PromptOverrides = map[string]string{"example.social": "Prompt for example.social."}
+
+	if prompt := getPromptForUser("en", "generateAltText", "someone@example.social", "gemini"); !strings.HasPrefix(prompt, "Prompt for example.social.") {
+		t.Errorf("prompt = %q", prompt)
+	}
+	if prompt := getPromptForUser("en", "generateAltText", "someone@other.social", "ollama"); strings.Contains(prompt, "Prompt for") {
+		t.Errorf("provider without its own prompt got %q", prompt)
+	}
+}
+
+func TestInstancePromptOverrides(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	config.Server.MastodonServer = "https://home.social"
+	defaultPrompt := getLocalizedString("en", "generateAltText", "prompt")
+
+	// Without overrides everyone gets the default prompt
+	if prompt := getPromptForUser("en", "generateAltText", "someone@tech.lgbt", ""); prompt != defaultPrompt {
+		t.Errorf("no overrides: %q", prompt)
+	}
+
+	config.PromptOverrides = map[string]string{"tech.lgbt": "Prompt for tech.lgbt.", "home.social": "Prompt for home.social."}
+	tests := []struct {
+		acct string
+		want string
+	}{
+		{"someone@tech.lgbt", "Prompt for tech.lgbt."},
+		{"@someone@Tech.LGBT", "Prompt for tech.lgbt."},
+		{"local", "Prompt for home.social."},
+		{"someone@mastodon.social", defaultPrompt},
+		{"", defaultPrompt},
+	}
+	for _, test := range tests {
+		if prompt := getPromptForUser("en", "generateAltText", test.acct, ""); !strings.HasPrefix(prompt, test.want) {
+			t.Errorf("%q got %q, want %q", test.acct, prompt, test.want)
+		}
+	}
+
+	// Other prompts aren't overridden
+	if prompt := getPromptForUser("en", "generateVideoAltText", "someone@tech.lgbt", ""); strings.Contains(prompt, "tech.lgbt") {
+		t.Errorf("video prompt = %q", prompt)
+	}
+}
+
+func TestPostProcessStripsLocalizedPreambles(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+
+	tests := []struct {
+		lang    string
+		altText string
+		want    string
+	}{
+		{"de", "Hier ist der Alt-Text für das Bild: Ein Hund liegt im Gras.", "Ein Hund liegt im Gras."},
+		{"de", "Hier ist eine Bildbeschreibung:\n\nEin Hund liegt im Gras.", "Ein Hund liegt im Gras."},
+		{"fr", "Voici le texte alternatif pour l'image : Un chien couché dans l'herbe.", "Un chien couché dans l'herbe."},
+		{"fr", "Voilà une description: Un chien couché dans l'herbe.", "Un chien couché dans l'herbe."},
+		{"en", "Here is the alt text for the image: A dog lying in the grass.", "A dog lying in the grass."},
+		// Languages without their own patterns use the English ones
+		{"xx", "Here's alt text for the image: A dog lying in the grass.", "A dog lying in the grass."},
+		// Only a leading preamble is removed
+		{"de", "Ein Schild: Hier ist der Eingang.", "Ein Schild: Hier ist der Eingang."},
+	}
+	for _, test := range tests {
+		if got := postProcessAltText(test.altText, test.lang); got != test.want {
+			t.Errorf("%s %q: got %q, want %q", test.lang, test.altText, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logLevel is the lowest level that is logged, set from [logging] level
+var logLevel = new(slog.LevelVar)
+
+// setupLogging sends the bot's logs to stderr as text or JSON lines, dropping those below the configured
+// level. Messages still written with the log package are logged at the info level.
+// The colorized startup banner and the dev mode output are printed directly and aren't affected.
+func setupLogging() {
+	switch strings.ToLower(config.Logging.Level) {
+	case "debug":
+		logLevel.Set(slog.LevelDebug)
+	case "", "info":
+		logLevel.Set(slog.LevelInfo)
+	case "warn", "warning":
+		logLevel.Set(slog.LevelWarn)
+	case "error":
+		logLevel.Set(slog.LevelError)
+	default:
+		log.Fatalf("Unsupported log level: %s (use \"debug\", \"info\", \"warn\" or \"error\")", config.Logging.Level)
+	}
+
+	options := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler
+	switch config.Logging.Format {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		log.Fatalf("Unsupported log format: %s (use \"text\" or \"json\")", config.Logging.Format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+}
+
+// logf logs a formatted message at a level, skipping the formatting when the level is off
+func logf(level slog.Level, format string, args ...interface{}) {
+	logger := slog.Default()
+	if !logger.Enabled(context.Background(), level) {
+		return
+	}
+	logger.Log(context.Background(), level, fmt.Sprintf(format, args...))
+}
+
+// logDebugf logs details that only help when following a request step by step
+func logDebugf(format string, args ...interface{}) {
+	logf(slog.LevelDebug, format, args...)
+}
+
+// logInfof logs what the bot is doing
+func logInfof(format string, args ...interface{}) {
+	logf(slog.LevelInfo, format, args...)
+}
+
+// logWarnf logs something unexpected the bot recovered from
+func logWarnf(format string, args ...interface{}) {
+	logf(slog.LevelWarn, format, args...)
+}
+
+// logErrorf logs a failure
+func logErrorf(format string, args ...interface{}) {
+	logf(slog.LevelError, format, args...)
+}
+
+// requestLog logs messages about an API request with its ID as the request_id attribute
+type requestLog string
+
+func (id requestLog) logf(level slog.Level, format string, args ...interface{}) {
+	logger := slog.Default()
+	if !logger.Enabled(context.Background(), level) {
+		return
+	}
+	logger.Log(context.Background(), level, fmt.Sprintf(format, args...), "request_id", string(id))
+}
+
+func (id requestLog) Debugf(format string, args ...interface{}) {
+	id.logf(slog.LevelDebug, format, args...)
+}
+
+func (id requestLog) Infof(format string, args ...interface{}) {
+	id.logf(slog.LevelInfo, format, args...)
+}
+
+func (id requestLog) Warnf(format string, args ...interface{}) {
+	id.logf(slog.LevelWarn, format, args...)
+}
+
+func (id requestLog) Errorf(format string, args ...interface{}) {
+	id.logf(slog.LevelError, format, args...)
+}
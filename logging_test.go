@@ -0,0 +1,82 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// restoreLogging puts the logger and level back after the test. Setting a default slog logger also
+// redirects the log package, which restoring the previous one doesn't undo, so that is restored too.
+func restoreLogging(t *testing.T) {
+	t.Helper()
+	previous, previousLevel := slog.Default(), logLevel.Level()
+	writer, flags := log.Writer(), log.Flags()
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+		logLevel.Set(previousLevel)
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+	})
+}
+
+// captureLogs sends the logs at level and above to the returned buffer for the rest of the test
+func captureLogs(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	restoreLogging(t)
+
+	var buf bytes.Buffer
+	logLevel.Set(level)
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: logLevel})))
+	return &buf
+}
+
+func TestWarnLevelSuppressesInfo(t *testing.T) {
+	logs := captureLogs(t, slog.LevelWarn)
+
+	logDebugf("debug message")
+	logInfof("info message")
+	logWarnf("warn message")
+	logErrorf("error message")
+
+	output := logs.String()
+	for _, suppressed := range []string{"debug message", "info message"} {
+		if strings.Contains(output, suppressed) {
+			t.Errorf("%q was logged at the warn level", suppressed)
+		}
+	}
+	for _, logged := range []string{"level=WARN msg=\"warn message\"", "level=ERROR msg=\"error message\""} {
+		if !strings.Contains(output, logged) {
+			t.Errorf("missing %s in %q", logged, output)
+		}
+	}
+}
+
+func TestRequestLogCarriesRequestID(t *testing.T) {
+	logs := captureLogs(t, slog.LevelDebug)
+
+	requestLog("abc123").Infof("Generated %s alt-text", "image")
+	if output := logs.String(); !strings.Contains(output, `msg="Generated image alt-text" request_id=abc123`) {
+		t.Errorf("got %q", output)
+	}
+}
+
+func TestSetupLoggingLevels(t *testing.T) {
+	useConfig(t)
+	restoreLogging(t)
+
+	for level, want := range map[string]slog.Level{"": slog.LevelInfo, "debug": slog.LevelDebug, "WARNING": slog.LevelWarn, "error": slog.LevelError} {
+		config.Logging.Level = level
+		setupLogging()
+		if logLevel.Level() != want {
+			t.Errorf("level %q gave %v, want %v", level, logLevel.Level(), want)
+		}
+	}
+}
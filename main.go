@@ -11,6 +11,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
@@ -19,12 +20,14 @@ import (
 	"image/png"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -63,47 +66,85 @@ type Config struct {
 		Username       string `toml:"username"`
 	} `toml:"server"`
 	LLM struct {
-		Provider                   string `toml:"provider"`
-		OllamaModel                string `toml:"ollama_model"`
-		OllamaKeepAlive            string `toml:"ollama_keep_alive"`
-		OllamaTranslationModel     string `toml:"ollama_translation_model"`
-		OllamaTranslationKeepAlive string `toml:"ollama_translation_keep_alive"`
-		UseTranslationLayer        bool   `toml:"use_translation_layer"`
-		PromptAddition             string `toml:"prompt_additional_instructions"`
-		PromptOverride             string `toml:"prompt_override"`
+		Provider                   string            `toml:"provider"`
+		OllamaModel                string            `toml:"ollama_model"`
+		OllamaKeepAlive            string            `toml:"ollama_keep_alive"`
+		OllamaTranslationModel     string            `toml:"ollama_translation_model"`
+		OllamaTranslationKeepAlive string            `toml:"ollama_translation_keep_alive"`
+		OllamaURL                  string            `toml:"ollama_url"`
+		OllamaTimeoutSeconds       int               `toml:"ollama_timeout_seconds"`
+		UseTranslationLayer        bool              `toml:"use_translation_layer"`
+		PromptAddition             string            `toml:"prompt_additional_instructions"`
+		PromptOverride             string            `toml:"prompt_override"`
+		ProviderPrompts            map[string]string `toml:"provider_prompts"`
+		Narration                  string            `toml:"narration"`
+		CategorizeImages           bool              `toml:"categorize_images"`
+		Glossary                   []string          `toml:"glossary"`
+		GlossaryMaxChars           int               `toml:"glossary_max_chars"`
+		MaxRetries                 int               `toml:"max_retries"`
+		RetryBaseDelay             string            `toml:"retry_base_delay"`
+		MaxConcurrentGenerations   int               `toml:"max_concurrent_generations"`
+		FallbackProviders          []string          `toml:"fallback_providers"`
+		QualityGuard               bool              `toml:"quality_guard"`
+		MinAltTextChars            int               `toml:"min_alt_text_chars"`
 	} `toml:"llm"`
+	PromptOverrides map[string]string `toml:"prompt_overrides"`
 	TransformersServerArgs struct {
-		Port       int     `toml:"port"`
-		Model      string  `toml:"model"`
-		Device     string  `toml:"device"`
-		MaxMemory  float64 `toml:"max_memory"`
-		TorchDtype string  `toml:"torch_dtype"`
+		Port         int     `toml:"port"`
+		Model        string  `toml:"model"`
+		Device       string  `toml:"device"`
+		MaxMemory    float64 `toml:"max_memory"`
+		TorchDtype   string  `toml:"torch_dtype"`
+		WhisperModel string  `toml:"whisper_model"`
 	} `toml:"transformers"`
 	Gemini struct {
 		Model                     string  `toml:"model"`
 		APIKey                    string  `toml:"api_key"`
 		Temperature               float32 `toml:"temperature"`
 		TopK                      int32   `toml:"top_k"`
+		TopP                      float32 `toml:"top_p"`
+		MaxOutputTokens           int32   `toml:"max_output_tokens"`
 		HarassmentThreshold       string  `toml:"harassment_threshold"`
 		HateSpeechThreshold       string  `toml:"hate_speech_threshold"`
 		SexuallyExplicitThreshold string  `toml:"sexually_explicit_threshold"`
 		DangerousContentThreshold string  `toml:"dangerous_content_threshold"`
+		UploadPollIntervalSeconds int     `toml:"upload_poll_interval_seconds"`
+		UploadMaxWaitSeconds      int     `toml:"upload_max_wait_seconds"`
+		MaxConcurrentUploads      int     `toml:"max_concurrent_uploads"`
 	} `toml:"gemini"`
 	Openai struct {
 		BaseURL                   string  `toml:"base_url"`
 		Model                     string  `toml:"model"`
 		APIKey                    string  `toml:"api_key"`
+		TimeoutSeconds            int     `toml:"timeout_seconds"`
 	} `toml:"openai"`
 	Localization struct {
 		DefaultLanguage string `toml:"default_language"`
+		GDPRLanguage    string `toml:"gdpr_language"`
 	} `toml:"localization"`
 	DNI struct {
 		Tags       []string `toml:"tags"`
 		IgnoreBots bool     `toml:"ignore_bots"`
 	} `toml:"dni"`
+	SimpleLanguage struct {
+		Tags     []string `toml:"tags"`
+		Keywords []string `toml:"keywords"`
+	} `toml:"simple_language"`
+	Transcription struct {
+		Tags           []string `toml:"tags"`
+		Keywords       []string `toml:"keywords"`
+		AutoDetect     bool     `toml:"auto_detect"`
+		MinEdgesPerRow float64  `toml:"min_edges_per_row"`
+	} `toml:"transcription"`
 	ImageProcessing struct {
-		DownscaleWidth uint `toml:"downscale_width"`
-		MaxSizeMB      uint `toml:"max_size_mb"`
+		DownscaleWidth         uint    `toml:"downscale_width"`
+		MaxSizeMB              uint    `toml:"max_size_mb"`
+		ResizeAlgorithm        string  `toml:"resize_algorithm"`
+		HEIFConvertPath        string  `toml:"heif_convert_path"`
+		DownloadTimeoutSeconds int     `toml:"download_timeout_seconds"`
+		AnimateGIFFrames       int     `toml:"animate_gif_frames"`
+		DecorativeMinSize      int     `toml:"decorative_min_size"`
+		DecorativeMaxDeviation float64 `toml:"decorative_max_deviation"`
 	} `toml:"image_processing"`
 	VideoProcessing struct {
 		MaxSizeMB          uint    `toml:"max_size_mb"`
@@ -111,37 +152,106 @@ type Config struct {
 		MaxFrames          int     `toml:"max_frames"`
 	} `toml:"video_processing"`
 	Behavior struct {
-		ReplyVisibility  string `toml:"reply_visibility"`
-		FollowBack       bool   `toml:"follow_back"`
-		AskForConsent    bool   `toml:"ask_for_consent"`
-		PrivacyPolicyURL string `toml:"privacy_policy_url"`
+		ReplyVisibility           string            `toml:"reply_visibility"`
+		FollowBack                bool              `toml:"follow_back"`
+		FollowBackPolicy          string            `toml:"follow_back_policy"`
+		AskForConsent             bool              `toml:"ask_for_consent"`
+		PrivacyPolicyURL          string            `toml:"privacy_policy_url"`
+		IgnoreInheritedMentions   bool              `toml:"ignore_inherited_mentions"`
+		DeleteRedundantReplies    bool              `toml:"delete_redundant_replies"`
+		CaptionPrefix             string            `toml:"caption_prefix"`
+		CaptionPrefixTranslations map[string]string `toml:"caption_prefix_translations"`
+		ReplyFormat               string            `toml:"reply_format"`
+		AltTextReview             bool              `toml:"alt_text_review"`
+		CWReplyPrefix             string            `toml:"cw_reply_prefix"`
+		RetryAsDirect             bool              `toml:"retry_as_direct"`
+		CombinedMultiImage        bool              `toml:"combined_multi_image"`
+		MaxAltTextChars           int               `toml:"max_alt_text_chars"`
+		InlineContext             bool              `toml:"inline_context"`
+		CWMediaVisibility         string            `toml:"cw_media_visibility"`
+		ConsentTTLDays            int               `toml:"consent_ttl_days"`
+		TrustedInstances          []string          `toml:"trusted_instances"`
+		AttributionEnabled        bool              `toml:"attribution_enabled"`
+		MaxMediaPerPost           int               `toml:"max_media_per_post"`
+		OverMediaLimit            string            `toml:"over_media_limit"`
 	} `toml:"behavior"`
 	WeeklySummary struct {
-		Enabled         bool     `toml:"enabled"`
-		PostDay         string   `toml:"post_day"`
-		PostTime        string   `toml:"post_time"`
-		MessageTemplate string   `toml:"message_template"`
-		Tips            []string `toml:"tips"`
+		Enabled          bool     `toml:"enabled"`
+		PostDay          string   `toml:"post_day"`
+		PostTime         string   `toml:"post_time"`
+		MessageTemplate  string   `toml:"message_template"`
+		Tips             []string `toml:"tips"`
+		QuietWeekMessage string   `toml:"quiet_week_message"`
 	} `toml:"weekly_summary"`
 	API struct {
-		Enabled               bool   `toml:"enabled"`
-		Port                  int    `toml:"port"`
-		MonthlyLimit          int    `toml:"monthly_limit"`
-		KofiVerificationToken string `toml:"kofi_verification_token"`
-		KofiShopItemCode      string `toml:"kofi_shop_item_code"`
-		KofiTierName          string `toml:"kofi_tier_name"`
-		PostmarkToken         string `toml:"postmark_token"`
-		PostmarkFromEmail     string `toml:"postmark_from_email"`
+		Enabled                 bool               `toml:"enabled"`
+		Port                    int                `toml:"port"`
+		MonthlyLimit            int                `toml:"monthly_limit"`
+		MediaLimits             map[string]int     `toml:"media_limits"`
+		AcceptedFormats         []string           `toml:"accepted_formats"`
+		MaxUploadMB             int                `toml:"max_upload_mb"`
+		RequestsPerMinute       int                `toml:"requests_per_minute"`
+		RequestsPerHour         int                `toml:"requests_per_hour"`
+		Tiers                   map[string]APITier `toml:"tiers"`
+		KofiVerificationToken   string             `toml:"kofi_verification_token"`
+		KofiShopItemCode        string             `toml:"kofi_shop_item_code"`
+		KofiTierName            string             `toml:"kofi_tier_name"`
+		KofiWebhookSecret       string             `toml:"kofi_webhook_secret"`
+		KofiSignatureHeader     string             `toml:"kofi_signature_header"`
+		PatreonWebhookSecret    string             `toml:"patreon_webhook_secret"`
+		PatreonTierName         string             `toml:"patreon_tier_name"`
+		EmailProvider           string             `toml:"email_provider"`
+		EmailFrom               string             `toml:"email_from"`
+		PostmarkToken           string             `toml:"postmark_token"`
+		PostmarkFromEmail       string             `toml:"postmark_from_email"`
+		SMTPHost                string             `toml:"smtp_host"`
+		SMTPPort                int                `toml:"smtp_port"`
+		SMTPUsername            string             `toml:"smtp_username"`
+		SMTPPassword            string             `toml:"smtp_password"`
+		EmailWorkers            int                `toml:"email_workers"`
+		EmailTimeoutSeconds     int                `toml:"email_timeout_seconds"`
+		EmailMaxAttempts        int                `toml:"email_max_attempts"`
+		EmailFailureNotifyAdmin bool               `toml:"email_failure_notify_admin"`
+		AdminToken              string             `toml:"admin_token"`
+		BatchSize               int                `toml:"batch_size"`
+		BatchWindowMs           int                `toml:"batch_window_ms"`
+		BatchMaxItems           int                `toml:"batch_max_items"`
+		AllowedOrigins          []string           `toml:"allowed_origins"`
+		AllowPrivateURLs        bool               `toml:"allow_private_urls"`
 	} `toml:"api"`
+	Logging struct {
+		Level  string `toml:"level"`
+		Format string `toml:"format"`
+	} `toml:"logging"`
 	Metrics struct {
 		Enabled          bool `toml:"enabled"`
 		DashboardEnabled bool `toml:"dashboard_enabled"`
 		DashboardPort    int  `toml:"dashboard_port"`
+		RetentionDays    int  `toml:"retention_days"`
 	} `toml:"metrics"`
 	PowerMetrics struct {
-		Enabled  bool    `toml:"enabled"`
-		GPUWatts float64 `toml:"gpu_watts"`
+		Enabled       bool    `toml:"enabled"`
+		GPUWatts      float64 `toml:"gpu_watts"`
+		CPUWatts      float64 `toml:"cpu_watts"`
+		IdleWatts     float64 `toml:"idle_watts"`
+		ReplyUnit     string  `toml:"reply_unit"`
+		GridIntensity float64 `toml:"grid_intensity_g_per_kwh"`
 	} `toml:"power_metrics"`
+	Cache struct {
+		Enabled    bool `toml:"enabled"`
+		MaxEntries int  `toml:"max_entries"`
+		TTLHours   int  `toml:"ttl_hours"`
+	} `toml:"cache"`
+	KnownImages struct {
+		Enabled     bool   `toml:"enabled"`
+		File        string `toml:"file"`
+		MaxDistance int    `toml:"max_distance"`
+	} `toml:"known_images"`
+	Archive struct {
+		Enabled        bool   `toml:"enabled"`
+		WebhookURL     string `toml:"webhook_url"`
+		IncludeCaption bool   `toml:"include_caption"`
+	} `toml:"archive"`
 	RateLimit struct {
 		Enabled                        bool   `toml:"enabled"`
 		MaxRequestsPerMinute           int    `toml:"max_requests_per_user_per_minute"`
@@ -151,6 +261,12 @@ type Config struct {
 		NewAccountPeriodDays           int    `toml:"new_account_period_days"`
 		ShadowBanThreshold             int    `toml:"shadow_ban_threshold"`
 		AdminContactHandle             string `toml:"admin_contact_handle"`
+		NewAccountPolicy               string `toml:"new_account_policy"`
+		ThreadReplyLimit               int    `toml:"thread_reply_limit"`
+		ThreadWindowMinutes            int    `toml:"thread_window_minutes"`
+		MaxDailyReplies                int    `toml:"max_daily_replies"`
+		DailyLimitNotifyAdmin          bool   `toml:"daily_limit_notify_admin"`
+		NotifyLimitedUsers             bool   `toml:"notify_limited_users"`
 	} `toml:"rate_limit"`
 	AltTextReminders struct {
 		Enabled      bool `toml:"enabled"`
@@ -185,12 +301,13 @@ var botAcct mastodon.Account
 
 var consentRequests = make(map[mastodon.ID]ConsentRequest)
 
-var videoProcessingCapability = false
-var audioProcessingCapability = false
 
 var rateLimiter *RateLimiter
 
 var processingIDs = make(map[mastodon.ID]bool)
+
+// inFlight tracks the alt-text generations in progress, so a shutdown can wait for them
+var inFlight sync.WaitGroup
 var processingIDsMu sync.Mutex
 
 var metricsManager *MetricsManager
@@ -208,13 +325,18 @@ const (
 
 var devMode bool
 
+// previewMode generates captions for real events but prints the replies instead of posting them
+var previewMode bool
+
 func main() {
 	setupFlag := flag.Bool("setup", false, "Run the setup wizard")
 	adminCmd := flag.Bool("admin", false, "Run admin command")
 	devFlag := flag.Bool("dev", false, "Run in development mode (print to terminal instead of posting)")
+	previewFlag := flag.Bool("preview", false, "Connect to Mastodon and generate captions, but print the replies instead of posting them")
 	flag.Parse()
 
 	devMode = *devFlag
+	previewMode = *previewFlag
 
 	// Handle admin commands and exit
 	if *adminCmd {
@@ -232,7 +354,7 @@ func main() {
 	if _, err := os.Stat("config.toml"); os.IsNotExist(err) {
 		if devMode {
 			// In dev mode, use example.config.toml directly without running setup wizard
-			log.Println("config.toml not found. Using example.config.toml for dev mode...")
+			logInfof("config.toml not found. Using example.config.toml for dev mode...")
 			if err := copyConfig("example.config.toml", "config.toml", 5); err != nil {
 				log.Fatalf("Error creating default config.toml: %v", err)
 			}
@@ -241,7 +363,7 @@ func main() {
 				log.Fatalf("Error creating default config.toml: %v", err)
 			}
 
-			log.Println("config.toml not found. Running setup wizard...")
+			logInfof("config.toml not found. Running setup wizard...")
 			*setupFlag = true
 		}
 	}
@@ -254,6 +376,7 @@ func main() {
 	if _, err := toml.DecodeFile("config.toml", &config); err != nil {
 		log.Fatalf("Error loading config.toml: %v", err)
 	}
+	setupLogging()
 
 	// Compare config with defaultConfig and print warnings or custom settings
 	customSettingsCount := compareConfigs(defaultConfig, config)
@@ -266,17 +389,15 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error initializing LLM provider: %v", err)
 	}
+	llmProvider = limitConcurrentGenerations(llmProvider, config.LLM.MaxConcurrentGenerations)
 	defer llmProvider.Close()
 
-	// Set video/audio processing capability based on provider
+	// What each provider can describe is reported by its Capabilities
 	switch config.LLM.Provider {
 	case "transformers":
 		// Transformers server management is now handled by the TransformersProvider
 		// in setupTransformersProvider, so we don't need to manually check/start it here
 
-		// Just set capability flag
-		videoProcessingCapability = true
-
 		// Log that we're using the Transformers provider
 		fmt.Printf("%s Using Transformers provider with model %s\n",
 			Yellow, config.TransformersServerArgs.Model)
@@ -287,20 +408,55 @@ func main() {
 			log.Fatalf("Error checking Ollama model: %v", err)
 		}
 
-	case "gemini":
-		// Gemini supports video/audio processing
-		videoProcessingCapability = true
-		audioProcessingCapability = true
-
-	case "openai":
-		// Not yet implemented
-		videoProcessingCapability = false
-		audioProcessingCapability = false
+	case "gemini", "openai":
+		// Nothing to check before starting
 
 	default:
 		log.Fatalf("Unsupported LLM provider: %s", config.LLM.Provider)
 	}
 
+	if _, ok := narrationPromptKeys[config.LLM.Narration]; !ok {
+		log.Fatalf("Unsupported narration voice: %s (use \"third\" or \"second\")", config.LLM.Narration)
+	}
+
+	if _, ok := resizeAlgorithms[config.ImageProcessing.ResizeAlgorithm]; !ok {
+		log.Fatalf("Unsupported resize algorithm: %s", config.ImageProcessing.ResizeAlgorithm)
+	}
+
+	if config.Behavior.ReplyFormat != "" && config.Behavior.ReplyFormat != "plain" && config.Behavior.ReplyFormat != "copy" {
+		log.Fatalf("Unsupported reply format: %s (use \"plain\" or \"copy\")", config.Behavior.ReplyFormat)
+	}
+
+	switch config.Behavior.CWMediaVisibility {
+	case "", "direct", "skip":
+	default:
+		log.Fatalf("Unsupported CW media visibility: %s (use \"direct\" or \"skip\")", config.Behavior.CWMediaVisibility)
+	}
+
+	switch config.Behavior.FollowBackPolicy {
+	case "", "all", "consented_only", "non_bots", "none":
+	default:
+		log.Fatalf("Unsupported follow back policy: %s (use \"all\", \"consented_only\", \"non_bots\" or \"none\")", config.Behavior.FollowBackPolicy)
+	}
+
+	switch config.Behavior.OverMediaLimit {
+	case "", "partial", "skip":
+	default:
+		log.Fatalf("Unsupported over media limit action: %s (use \"partial\" or \"skip\")", config.Behavior.OverMediaLimit)
+	}
+
+	switch config.RateLimit.NewAccountPolicy {
+	case "", "limit", "warn", "consent":
+	default:
+		log.Fatalf("Unsupported new account policy: %s (use \"limit\", \"warn\" or \"consent\")", config.RateLimit.NewAccountPolicy)
+	}
+
+	switch config.PowerMetrics.ReplyUnit {
+	case "", "Wh", "kWh", "co2e", "none":
+	default:
+		log.Fatalf("Unsupported power metrics reply unit: %s (use \"Wh\", \"kWh\", \"co2e\" or \"none\")", config.PowerMetrics.ReplyUnit)
+	}
+
 	err = loadLocalizations()
 	if err != nil {
 		log.Fatalf("Error loading localizations: %v", err)
@@ -311,6 +467,8 @@ func main() {
 	fmt.Printf("%sAltbot%s v%s (%s)\n", Cyan, Reset, Version, config.LLM.Provider)
 	if devMode {
 		fmt.Printf("%s[DEV MODE]%s Interactive testing mode - no Mastodon connection\n", Yellow, Reset)
+	} else if previewMode {
+		fmt.Printf("%s[PREVIEW MODE]%s Captions are generated but printed instead of posted\n", Yellow, Reset)
 	}
 	checkForUpdates()
 
@@ -319,16 +477,28 @@ func main() {
 	defer cancel()
 
 	// Print capabilities
-	if videoProcessingCapability {
-		fmt.Printf("%s Video Processing: %v\n", getStatusSymbol(true), videoProcessingCapability)
+	capabilities := llmProvider.Capabilities()
+	if capabilities.Video {
+		fmt.Printf("%s Video Processing: %v\n", getStatusSymbol(true), capabilities.Video)
 	} else {
 		fmt.Printf("%s Video Processing: Unsupported by LLM\n", getStatusSymbol(false))
 	}
-	if audioProcessingCapability {
-		fmt.Printf("%s Audio Processing: %v\n", getStatusSymbol(true), audioProcessingCapability)
+	if capabilities.Audio {
+		fmt.Printf("%s Audio Processing: %v\n", getStatusSymbol(true), capabilities.Audio)
 	} else {
 		fmt.Printf("%s Audio Processing: Unsupported by LLM\n", getStatusSymbol(false))
 	}
+	// Find out now rather than on the first mention if the model can't be reached
+	if err := pingLLMProvider(); err != nil {
+		fmt.Printf("%s LLM Provider (%s): %v\n", getStatusSymbol(false), config.LLM.Provider, err)
+	} else {
+		fmt.Printf("%s LLM Provider (%s): Reachable\n", getStatusSymbol(true), config.LLM.Provider)
+	}
+	if detectHEIFSupport() {
+		fmt.Printf("%s HEIC/HEIF Images: Using %s\n", getStatusSymbol(true), heifConverterPath)
+	} else {
+		fmt.Printf("%s HEIC/HEIF Images: heif-convert not found (install libheif to support them)\n", getStatusSymbol(false))
+	}
 
 	PromptAdditionState = config.LLM.PromptAddition != ""
 
@@ -340,6 +510,44 @@ func main() {
 		fmt.Printf("%s Default Prompts: %s\n", getStatusSymbol(true), "Loaded")
 	}
 
+	if prompt := config.LLM.ProviderPrompts[config.LLM.Provider]; prompt != "" {
+		fmt.Printf("%s Provider Prompt (%s): Set to \"%.30s...\"\n", getStatusSymbol(true), config.LLM.Provider, prompt)
+	}
+
+	if config.LLM.Narration != "" {
+		fmt.Printf("%s Narration Voice: %s person\n", getStatusSymbol(true), config.LLM.Narration)
+	}
+
+	if len(config.LLM.Glossary) > 0 {
+		var included int
+		glossaryTerms, included = buildGlossary(config.LLM.Glossary, config.LLM.GlossaryMaxChars)
+		if included < len(config.LLM.Glossary) {
+			fmt.Printf("%s Glossary: %d of %d terms (glossary_max_chars reached)\n", getStatusSymbol(false), included, len(config.LLM.Glossary))
+		} else {
+			fmt.Printf("%s Glossary: %d terms\n", getStatusSymbol(true), included)
+		}
+	}
+
+	if config.KnownImages.Enabled {
+		knownImagesFile := config.KnownImages.File
+		if knownImagesFile == "" {
+			knownImagesFile = "known_images.json"
+		}
+		if err := loadKnownImages(knownImagesFile); err != nil {
+			fmt.Printf("%s Known Images: Error loading %s: %v\n", getStatusSymbol(false), knownImagesFile, err)
+		} else {
+			fmt.Printf("%s Known Images: %d curated captions\n", getStatusSymbol(true), len(knownImages))
+		}
+	}
+
+	if config.Cache.Enabled {
+		if err := loadAltTextCache(); err != nil {
+			fmt.Printf("%s Alt-Text Cache: Error loading %s: %v\n", getStatusSymbol(false), altTextCacheFile, err)
+		} else {
+			fmt.Printf("%s Alt-Text Cache: %d entries\n", getStatusSymbol(true), len(altTextCache.Entries))
+		}
+	}
+
 	// Set up Gemini AI model (needed for dev mode too if using gemini)
 	err = Setup(config.Gemini.APIKey)
 	if err != nil && !devMode {
@@ -378,7 +586,7 @@ func main() {
 
 	if config.Profile.Enabled {
 		if err := updateBotProfile(c, config); err != nil {
-			fmt.Printf("%s Warning: Failed to update profile fields: %v\n", Yellow, err)
+			logWarnf("Failed to update profile fields: %v", err)
 		}
 	} else {
 		fmt.Printf("%s Dynamic Profile Fields: %s\n", getStatusSymbol(false), "Disabled")
@@ -387,7 +595,12 @@ func main() {
 	// Connect to Mastodon streaming API
 	ws := c.NewWSClient()
 
-	events, err := ws.StreamingWSUser(ctx)
+	// The stream has its own context so a shutdown can stop new mentions while in-flight ones finish
+	streamCtx, stopStream := context.WithCancel(ctx)
+	defer stopStream()
+	handleShutdownSignals(stopStream)
+
+	events, err := ws.StreamingWSUser(streamCtx)
 	if err != nil {
 		log.Fatalf("Error connecting to streaming API: %v", err)
 	}
@@ -407,6 +620,12 @@ func main() {
 		fmt.Printf("%s Alt Text Reminders: %v\n", getStatusSymbol(config.AltTextReminders.Enabled), config.AltTextReminders.Enabled)
 	}
 
+	// Let admin commands that change the stores below know the bot is running
+	if err := writeBotPIDFile(); err != nil {
+		logWarnf("Error writing %s: %v", botPIDFile, err)
+	}
+	defer removeBotPIDFile()
+
 	// Initialize the rate limiter
 	rateLimiter = NewRateLimiter()
 
@@ -416,19 +635,11 @@ func main() {
 			log.Fatalf("Error loading rate limiter state: %v", err)
 		}
 
-		// Reset minute counts every minute
-		go func() {
-			for {
-				time.Sleep(1 * time.Minute)
-				rateLimiter.ResetMinuteCounts()
-			}
-		}()
-
-		// Reset hour counts every hour
+		// Drop requests that left the window, reset exceeded counts and prune the account age cache every hour
 		go func() {
 			for {
 				time.Sleep(1 * time.Hour)
-				rateLimiter.ResetHourCounts()
+				rateLimiter.PruneRequests()
 			}
 		}()
 	}
@@ -436,6 +647,9 @@ func main() {
 	// Start a goroutine for periodic cleanup of old reply entries
 	go cleanupOldEntries()
 
+	// Remove temporary media files left behind by a process killed mid-generation
+	sweepStaleTempFiles()
+
 	if err := loadConsentRequestsFromFile("consent_requests.json"); err != nil {
 		log.Fatalf("Error loading consent requests: %v", err)
 	}
@@ -456,22 +670,28 @@ func main() {
 
 	// Initialize pending GDPR requests (for PixelFed and similar platforms)
 	if err := InitializePendingGDPRRequests(); err != nil {
-		log.Printf("Warning: Error loading pending GDPR requests: %v", err)
+		logWarnf("Error loading pending GDPR requests: %v", err)
 	}
 
 	// Start cleanup routine for expired GDPR requests
 	StartGDPRCleanupRoutine()
 
+	// Revoke the consent of users who block the bot
+	StartBlockCheckRoutine(c)
+
 	fmt.Printf("%s Legacy Consent System: %v\n", getStatusSymbol(config.Behavior.AskForConsent), config.Behavior.AskForConsent)
 
 	// Start metrics manager
-	metricsManager = NewMetricsManager(config.Metrics.Enabled, "metrics.json", 10*time.Second)
+	metricsManager = NewMetricsManager(config.Metrics.Enabled, "metrics.json", 10*time.Second, config.Metrics.RetentionDays)
 	defer metricsManager.stop()
 
 	fmt.Printf("%s Metrics Collection: %v\n", getStatusSymbol(config.Metrics.Enabled), config.Metrics.Enabled)
 
 	if config.Metrics.DashboardEnabled {
-		dashboard.StartDashboard("metrics.json", config.Metrics.DashboardPort)
+		dashboard.StartDashboard("metrics.json", config.Metrics.DashboardPort, map[string]func() interface{}{
+			"languages": func() interface{} { return metricsManager.GetLanguageStats() },
+			"energy":    func() interface{} { return metricsManager.GetEnergyStats() },
+		})
 		fmt.Printf("%s Metrics Dashboard: %s\n", getStatusSymbol(true), "http://localhost:"+strconv.Itoa(config.Metrics.DashboardPort))
 	} else {
 		fmt.Printf("%s Metrics Dashboard: %v\n", getStatusSymbol(false), config.Metrics.DashboardEnabled)
@@ -481,14 +701,38 @@ func main() {
 		if err := InitAPIKeyStore("api_keys.json"); err != nil {
 			log.Fatalf("Error initializing API key store: %v", err)
 		}
-		StartAPIServer(config.API.Port, config.API.MonthlyLimit)
+		switch config.API.EmailProvider {
+		case "", "postmark", "smtp":
+		default:
+			log.Fatalf("Invalid email_provider: %s (expected \"postmark\" or \"smtp\")", config.API.EmailProvider)
+		}
+		StartEmailQueue(c)
+		for _, key := range ListAPIKeys() {
+			if _, ok := config.API.Tiers[key.Tier]; key.Tier != "" && !ok {
+				logWarnf("API key of %s uses unknown tier %q, the default limits apply", key.Email, key.Tier)
+			}
+		}
+		for _, format := range config.API.AcceptedFormats {
+			if !slices.Contains(supportedImageFormats, format) {
+				log.Fatalf("Unsupported API image format: %s (supported: %s)", format, strings.Join(supportedImageFormats, ", "))
+			}
+		}
+		monthlyLimits := map[string]int{"image": config.API.MonthlyLimit}
+		for mediaType, limit := range config.API.MediaLimits {
+			if mediaType != "image" {
+				monthlyLimits[mediaType] = limit
+			}
+		}
+		StartAPIServer(config.API.Port, monthlyLimits)
 	}
 
 	fmt.Printf("%s Public API: %v\n", getStatusSymbol(config.API.Enabled), config.API.Enabled)
 
+	fmt.Printf("%s Caption Archive: %v\n", getStatusSymbol(config.Archive.Enabled), config.Archive.Enabled)
+
 	// Display power metrics status if using a local model
 	if config.LLM.Provider != "gemini" {
-		powerMetricsStatus := fmt.Sprintf("%v (%.1f watts)", config.PowerMetrics.Enabled, config.PowerMetrics.GPUWatts)
+		powerMetricsStatus := fmt.Sprintf("%v (%.1f GPU + %.1f CPU watts, %.1f idle)", config.PowerMetrics.Enabled, config.PowerMetrics.GPUWatts, config.PowerMetrics.CPUWatts, config.PowerMetrics.IdleWatts)
 		fmt.Printf("%s Power Consumption Metrics: %s\n", getStatusSymbol(config.PowerMetrics.Enabled), powerMetricsStatus)
 	}
 
@@ -521,7 +765,7 @@ func main() {
 					parentStatus, err := c.GetStatus(ctx, parentStatusID)
 
 					if parentStatus == nil {
-						log.Printf("Error fetching parent status: %v", err)
+						logErrorf("Error fetching parent status: %v", err)
 						break
 					}
 
@@ -547,11 +791,11 @@ func main() {
 					} else {
 						// Check if this might be a GDPR consent response
 						isGDPRConsent := HandleGDPRConsentResponse(c, e.Notification.Status)
-						if !isGDPRConsent {
+						if !isGDPRConsent && !handleCorrectionReply(c, e.Notification.Status, parentStatus) && !handleRegenerateReply(c, e.Notification.Status, parentStatus) && !handleForgetRequest(c, e.Notification.Status) && !handleDataRequest(c, e.Notification.Status) {
 							handleMention(c, e.Notification)
 						}
 					}
-				} else {
+				} else if !handleCaptionPreferenceCommand(c, e.Notification.Status) && !handleForgetRequest(c, e.Notification.Status) && !handleDataRequest(c, e.Notification.Status) {
 					handleMention(c, e.Notification)
 				}
 			case "follow":
@@ -559,12 +803,16 @@ func main() {
 			}
 		case *mastodon.UpdateEvent:
 			handleUpdate(c, e.Status)
+		case *mastodon.UpdateEditEvent:
+			handleEditEvent(c, e.Status)
 		case *mastodon.ErrorEvent:
-			log.Printf("Error event: %v", e.Error())
+			logErrorf("Error event: %v", e.Error())
 		case *mastodon.DeleteEvent:
 			handleDeleteEvent(c, e.ID)
 		}
 	}
+
+	shutdown(cancel)
 }
 
 // fetchAndVerifyBotAccountID fetches and prints the bot account details to verify the account ID
@@ -584,7 +832,7 @@ func Setup(apiKey string) error {
 		ctx = context.Background()
 	}
 
-	if config.LLM.Provider != "gemini" {
+	if config.LLM.Provider != "gemini" && !slices.Contains(config.LLM.FallbackProviders, "gemini") {
 		return nil
 	}
 
@@ -603,10 +851,14 @@ func Setup(apiKey string) error {
 		geminiModelName = config.Gemini.Model
 	}
 	if geminiGenerationConfig == nil {
-		geminiGenerationConfig = cloneGenerateContentConfig(&genai.GenerateContentConfig{
-			Temperature: genai.Ptr(config.Gemini.Temperature),
-			TopK:        genai.Ptr(float32(config.Gemini.TopK)),
-		})
+		generationConfig, err := newGeminiGenerationConfig(config)
+		if err != nil {
+			return err
+		}
+		geminiGenerationConfig = generationConfig
+	}
+	if geminiUploadSlots == nil && config.Gemini.MaxConcurrentUploads > 0 {
+		geminiUploadSlots = make(chan struct{}, config.Gemini.MaxConcurrentUploads)
 	}
 
 	return nil
@@ -647,7 +899,7 @@ func openaiSetup(apiKey string) error {
 
 // handleMention processes incoming mentions and generates alt-text descriptions
 func handleMention(c *mastodon.Client, notification *mastodon.Notification) {
-	if isDNI(&notification.Account) {
+	if isSelf(&notification.Account) || isDNI(&notification.Account) {
 		return
 	}
 
@@ -664,25 +916,38 @@ func handleMention(c *mastodon.Client, notification *mastodon.Notification) {
 	case mastodon.ID:
 		originalStatusID = id
 	default:
-		log.Printf("Unexpected type for InReplyToID: %T", originalStatus)
+		logWarnf("Unexpected type for InReplyToID: %T", originalStatus)
 	}
 
-	status, err := c.GetStatus(ctx, originalStatusID)
+	parent, err := c.GetStatus(ctx, originalStatusID)
 	if err != nil {
-		log.Printf("Error fetching original status: %v", err)
+		logErrorf("Error fetching original status: %v", err)
 		return
 	}
 
+	// A mention under a boost or a quote post is about the media of the post it shares
+	status := resolveMediaStatus(c, parent)
+	originalStatusID = status.ID
+
+	// Many posts come without a language, guess it so replies aren't all in the default language
+	fillStatusLanguage(notification.Status, status)
+
 	//Check if the original status has any media attachments
 	if len(status.MediaAttachments) == 0 {
 		return
 	}
 
+	// Skip mentions that were only carried along from the thread
+	if config.Behavior.IgnoreInheritedMentions && !isDirectMention(notification.Status, parent) {
+		logInfof("Ignoring inherited mention from %s in status %s", notification.Account.Acct, notification.Status.ID)
+		return
+	}
+
 	// Skip if this status is already being processed
 	processingIDsMu.Lock()
 	if processingIDs[originalStatusID] {
 		processingIDsMu.Unlock()
-		log.Printf("Already processing status %s, skipping duplicate request", originalStatusID)
+		logInfof("Already processing status %s, skipping duplicate request", originalStatusID)
 		return
 	}
 	processingIDs[originalStatusID] = true
@@ -693,22 +958,34 @@ func handleMention(c *mastodon.Client, notification *mastodon.Notification) {
 		processingIDsMu.Unlock()
 	}()
 
+	// Don't let the bot take over a thread where it was asked to caption many posts
+	if !checkThreadReplyLimit(c, status, notification) {
+		return
+	}
+
+	// Experimental: "rate alt" asks for feedback on the existing alt-text instead of a new description
+	if config.Behavior.AltTextReview && isAltTextReviewRequest(notification.Status) {
+		reviewAltText(c, status, notification)
+		return
+	}
+
 	// Check if the person who mentioned the bot is the OP
 	if status.Account.ID == notification.Account.ID {
 		userID := string(notification.Account.ID)
 		// If user hasn't provided GDPR consent, request it first
-		if !HasUserConsent(userID) {
-			log.Printf("User %s has not provided GDPR consent, requesting it", notification.Account.Acct)
+		if !isTrustedInstance(notification.Account.Acct) && !HasUserConsent(userID) {
+			logInfof("User %s has not provided GDPR consent, requesting it", notification.Account.Acct)
 
 			_, err := RequestGDPRConsent(c, userID, notification.Account.Acct, notification.Status.Language, notification.Status.ID, false)
 			if err != nil {
-				log.Printf("Error requesting GDPR consent: %v", err)
+				logErrorf("Error requesting GDPR consent: %v", err)
 			}
 			return
 		}
-		generateAndPostAltText(c, status, notification.Status.ID)
-	} else if !config.Behavior.AskForConsent {
-		generateAndPostAltText(c, status, notification.Status.ID)
+		// The OP can say what the media shows in the mention itself, e.g. the name of their cat
+		generateAndPostAltText(c, status, notification.Status.ID, altTextOptions{UserContext: mentionContext(notification.Status)})
+	} else if !config.Behavior.AskForConsent && !newAccountNeedsConsent(c, string(notification.Account.ID)) {
+		generateAndPostAltText(c, status, notification.Status.ID, altTextOptions{})
 	} else {
 		requestConsent(c, status, notification)
 	}
@@ -720,7 +997,7 @@ func requestConsent(c *mastodon.Client, status *mastodon.Status, notification *m
 	hasAltText := true
 
 	for _, attachment := range status.MediaAttachments {
-		if attachment.Description == "" && (attachment.Type == "image" || ((attachment.Type == "video" || attachment.Type == "gifv" && videoProcessingCapability) || (attachment.Type == "audio" && audioProcessingCapability))) {
+		if attachment.Description == "" && llmProvider.Capabilities().canDescribe(attachment.Type) {
 			hasAltText = false
 		}
 	}
@@ -735,8 +1012,10 @@ func requestConsent(c *mastodon.Client, status *mastodon.Status, notification *m
 	}
 
 	consentRequests[status.ID] = ConsentRequest{
-		RequestID: notification.Status.ID,
-		Timestamp: time.Now(),
+		RequestID:   notification.Status.ID,
+		Timestamp:   time.Now(),
+		AuthorID:    string(status.Account.ID),
+		RequesterID: string(notification.Account.ID),
 	}
 
 	message := fmt.Sprintf("@%s "+getLocalizedString(notification.Status.Language, "consentRequest", "response"), status.Account.Acct, notification.Account.Acct)
@@ -751,6 +1030,10 @@ func requestConsent(c *mastodon.Client, status *mastodon.Status, notification *m
 		return
 	}
 
+	if !allowReply(c) {
+		return
+	}
+
 	_, err := c.PostStatus(ctx, &mastodon.Toot{
 		Status:      message,
 		InReplyToID: status.ID,
@@ -758,11 +1041,11 @@ func requestConsent(c *mastodon.Client, status *mastodon.Status, notification *m
 		Language:    notification.Status.Language,
 	})
 	if err != nil {
-		log.Printf("Error posting consent request: %v", err)
+		logErrorf("Error posting consent request: %v", err)
 	}
 
 	if err := saveConsentRequestsToFile("consent_requests.json"); err != nil {
-		log.Printf("Error saving consent requests: %v", err)
+		logErrorf("Error saving consent requests: %v", err)
 	}
 }
 
@@ -771,55 +1054,81 @@ func handleConsentResponse(c *mastodon.Client, ID mastodon.ID, consentStatus *ma
 	originalStatusID := ID
 	status, err := c.GetStatus(ctx, originalStatusID)
 	if err != nil {
-		log.Printf("Error fetching original status for ID %s: %v", originalStatusID, err)
+		logErrorf("Error fetching original status for ID %s: %v", originalStatusID, err)
+		return
+	}
+
+	if isSelf(&consentStatus.Account) {
 		return
 	}
 
 	if consentStatus.Account.Acct != status.Account.Acct {
-		log.Printf("Unauthorized consent response from: %s, expected: %s", consentStatus.Account.Acct, status.Account.Acct)
+		logWarnf("Unauthorized consent response from: %s, expected: %s", consentStatus.Account.Acct, status.Account.Acct)
 		return
 	}
 
 	// Clean up HTML content to extract plain text
 	plainTextContent := stripHTMLTags(consentStatus.Content)
-	log.Printf("Cleaned consent content: %q from user: %s", plainTextContent, consentStatus.Account.Acct)
+	logDebugf("Cleaned consent content: %q from user: %s", plainTextContent, consentStatus.Account.Acct)
 
 	if plainTextContent == "" {
-		log.Printf("No content in consent response from: %s", consentStatus.Account.Acct)
+		logDebugf("No content in consent response from: %s", consentStatus.Account.Acct)
 		return
 	}
 
 	// Split content into words and check the last word
 	consentResponse := strings.Fields(plainTextContent)
 	if len(consentResponse) == 0 {
-		log.Printf("Empty content after stripping HTML.")
+		logDebugf("Empty content after stripping HTML.")
 		return
 	}
 	lastWord := strings.ToLower(consentResponse[len(consentResponse)-1])
-	log.Printf("Extracted last word: %q from cleaned content", lastWord)
+	logDebugf("Extracted last word: %q from cleaned content", lastWord)
 
 	if lastWord == "y" || lastWord == "yes" {
-		log.Printf("Consent granted by the original poster: %s", consentStatus.Account.Acct)
-		generateAndPostAltText(c, status, consentStatus.ID)
+		logInfof("Consent granted by the original poster: %s", consentStatus.Account.Acct)
+		generateAndPostAltText(c, status, consentStatus.ID, altTextOptions{})
 		metricsManager.logConsentRequest(string(status.Account.ID), true)
 	} else {
-		log.Printf("Consent denied based on last word: %q from user: %s", lastWord, consentStatus.Account.Acct)
+		logInfof("Consent denied based on last word: %q from user: %s", lastWord, consentStatus.Account.Acct)
 		metricsManager.logConsentRequest(string(status.Account.ID), false)
 	}
 
 	delete(consentRequests, originalStatusID)
-	log.Printf("Removed consent request for ID %s after processing", originalStatusID)
+	logDebugf("Removed consent request for ID %s after processing", originalStatusID)
 
 	if err := saveConsentRequestsToFile("consent_requests.json"); err != nil {
-		log.Printf("Error saving consent requests: %v", err)
+		logErrorf("Error saving consent requests: %v", err)
+	}
+}
+
+// limitMediaPerPost returns the attachments of a post to describe under [behavior] max_media_per_post,
+// and how many of them are skipped
+func limitMediaPerPost(attachments []mastodon.Attachment) ([]mastodon.Attachment, int) {
+	limit := config.Behavior.MaxMediaPerPost
+	if limit <= 0 || len(attachments) <= limit {
+		return attachments, 0
+	}
+	if config.Behavior.OverMediaLimit == "skip" {
+		return nil, len(attachments)
 	}
+	return attachments[:limit], len(attachments) - limit
+}
+
+// isSelf checks if an account is the bot's own, so it never replies to itself. The account ID is
+// compared once it's known, as usernames can look alike across instances.
+func isSelf(account *mastodon.Account) bool {
+	if botAcct.ID != "" {
+		return account.ID == botAcct.ID
+	}
+	return account.Acct == config.Server.Username
 }
 
 // isDNI checks if an account meets the Do Not Interact (DNI) conditions
 func isDNI(account *mastodon.Account) bool {
 	dniList := config.DNI.Tags
 
-	if account.Acct == config.Server.Username {
+	if isSelf(account) {
 		return true
 	} else if account.Bot && config.DNI.IgnoreBots {
 		return true
@@ -834,56 +1143,300 @@ func isDNI(account *mastodon.Account) bool {
 	return false
 }
 
+// isDirectMention checks if the bot was intentionally mentioned in a status, rather than
+// inherited from the thread through the reply's automatic leading mentions
+func isDirectMention(status *mastodon.Status, parent *mastodon.Status) bool {
+	var botMention *mastodon.Mention
+	for i, mention := range status.Mentions {
+		if mention.ID == botAcct.ID {
+			botMention = &status.Mentions[i]
+			break
+		}
+	}
+	if botMention == nil {
+		// Can't tell from the mentions list, so assume it was intended
+		return true
+	}
+
+	// A mention typed after the leading block of reply mentions was added on purpose
+	words := strings.Fields(stripHTMLTags(status.Content))
+	leading := true
+	for _, word := range words {
+		if !strings.HasPrefix(word, "@") {
+			leading = false
+			continue
+		}
+		handle := strings.SplitN(strings.TrimPrefix(strings.TrimRight(word, ".,!?:;"), "@"), "@", 2)[0]
+		if !leading && strings.EqualFold(handle, botMention.Username) {
+			return true
+		}
+	}
+
+	// The bot sits in the leading block, it's inherited if the parent already included it
+	if parent.Account.ID == botAcct.ID {
+		return false
+	}
+	for _, mention := range parent.Mentions {
+		if mention.ID == botAcct.ID {
+			return false
+		}
+	}
+
+	return true
+}
+
+// handleCaptionPreferenceCommand handles a direct message asking the bot to stop ("mentions only") or
+// resume ("auto captions") captioning the sender's own posts. It returns false if the status isn't such a command.
+func handleCaptionPreferenceCommand(c *mastodon.Client, status *mastodon.Status) bool {
+	if status.Visibility != "direct" {
+		return false
+	}
+
+	content := strings.ToLower(stripHTMLTags(status.Content))
+	var mentionsOnly bool
+	switch {
+	case strings.Contains(content, "mentions only"):
+		mentionsOnly = true
+	case strings.Contains(content, "auto captions"):
+		mentionsOnly = false
+	default:
+		return false
+	}
+
+	userID := string(status.Account.ID)
+
+	// The preference is stored with the consent record, so consent has to be given first
+	if !HasUserConsent(userID) {
+		_, err := RequestGDPRConsent(c, userID, status.Account.Acct, status.Language, status.ID, false)
+		if err != nil {
+			logErrorf("Error requesting GDPR consent: %v", err)
+		}
+		return true
+	}
+
+	if err := SetMentionsOnly(userID, mentionsOnly); err != nil {
+		logErrorf("Error saving caption preference for %s: %v", status.Account.Acct, err)
+		return true
+	}
+	logInfof("User %s set mentions only to %v", status.Account.Acct, mentionsOnly)
+
+	key := "autoCaptionsEnabled"
+	if mentionsOnly {
+		key = "mentionsOnlyEnabled"
+	}
+	message := fmt.Sprintf("@%s %s", status.Account.Acct, getLocalizedString(status.Language, key, "response"))
+
+	// Dev mode: print to terminal instead of posting
+	if devMode {
+		fmt.Printf("\n%s[DEV MODE - Would post caption preference confirmation]%s\n", Yellow, Reset)
+		fmt.Printf("  To: @%s\n", status.Account.Acct)
+		fmt.Printf("  Content: %s\n", message)
+		fmt.Println("---")
+		return true
+	}
+
+	if !allowReply(c) {
+		return true
+	}
+
+	_, err := c.PostStatus(ctx, &mastodon.Toot{
+		Status:      message,
+		InReplyToID: status.ID,
+		Visibility:  "direct",
+		Language:    status.Language,
+	})
+	if err != nil {
+		logErrorf("Error posting caption preference confirmation: %v", err)
+	}
+
+	return true
+}
+
+// isAltTextReviewRequest checks if a mention asks the bot to "rate alt" instead of describing the media
+func isAltTextReviewRequest(status *mastodon.Status) bool {
+	return altTextReviewCommand.MatchString(stripHTMLTags(status.Content))
+}
+
+// altTextReviewCommand matches "rate alt" as whole words, so "accurate alt-text" isn't taken for it
+var altTextReviewCommand = regexp.MustCompile(`(?i)\brate alt\b`)
+
+// reviewAltText privately sends the requester feedback on the human-written alt-text of a post's images
+func reviewAltText(c *mastodon.Client, status *mastodon.Status, notification *mastodon.Notification) {
+	userID := string(notification.Account.ID)
+	lang := notification.Status.Language
+
+	if !HasUserConsent(userID) {
+		_, err := RequestGDPRConsent(c, userID, notification.Account.Acct, lang, notification.Status.ID, false)
+		if err != nil {
+			logErrorf("Error requesting GDPR consent: %v", err)
+		}
+		return
+	}
+
+	var feedback []string
+	for _, attachment := range status.MediaAttachments {
+		if attachment.Type != "image" || attachment.Description == "" {
+			continue
+		}
+
+		if allowed, resetAt := rateLimiter.Increment(c, userID, notification.Account.Acct); !allowed {
+			logWarnf("User @%s has exceeded their rate limit", notification.Account.Acct)
+			metricsManager.logRateLimitHit(userID)
+			if !resetAt.IsZero() {
+				feedback = append(feedback, rateLimitNotice(lang, resetAt))
+			} else {
+				feedback = append(feedback, getLocalizedString(lang, "altTextError", "response"))
+			}
+			break
+		}
+
+		review, err := generateAltTextReview(attachment.URL, attachment.Description, lang)
+		if err != nil || review == "" {
+			logErrorf("Error reviewing alt-text: %v", err)
+			review = getLocalizedString(lang, "altTextError", "response")
+		}
+		feedback = append(feedback, review)
+	}
+
+	if len(feedback) == 0 {
+		feedback = append(feedback, getLocalizedString(lang, "noAltTextToReview", "response"))
+	}
+
+	message := fmt.Sprintf("@%s %s", notification.Account.Acct, strings.Join(feedback, "\n―\n"))
+
+	// Dev mode: print to terminal instead of posting
+	if devMode {
+		fmt.Printf("\n%s[DEV MODE - Would post alt-text review]%s\n", Yellow, Reset)
+		fmt.Printf("  To: @%s\n", notification.Account.Acct)
+		fmt.Printf("  Visibility: direct\n")
+		fmt.Printf("  Content:\n%s\n", message)
+		fmt.Println("---")
+		return
+	}
+
+	if !allowReply(c) {
+		return
+	}
+
+	// Feedback is always sent privately so it doesn't call anyone out in public
+	_, err := c.PostStatus(ctx, &mastodon.Toot{
+		Status:      message,
+		InReplyToID: notification.Status.ID,
+		Visibility:  "direct",
+		Language:    lang,
+	})
+	if err != nil {
+		logErrorf("Error posting alt-text review: %v", err)
+		return
+	}
+	recordThreadReply(c, status)
+}
+
 // handleFollow processes new follows and follows back
 func handleFollow(c *mastodon.Client, notification *mastodon.Notification) {
+	if isSelf(&notification.Account) {
+		return
+	}
+
 	userID := string(notification.Account.ID)
 
 	// Check if the user has already provided GDPR consent
 	if !HasUserConsent(userID) {
 		// Send a welcome message with GDPR consent request
-		log.Printf("New follower %s, sending GDPR consent request", notification.Account.Acct)
+		logInfof("New follower %s, sending GDPR consent request", notification.Account.Acct)
 
 		// Now send the GDPR consent request as a reply to our welcome message
 		_, err := RequestGDPRConsent(c, userID, notification.Account.Acct, "en", mastodon.ID(""), true) // Hardcoded to English cuz we don't have the user's language
 		if err != nil {
-			log.Printf("Error requesting GDPR consent: %v", err)
+			logErrorf("Error requesting GDPR consent: %v", err)
 		}
 
 	}
 
+	if shouldFollowBack(&notification.Account) {
+		followBack(c, &notification.Account)
+	}
+}
+
+// followBackPolicy is [behavior] follow_back_policy, or what follow_back amounts to when it isn't set
+func followBackPolicy() string {
+	if config.Behavior.FollowBackPolicy != "" {
+		return config.Behavior.FollowBackPolicy
+	}
 	if config.Behavior.FollowBack {
-		_, err := c.AccountFollow(ctx, notification.Account.ID)
-		if err != nil {
-			log.Printf("Error following back: %v", err)
-			return
-		}
-		LogEvent("new_follower")
-		metricsManager.logFollow(string(notification.Account.ID))
-		fmt.Printf("Followed back: %s\n", notification.Account.Acct)
+		return "all"
+	}
+	return "none"
+}
+
+// shouldFollowBack decides whether a new follower is followed back. Under the consented_only policy
+// that happens once they give consent, see followBackAfterConsent.
+func shouldFollowBack(account *mastodon.Account) bool {
+	switch followBackPolicy() {
+	case "all":
+		return true
+	case "non_bots":
+		return !account.Bot
+	case "consented_only":
+		return HasUserConsent(string(account.ID))
+	}
+	return false
+}
+
+// followBack follows an account that follows the bot
+func followBack(c *mastodon.Client, account *mastodon.Account) {
+	_, err := c.AccountFollow(ctx, account.ID)
+	if err != nil {
+		logErrorf("Error following back: %v", err)
+		return
+	}
+	LogEvent("new_follower")
+	metricsManager.logFollow(string(account.ID))
+	logInfof("Followed back: %s", account.Acct)
+}
+
+// followBackAfterConsent follows back a follower who just gave consent, under the consented_only policy
+func followBackAfterConsent(c *mastodon.Client, account *mastodon.Account) {
+	if followBackPolicy() != "consented_only" {
+		return
+	}
+
+	relationships, err := c.GetAccountRelationships(ctx, []string{string(account.ID)})
+	if err != nil {
+		logErrorf("Error checking whether %s follows the bot: %v", account.Acct, err)
+		return
+	}
+	if len(relationships) == 0 || !relationships[0].FollowedBy || relationships[0].Following {
+		return
 	}
+	followBack(c, account)
 }
 
 // handleUpdate processes new posts and generates alt-text descriptions if missing
 func handleUpdate(c *mastodon.Client, status *mastodon.Status) {
-	if status.Account.Acct == config.Server.Username {
+	if isSelf(&status.Account) {
 		return
 	}
 
 	userID := string(status.Account.ID)
 
 	for _, attachment := range status.MediaAttachments {
-		if attachment.Type == "image" || ((attachment.Type == "video" || attachment.Type == "gifv" && videoProcessingCapability) || (attachment.Type == "audio" && audioProcessingCapability)) {
+		if llmProvider.Capabilities().canDescribe(attachment.Type) {
 			if attachment.Description == "" {
+				// The user only wants captions when they ask for them
+				if IsMentionsOnly(userID) {
+					return
+				}
 
-				if !HasUserConsent(userID) {
+				if !isTrustedInstance(status.Account.Acct) && !HasUserConsent(userID) {
 					// Send a GDPR consent request
 					_, err := RequestGDPRConsent(c, userID, status.Account.Acct, status.Language, status.ID, false)
 					if err != nil {
-						log.Printf("Error requesting GDPR consent: %v", err)
+						logErrorf("Error requesting GDPR consent: %v", err)
 					}
 					return
 				}
-				generateAndPostAltText(c, status, status.ID)
+				generateAndPostAltText(c, status, status.ID, altTextOptions{})
 				break
 			} else {
 				LogEventWithUsername("human_written_alt_text", status.Account.Acct)
@@ -892,16 +1445,42 @@ func handleUpdate(c *mastodon.Client, status *mastodon.Status) {
 	}
 }
 
-// generateAndPostAltText generates alt-text for images and posts it as a reply
-func generateAndPostAltText(c *mastodon.Client, status *mastodon.Status, replyToID mastodon.ID) {
+// altTextOptions are the choices of a single request that change how its captions are generated
+type altTextOptions struct {
+	Regenerate  bool   // Another attempt at the captions, see handleRegenerateReply
+	UserContext string // What the poster said about the media, see mentionContext
+	Style       string // How the captions are written, see captionStyle
+}
+
+// generateAndPostAltText describes the media of status and replies to replyToID with the captions
+func generateAndPostAltText(c *mastodon.Client, status *mastodon.Status, replyToID mastodon.ID, opts altTextOptions) {
+	inFlight.Add(1)
+	defer inFlight.Done()
+
+	// Media behind a content warning can be kept out of threads entirely
+	if status.SpoilerText != "" && config.Behavior.CWMediaVisibility == "skip" {
+		logInfof("Not describing post %s as it is behind a content warning", status.ID)
+		return
+	}
+
 	replyPost, err := c.GetStatus(ctx, replyToID)
 	if err != nil {
-		log.Printf("Error fetching reply status: %v", err)
+		logErrorf("Error fetching reply status: %v", err)
 		return
 	}
+	fillStatusLanguage(replyPost, status)
 
 	metricsManager.logRequest(string(replyPost.Account.ID))
 
+	// Plain-language captions for those who asked for them in their bio or, unless it's the post itself, the mention
+	if opts.Style == "" {
+		var mention *mastodon.Status
+		if replyPost.ID != status.ID {
+			mention = replyPost
+		}
+		opts.Style = captionStyle(&replyPost.Account, mention)
+	}
+
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var responses []string
@@ -909,35 +1488,74 @@ func generateAndPostAltText(c *mastodon.Client, status *mastodon.Status, replyTo
 	altTextGenerated := false
 	altTextAlreadyExists := false
 
-	// Track total processing time for power calculation
+	// Track total processing time and the number of generations for power calculation
 	var totalProcessingTimeMs int64
+	var generations int
 	var isLocalModel bool = config.LLM.Provider != "gemini"
 
-	for _, attachment := range status.MediaAttachments {
+	// Providers that wrote the captions, in the order they did, for the attribution
+	var servedBy []string
+
+	capabilities := llmProvider.Capabilities()
+
+	// Describe the images of the post together so series of images keep their shared context.
+	// A regeneration describes each image on its own, so every caption gets another attempt.
+	// Posts with more media than allowed only get the first ones described, or none at all
+	attachments, skipped := limitMediaPerPost(status.MediaAttachments)
+	if skipped > 0 {
+		logInfof("Skipping %d of the %d media of post %s", skipped, len(status.MediaAttachments), status.ID)
+		LogEvent("media_limit_exceeded")
+	}
+
+	// Count every attachment against the rate limit before any of them is described, so the combined
+	// request only gets the media the user is allowed to have described. Denied ones map to when the limit resets.
+	rateLimited := make(map[mastodon.ID]time.Time)
+	var allowedAttachments []mastodon.Attachment
+	for _, attachment := range attachments {
+		if allowed, resetAt := rateLimiter.Increment(c, string(replyPost.Account.ID), replyPost.Account.Acct); !allowed {
+			rateLimited[attachment.ID] = resetAt
+			continue
+		}
+		allowedAttachments = append(allowedAttachments, attachment)
+	}
+
+	var combinedCaptions map[mastodon.ID]string
+	var combinedServedBy string
+	if config.Behavior.CombinedMultiImage && capabilities.MultiImage && !opts.Regenerate {
+		combinedCaptions, combinedServedBy = generateCombinedImageAltText(allowedAttachments, replyPost.Language, replyPost.Account.Acct, opts)
+	}
+
+	for _, attachment := range attachments {
 		wg.Add(1)
 		go func(attachment mastodon.Attachment) {
 			defer wg.Done()
-			var altText string
+			var altText, provider string
 			var err error
 
 			start := time.Now()
 
 			// Check if the user has exceeded their rate limit
-			if !rateLimiter.Increment(c, string(replyPost.Account.ID)) {
-				log.Printf("User @%s has exceeded their rate limit", replyPost.Account.Acct)
+			if resetAt, limited := rateLimited[attachment.ID]; limited {
+				logWarnf("User @%s has exceeded their rate limit", replyPost.Account.Acct)
 				metricsManager.logRateLimitHit(string(replyPost.Account.ID))
 				mu.Lock()
-				responses = append(responses, getLocalizedString(replyPost.Language, "altTextError", "response"))
+				if !resetAt.IsZero() {
+					responses = append(responses, rateLimitNotice(replyPost.Language, resetAt))
+				} else {
+					responses = append(responses, getLocalizedString(replyPost.Language, "altTextError", "response"))
+				}
 				mu.Unlock()
 				return
 			}
 
-			if attachment.Type == "image" && attachment.Description == "" {
-				altText, err = generateImageAltText(attachment.URL, replyPost.Language)
-			} else if (attachment.Type == "video" || attachment.Type == "gifv") && videoProcessingCapability && attachment.Description == "" {
-				altText, err = generateVideoAltText(attachment.URL, replyPost.Language)
-			} else if attachment.Type == "audio" && audioProcessingCapability && attachment.Description == "" {
-				altText, err = generateAudioAltText(attachment.URL, replyPost.Language)
+			if caption, ok := combinedCaptions[attachment.ID]; ok && attachment.Description == "" {
+				altText, provider = caption, combinedServedBy
+			} else if attachment.Type == "image" && attachment.Description == "" {
+				altText, provider, err = generateImageAltText(attachment.URL, replyPost.Language, replyPost.Account.Acct, opts)
+			} else if (attachment.Type == "video" || attachment.Type == "gifv") && capabilities.Video && attachment.Description == "" {
+				altText, provider, err = generateVideoAltText(attachment.URL, replyPost.Language)
+			} else if attachment.Type == "audio" && capabilities.Audio && attachment.Description == "" {
+				altText, provider, err = generateAudioAltText(attachment.URL, replyPost.Language)
 			} else if attachment.Description != "" {
 				if !altTextGenerated && !altTextAlreadyExists {
 					mu.Lock()
@@ -946,21 +1564,33 @@ func generateAndPostAltText(c *mastodon.Client, status *mastodon.Status, replyTo
 					altTextAlreadyExists = true
 				}
 				return
-			} else if videoProcessingCapability && audioProcessingCapability {
+			} else if capabilities.Video && capabilities.Audio {
 				mu.Lock()
 				responses = append(responses, getLocalizedString(replyPost.Language, "unsupportedFile", "response"))
 				mu.Unlock()
 				return
 			}
 
-			if err != nil {
-				log.Printf("Error generating alt-text: %v", err)
+			if errors.Is(err, errDecorativeImage) {
+				mu.Lock()
+				responses = append(responses, getLocalizedString(replyPost.Language, "decorativeImage", "response"))
+				mu.Unlock()
+				return
+			} else if errors.Is(err, errUnusableAltText) {
+				mu.Lock()
+				responses = append(responses, getLocalizedString(replyPost.Language, "couldNotDescribe", "response"))
+				mu.Unlock()
+				return
+			} else if err != nil {
+				logErrorf("Error generating alt-text: %v", err)
 				sucessCount -= 1
 				altText = getLocalizedString(replyPost.Language, "altTextError", "response")
 			} else if altText == "" {
-				log.Printf("Error generating alt-text: Empty response")
+				logErrorf("Error generating alt-text: Empty response")
 				sucessCount -= 1
 				altText = getLocalizedString(replyPost.Language, "altTextError", "response")
+			} else {
+				altText = addCaptionPrefix(altText, replyPost.Language)
 			}
 
 			elapsed := time.Since(start).Milliseconds()
@@ -968,6 +1598,10 @@ func generateAndPostAltText(c *mastodon.Client, status *mastodon.Status, replyTo
 			mu.Lock()
 			responses = append(responses, altText)
 			totalProcessingTimeMs += elapsed
+			generations++
+			if provider != "" && !slices.Contains(servedBy, provider) {
+				servedBy = append(servedBy, provider)
+			}
 			mu.Unlock()
 
 			sucessCount += 1
@@ -979,32 +1613,55 @@ func generateAndPostAltText(c *mastodon.Client, status *mastodon.Status, replyTo
 
 	wg.Wait()
 
-	altTextGenerated = sucessCount > 0
+	if skipped == len(status.MediaAttachments) {
+		responses = append(responses, fmt.Sprintf(getLocalizedString(replyPost.Language, "mediaLimitSkipped", "response"), config.Behavior.MaxMediaPerPost))
+	} else if skipped > 0 {
+		responses = append(responses, fmt.Sprintf(getLocalizedString(replyPost.Language, "mediaLimitPartial", "response"), len(attachments), skipped))
+	}
 
-	// Combine all responses with a separator
-	combinedResponse := strings.Join(responses, "\n―\n")
+	altTextGenerated = sucessCount > 0
 
 	// Prepare the content warning for the reply
-	contentWarning := status.SpoilerText
-	if contentWarning != "" && !strings.HasPrefix(contentWarning, "re:") {
-		contentWarning = "re: " + contentWarning
-	}
+	contentWarning := replyContentWarning(status.SpoilerText, replyPost.Language)
 
 	// Add mention to the original poster at the start
-	combinedResponse = fmt.Sprintf("@%s %s", replyPost.Account.Acct, combinedResponse)
+	var prefix, suffix string
+	if config.Behavior.ReplyFormat == "copy" && altTextGenerated && replyPost.Account.ID == status.Account.ID {
+		// Put the captions on their own so the OP can copy them straight into their media descriptions.
+		// The bot can't edit the OP's media itself, as the Mastodon API only allows the author to do that.
+		prefix = fmt.Sprintf("@%s %s\n\n", replyPost.Account.Acct, getLocalizedString(replyPost.Language, "copyAltTextHint", "response"))
+	} else {
+		prefix = fmt.Sprintf("@%s ", replyPost.Account.Acct)
+	}
 
-	// Add provider attribution
-	if altTextGenerated {
-		combinedResponse = fmt.Sprintf("%s\n\n%s", getProviderAttribution(config, replyPost.Language), combinedResponse)
+	// Add provider attribution, curated and cached captions are credited to the configured provider
+	if altTextGenerated && config.Behavior.AttributionEnabled {
+		if len(servedBy) == 0 {
+			servedBy = []string{config.LLM.Provider}
+		}
+		attributions := make([]string, len(servedBy))
+		for i, provider := range servedBy {
+			attributions[i] = getProviderAttribution(config, replyPost.Language, provider)
+		}
+		prefix = fmt.Sprintf("%s\n\n%s", strings.Join(attributions, "\n"), prefix)
 	}
 
 	// Add power consumption information at the end if enabled and using a local model
 	if config.PowerMetrics.Enabled && isLocalModel && altTextGenerated {
-		powerConsumption := calculatePowerConsumption(totalProcessingTimeMs, config.PowerMetrics.GPUWatts)
-		powerInfo := fmt.Sprintf("\n\n"+getLocalizedString(replyPost.Language, "energyUsageMessage", "response"), powerConsumption)
-		combinedResponse += powerInfo
+		powerConsumption := calculatePowerConsumption(totalProcessingTimeMs, generations)
+		if powerInfo := energyUsageMessage(replyPost.Language, powerConsumption); powerInfo != "" {
+			suffix += "\n\n" + powerInfo
+		}
+	}
+
+	// Let new accounts know they are being watched more closely
+	if config.RateLimit.NewAccountPolicy == "warn" && altTextGenerated && rateLimiter.CheckNewAccount(c, string(replyPost.Account.ID)) {
+		suffix += "\n\n" + getLocalizedString(replyPost.Language, "newAccountWarning", "response")
 	}
 
+	// Combine all responses with a separator, sharing the length limit between them
+	combinedResponse := fitReply(prefix, responses, suffix, maxAltTextChars())
+
 	// Post the combined response
 	if combinedResponse != "" {
 		visibility := replyPost.Visibility
@@ -1049,11 +1706,22 @@ func generateAndPostAltText(c *mastodon.Client, status *mastodon.Status, replyTo
 			visibility = "direct"
 		}
 
-		// Dev mode: print to terminal instead of posting
-		if devMode {
-			fmt.Printf("\n%s[DEV MODE - Would post reply]%s\n", Yellow, Reset)
+		// Descriptions of media behind a content warning can be limited to the people mentioned
+		if status.SpoilerText != "" && config.Behavior.CWMediaVisibility == "direct" {
+			visibility = "direct"
+		}
+
+		// Dev and preview mode: print to terminal instead of posting
+		if devMode || previewMode {
+			if previewMode {
+				fmt.Printf("\n%s[PREVIEW - Would post reply]%s\n", Yellow, Reset)
+				fmt.Printf("  In reply to: %s\n", status.URL)
+				fmt.Printf("  Provider: %s\n", config.LLM.Provider)
+			} else {
+				fmt.Printf("\n%s[DEV MODE - Would post reply]%s\n", Yellow, Reset)
+			}
 			fmt.Printf("  To: @%s\n", replyPost.Account.Acct)
-			fmt.Printf("  Visibility: %s\n", visibility)
+			fmt.Printf("  Visibility: %s (original post: %s)\n", visibility, replyPost.Visibility)
 			if contentWarning != "" {
 				fmt.Printf("  CW: %s\n", contentWarning)
 			}
@@ -1062,6 +1730,11 @@ func generateAndPostAltText(c *mastodon.Client, status *mastodon.Status, replyTo
 			return
 		}
 
+		// Safety valve against reply loops, see max_daily_replies
+		if !allowReply(c) {
+			return
+		}
+
 		reply, err := c.PostStatus(ctx, &mastodon.Toot{
 			Status:      combinedResponse,
 			InReplyToID: replyToID,
@@ -1070,15 +1743,28 @@ func generateAndPostAltText(c *mastodon.Client, status *mastodon.Status, replyTo
 			SpoilerText: contentWarning,
 		})
 
+		// The instance may refuse the visibility for this thread, retry privately so the user still gets the captions
+		if err != nil && config.Behavior.RetryAsDirect && visibility != "direct" && isVisibilityError(err) {
+			logWarnf("Reply rejected at %s visibility, retrying as direct: %v", visibility, err)
+			visibility = "direct"
+			reply, err = c.PostStatus(ctx, &mastodon.Toot{
+				Status:      combinedResponse,
+				InReplyToID: replyToID,
+				Visibility:  visibility,
+				Language:    replyPost.Language,
+				SpoilerText: contentWarning,
+			})
+		}
+
 		if err != nil {
-			log.Printf("Error posting reply: %v", err)
+			logErrorf("Error posting reply: %v", err)
 			_, err = c.PostStatus(ctx, &mastodon.Toot{
 				Status:      getLocalizedString(replyPost.Language, "replyError", "response"),
 				InReplyToID: replyToID,
 				Visibility:  visibility,
 			})
 			if err != nil {
-				log.Printf("What the fuck happened here....")
+				logErrorf("What the fuck happened here....")
 			}
 		}
 
@@ -1087,121 +1773,266 @@ func generateAndPostAltText(c *mastodon.Client, status *mastodon.Status, replyTo
 		}
 
 		if reply != nil {
+			recordThreadReply(c, status)
+
 			// Track the reply with a timestamp
 			mapMutex.Lock()
-			replyMap[status.ID] = ReplyInfo{ReplyID: reply.ID, Timestamp: time.Now()}
+			authorID, requesterID := string(status.Account.ID), string(replyPost.Account.ID)
+			replyMap[status.ID] = ReplyInfo{ReplyID: reply.ID, Timestamp: time.Now(), AuthorID: authorID, RequesterID: requesterID}
+			replyOrigins[reply.ID] = ReplyOrigin{OriginalID: status.ID, Timestamp: time.Now(), AuthorID: authorID, RequesterID: requesterID}
 			mapMutex.Unlock()
 		}
 	}
 }
 
-// downloadToTempFile downloads a file from a given URL and saves it to a temporary file.
-// It returns the path to the temporary file.
-func downloadToTempFile(fileURL, prefix, extension string) (string, error) {
-	// Download the file from the remote URL
-	resp, err := http.Get(fileURL)
-	if err != nil {
-		return "", err
+// isVisibilityError reports whether posting failed because the instance rejected the reply's visibility
+func isVisibilityError(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "visibility")
+}
+
+// replyContentWarning builds the reply's content warning from the original one, adding the
+// cw_reply_prefix (localized "re: " by default, "none" to keep it unchanged) only once across nested replies
+func replyContentWarning(spoilerText string, lang string) string {
+	if spoilerText == "" || config.Behavior.CWReplyPrefix == "none" {
+		return spoilerText
 	}
-	defer resp.Body.Close()
 
-	// Check the Content-Length header
-	contentLength := resp.Header.Get("Content-Length")
-	if contentLength != "" {
-		size, err := strconv.ParseInt(contentLength, 10, 64)
-		if err == nil && size > int64(config.ImageProcessing.MaxSizeMB*1024*1024) {
-			return "", fmt.Errorf("file size exceeds maximum limit of %d MB", config.ImageProcessing.MaxSizeMB)
-		}
+	prefix := config.Behavior.CWReplyPrefix
+	if prefix == "" {
+		prefix = getLocalizedString(lang, "cwReplyPrefix", "response")
+	}
+	if prefix == "" {
+		prefix = "re: "
+	}
+
+	lowerSpoiler := strings.ToLower(spoilerText)
+	if strings.HasPrefix(lowerSpoiler, strings.ToLower(strings.TrimSpace(prefix))) || strings.HasPrefix(lowerSpoiler, "re:") {
+		return spoilerText
+	}
+
+	return prefix + spoilerText
+}
+
+// addCaptionPrefix prepends the configured caption prefix, translated for the reply language when available.
+// It runs after post-processing so the label is never altered, and is part of the reply's character count.
+func addCaptionPrefix(altText string, lang string) string {
+	prefix := config.Behavior.CaptionPrefix
+	if prefix == "" {
+		return altText
+	}
+	if translated, ok := config.Behavior.CaptionPrefixTranslations[lang]; ok && translated != "" {
+		prefix = translated
+	}
+	return prefix + " " + altText
+}
+
+// getMedia downloads media with the download_timeout_seconds limit (default 30), so a slow or
+// misbehaving media server can't hang a goroutine. Downloads are tied to the bot's context.
+func getMedia(mediaURL string) (*http.Response, error) {
+	return getMediaWith(http.DefaultTransport, mediaURL)
+}
+
+// getMediaWith downloads media like getMedia over the given transport
+func getMediaWith(transport http.RoundTripper, mediaURL string) (*http.Response, error) {
+	timeout := time.Duration(config.ImageProcessing.DownloadTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	parent := ctx
+	if parent == nil {
+		parent = context.Background()
 	}
 
-	// Read the file content
-	fileData, err := io.ReadAll(resp.Body)
+	req, err := http.NewRequestWithContext(parent, http.MethodGet, mediaURL, nil)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: timeout, Transport: transport}
+	return client.Do(req)
+}
+
+// mediaTooLargeError is returned by readMediaBody when a download exceeds its size limit
+type mediaTooLargeError struct {
+	kind      string
+	maxSizeMB uint
+}
+
+func (e *mediaTooLargeError) Error() string {
+	return fmt.Sprintf("%s size exceeds maximum limit of %d MB", e.kind, e.maxSizeMB)
+}
+
+// readMediaBody reads a media download of at most maxSizeMB. Content-Length is only used to reject
+// oversized files early, as servers can omit it or lie about it, so the body itself is capped too.
+func readMediaBody(resp *http.Response, maxSizeMB uint, kind string) ([]byte, error) {
+	maxBytes := int64(maxSizeMB) * 1024 * 1024
+	sizeError := &mediaTooLargeError{kind: kind, maxSizeMB: maxSizeMB}
+
+	if resp.ContentLength > maxBytes {
+		return nil, sizeError
 	}
 
-	// Create a temporary file to save the content
-	tmpFile, err := os.CreateTemp("", prefix+"-*."+extension)
+	// Read one byte past the limit to tell a file of exactly the maximum size from a larger one
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, sizeError
 	}
-	defer tmpFile.Close()
 
-	// Write the file data to the temporary file
-	if _, err := tmpFile.Write(fileData); err != nil {
-		return "", err
+	return data, nil
+}
+
+// fetchImage downloads an image, enforcing the configured maximum size
+func fetchImage(imageURL string) ([]byte, error) {
+	resp, err := getMedia(imageURL)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	return tmpFile.Name(), nil
+	return readMediaBody(resp, config.ImageProcessing.MaxSizeMB, "file")
 }
 
-// generateImageAltText generates alt-text for an image using Gemini AI or Ollama
-func generateImageAltText(imageURL string, lang string) (string, error) {
-	resp, err := http.Get(imageURL)
+// generateImageAltText generates alt-text for an image using Gemini AI or Ollama, along with the name of
+// the provider that wrote it ("" for curated and cached captions).
+// acct is the account asking for it, whose instance may have its own prompt. Regenerations skip
+// curated and cached captions and sample with a higher temperature.
+func generateImageAltText(imageURL string, lang string, acct string, opts altTextOptions) (string, string, error) {
+	img, err := fetchImage(imageURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Use the operator's curated caption for images that are posted often
+	if caption, ok := lookupKnownImage(img, lang); ok && !opts.Regenerate {
+		logInfof("Using curated caption for known image: %s", imageURL)
+		LogEvent("known_image_caption")
+		return caption, "", nil
+	}
+
+	// Boosted and re-federated posts often bring the same image again.
+	// Instances with their own prompt skip the cache, as it holds captions made with the default prompt.
+	// Regenerated captions and those written with the poster's context or in another style aren't cached either.
+	_, instancePrompt := instancePromptOverride(acct)
+	useCache := !instancePrompt && !opts.Regenerate && opts.UserContext == "" && opts.Style == ""
+	if altText, ok := getCachedAltText(img, lang); ok && useCache {
+		logInfof("Using cached alt-text for image: %s", imageURL)
+		LogEvent("cache_hit")
+		return altText, "", nil
+	}
+
+	// Tracking pixels, spacers and blank images have nothing to describe
+	decoded, _, decodeErr := decodeImage(img)
+	if decodeErr == nil && isDecorativeImage(decoded) {
+		logInfof("Skipping decorative image: %s", imageURL)
+		LogEvent("skipped_decorative")
+		return "", "", errDecorativeImage
+	}
+
+	// Screenshots of text are transcribed rather than described, unless the user asked for another style
+	if opts.Style == "" && config.Transcription.AutoDetect && decodeErr == nil && isTextHeavy(decoded) {
+		logInfof("Transcribing text-heavy image: %s", imageURL)
+		LogEvent("transcribed_text_image")
+		opts.Style = styleTranscribe
+	}
+
+	// Downscale the image to a smaller width using config settings
+	downscaledImg, format, err := downscaleImage(img, config.ImageProcessing.DownscaleWidth)
+	if err != nil {
+		return "", "", err
+	}
+
+	LogEvent("alt_text_generated")
+
+	logInfof("Processing image: %s", imageURL)
+
+	// Animated GIFs are described from several frames so the motion isn't lost
+	// The prompt depends on the provider it's sent to, the guard checks the reply against the last one
+	var prompt string
+	generate := func(name string, provider LLMProvider) (string, error) {
+		prompt = withUserContext(imageAltTextPrompt(downscaledImg, format, lang, acct, opts.Style, name), lang, opts.UserContext)
+		return withLLMRetry(func() (string, error) {
+			return provider.GenerateAltText(prompt, downscaledImg, format, lang)
+		})
+	}
+
+	altText, servedBy, err := generateAnimatedGIFAltText(img, lang, acct, opts.Style)
+	if err != nil || altText == "" {
+		provider := llmProvider
+		if opts.Regenerate {
+			provider = llmProvider.WithTemperatureBoost(regenerateTemperatureBoost)
+		}
+
+		altText, servedBy, err = generateWith(provider, "image", canImage, generate)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	// Refusals and other unusable output get one more try as a single image, with more variety
+	altText, err = guardAltText(postProcessAltText(altText, lang), prompt, lang, func() (string, error) {
+		text, name, err := generateWith(llmProvider.WithTemperatureBoost(regenerateTemperatureBoost), "image", canImage, generate)
+		servedBy = name
+		return text, err
+	})
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	defer resp.Body.Close()
 
-	contentLength := resp.Header.Get("Content-Length")
-	if contentLength != "" {
-		size, err := strconv.ParseInt(contentLength, 10, 64)
-		if err == nil && size > int64(config.ImageProcessing.MaxSizeMB*1024*1024) {
-			return "", fmt.Errorf("file size exceeds maximum limit of %d MB", config.ImageProcessing.MaxSizeMB)
-		}
+	if useCache {
+		cacheAltText(img, lang, altText)
 	}
+	archiveCaption("bot", "image", img, lang, altText)
 
-	img, err := io.ReadAll(resp.Body)
+	return altText, servedBy, nil
+}
+
+// generateAltTextReview asks the LLM to compare an image with its existing alt-text and suggest improvements
+func generateAltTextReview(imageURL string, description string, lang string) (string, error) {
+	img, err := fetchImage(imageURL)
 	if err != nil {
 		return "", err
 	}
 
-	// Downscale the image to a smaller width using config settings
 	downscaledImg, format, err := downscaleImage(img, config.ImageProcessing.DownscaleWidth)
 	if err != nil {
 		return "", err
 	}
 
-	LogEvent("alt_text_generated")
-
-	prompt := getLocalizedString(lang, "generateAltText", "prompt")
+	prompt := getFormattedPrompt(lang, "reviewAltText", description)
 
-	fmt.Println("Processing image: " + imageURL)
+	logInfof("Reviewing alt-text of image: %s", imageURL)
 
-	altText, err := llmProvider.GenerateAltText(prompt, downscaledImg, format, lang)
+	feedback, err := llmProvider.GenerateAltText(prompt, downscaledImg, format, lang)
 	if err != nil {
 		return "", err
 	}
 
-	return postProcessAltText(altText), nil
+	return postProcessAltText(feedback, lang), nil
 }
 
-// generateVideoAltText generates alt-text for a video using the configured LLM provider
-func generateVideoAltText(videoURL string, lang string) (string, error) {
-	resp, err := http.Get(videoURL)
+// generateVideoAltText generates alt-text for a video using the configured LLM provider, along with
+// the name of the provider that wrote it
+func generateVideoAltText(videoURL string, lang string) (string, string, error) {
+	resp, err := getMedia(videoURL)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	defer resp.Body.Close()
 
-	contentLength := resp.Header.Get("Content-Length")
-	if contentLength != "" {
-		size, err := strconv.ParseInt(contentLength, 10, 64)
-		if err == nil && size > int64(config.VideoProcessing.MaxSizeMB*1024*1024) {
-			return "", fmt.Errorf("video file size exceeds maximum limit of %d MB", config.VideoProcessing.MaxSizeMB)
-		}
-	}
-
-	videoData, err := io.ReadAll(resp.Body)
+	videoData, err := readMediaBody(resp, config.VideoProcessing.MaxSizeMB, "video file")
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	LogEvent("video_alt_text_generated")
 
 	prompt := getLocalizedString(lang, "generateVideoAltText", "prompt")
 
-	fmt.Println("Processing video: " + videoURL)
+	logInfof("Processing video: %s", videoURL)
 
 	// Determine the video format from URL or content type
 	format := "mp4" // Default
@@ -1216,17 +2047,38 @@ func generateVideoAltText(videoURL string, lang string) (string, error) {
 		}
 	}
 
-	altText, err := llmProvider.GenerateVideoAltText(prompt, videoData, format, lang)
+	generate := func(_ string, provider LLMProvider) (string, error) {
+		return withLLMRetry(func() (string, error) {
+			return provider.GenerateVideoAltText(prompt, videoData, format, lang)
+		})
+	}
+
+	altText, servedBy, err := generateWith(llmProvider, "video", canVideo, generate)
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+
+	altText, err = guardAltText(postProcessAltText(altText, lang), prompt, lang, func() (string, error) {
+		text, name, err := generateWith(llmProvider.WithTemperatureBoost(regenerateTemperatureBoost), "video", canVideo, generate)
+		servedBy = name
+		return text, err
+	})
+	if err != nil {
+		return "", "", err
 	}
+	archiveCaption("bot", "video", videoData, lang, altText)
 
-	return postProcessAltText(altText), nil
+	return altText, servedBy, nil
 }
 
+// videoFormats and audioFormats are the known video and audio format extensions
+var (
+	videoFormats = []string{"mp4", "webm", "mov", "avi", "mkv", "m4v", "3gp"}
+	audioFormats = []string{"mp3", "wav", "flac", "ogg", "aac", "m4a", "opus"}
+)
+
 // isVideoFormat checks if the given string is a known video format extension
 func isVideoFormat(format string) bool {
-	videoFormats := []string{"mp4", "webm", "mov", "avi", "mkv", "m4v", "3gp"}
 	format = strings.ToLower(format)
 	for _, f := range videoFormats {
 		if format == f {
@@ -1236,23 +2088,55 @@ func isVideoFormat(format string) bool {
 	return false
 }
 
-// generateAudioAltText generates alt-text for an audio file using Gemini AI
-func generateAudioAltText(audioURL string, lang string) (string, error) {
+// generateAudioAltText generates alt-text for an audio file using the configured LLM provider, along with
+// the name of the provider that wrote it
+func generateAudioAltText(audioURL string, lang string) (string, string, error) {
+	resp, err := getMedia(audioURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	audioData, err := readMediaBody(resp, config.ImageProcessing.MaxSizeMB, "audio file")
+	if err != nil {
+		return "", "", err
+	}
+
+	LogEvent("audio_alt_text_generated")
+
 	prompt := getLocalizedString(lang, "generateAudioAltText", "prompt")
 
-	fmt.Println("Processing audio: " + audioURL)
+	logInfof("Processing audio: %s", audioURL)
+
+	// Determine the audio format from URL or content type
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	format := detectMediaFormat("audio", resp.Request.URL.Path, contentType, audioData)
+	if format == "" {
+		format = "mp3"
+	}
+
+	generate := func(_ string, provider LLMProvider) (string, error) {
+		return withLLMRetry(func() (string, error) {
+			return provider.GenerateAudioAltText(prompt, audioData, format, lang)
+		})
+	}
 
-	// Use the helper function to download the audio
-	audioFilePath, err := downloadToTempFile(audioURL, "audio", "mp3")
+	altText, servedBy, err := generateWith(llmProvider, "audio", canAudio, generate)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	defer os.Remove(audioFilePath) // Clean up the file afterwards
 
-	LogEvent("audio_alt_text_generated")
+	altText, err = guardAltText(postProcessAltText(altText, lang), prompt, lang, func() (string, error) {
+		text, name, err := generateWith(llmProvider.WithTemperatureBoost(regenerateTemperatureBoost), "audio", canAudio, generate)
+		servedBy = name
+		return text, err
+	})
+	if err != nil {
+		return "", "", err
+	}
+	archiveCaption("bot", "audio", audioData, lang, altText)
 
-	// Pass the local temporary file path to GenerateAudioAltWithGemini
-	return GenerateAudioAltWithGemini(prompt, audioFilePath)
+	return altText, servedBy, nil
 }
 
 // Generate creates a response using the Gemini AI model
@@ -1276,13 +2160,13 @@ func GenerateImageAltWithGemini(strPrompt string, image []byte, fileExtension st
 	}
 	contents := []*genai.Content{{Parts: parts}}
 
-	fmt.Println("Generating content...")
+	logDebugf("Generating content...")
 
 	resp, err := client.Models.GenerateContent(ctx, geminiModelName, contents, cloneGenerateContentConfig(geminiGenerationConfig))
 	if err != nil {
 		return "", err
 	}
-	return postProcessAltText(getResponse(resp)), nil
+	return postProcessAltText(getResponse(resp), ""), nil
 }
 
 // GenerateVideoAltWithGemini generates alt-text for a video using the Gemini AI model
@@ -1308,23 +2192,11 @@ func GenerateVideoAltWithGemini(strPrompt string, videoFilePath string) (string,
 		return "", err
 	}
 
-	uploadedFile, err := client.Files.Upload(ctx, videoFile, &genai.UploadFileConfig{
-		DisplayName: "Video for Alt-Text",
-		MIMEType:    mimeType,
-	})
+	response, err := uploadGeminiFile(client.Files, videoFile, "Video for Alt-Text", mimeType, 1*time.Second)
 	if err != nil {
 		return "", err
 	}
-
-	// Poll until the file is in the ACTIVE state
-	response := uploadedFile
-	for response.State == genai.FileStateProcessing {
-		time.Sleep(1 * time.Second)
-		response, err = client.Files.Get(ctx, response.Name, nil)
-		if err != nil {
-			return "", err
-		}
-	}
+	defer deleteGeminiFile(client.Files, response.Name)
 
 	// Create a prompt using the text and the URI reference for the uploaded file
 	parts := []*genai.Part{
@@ -1339,7 +2211,7 @@ func GenerateVideoAltWithGemini(strPrompt string, videoFilePath string) (string,
 	}
 
 	// Handle the response of generated text
-	return postProcessAltText(getResponse(resp)), nil
+	return postProcessAltText(getResponse(resp), ""), nil
 }
 
 // GenerateAudioAltWithGemini generates alt-text for an audio file using the Gemini AI model
@@ -1365,23 +2237,11 @@ func GenerateAudioAltWithGemini(strPrompt string, audioFilePath string) (string,
 		return "", err
 	}
 
-	uploadedFile, err := client.Files.Upload(ctx, audioFile, &genai.UploadFileConfig{
-		DisplayName: "Audio for Alt-Text",
-		MIMEType:    mimeType,
-	})
+	response, err := uploadGeminiFile(client.Files, audioFile, "Audio for Alt-Text", mimeType, 10*time.Second)
 	if err != nil {
 		return "", err
 	}
-
-	// Poll until the file is in the ACTIVE state
-	response := uploadedFile
-	for response.State == genai.FileStateProcessing {
-		time.Sleep(10 * time.Second)
-		response, err = client.Files.Get(ctx, response.Name, nil)
-		if err != nil {
-			return "", err
-		}
-	}
+	defer deleteGeminiFile(client.Files, response.Name)
 
 	// Create a prompt using the text and the URI reference for the uploaded file
 	parts := []*genai.Part{
@@ -1396,7 +2256,94 @@ func GenerateAudioAltWithGemini(strPrompt string, audioFilePath string) (string,
 	}
 
 	// Handle the response of generated text
-	return postProcessAltText(getResponse(resp)), nil
+	return postProcessAltText(getResponse(resp), ""), nil
+}
+
+// errGeminiFileTimeout is returned when an uploaded file doesn't become active within upload_max_wait_seconds
+var errGeminiFileTimeout = errors.New("timed out waiting for Gemini to process the uploaded file")
+
+// geminiMaxPollInterval caps the backoff between checks of an uploaded file
+const geminiMaxPollInterval = 30 * time.Second
+
+// geminiUploadSlots bounds how many files are uploaded and processed by Gemini at once, nil means unlimited
+var geminiUploadSlots chan struct{}
+
+// geminiFileAPI is the part of the Gemini Files API that uploads use
+type geminiFileAPI interface {
+	Upload(ctx context.Context, r io.Reader, config *genai.UploadFileConfig) (*genai.File, error)
+	Get(ctx context.Context, name string, config *genai.GetFileConfig) (*genai.File, error)
+	Delete(ctx context.Context, name string, config *genai.DeleteFileConfig) (*genai.DeleteFileResponse, error)
+}
+
+// uploadGeminiFile uploads media to Gemini and polls until it is ready to be used in a prompt.
+// defaultInterval is used when upload_poll_interval_seconds isn't set.
+func uploadGeminiFile(files geminiFileAPI, r io.Reader, displayName string, mimeType string, defaultInterval time.Duration) (*genai.File, error) {
+	// Wait for a free upload slot, the slot is held until the file is ready
+	if geminiUploadSlots != nil {
+		geminiUploadSlots <- struct{}{}
+		defer func() { <-geminiUploadSlots }()
+	}
+
+	uploadedFile, err := files.Upload(ctx, r, &genai.UploadFileConfig{
+		DisplayName: displayName,
+		MIMEType:    mimeType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	interval := defaultInterval
+	if config.Gemini.UploadPollIntervalSeconds > 0 {
+		interval = time.Duration(config.Gemini.UploadPollIntervalSeconds) * time.Second
+	}
+	maxWait := 5 * time.Minute
+	if config.Gemini.UploadMaxWaitSeconds > 0 {
+		maxWait = time.Duration(config.Gemini.UploadMaxWaitSeconds) * time.Second
+	}
+	deadline := time.Now().Add(maxWait)
+
+	// Poll until the file is in the ACTIVE state, backing off as long files keep processing
+	response := uploadedFile
+	for response.State == genai.FileStateProcessing {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			deleteGeminiFile(files, response.Name)
+			return nil, fmt.Errorf("%w (%s after %v)", errGeminiFileTimeout, response.Name, maxWait)
+		}
+		time.Sleep(min(interval, remaining))
+		interval = min(interval*2, max(geminiMaxPollInterval, interval))
+		response, err = files.Get(ctx, response.Name, nil)
+		if err != nil {
+			deleteGeminiFile(files, uploadedFile.Name)
+			return nil, err
+		}
+	}
+
+	if response.State == genai.FileStateFailed {
+		deleteGeminiFile(files, response.Name)
+		return nil, fmt.Errorf("gemini failed to process the uploaded file %s", response.Name)
+	}
+
+	return response, nil
+}
+
+// deleteGeminiFile removes an uploaded file so they don't pile up against the storage quota
+func deleteGeminiFile(files geminiFileAPI, name string) {
+	if _, err := files.Delete(ctx, name, nil); err != nil {
+		logErrorf("Error deleting Gemini file %s: %v", name, err)
+	}
+}
+
+// resizeAlgorithms maps the resize_algorithm config values to their interpolation functions.
+// An empty value keeps the default of Lanczos3.
+var resizeAlgorithms = map[string]resize.InterpolationFunction{
+	"":                   resize.Lanczos3,
+	"nearest":            resize.NearestNeighbor,
+	"bilinear":           resize.Bilinear,
+	"bicubic":            resize.Bicubic,
+	"mitchell_netravali": resize.MitchellNetravali,
+	"lanczos2":           resize.Lanczos2,
+	"lanczos3":           resize.Lanczos3,
 }
 
 // downscaleImage resizes the image to the specified width while maintaining the aspect ratio
@@ -1408,7 +2355,7 @@ func downscaleImage(imgData []byte, width uint) ([]byte, string, error) {
 	}
 
 	// Resize the image to the specified width while maintaining the aspect ratio
-	resizedImg := resize.Resize(width, 0, img, resize.Lanczos3)
+	resizedImg := resize.Resize(width, 0, img, resizeAlgorithms[config.ImageProcessing.ResizeAlgorithm])
 
 	// Convert the image to PNG or JPEG if it is in a different format
 	var buf bytes.Buffer
@@ -1446,6 +2393,10 @@ func downscaleImage(imgData []byte, width uint) ([]byte, string, error) {
 func decodeImage(imgData []byte) (image.Image, string, error) {
 	img, format, err := image.Decode(bytes.NewReader(imgData))
 	if err == nil {
+		// Phone photos are often stored sideways with an EXIF tag saying how to turn them
+		if format == "jpeg" || format == "tiff" {
+			img = applyOrientation(img, exifOrientation(imgData))
+		}
 		return img, format, nil
 	}
 
@@ -1464,7 +2415,7 @@ func decodeImage(imgData []byte) (image.Image, string, error) {
 	// Try decoding as TIFF if the previous decodings fail
 	img, err = tiff.Decode(bytes.NewReader(imgData))
 	if err == nil {
-		return img, "tiff", nil
+		return applyOrientation(img, exifOrientation(imgData)), "tiff", nil
 	}
 
 	// Try decoding as GIF if the previous decodings fail
@@ -1473,6 +2424,14 @@ func decodeImage(imgData []byte) (image.Image, string, error) {
 		return img, "gif", nil
 	}
 
+	// HEIC/HEIF photos from Apple devices are converted to JPEG with heif-convert, if installed
+	if heifConverterPath != "" && isHEIF(imgData) {
+		img, err = decodeHEIF(imgData)
+		if err == nil {
+			return img, "jpeg", nil
+		}
+	}
+
 	return nil, "", fmt.Errorf("unsupported image format: %v", err)
 }
 
@@ -1492,7 +2451,7 @@ func getResponse(resp *genai.GenerateContentResponse) string {
 }
 
 // postProcessAltText cleans up the alt-text by removing unwanted introductory phrases.
-func postProcessAltText(altText string) string {
+func postProcessAltText(altText string, lang string) string {
 	// Strip ANSI escape sequences (e.g. cursor movement codes from some LLM outputs like gemma4)
 	ansiEscape := regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
 	altText = ansiEscape.ReplaceAllString(altText, "")
@@ -1543,15 +2502,8 @@ func postProcessAltText(altText string) string {
 	// Collapse any double spaces produced by the join
 	altText = regexp.MustCompile(` {2,}`).ReplaceAllString(altText, " ")
 
-	// Define a regex pattern to match introductory phrases
-	// This pattern matches phrases like "Here's alt text describing the image:" or "Here's alt text for the image:"
-	pattern := `(?i)here's alt text (describing|for) the (image|video|audio):?\s*`
-
-	// Compile the regex
-	re := regexp.MustCompile(pattern)
-
-	// Use the regex to replace matches with an empty string
-	altText = re.ReplaceAllString(altText, "")
+	// Remove introductory phrases like "Here's alt text for the image:" in the reply's language
+	altText = stripIntroPhrases(altText, lang)
 
 	// Unescape common escape sequences output by some models
 	altText = strings.NewReplacer(
@@ -1564,12 +2516,15 @@ func postProcessAltText(altText string) string {
 		`\\`, `\`,
 	).Replace(altText)
 
-	// Remove any mentions
-	altText = strings.ReplaceAll(altText, "@", "[@]")
+	// Remove any mentions, text that was already post-processed keeps its escaped mentions as they are
+	altText = strings.ReplaceAll(strings.ReplaceAll(altText, "[@]", "@"), "@", "[@]")
 
 	// Remove any leading or trailing whitespace
 	altText = strings.TrimSpace(altText)
 
+	// Keep the description within max_alt_text_chars
+	altText = truncateAltText(altText, maxAltTextChars())
+
 	return altText
 }
 
@@ -1594,13 +2549,26 @@ func checkOllamaModel() error {
 
 // Struct to store reply information with a timestamp
 type ReplyInfo struct {
-	ReplyID   mastodon.ID
-	Timestamp time.Time
+	ReplyID     mastodon.ID
+	Timestamp   time.Time
+	AuthorID    string // Of the described post
+	RequesterID string // Who the reply answers, the author or whoever mentioned the bot
 }
 
 var replyMap = make(map[mastodon.ID]ReplyInfo)
 var mapMutex sync.Mutex
 
+// Struct to map one of Altbot's replies back to the post it described
+type ReplyOrigin struct {
+	OriginalID  mastodon.ID
+	Timestamp   time.Time
+	AuthorID    string
+	RequesterID string
+}
+
+// replyOrigins is keyed by the ID of Altbot's reply, guarded by mapMutex
+var replyOrigins = make(map[mastodon.ID]ReplyOrigin)
+
 func handleDeleteEvent(c *mastodon.Client, originalID mastodon.ID) {
 	mapMutex.Lock()
 	defer mapMutex.Unlock()
@@ -1609,14 +2577,41 @@ func handleDeleteEvent(c *mastodon.Client, originalID mastodon.ID) {
 		// Delete Altbot's reply
 		err := c.DeleteStatus(ctx, replyInfo.ReplyID)
 		if err != nil {
-			log.Printf("Error deleting reply: %v", err)
+			logErrorf("Error deleting reply: %v", err)
 		} else {
-			log.Printf("Deleted reply for original post ID: %v", originalID)
+			logInfof("Deleted reply for original post ID: %v", originalID)
 			delete(replyMap, originalID)
 		}
 	}
 }
 
+// handleEditEvent removes Altbot's reply once the author has added their own alt-text to every attachment
+func handleEditEvent(c *mastodon.Client, status *mastodon.Status) {
+	if !config.Behavior.DeleteRedundantReplies || len(status.MediaAttachments) == 0 || isSelf(&status.Account) {
+		return
+	}
+
+	for _, attachment := range status.MediaAttachments {
+		if attachment.Description == "" {
+			return
+		}
+	}
+
+	mapMutex.Lock()
+	defer mapMutex.Unlock()
+
+	if replyInfo, exists := replyMap[status.ID]; exists {
+		err := c.DeleteStatus(ctx, replyInfo.ReplyID)
+		if err != nil {
+			logErrorf("Error deleting redundant reply: %v", err)
+		} else {
+			logInfof("Deleted redundant reply for edited post ID: %v", status.ID)
+			delete(replyMap, status.ID)
+			LogEventWithUsername("human_written_alt_text", status.Account.Acct)
+		}
+	}
+}
+
 func cleanupOldEntries() {
 	for {
 		time.Sleep(10 * time.Minute) // Run cleanup every 10 minutes
@@ -1627,29 +2622,48 @@ func cleanupOldEntries() {
 				delete(replyMap, originalID)
 			}
 		}
+		for replyID, origin := range replyOrigins {
+			if time.Since(origin.Timestamp) > time.Hour {
+				delete(replyOrigins, replyID)
+			}
+		}
 		mapMutex.Unlock()
+
+		threadLimiter.Cleanup(threadWindow())
 	}
 }
 
+// rateLimitWindow is the longest window requests are limited over, older requests are forgotten
+const rateLimitWindow = time.Hour
+
+const (
+	// accountAgeCacheTTL is how long a cached account creation date is kept after the user was last seen
+	accountAgeCacheTTL = 30 * 24 * time.Hour
+	// accountAgeCacheSize is how many creation dates are cached, the least recently seen are evicted first
+	accountAgeCacheSize = 10000
+)
+
 type RateLimiter struct {
-	MinuteCounts   map[string]int       `json:"minute_counts"`
-	HourCounts     map[string]int       `json:"hour_counts"`
-	AccountAges    map[string]time.Time `json:"account_ages"`
+	Requests       map[string][]time.Time `json:"requests"`
+	AccountAges    map[string]time.Time   `json:"account_ages"`
+	AccountSeen    map[string]time.Time   `json:"account_seen"`
 	mu             sync.Mutex
-	ExceededCounts map[string]int  `json:"exceeded_counts"`
-	ShadowBanned   map[string]bool `json:"shadow_banned"`
-	Whitelist      map[string]bool `json:"whitelist"`
+	ExceededCounts map[string]int       `json:"exceeded_counts"`
+	ShadowBanned   map[string]bool      `json:"shadow_banned"`
+	Whitelist      map[string]bool      `json:"whitelist"`
+	InformedUntil  map[string]time.Time `json:"informed_until"`
 }
 
 // NewRateLimiter creates a new RateLimiter
 func NewRateLimiter() *RateLimiter {
 	return &RateLimiter{
-		MinuteCounts:   make(map[string]int),
-		HourCounts:     make(map[string]int),
+		Requests:       make(map[string][]time.Time),
 		AccountAges:    make(map[string]time.Time),
+		AccountSeen:    make(map[string]time.Time),
 		ExceededCounts: make(map[string]int),
 		ShadowBanned:   make(map[string]bool),
 		Whitelist:      make(map[string]bool),
+		InformedUntil:  make(map[string]time.Time),
 	}
 }
 
@@ -1660,21 +2674,85 @@ func (rl *RateLimiter) IsNewAccount(c *mastodon.Client, userID string) bool {
 		// Fetch the account creation date if it doesn't exist
 		account, err := c.GetAccount(ctx, mastodon.ID(userID))
 		if err != nil {
-			log.Printf("Error fetching account: %v", err)
+			logErrorf("Error fetching account: %v", err)
 			return false
 		}
 
 		creationDate = account.CreatedAt
 		rl.AccountAges[userID] = creationDate
+		rl.evictAccountAges(accountAgeCacheSize)
 	}
-	log.Printf("Account creation date: %v", creationDate)
+	rl.AccountSeen[userID] = time.Now()
+	logDebugf("Account creation date: %v", creationDate)
 	return time.Since(creationDate).Hours() < 24*float64(config.RateLimit.NewAccountPeriodDays)
 }
 
-// Increment increments the request count for a user and checks limits
-func (rl *RateLimiter) Increment(c *mastodon.Client, userID string) bool {
+// evictAccountAges drops the cached creation dates of users not seen within the TTL,
+// then those least recently seen until at most size are left
+func (rl *RateLimiter) evictAccountAges(size int) {
+	cutoff := time.Now().Add(-accountAgeCacheTTL)
+	for userID := range rl.AccountAges {
+		// Dates cached before last use was tracked count as seen now
+		if _, tracked := rl.AccountSeen[userID]; !tracked {
+			rl.AccountSeen[userID] = time.Now()
+		}
+		if rl.AccountSeen[userID].Before(cutoff) {
+			delete(rl.AccountAges, userID)
+		}
+	}
+	for userID := range rl.AccountSeen {
+		if _, exists := rl.AccountAges[userID]; !exists {
+			delete(rl.AccountSeen, userID)
+		}
+	}
+
+	if len(rl.AccountAges) <= size {
+		return
+	}
+	users := make([]string, 0, len(rl.AccountAges))
+	for userID := range rl.AccountAges {
+		users = append(users, userID)
+	}
+	slices.SortFunc(users, func(a, b string) int {
+		return rl.AccountSeen[a].Compare(rl.AccountSeen[b])
+	})
+	for _, userID := range users[:len(users)-size] {
+		delete(rl.AccountAges, userID)
+		delete(rl.AccountSeen, userID)
+	}
+}
+
+// CheckNewAccount is IsNewAccount for callers that don't already hold the lock
+func (rl *RateLimiter) CheckNewAccount(c *mastodon.Client, userID string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.IsNewAccount(c, userID)
+}
+
+// isTrustedInstance reports whether an account is on one of the trusted_instances, which moderate their
+// own users. Their users don't need to give GDPR consent and get relaxed rate limits.
+func isTrustedInstance(acct string) bool {
+	domain := instanceDomain(acct)
+	for _, trusted := range config.Behavior.TrustedInstances {
+		if strings.EqualFold(trusted, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// newAccountNeedsConsent reports whether the new account policy requires the OP's consent
+// before a mention from this user is acted on, even when ask_for_consent is off
+func newAccountNeedsConsent(c *mastodon.Client, userID string) bool {
+	return config.RateLimit.NewAccountPolicy == "consent" && rateLimiter.CheckNewAccount(c, userID)
+}
+
+// Increment increments the request count for a user and checks limits. When a request is over the
+// limit and the user should be told about it, it also returns when the limit resets.
+// Users of trusted instances get the normal limits even with a new account and aren't shadow banned.
+func (rl *RateLimiter) Increment(c *mastodon.Client, userID, acct string) (bool, time.Time) {
 	if !config.RateLimit.Enabled {
-		return true
+		return true, time.Time{}
 	}
 
 	rl.mu.Lock()
@@ -1682,52 +2760,125 @@ func (rl *RateLimiter) Increment(c *mastodon.Client, userID string) bool {
 
 	isBanned := rl.IsShadowBanned(userID)
 	if isBanned {
-		log.Printf("User %s is shadow banned: %v", userID, isBanned)
-		return false
+		logWarnf("User %s is shadow banned: %v", userID, isBanned)
+		return false, time.Time{}
 	}
 
 	defer func() {
 		if err := rateLimiter.SaveToFile("ratelimiter.json"); err != nil {
-			log.Printf("Error saving rate limiter state: %v", err)
+			logErrorf("Error saving rate limiter state: %v", err)
 		}
 	}()
 
-	isNew := rl.IsNewAccount(c, userID)
+	trusted := isTrustedInstance(acct)
+	isNew := !trusted && rl.IsNewAccount(c, userID)
 
 	if isNew {
-		log.Printf("Sussy baka New account!!1!1!! feds get his ass: %s", userID)
+		logDebugf("New account activity from %s (policy: %s)", userID, config.RateLimit.NewAccountPolicy)
 		metricsManager.logNewAccountActivity(string(userID))
 	}
 
-	// Determine limits based on account age
+	// Determine limits based on account age, stricter limits are the default new account policy
 	maxPerMinute := config.RateLimit.MaxRequestsPerMinute
 	maxPerHour := config.RateLimit.MaxRequestsPerHour
-	if isNew {
+	strictLimits := config.RateLimit.NewAccountPolicy == "" || config.RateLimit.NewAccountPolicy == "limit"
+	if isNew && strictLimits {
 		maxPerMinute = config.RateLimit.NewAccountMaxRequestsPerMinute
 		maxPerHour = config.RateLimit.NewAccountMaxRequestsPerHour
 	}
 
+	// Requests are counted over the rolling minute and hour before now, not per clock minute or hour,
+	// so a burst straddling the turn of a minute can't get twice the limit through
+	now := time.Now()
+	requests := rl.recentRequests(userID, now)
+
 	// Check per-minute limit
-	if rl.MinuteCounts[userID] >= maxPerMinute {
-		rl.ExceededCounts[userID]++
-		if rl.ExceededCounts[userID] >= config.RateLimit.ShadowBanThreshold {
-			rl.ShadowBanUser(c, userID)
-		}
-		return false
+	lastMinute := requests[len(requests)-countRequestsSince(requests, now.Add(-time.Minute)):]
+	if len(lastMinute) >= maxPerMinute {
+		return false, rl.limitExceeded(c, userID, trusted, limitResetAt(lastMinute, maxPerMinute, time.Minute), now)
 	}
 
 	// Check per-hour limit
-	if rl.HourCounts[userID] >= maxPerHour {
-		rl.ExceededCounts[userID]++
-		if rl.ExceededCounts[userID] >= config.RateLimit.ShadowBanThreshold {
-			rl.ShadowBanUser(c, userID)
+	if len(requests) >= maxPerHour {
+		return false, rl.limitExceeded(c, userID, trusted, limitResetAt(requests, maxPerHour, rateLimitWindow), now)
+	}
+
+	rl.Requests[userID] = append(requests, now)
+	return true, time.Time{}
+}
+
+// limitExceeded counts a request over the limit towards a shadow ban. With notify_limited_users the
+// first one in a window isn't counted, instead it returns when the limit resets so the user can be told;
+// only requests after they've been told count. Otherwise it returns the zero time.
+func (rl *RateLimiter) limitExceeded(c *mastodon.Client, userID string, trusted bool, resetAt, now time.Time) time.Time {
+	if config.RateLimit.NotifyLimitedUsers && !now.Before(rl.InformedUntil[userID]) {
+		rl.InformedUntil[userID] = resetAt
+		return resetAt
+	}
+
+	rl.ExceededCounts[userID]++
+	if rl.ExceededCounts[userID] >= config.RateLimit.ShadowBanThreshold && !trusted {
+		rl.ShadowBanUser(c, userID)
+	}
+	return time.Time{}
+}
+
+// limitResetAt returns when enough of the requests (oldest first) leave the window to be under max again
+func limitResetAt(requests []time.Time, max int, window time.Duration) time.Time {
+	if max <= 0 || len(requests) < max {
+		return time.Now()
+	}
+	return requests[len(requests)-max].Add(window)
+}
+
+// rateLimitNotice tells a user they've hit the rate limit and in how many minutes it resets
+func rateLimitNotice(lang string, resetAt time.Time) string {
+	minutes := int((time.Until(resetAt) + time.Minute - 1) / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	return fmt.Sprintf(getLocalizedString(lang, "rateLimitReached", "response"), minutes)
+}
+
+// recentRequests returns the user's requests within the rate limit window, dropping older ones
+func (rl *RateLimiter) recentRequests(userID string, now time.Time) []time.Time {
+	requests := rl.Requests[userID]
+	cutoff := now.Add(-rateLimitWindow)
+
+	kept := requests[:0]
+	for _, t := range requests {
+		if t.After(cutoff) {
+			kept = append(kept, t)
 		}
-		return false
 	}
 
-	rl.MinuteCounts[userID]++
-	rl.HourCounts[userID]++
-	return true
+	if len(kept) == 0 {
+		delete(rl.Requests, userID)
+		return nil
+	}
+	rl.Requests[userID] = kept
+	return kept
+}
+
+// countRequestsSince counts the requests made after since
+func countRequestsSince(requests []time.Time, since time.Time) int {
+	count := 0
+	for _, t := range requests {
+		if t.After(since) {
+			count++
+		}
+	}
+	return count
+}
+
+// RequestCounts returns how many requests the user made in the last minute and the last hour
+func (rl *RateLimiter) RequestCounts(userID string) (int, int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	requests := rl.recentRequests(userID, now)
+	return countRequestsSince(requests, now.Add(-time.Minute)), len(requests)
 }
 
 func (rl *RateLimiter) ShadowBanUser(c *mastodon.Client, userID string) {
@@ -1735,7 +2886,7 @@ func (rl *RateLimiter) ShadowBanUser(c *mastodon.Client, userID string) {
 		return
 	}
 
-	log.Printf("Get shadow banned noob %s", userID)
+	logWarnf("Get shadow banned noob %s", userID)
 	rl.ShadowBanned[userID] = true
 	metricsManager.logShadowBan(string(userID))
 	rl.notifyAdmin(c, userID)
@@ -1748,7 +2899,7 @@ func (rl *RateLimiter) IsShadowBanned(userID string) bool {
 func (rl *RateLimiter) notifyAdmin(c *mastodon.Client, userID string) {
 	account, err := c.GetAccount(ctx, mastodon.ID(userID))
 	if err != nil {
-		log.Printf("Error fetching account: %v", err)
+		logErrorf("Error fetching account: %v", err)
 		return
 	}
 	name := account.Acct
@@ -1770,7 +2921,7 @@ func (rl *RateLimiter) notifyAdmin(c *mastodon.Client, userID string) {
 		Visibility: "direct",
 	})
 	if err != nil {
-		log.Printf("Error posting shadow ban notification: %v", err)
+		logErrorf("Error posting shadow ban notification: %v", err)
 	}
 }
 
@@ -1781,11 +2932,63 @@ func (rl *RateLimiter) UnbanAndWhitelistUser(userID string) {
 	delete(rl.ShadowBanned, userID)
 	rl.Whitelist[userID] = true
 
-	log.Printf("User %s has been unbanned and added to the whitelist.", userID)
+	logInfof("User %s has been unbanned and added to the whitelist.", userID)
+
+	if err := rl.SaveToFile("ratelimiter.json"); err != nil {
+		logErrorf("Error saving rate limiter state: %v", err)
+	}
+}
+
+// WhitelistUser exempts a user from shadow bans without lifting one they already have
+func (rl *RateLimiter) WhitelistUser(userID string) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.Whitelist[userID] = true
+	return rl.SaveToFile("ratelimiter.json")
+}
+
+// DeleteUser removes a user's counters, account age and whitelisting, returning how many entries went.
+// Unless keepBan is false a shadow ban and the exceeded limits that led to it are kept, so a ban
+// can't be shed by asking to be forgotten.
+func (rl *RateLimiter) DeleteUser(userID string, keepBan bool) int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	flags := []map[string]bool{rl.Whitelist}
+	if !keepBan {
+		flags = append(flags, rl.ShadowBanned)
+	}
+
+	removed := 0
+	if _, exists := rl.Requests[userID]; exists {
+		delete(rl.Requests, userID)
+		removed++
+	}
+	if _, exists := rl.ExceededCounts[userID]; exists && !keepBan {
+		delete(rl.ExceededCounts, userID)
+		removed++
+	}
+	if _, exists := rl.InformedUntil[userID]; exists {
+		delete(rl.InformedUntil, userID)
+		removed++
+	}
+	for _, m := range flags {
+		if _, exists := m[userID]; exists {
+			delete(m, userID)
+			removed++
+		}
+	}
+	if _, exists := rl.AccountAges[userID]; exists {
+		delete(rl.AccountAges, userID)
+		delete(rl.AccountSeen, userID)
+		removed++
+	}
 
-	if err := rateLimiter.SaveToFile("ratelimiter.json"); err != nil {
-		log.Printf("Error saving rate limiter state: %v", err)
+	if err := rl.SaveToFile("ratelimiter.json"); err != nil {
+		logErrorf("Error saving rate limiter state: %v", err)
 	}
+	return removed
 }
 
 func handleAdminReply(c *mastodon.Client, reply *mastodon.Status, rl *RateLimiter) {
@@ -1796,7 +2999,7 @@ func handleAdminReply(c *mastodon.Client, reply *mastodon.Status, rl *RateLimite
 	if len(parts) == 3 && parts[1] == "unban" {
 		userID := parts[2]
 		rl.UnbanAndWhitelistUser(userID)
-		log.Printf("Admin unbanned user %s based on reply.", userID)
+		logInfof("Admin unbanned user %s based on reply.", userID)
 		metricsManager.logUnBan(string(userID))
 
 		message := fmt.Sprintf("%s User %s has been unbanned and added to the whitelist.", config.RateLimit.AdminContactHandle, userID)
@@ -1817,33 +3020,65 @@ func handleAdminReply(c *mastodon.Client, reply *mastodon.Status, rl *RateLimite
 			InReplyToID: reply.ID,
 		})
 		if err != nil {
-			log.Printf("Error sending confirmation of unban: %v", err)
+			logErrorf("Error sending confirmation of unban: %v", err)
 		}
 	}
-}
 
-// ResetMinuteCounts resets the per-minute request counts for all users
-func (rl *RateLimiter) ResetMinuteCounts() {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+	if len(parts) == 3 && parts[1] == "forget" {
+		userID := parts[2]
+		removed, err := forgetUserData(userID, false)
+		if err != nil {
+			logErrorf("Error forgetting user %s: %v", userID, err)
+			return
+		}
+		logInfof("Admin erased the data of user %s based on reply: %v", userID, removed)
+
+		message := fmt.Sprintf("%s The data of user %s has been erased (%d stores).", config.RateLimit.AdminContactHandle, userID, len(removed))
+
+		// Dev mode: print to terminal instead of posting
+		if devMode {
+			fmt.Printf("\n%s[DEV MODE - Would confirm forget]%s\n", Yellow, Reset)
+			fmt.Printf("  To: %s\n", config.RateLimit.AdminContactHandle)
+			fmt.Printf("  Visibility: direct\n")
+			fmt.Printf("  Content: %s\n", message)
+			fmt.Println("---")
+			return
+		}
 
-	for userID := range rl.MinuteCounts {
-		rl.MinuteCounts[userID] = 0
+		_, err = c.PostStatus(ctx, &mastodon.Toot{
+			Status:      message,
+			Visibility:  "direct",
+			InReplyToID: reply.ID,
+		})
+		if err != nil {
+			logErrorf("Error sending confirmation of forget: %v", err)
+		}
 	}
 }
 
-// ResetHourCounts resets the per-hour request counts for all users
-func (rl *RateLimiter) ResetHourCounts() {
+// PruneRequests drops the requests that left the rate limit window for all users
+// and resets the exceeded counts, so old violations don't lead to a shadow ban.
+// It also prunes the account age cache.
+func (rl *RateLimiter) PruneRequests() {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	for userID := range rl.HourCounts {
-		rl.HourCounts[userID] = 0
+	now := time.Now()
+	for userID := range rl.Requests {
+		rl.recentRequests(userID, now)
 	}
 
 	for userID := range rl.ExceededCounts {
 		rl.ExceededCounts[userID] = 0
 	}
+
+	for userID, until := range rl.InformedUntil {
+		if now.After(until) {
+			delete(rl.InformedUntil, userID)
+		}
+	}
+
+	rl.evictAccountAges(accountAgeCacheSize)
 }
 
 func (rl *RateLimiter) LoadFromFile(filePath string) error {
@@ -1854,7 +3089,21 @@ func (rl *RateLimiter) LoadFromFile(filePath string) error {
 		}
 		return err
 	}
-	return json.Unmarshal(data, rl)
+	if err := json.Unmarshal(data, rl); err != nil {
+		return err
+	}
+
+	// State saved before the sliding window only has minute and hour counts, which are dropped
+	if rl.Requests == nil {
+		rl.Requests = make(map[string][]time.Time)
+	}
+	if rl.InformedUntil == nil {
+		rl.InformedUntil = make(map[string]time.Time)
+	}
+	if rl.AccountSeen == nil {
+		rl.AccountSeen = make(map[string]time.Time)
+	}
+	return nil
 }
 
 func (rl *RateLimiter) SaveToFile(filePath string) error {
@@ -1867,8 +3116,10 @@ func (rl *RateLimiter) SaveToFile(filePath string) error {
 
 // ConsentRequest struct to store consent requests
 type ConsentRequest struct {
-	RequestID mastodon.ID
-	Timestamp time.Time
+	RequestID   mastodon.ID
+	Timestamp   time.Time
+	AuthorID    string // Of the post, who is asked for consent
+	RequesterID string // Who mentioned the bot under it
 }
 
 func saveConsentRequestsToFile(filePath string) error {
@@ -1910,7 +3161,7 @@ func cleanupOldConsentRequests() {
 func stripHTMLTags(htmlContent string) string {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
-		log.Printf("Error parsing HTML: %v", err)
+		logErrorf("Error parsing HTML: %v", err)
 		return htmlContent // Return unchanged if parsing fails
 	}
 	return extractText(doc)
@@ -1994,7 +3245,7 @@ func checkForUpdates() {
 func fetchLatestVersion() string {
 	resp, err := http.Get("https://api.github.com/repos/micr0-dev/Altbot/releases/latest")
 	if err != nil {
-		log.Printf("Error fetching latest version: %v", err)
+		logErrorf("Error fetching latest version: %v", err)
 		return ""
 	}
 	defer resp.Body.Close()
@@ -2003,7 +3254,7 @@ func fetchLatestVersion() string {
 		TagName string `json:"tag_name"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
-		log.Printf("Error decoding JSON: %v", err)
+		logErrorf("Error decoding JSON: %v", err)
 		return ""
 	}
 
@@ -2043,6 +3294,16 @@ func shouldSendReminder(userID string) bool {
 	return false
 }
 
+// forgetReminderState drops when a user was last reminded to add alt-text, returning whether there was anything
+func forgetReminderState(userID string) bool {
+	altTextReminderTracker.mu.Lock()
+	defer altTextReminderTracker.mu.Unlock()
+
+	_, exists := altTextReminderTracker.LastReminded[userID]
+	delete(altTextReminderTracker.LastReminded, userID)
+	return exists
+}
+
 func queuePostForAltTextCheck(post *mastodon.Status, userID string) {
 	altTextChecks[post.ID] = AltTextCheck{
 		PostID:    post.ID,
@@ -2062,7 +3323,7 @@ func checkAltTextPeriodically(c *mastodon.Client, interval time.Duration, checkT
 				// Fetch post details
 				post, err := c.GetStatus(ctx, check.PostID)
 				if err != nil {
-					log.Printf("Error fetching post %s during alt-text check. Deleting from queue: %v", check.PostID, err)
+					logErrorf("Error fetching post %s during alt-text check. Deleting from queue: %v", check.PostID, err)
 					delete(altTextChecks, postID)
 					continue
 				}
@@ -2077,7 +3338,7 @@ func checkAltTextPeriodically(c *mastodon.Client, interval time.Duration, checkT
 				}
 
 				if missingAltText {
-					log.Printf("Notifying user %s about missing alt-text in post %s...", check.UserID, check.PostID)
+					logInfof("Notifying user %s about missing alt-text in post %s...", check.UserID, check.PostID)
 					metricsManager.logMissingAltText(string(check.UserID))
 					if shouldSendReminder(check.UserID) {
 						username := post.Account.Acct
@@ -2094,7 +3355,14 @@ func checkAltTextPeriodically(c *mastodon.Client, interval time.Duration, checkT
 }
 
 func notifyUserOfMissingAltText(c *mastodon.Client, post *mastodon.Status, userID string) {
-	message := fmt.Sprintf(getLocalizedString(post.Language, "altTextReminder", "response"), userID)
+	missingCount := 0
+	for _, media := range post.MediaAttachments {
+		if media.Description == "" {
+			missingCount++
+		}
+	}
+
+	message := fmt.Sprintf(getLocalizedPluralString(post.Language, "altTextReminder", missingCount), userID)
 
 	// Dev mode: print to terminal instead of posting
 	if devMode {
@@ -2106,13 +3374,17 @@ func notifyUserOfMissingAltText(c *mastodon.Client, post *mastodon.Status, userI
 		return
 	}
 
+	if !allowReply(c) {
+		return
+	}
+
 	_, err := c.PostStatus(ctx, &mastodon.Toot{
 		Status:      message,
 		InReplyToID: post.ID,
 		Visibility:  "direct",
 	})
 	if err != nil {
-		log.Printf("Error notifying user %s about missing alt-text: %v", userID, err)
+		logErrorf("Error notifying user %s about missing alt-text: %v", userID, err)
 	}
 }
 
@@ -2169,8 +3441,8 @@ func compareConfigs(defaultConfig, userConfig Config) int {
 
 	checkDifferences(reflect.ValueOf(defaultConfig), reflect.ValueOf(userConfig), "", &customCount, &warnings)
 
-	if len(warnings) > 0 {
-		fmt.Printf("Warnings:\n%s\n", warnings)
+	for _, warning := range warnings {
+		logWarnf("Config: %s", warning)
 	}
 
 	return customCount
@@ -2221,16 +3493,17 @@ func getPrivacyPolicyURL() string {
 	return defaultPrivacyPolicyURL
 }
 
-func getProviderAttribution(config Config, lang string) string {
+// getProviderAttribution credits the provider that wrote a reply's captions
+func getProviderAttribution(config Config, lang string, provider string) string {
 	var modelInfo string
 	var messageKey string
 
-	switch config.LLM.Provider {
+	switch provider {
 	case "transformers", "ollama":
 		// These are local providers
 		messageKey = "providedByMessageLocal"
 
-		if config.LLM.Provider == "transformers" {
+		if provider == "transformers" {
 			modelName := config.TransformersServerArgs.Model
 			modelInfo = strings.Split(modelName, "/")[1] // Just use the model name without path
 		} else {
@@ -2247,6 +3520,11 @@ func getProviderAttribution(config Config, lang string) string {
 		modelInfo = ""
 	}
 
+	// An operator's own attribution in localizations.json replaces both messages
+	if custom := getLocalizedString(lang, "customAttribution", "response"); custom != "" {
+		return strings.NewReplacer("{{bot}}", config.Server.Username, "{{model}}", modelInfo).Replace(custom)
+	}
+
 	providerMessage := getLocalizedString(lang, messageKey, "response")
 	return fmt.Sprintf(providerMessage, config.Server.Username, modelInfo)
 }
@@ -2338,7 +3616,7 @@ func updateBotProfile(client *mastodon.Client, config Config) error {
 	// Ensure we don't exceed the maximum number of fields (typically 4)
 	if len(fields) > 4 && !config.Profile.OverrideFeildCount {
 		fields = fields[:4]
-		fmt.Printf("%s Warning: Some profile fields were omitted due to the 4-field limit\n", Yellow)
+		logWarnf("Some profile fields were omitted due to the 4-field limit")
 	}
 
 	// Update profile
@@ -2399,7 +3677,7 @@ func runDevMode() {
 				fmt.Printf("%sUsage:%s /video <url>\n", Yellow, Reset)
 				continue
 			}
-			if !videoProcessingCapability {
+			if !llmProvider.Capabilities().Video {
 				fmt.Printf("%sError:%s Video processing is not supported by the current LLM provider (%s)\n", Red, Reset, config.LLM.Provider)
 				continue
 			}
@@ -2410,7 +3688,7 @@ func runDevMode() {
 				fmt.Printf("%sUsage:%s /audio <url>\n", Yellow, Reset)
 				continue
 			}
-			if !audioProcessingCapability {
+			if !llmProvider.Capabilities().Audio {
 				fmt.Printf("%sError:%s Audio processing is not supported by the current LLM provider (%s)\n", Red, Reset, config.LLM.Provider)
 				continue
 			}
@@ -2467,8 +3745,9 @@ func printDevStatus(currentLang string) {
 	fmt.Printf("%s=== Dev Mode Status ===%s\n", Cyan, Reset)
 	fmt.Printf("  LLM Provider: %s\n", config.LLM.Provider)
 	fmt.Printf("  Language: %s\n", currentLang)
-	fmt.Printf("  Video Processing: %v\n", videoProcessingCapability)
-	fmt.Printf("  Audio Processing: %v\n", audioProcessingCapability)
+	capabilities := llmProvider.Capabilities()
+	fmt.Printf("  Video Processing: %v\n", capabilities.Video)
+	fmt.Printf("  Audio Processing: %v\n", capabilities.Audio)
 	fmt.Println()
 }
 
@@ -2476,7 +3755,7 @@ func processDevImage(imageURL string, lang string) {
 	fmt.Printf("\n%sProcessing image:%s %s\n", Cyan, Reset, imageURL)
 	fmt.Println("Please wait...")
 
-	altText, err := generateImageAltText(imageURL, lang)
+	altText, _, err := generateImageAltText(imageURL, lang, "", altTextOptions{})
 	if err != nil {
 		fmt.Printf("%sError:%s %v\n", Red, Reset, err)
 		return
@@ -2491,7 +3770,7 @@ func processDevVideo(videoURL string, lang string) {
 	fmt.Printf("\n%sProcessing video:%s %s\n", Cyan, Reset, videoURL)
 	fmt.Println("Please wait (this may take a while)...")
 
-	altText, err := generateVideoAltText(videoURL, lang)
+	altText, _, err := generateVideoAltText(videoURL, lang)
 	if err != nil {
 		fmt.Printf("%sError:%s %v\n", Red, Reset, err)
 		return
@@ -2506,7 +3785,7 @@ func processDevAudio(audioURL string, lang string) {
 	fmt.Printf("\n%sProcessing audio:%s %s\n", Cyan, Reset, audioURL)
 	fmt.Println("Please wait...")
 
-	altText, err := generateAudioAltText(audioURL, lang)
+	altText, _, err := generateAudioAltText(audioURL, lang)
 	if err != nil {
 		fmt.Printf("%sError:%s %v\n", Red, Reset, err)
 		return
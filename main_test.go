@@ -0,0 +1,1037 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestReplyContentWarning(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+
+	tests := []struct {
+		prefix  string
+		spoiler string
+		want    string
+	}{
+		{"", "", ""},
+		{"", "food", "re: food"},
+		{"", "re: food", "re: food"},
+		{"", "Re: re: food", "Re: re: food"},
+		{"", "RE:food", "RE:food"},
+		{"CW: ", "food", "CW: food"},
+		{"CW: ", "cw: food", "cw: food"},
+		{"CW: ", "re: food", "re: food"},
+		{"none", "food", "food"},
+		{"none", "re: food", "re: food"},
+	}
+	for _, test := range tests {
+		config.Behavior.CWReplyPrefix = test.prefix
+		if got := replyContentWarning(test.spoiler, "en"); got != test.want {
+			t.Errorf("prefix %q, CW %q: got %q, want %q", test.prefix, test.spoiler, got, test.want)
+		}
+	}
+}
+
+func TestCWMediaVisibility(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	useBotState(t)
+	config.ImageProcessing.MaxSizeMB = 10
+	config.Behavior.ReplyVisibility = "unlisted"
+	config.Behavior.CWReplyPrefix = ""
+	media := mediaServer(t, "image/png", testPNG(t))
+
+	tests := []struct {
+		mode       string
+		visibility string // "" when nothing is posted
+	}{
+		{"", "unlisted"},
+		{"direct", "direct"},
+		{"skip", ""},
+	}
+	for _, test := range tests {
+		config.Behavior.CWMediaVisibility = test.mode
+		provider := newStubProvider(stubResponse{text: "An X-ray of a broken wrist."})
+		useProvider(t, provider)
+
+		posts := make(chan url.Values, 1)
+		c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.Method == http.MethodPost {
+				r.ParseForm()
+				posts <- r.Form
+				io.WriteString(w, `{"id":"3"}`)
+				return
+			}
+			io.WriteString(w, `{"id":"2","visibility":"public","language":"en","content":"","account":{"id":"10","acct":"alice"}}`)
+		})
+
+		status := &mastodon.Status{
+			ID:               "1",
+			SpoilerText:      "medical",
+			Account:          mastodon.Account{ID: "20", Acct: "bob"},
+			MediaAttachments: []mastodon.Attachment{{ID: "m1", Type: "image", URL: media.URL + "/xray.png"}},
+		}
+		generateAndPostAltText(c, status, "2", altTextOptions{})
+
+		if test.visibility == "" {
+			if len(posts) != 0 || provider.calls() != 0 {
+				t.Errorf("%q: described a post behind a content warning", test.mode)
+			}
+			continue
+		}
+		if len(posts) != 1 {
+			t.Fatalf("%q: nothing was posted", test.mode)
+		}
+		// The reply keeps the content warning whatever its visibility
+		if form := <-posts; form.Get("visibility") != test.visibility || form.Get("spoiler_text") != "re: medical" {
+			t.Errorf("%q: posted with visibility %q and CW %q, want %q and \"re: medical\"", test.mode, form.Get("visibility"), form.Get("spoiler_text"), test.visibility)
+		}
+	}
+}
+
+// stalledServer accepts requests and never answers them, until the test ends
+func stalledServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	return server
+}
+
+func TestMediaDownloadTimesOut(t *testing.T) {
+	useConfig(t)
+	config.ImageProcessing.DownloadTimeoutSeconds = 1
+	config.ImageProcessing.MaxSizeMB = 10
+	server := stalledServer(t)
+
+	start := time.Now()
+	_, err := fetchImage(server.URL + "/slow.png")
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("download took %v", elapsed)
+	}
+}
+
+func TestMediaDownloadStopsOnShutdown(t *testing.T) {
+	useConfig(t)
+	config.ImageProcessing.MaxSizeMB = 10
+	server := stalledServer(t)
+
+	previous := ctx
+	shutdown, cancel := context.WithCancel(context.Background())
+	ctx = shutdown
+	t.Cleanup(func() { ctx = previous })
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if _, err := fetchImage(server.URL + "/slow.png"); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want the download cancelled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("download took %v after shutdown", elapsed)
+	}
+}
+
+// oversizedServer streams size bytes without a Content-Length, as a server hiding a file's size would
+func oversizedServer(t *testing.T, size int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunk := make([]byte, 64<<10)
+		for written := 0; written < size; written += len(chunk) {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOversizedDownloadWithoutContentLength(t *testing.T) {
+	useConfig(t)
+	useProvider(t, newStubProvider(stubResponse{text: "unused"}))
+	config.ImageProcessing.MaxSizeMB = 1
+	config.VideoProcessing.MaxSizeMB = 1
+	server := oversizedServer(t, 3<<20)
+
+	var tooLarge *mediaTooLargeError
+	if _, err := fetchImage(server.URL + "/large.png"); !errors.As(err, &tooLarge) || !strings.Contains(err.Error(), "size exceeds maximum limit of 1 MB") {
+		t.Errorf("image: err = %v", err)
+	}
+	if _, _, err := generateVideoAltText(server.URL+"/large.mp4", "en"); !errors.As(err, &tooLarge) || !strings.Contains(err.Error(), "size exceeds") {
+		t.Errorf("video: err = %v", err)
+	}
+}
+
+func TestReadMediaBodyAcceptsFileOfExactlyTheLimit(t *testing.T) {
+	resp := &http.Response{ContentLength: -1, Body: io.NopCloser(bytes.NewReader(make([]byte, 1<<20)))}
+	if data, err := readMediaBody(resp, 1, "file"); err != nil || len(data) != 1<<20 {
+		t.Errorf("got %d bytes, %v", len(data), err)
+	}
+
+	// A declared size over the limit is rejected before reading
+	resp = &http.Response{ContentLength: 2 << 20, Body: io.NopCloser(strings.NewReader(""))}
+	var tooLarge *mediaTooLargeError
+	if _, err := readMediaBody(resp, 1, "file"); !errors.As(err, &tooLarge) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+// useBotState gives the test a fresh rate limiter and disabled metrics, in a temporary directory
+func useBotState(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	previousLimiter, previousMetrics := rateLimiter, metricsManager
+	rateLimiter = NewRateLimiter()
+	metricsManager = NewMetricsManager(false, "metrics.json", time.Hour, 0)
+	t.Cleanup(func() { rateLimiter, metricsManager = previousLimiter, previousMetrics })
+}
+
+// captureStdout returns what fn prints to standard output
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = previous }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-output
+}
+
+func TestPreviewModeDoesNotPost(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	useBotState(t)
+	useProvider(t, newStubProvider(stubResponse{text: "A red bicycle leaning against a wall."}))
+	config.ImageProcessing.MaxSizeMB = 10
+	config.LLM.Provider = "ollama"
+	config.LLM.OllamaModel = "llava:7b"
+	config.Behavior.AttributionEnabled = true
+	config.Behavior.ReplyVisibility = "unlisted"
+
+	previous := previewMode
+	previewMode = true
+	t.Cleanup(func() { previewMode = previous })
+
+	var posted atomic.Bool
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			posted.Store(true)
+			http.Error(w, "unexpected post", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"2","visibility":"public","language":"en","content":"","account":{"id":"10","acct":"alice"}}`)
+	})
+
+	media := mediaServer(t, "image/png", testPNG(t))
+	status := &mastodon.Status{
+		ID:               "1",
+		URL:              "https://example.com/@bob/1",
+		Account:          mastodon.Account{ID: "20", Acct: "bob"},
+		MediaAttachments: []mastodon.Attachment{{ID: "m1", Type: "image", URL: media.URL + "/a.png"}},
+	}
+
+	output := captureStdout(t, func() { generateAndPostAltText(c, status, "2", altTextOptions{}) })
+	if posted.Load() {
+		t.Error("preview mode posted a reply")
+	}
+	for _, want := range []string{"PREVIEW", "In reply to: https://example.com/@bob/1", "@alice", "A red bicycle leaning against a wall.", "Visibility: unlisted", getProviderAttribution(config, "en", "ollama")} {
+		if !strings.Contains(output, want) {
+			t.Errorf("preview is missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestHandleUpdateSkipsMediaTheProviderCannotDescribe(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	useBotState(t)
+	useProvider(t, &OllamaProvider{})
+
+	var requests atomic.Int32
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	video := &mastodon.Status{
+		ID:               "1",
+		Account:          mastodon.Account{ID: "20", Acct: "bob"},
+		MediaAttachments: []mastodon.Attachment{{ID: "m1", Type: "video"}, {ID: "m2", Type: "audio"}},
+	}
+	handleUpdate(c, video)
+	if requests.Load() != 0 {
+		t.Errorf("a video and audio post made %d requests with Ollama", requests.Load())
+	}
+
+	// An image from the same user without consent gets a consent request
+	image := &mastodon.Status{
+		ID:               "2",
+		Account:          mastodon.Account{ID: "20", Acct: "bob"},
+		MediaAttachments: []mastodon.Attachment{{ID: "m3", Type: "image"}},
+	}
+	handleUpdate(c, image)
+	if requests.Load() == 0 {
+		t.Error("an image post wasn't handled")
+	}
+}
+
+// useRateLimit enables the bot's rate limit at 5 requests a minute and 30 an hour, for an established account
+func useRateLimit(t *testing.T, userID string) {
+	t.Helper()
+	useConfig(t)
+	useBotState(t)
+	config.RateLimit.Enabled = true
+	config.RateLimit.MaxRequestsPerMinute = 5
+	config.RateLimit.MaxRequestsPerHour = 30
+	config.RateLimit.ShadowBanThreshold = 100
+	config.RateLimit.NewAccountPeriodDays = 30
+	rateLimiter.AccountAges[userID] = time.Now().AddDate(-1, 0, 0)
+}
+
+// requestsAgo are requests made the given times before now
+func requestsAgo(ago ...time.Duration) []time.Time {
+	now := time.Now()
+	var requests []time.Time
+	for _, d := range ago {
+		requests = append(requests, now.Add(-d))
+	}
+	return requests
+}
+
+func TestRateLimitBurstAcrossMinuteBoundary(t *testing.T) {
+	useRateLimit(t, "20")
+
+	// A full minute's worth of requests in the seconds before the turn of the minute
+	rateLimiter.Requests["20"] = requestsAgo(14*time.Second, 13*time.Second, 12*time.Second, 11*time.Second, 10*time.Second)
+	if allowed, _ := rateLimiter.Increment(nil, "20", "bob"); allowed {
+		t.Error("a burst straddling the turn of a minute got past the limit")
+	}
+
+	// Requests only leave the window a full minute after they were made
+	rateLimiter.Requests["20"] = requestsAgo(59*time.Second, 50*time.Second, 40*time.Second, 30*time.Second, 20*time.Second)
+	if allowed, _ := rateLimiter.Increment(nil, "20", "bob"); allowed {
+		t.Error("allowed with 5 requests in the last minute")
+	}
+	rateLimiter.Requests["20"] = requestsAgo(61*time.Second, 50*time.Second, 40*time.Second, 30*time.Second, 20*time.Second)
+	if allowed, _ := rateLimiter.Increment(nil, "20", "bob"); !allowed {
+		t.Error("limited with only 4 requests in the last minute")
+	}
+	if minute, hour := rateLimiter.RequestCounts("20"); minute != 5 || hour != 6 {
+		t.Errorf("counted %d in the last minute and %d in the hour, want 5 and 6", minute, hour)
+	}
+}
+
+func TestRateLimitRollingHour(t *testing.T) {
+	useRateLimit(t, "20")
+
+	// 30 requests, two minutes apart, all within the last hour
+	var ago []time.Duration
+	for i := 0; i < 30; i++ {
+		ago = append(ago, time.Duration(59-2*i)*time.Minute-30*time.Second)
+	}
+	rateLimiter.Requests["20"] = requestsAgo(ago...)
+	if allowed, _ := rateLimiter.Increment(nil, "20", "bob"); allowed {
+		t.Error("allowed past the hourly limit")
+	}
+
+	// Once the oldest is over an hour ago there's room for one more, and it's forgotten
+	rateLimiter.Requests["20"][0] = time.Now().Add(-61 * time.Minute)
+	if allowed, _ := rateLimiter.Increment(nil, "20", "bob"); !allowed {
+		t.Error("limited with 29 requests in the last hour")
+	}
+	if _, hour := rateLimiter.RequestCounts("20"); hour != 30 {
+		t.Errorf("counted %d requests in the hour, want 30", hour)
+	}
+}
+
+func TestLimitResetAt(t *testing.T) {
+	requests := requestsAgo(50*time.Second, 40*time.Second, 30*time.Second)
+	if got := limitResetAt(requests, 3, time.Minute); !got.Equal(requests[0].Add(time.Minute)) {
+		t.Errorf("at the limit: resets at %v, want when the oldest leaves the window", got)
+	}
+	if got := limitResetAt(requests, 2, time.Minute); !got.Equal(requests[1].Add(time.Minute)) {
+		t.Errorf("over the limit: resets at %v, want when the second oldest leaves the window", got)
+	}
+}
+
+func TestRateLimiterPersistsRequestTimes(t *testing.T) {
+	useBotState(t)
+	requests := requestsAgo(30*time.Minute, 10*time.Second)
+	rateLimiter.Requests["20"] = requests
+	if err := rateLimiter.SaveToFile("ratelimiter.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewRateLimiter()
+	if err := loaded.LoadFromFile("ratelimiter.json"); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Requests["20"]) != 2 || !loaded.Requests["20"][1].Equal(requests[1]) {
+		t.Errorf("loaded %v, want %v", loaded.Requests["20"], requests)
+	}
+	if minute, hour := loaded.RequestCounts("20"); minute != 1 || hour != 2 {
+		t.Errorf("counted %d and %d after loading, want 1 and 2", minute, hour)
+	}
+}
+
+func TestRateLimitedUserIsInformedOncePerWindow(t *testing.T) {
+	useRateLimit(t, "20")
+	config.RateLimit.NotifyLimitedUsers = true
+	config.RateLimit.ShadowBanThreshold = 2
+
+	var adminNotified atomic.Bool
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			adminNotified.Store(true)
+			io.WriteString(w, `{"id":"3"}`)
+			return
+		}
+		io.WriteString(w, `{"id":"20","acct":"bob"}`)
+	})
+
+	rateLimiter.Requests["20"] = requestsAgo(50*time.Second, 40*time.Second, 30*time.Second, 20*time.Second, 10*time.Second)
+	allowed, resetAt := rateLimiter.Increment(c, "20", "bob")
+	if allowed || !resetAt.Equal(rateLimiter.Requests["20"][0].Add(time.Minute)) {
+		t.Fatalf("first request over the limit: allowed %v, resets at %v", allowed, resetAt)
+	}
+	if rateLimiter.ExceededCounts["20"] != 0 {
+		t.Error("the request the user is informed about counted towards a ban")
+	}
+
+	// Only requests after being told count, and the user isn't told again
+	for i := 1; i <= 2; i++ {
+		if allowed, resetAt := rateLimiter.Increment(c, "20", "bob"); allowed || !resetAt.IsZero() {
+			t.Errorf("request %d after being informed: allowed %v, resets at %v", i, allowed, resetAt)
+		}
+		if rateLimiter.ExceededCounts["20"] != i {
+			t.Errorf("exceeded count %d after %d requests, want %d", rateLimiter.ExceededCounts["20"], i, i)
+		}
+	}
+	if !rateLimiter.IsShadowBanned("20") || !adminNotified.Load() {
+		t.Error("not shadow banned after reaching the threshold")
+	}
+
+	// The next window the user is told again
+	delete(rateLimiter.ShadowBanned, "20")
+	rateLimiter.ExceededCounts["20"] = 0
+	rateLimiter.InformedUntil["20"] = time.Now().Add(-time.Second)
+	if _, resetAt := rateLimiter.Increment(c, "20", "bob"); resetAt.IsZero() {
+		t.Error("not informed in a new window")
+	}
+
+	// Without notify_limited_users every request over the limit counts
+	config.RateLimit.NotifyLimitedUsers = false
+	delete(rateLimiter.InformedUntil, "20")
+	if _, resetAt := rateLimiter.Increment(c, "20", "bob"); !resetAt.IsZero() || rateLimiter.ExceededCounts["20"] != 1 {
+		t.Errorf("without notifications: resets at %v, exceeded count %d", resetAt, rateLimiter.ExceededCounts["20"])
+	}
+}
+
+func TestRateLimitNoticeIsPosted(t *testing.T) {
+	loadTestLocalizations(t)
+	useRateLimit(t, "10")
+	config.RateLimit.NotifyLimitedUsers = true
+	config.ImageProcessing.MaxSizeMB = 10
+	provider := newStubProvider(stubResponse{text: "A cat."})
+	useProvider(t, provider)
+	media := mediaServer(t, "image/png", testPNG(t))
+
+	posts := make(chan url.Values, 1)
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			r.ParseForm()
+			posts <- r.Form
+			io.WriteString(w, `{"id":"3"}`)
+			return
+		}
+		io.WriteString(w, `{"id":"2","visibility":"public","language":"en","content":"","account":{"id":"10","acct":"alice"}}`)
+	})
+
+	rateLimiter.Requests["10"] = requestsAgo(50*time.Second, 40*time.Second, 30*time.Second, 20*time.Second, 10*time.Second)
+	status := &mastodon.Status{
+		ID:               "1",
+		Account:          mastodon.Account{ID: "20", Acct: "bob"},
+		MediaAttachments: []mastodon.Attachment{{ID: "m1", Type: "image", URL: media.URL + "/cat.png"}},
+	}
+	generateAndPostAltText(c, status, "2", altTextOptions{})
+
+	if provider.calls() != 0 {
+		t.Error("media of a rate limited user was described")
+	}
+	if len(posts) != 1 {
+		t.Fatal("the user wasn't told about the limit")
+	}
+	if form := <-posts; !strings.Contains(form.Get("status"), fmt.Sprintf(getLocalizedString("en", "rateLimitReached", "response"), 1)) {
+		t.Errorf("posted %q, want the rate limit notice", form.Get("status"))
+	}
+}
+
+func TestAccountAgeIsCached(t *testing.T) {
+	useConfig(t)
+	useBotState(t)
+	config.RateLimit.NewAccountPeriodDays = 7
+
+	var lookups atomic.Int32
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"20","acct":"bob","created_at":%q}`, time.Now().AddDate(0, 0, -2).Format(time.RFC3339))
+	})
+
+	for i := 0; i < 3; i++ {
+		if !rateLimiter.CheckNewAccount(c, "20") {
+			t.Fatal("a two day old account isn't new")
+		}
+	}
+	if lookups.Load() != 1 {
+		t.Errorf("looked the account up %d times, want once", lookups.Load())
+	}
+	if time.Since(rateLimiter.AccountSeen["20"]) > time.Minute {
+		t.Error("last use of the cached date wasn't tracked")
+	}
+}
+
+func TestEvictAccountAges(t *testing.T) {
+	rl := NewRateLimiter()
+	now := time.Now()
+	created := now.AddDate(-1, 0, 0)
+	for i, seen := range []time.Time{now.Add(-accountAgeCacheTTL - time.Hour), now.Add(-3 * time.Hour), now.Add(-2 * time.Hour), now.Add(-time.Hour), now} {
+		userID := fmt.Sprint(i)
+		rl.AccountAges[userID] = created
+		rl.AccountSeen[userID] = seen
+	}
+	// Cached before last use was tracked
+	rl.AccountAges["untracked"] = created
+	// Last use of a date no longer cached
+	rl.AccountSeen["orphan"] = now
+
+	rl.evictAccountAges(3)
+
+	var kept []string
+	for userID := range rl.AccountAges {
+		kept = append(kept, userID)
+	}
+	sort.Strings(kept)
+	if strings.Join(kept, ",") != "3,4,untracked" {
+		t.Errorf("kept %v, want the 3 most recently seen and none past the TTL", kept)
+	}
+	if len(rl.AccountSeen) != len(rl.AccountAges) {
+		t.Errorf("last seen times %v don't match the cached dates", rl.AccountSeen)
+	}
+}
+
+func TestPruneRequestsBoundsAccountAgeCache(t *testing.T) {
+	rl := NewRateLimiter()
+	for i := 0; i < accountAgeCacheSize+5; i++ {
+		userID := fmt.Sprint(i)
+		rl.AccountAges[userID] = time.Now().AddDate(-1, 0, 0)
+		rl.AccountSeen[userID] = time.Now().Add(time.Duration(i) * time.Millisecond)
+	}
+
+	rl.PruneRequests()
+	if len(rl.AccountAges) != accountAgeCacheSize {
+		t.Errorf("cached %d dates, want %d", len(rl.AccountAges), accountAgeCacheSize)
+	}
+	if _, exists := rl.AccountAges["0"]; exists {
+		t.Error("the least recently seen account was kept")
+	}
+}
+
+func TestIsTrustedInstance(t *testing.T) {
+	useConfig(t)
+	config.Server.MastodonServer = "https://home.example"
+
+	if isTrustedInstance("bob@trusted.social") {
+		t.Error("trusted without any trusted_instances")
+	}
+
+	config.Behavior.TrustedInstances = []string{"Trusted.Social", "home.example"}
+	tests := []struct {
+		acct string
+		want bool
+	}{
+		{"bob@trusted.social", true},
+		{"@bob@TRUSTED.social", true},
+		{"bob", true}, // A local account of the bot's own instance
+		{"bob@untrusted.social", false},
+		{"bob@trusted.social.evil.example", false},
+	}
+	for _, test := range tests {
+		if got := isTrustedInstance(test.acct); got != test.want {
+			t.Errorf("isTrustedInstance(%q) = %v, want %v", test.acct, got, test.want)
+		}
+	}
+}
+
+func TestTrustedInstanceSkipsConsent(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	useBotState(t)
+	useConsentDB(t)
+	config.ImageProcessing.MaxSizeMB = 10
+	config.Behavior.TrustedInstances = []string{"trusted.social"}
+	useProvider(t, newStubProvider(stubResponse{text: "A lighthouse."}))
+	media := mediaServer(t, "image/png", testPNG(t))
+
+	posts := make(chan url.Values, 2)
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			r.ParseForm()
+			posts <- r.Form
+			io.WriteString(w, `{"id":"3"}`)
+			return
+		}
+		io.WriteString(w, `{"id":"1","visibility":"public","language":"en","content":"","account":{"id":"20","acct":"bob@trusted.social"}}`)
+	})
+
+	for _, acct := range []string{"bob@trusted.social", "eve@untrusted.social"} {
+		handleUpdate(c, &mastodon.Status{
+			ID:               "1",
+			Language:         "en",
+			Account:          mastodon.Account{ID: mastodon.ID(acct), Acct: acct},
+			MediaAttachments: []mastodon.Attachment{{ID: "m1", Type: "image", URL: media.URL + "/lighthouse.png"}},
+		})
+	}
+
+	if len(posts) != 2 {
+		t.Fatalf("posted %d times, want a caption and a consent request", len(posts))
+	}
+	if caption := <-posts; !strings.Contains(caption.Get("status"), "A lighthouse.") {
+		t.Errorf("trusted user got %q, want the caption", caption.Get("status"))
+	}
+	if request := <-posts; !strings.Contains(request.Get("status"), getLocalizedString("en", "gdprConsentRequest", "response")) {
+		t.Errorf("untrusted user got %q, want a consent request", request.Get("status"))
+	}
+}
+
+func TestTrustedInstanceRateLimits(t *testing.T) {
+	useRateLimit(t, "30")
+	config.Behavior.TrustedInstances = []string{"trusted.social"}
+	config.RateLimit.NewAccountMaxRequestsPerMinute = 1
+	config.RateLimit.ShadowBanThreshold = 1
+
+	// Trusted users get the normal limits without their account age being looked up
+	for i := 0; i < 5; i++ {
+		if allowed, _ := rateLimiter.Increment(nil, "20", "bob@trusted.social"); !allowed {
+			t.Fatalf("request %d of a trusted user was limited", i+1)
+		}
+	}
+	if _, looked := rateLimiter.AccountAges["20"]; looked {
+		t.Error("the account age of a trusted user was looked up")
+	}
+
+	// and aren't shadow banned for going over them
+	rateLimiter.Increment(nil, "20", "bob@trusted.social")
+	if rateLimiter.ExceededCounts["20"] != 1 || rateLimiter.IsShadowBanned("20") {
+		t.Errorf("exceeded count %d, banned %v, want counted but not banned", rateLimiter.ExceededCounts["20"], rateLimiter.IsShadowBanned("20"))
+	}
+
+	// An untrusted user with a new account gets the stricter limit
+	config.RateLimit.ShadowBanThreshold = 100
+	rateLimiter.AccountAges["30"] = time.Now().AddDate(0, 0, -1)
+	rateLimiter.Increment(nil, "30", "eve@untrusted.social")
+	if allowed, _ := rateLimiter.Increment(nil, "30", "eve@untrusted.social"); allowed {
+		t.Error("a new untrusted account got past the stricter limit")
+	}
+}
+
+// postReplyText generates a reply for an image post as the configured provider and returns the posted text
+func postReplyText(t *testing.T) string {
+	t.Helper()
+	useProvider(t, newStubProvider(stubResponse{text: "A red bicycle leaning against a wall."}))
+	config.ImageProcessing.MaxSizeMB = 10
+	config.LLM.Provider = "gemini"
+	config.Server.Username = "altbot"
+	media := mediaServer(t, "image/png", testPNG(t))
+
+	posts := make(chan url.Values, 1)
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			r.ParseForm()
+			posts <- r.Form
+			io.WriteString(w, `{"id":"3"}`)
+			return
+		}
+		io.WriteString(w, `{"id":"2","visibility":"public","language":"en","content":"","account":{"id":"10","acct":"alice"}}`)
+	})
+
+	status := &mastodon.Status{
+		ID:               "1",
+		Account:          mastodon.Account{ID: "20", Acct: "bob"},
+		MediaAttachments: []mastodon.Attachment{{ID: "m1", Type: "image", URL: media.URL + "/a.png"}},
+	}
+	generateAndPostAltText(c, status, "2", altTextOptions{})
+
+	if len(posts) != 1 {
+		t.Fatal("nothing was posted")
+	}
+	return (<-posts).Get("status")
+}
+
+func TestAttributionCanBeDisabled(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	useBotState(t)
+
+	config.Behavior.AttributionEnabled = true
+	if reply := postReplyText(t); !strings.HasPrefix(reply, "Provided by @altbot, generated using Gemini\n\n@alice ") {
+		t.Errorf("reply %q, want it to start with the attribution", reply)
+	}
+
+	config.Behavior.AttributionEnabled = false
+	reply := postReplyText(t)
+	if strings.Contains(reply, "Provided by") || !strings.HasPrefix(reply, "@alice ") {
+		t.Errorf("reply %q, want no attribution", reply)
+	}
+}
+
+func TestCustomAttribution(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	useBotState(t)
+	config.Behavior.AttributionEnabled = true
+
+	localizations["en"].Responses["customAttribution"] = "Described by {{bot}} with {{model}}"
+	t.Cleanup(func() { delete(localizations["en"].Responses, "customAttribution") })
+
+	if reply := postReplyText(t); !strings.HasPrefix(reply, "Described by altbot with Gemini\n\n@alice ") {
+		t.Errorf("reply %q, want the custom attribution", reply)
+	}
+
+	// Languages without their own template keep the default one
+	if attribution := getProviderAttribution(config, "ru", "gemini"); !strings.HasPrefix(attribution, "Предоставлено @altbot") {
+		t.Errorf("ru attribution %q", attribution)
+	}
+}
+
+func TestIsSelf(t *testing.T) {
+	useConfig(t)
+	config.Server.Username = "altbot"
+
+	// Until the bot's account is known only the username can be compared
+	useBotAccount(t, mastodon.Account{})
+	if !isSelf(&mastodon.Account{ID: "99", Acct: "altbot"}) || isSelf(&mastodon.Account{ID: "10", Acct: "alice"}) {
+		t.Error("username fallback doesn't match only the bot")
+	}
+
+	// Then the ID decides, an account elsewhere with the same username isn't the bot
+	useBotAccount(t, mastodon.Account{ID: "99", Acct: "altbot"})
+	if !isSelf(&mastodon.Account{ID: "99", Acct: "altbot"}) || isSelf(&mastodon.Account{ID: "10", Acct: "altbot@other.example"}) || isSelf(&mastodon.Account{ID: "11", Acct: "altbot"}) {
+		t.Error("isSelf didn't compare the account ID")
+	}
+}
+
+func TestOwnEventsAreIgnored(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	useBotState(t)
+	useConsentDB(t)
+	useReplyMaps(t)
+	useProvider(t, newStubProvider(stubResponse{text: "A red bicycle leaning against a wall."}))
+	config.Behavior.DeleteRedundantReplies = true
+	self := mastodon.Account{ID: "99", Acct: "altbot"}
+	useBotAccount(t, self)
+
+	var requests atomic.Int32
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"2","visibility":"public","language":"en","content":"","account":{"id":"99","acct":"altbot"}}`)
+	})
+
+	original := mastodon.ID("1")
+	ownPost := &mastodon.Status{
+		ID:               "5",
+		InReplyToID:      original,
+		Account:          self,
+		Content:          "<p>@altbot yes</p>",
+		MediaAttachments: []mastodon.Attachment{{ID: "m1", Type: "image"}},
+	}
+	// An edit that describes the media of a post the bot replied to
+	edited := *ownPost
+	edited.MediaAttachments = []mastodon.Attachment{{ID: "m1", Type: "image", Description: "A quoted image"}}
+	mapMutex.Lock()
+	replyMap[ownPost.ID] = ReplyInfo{ReplyID: "6", Timestamp: time.Now()}
+	mapMutex.Unlock()
+
+	handleMention(c, &mastodon.Notification{Type: "mention", Account: self, Status: ownPost})
+	handleUpdate(c, ownPost)
+	handleFollow(c, &mastodon.Notification{Type: "follow", Account: self})
+	handleEditEvent(c, &edited)
+	if HandleGDPRConsentResponse(c, ownPost) {
+		t.Error("the bot's own post was taken as a consent response")
+	}
+
+	if n := requests.Load(); n != 0 {
+		t.Errorf("made %d requests for the bot's own events", n)
+	}
+	if _, exists := replyMap[ownPost.ID]; !exists {
+		t.Error("an edit of the bot's own post deleted a reply")
+	}
+}
+
+func TestEditAddingAltTextDeletesReply(t *testing.T) {
+	useConfig(t)
+	useBotState(t)
+	useReplyMaps(t)
+	useBotAccount(t, mastodon.Account{ID: "99", Acct: "altbot"})
+
+	deleted := make(chan string, 3)
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.NotFound(w, r)
+			return
+		}
+		deleted <- strings.TrimPrefix(r.URL.Path, "/api/v1/statuses/")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"6"}`)
+	})
+
+	mapMutex.Lock()
+	replyMap["5"] = ReplyInfo{ReplyID: "6", Timestamp: time.Now()}
+	mapMutex.Unlock()
+	edit := func(descriptions ...string) *mastodon.Status {
+		status := &mastodon.Status{ID: "5", Account: mastodon.Account{ID: "20", Acct: "bob"}}
+		for i, description := range descriptions {
+			status.MediaAttachments = append(status.MediaAttachments, mastodon.Attachment{ID: mastodon.ID(fmt.Sprint(i)), Type: "image", Description: description})
+		}
+		return status
+	}
+
+	// Off unless delete_redundant_replies is set
+	handleEditEvent(c, edit("A cat", "A dog"))
+	if len(deleted) != 0 {
+		t.Fatal("deleted the reply without delete_redundant_replies")
+	}
+	config.Behavior.DeleteRedundantReplies = true
+
+	// One image is still undescribed
+	handleEditEvent(c, edit("A cat", ""))
+	if len(deleted) != 0 {
+		t.Fatalf("deleted %s before every image was described", <-deleted)
+	}
+
+	handleEditEvent(c, edit("A cat", "A dog"))
+	if len(deleted) != 1 {
+		t.Fatalf("deleted %d replies, want the tracked one", len(deleted))
+	}
+	if id := <-deleted; id != "6" {
+		t.Errorf("deleted status %s, want the reply 6", id)
+	}
+	if _, exists := replyMap["5"]; exists {
+		t.Error("the deleted reply is still tracked")
+	}
+
+	// Edits of posts the bot didn't reply to are left alone
+	handleEditEvent(c, &mastodon.Status{ID: "7", Account: mastodon.Account{ID: "20"}, MediaAttachments: []mastodon.Attachment{{ID: "m", Description: "A bird"}}})
+	if len(deleted) != 0 {
+		t.Error("deleted a reply for an untracked post")
+	}
+}
+
+func TestPostOverMediaLimit(t *testing.T) {
+	loadTestLocalizations(t)
+	useRateLimit(t, "10")
+	config.ImageProcessing.MaxSizeMB = 10
+	config.Behavior.MaxMediaPerPost = 2
+	media := mediaServer(t, "image/png", testPNG(t))
+
+	posts := make(chan url.Values, 1)
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			r.ParseForm()
+			posts <- r.Form
+			io.WriteString(w, `{"id":"3"}`)
+			return
+		}
+		io.WriteString(w, `{"id":"2","visibility":"public","language":"en","content":"","account":{"id":"10","acct":"alice"}}`)
+	})
+
+	status := &mastodon.Status{ID: "1", Account: mastodon.Account{ID: "20", Acct: "bob"}}
+	for i := 0; i < 4; i++ {
+		status.MediaAttachments = append(status.MediaAttachments, mastodon.Attachment{ID: mastodon.ID(fmt.Sprint(i)), Type: "image", URL: fmt.Sprintf("%s/%d.png", media.URL, i)})
+	}
+
+	tests := []struct {
+		mode       string
+		calls      int
+		note       string
+		rateLimits int
+	}{
+		{"partial", 2, fmt.Sprintf(getLocalizedString("en", "mediaLimitPartial", "response"), 2, 2), 2},
+		{"skip", 0, fmt.Sprintf(getLocalizedString("en", "mediaLimitSkipped", "response"), 2), 0},
+	}
+	for _, test := range tests {
+		config.Behavior.OverMediaLimit = test.mode
+		rateLimiter.Requests = make(map[string][]time.Time)
+		provider := newStubProvider(stubResponse{text: "A colourful gradient."})
+		useProvider(t, provider)
+
+		generateAndPostAltText(c, status, "2", altTextOptions{})
+
+		if len(posts) != 1 {
+			t.Fatalf("%s: nothing was posted", test.mode)
+		}
+		reply := (<-posts).Get("status")
+		if provider.calls() != test.calls || strings.Count(reply, "A colourful gradient.") != test.calls {
+			t.Errorf("%s: %d generations, reply %q, want %d", test.mode, provider.calls(), reply, test.calls)
+		}
+		if !strings.Contains(reply, test.note) {
+			t.Errorf("%s: reply %q is missing %q", test.mode, reply, test.note)
+		}
+		// Only described media count against the rate limit
+		if n := len(rateLimiter.Requests["10"]); n != test.rateLimits {
+			t.Errorf("%s: %d requests counted against the rate limit, want %d", test.mode, n, test.rateLimits)
+		}
+	}
+}
+
+// useFollowServer is a Mastodon server that accepts posts and follows, and sends the IDs of followed accounts
+func useFollowServer(t *testing.T, relationship string) (*mastodon.Client, chan string) {
+	t.Helper()
+	follows := make(chan string, 10)
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/follow"):
+			id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/accounts/"), "/follow")
+			follows <- id
+			fmt.Fprintf(w, `{"id":%q,"following":true}`, id)
+		case r.URL.Path == "/api/v1/accounts/relationships":
+			io.WriteString(w, "["+relationship+"]")
+		case r.Method == http.MethodPost:
+			io.WriteString(w, `{"id":"3"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	return c, follows
+}
+
+func TestFollowBackPolicies(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	useBotState(t)
+	useConsentDB(t)
+	if err := RecordUserConsent("30", "reply"); err != nil {
+		t.Fatal(err)
+	}
+
+	followers := []mastodon.Account{
+		{ID: "20", Acct: "bob"},
+		{ID: "21", Acct: "newsbot", Bot: true},
+		{ID: "30", Acct: "carol"}, // Gave consent before
+	}
+	tests := []struct {
+		policy     string
+		followBack bool
+		want       string
+	}{
+		{"all", false, "20,21,30"},
+		{"non_bots", false, "20,30"},
+		{"consented_only", false, "30"},
+		{"none", true, ""},
+		// Without a policy follow_back decides
+		{"", true, "20,21,30"},
+		{"", false, ""},
+	}
+	for _, test := range tests {
+		config.Behavior.FollowBackPolicy, config.Behavior.FollowBack = test.policy, test.followBack
+		c, follows := useFollowServer(t, "")
+
+		for i := range followers {
+			handleFollow(c, &mastodon.Notification{Type: "follow", Account: followers[i]})
+		}
+		close(follows)
+
+		var followed []string
+		for id := range follows {
+			followed = append(followed, id)
+		}
+		sort.Strings(followed)
+		if got := strings.Join(followed, ","); got != test.want {
+			t.Errorf("policy %q, follow_back %v: followed %q, want %q", test.policy, test.followBack, got, test.want)
+		}
+	}
+}
+
+func TestFollowBackAfterConsent(t *testing.T) {
+	useConfig(t)
+	useBotState(t)
+	bob := &mastodon.Account{ID: "20", Acct: "bob"}
+
+	tests := []struct {
+		policy       string
+		relationship string
+		want         bool
+	}{
+		{"consented_only", `{"id":"20","followed_by":true,"following":false}`, true},
+		// Not a follower, or already followed
+		{"consented_only", `{"id":"20","followed_by":false,"following":false}`, false},
+		{"consented_only", `{"id":"20","followed_by":true,"following":true}`, false},
+		// The other policies decide when the follow happens
+		{"all", `{"id":"20","followed_by":true,"following":false}`, false},
+		{"non_bots", `{"id":"20","followed_by":true,"following":false}`, false},
+	}
+	for _, test := range tests {
+		config.Behavior.FollowBackPolicy = test.policy
+		c, follows := useFollowServer(t, test.relationship)
+
+		followBackAfterConsent(c, bob)
+		if followed := len(follows) == 1; followed != test.want {
+			t.Errorf("policy %s with %s: followed %v, want %v", test.policy, test.relationship, followed, test.want)
+		}
+	}
+}
@@ -9,8 +9,12 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
-	"log"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -23,15 +27,17 @@ type MetricEvent struct {
 	Details   map[string]interface{}
 }
 
-// MetricsManager handles the metrics collection and reporting with detailed logs
+// MetricsManager handles the metrics collection and reporting with detailed logs.
+// The current file only holds today's events, earlier days are rotated into one file per day.
 type MetricsManager struct {
-	enabled   bool
-	fileMutex sync.Mutex
-	logs      []MetricEvent
-	filePath  string
-	ticker    *time.Ticker
-	wg        sync.WaitGroup
-	stopChan  chan struct{}
+	enabled       bool
+	fileMutex     sync.Mutex
+	logs          []MetricEvent
+	filePath      string
+	retentionDays int
+	ticker        *time.Ticker
+	wg            sync.WaitGroup
+	stopChan      chan struct{}
 }
 
 // hashUserID creates a SHA-256 hash of the user ID
@@ -41,14 +47,16 @@ func hashUserID(userID string) string {
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
-// NewMetricsManager initializes a new metrics manager
-func NewMetricsManager(enabled bool, filePath string, interval time.Duration) *MetricsManager {
+// NewMetricsManager initializes a new metrics manager, rotated files older than retentionDays
+// are deleted (0 keeps them all)
+func NewMetricsManager(enabled bool, filePath string, interval time.Duration, retentionDays int) *MetricsManager {
 	mm := &MetricsManager{
-		enabled:  enabled,
-		logs:     []MetricEvent{},
-		filePath: filePath,
-		ticker:   time.NewTicker(interval),
-		stopChan: make(chan struct{}),
+		enabled:       enabled,
+		logs:          []MetricEvent{},
+		filePath:      filePath,
+		retentionDays: retentionDays,
+		ticker:        time.NewTicker(interval),
+		stopChan:      make(chan struct{}),
 	}
 
 	if mm.enabled {
@@ -71,13 +79,13 @@ func (mm *MetricsManager) loadAndHashExistingData() {
 
 	file, err := os.ReadFile(mm.filePath)
 	if err != nil {
-		log.Printf("Error reading metrics file: %v", err)
+		logErrorf("Error reading metrics file: %v", err)
 		return
 	}
 
 	var existingLogs []MetricEvent
 	if err := json.Unmarshal(file, &existingLogs); err != nil {
-		log.Printf("Error parsing metrics file: %v", err)
+		logErrorf("Error parsing metrics file: %v", err)
 		return
 	}
 
@@ -131,11 +139,86 @@ func (mm *MetricsManager) logFollow(userID string) {
 	mm.logEvent(userID, "follow", nil)
 }
 
-// calculatePowerConsumption calculates the power consumption in Wh based on processing time and GPU watts
-func calculatePowerConsumption(processingTimeMs int64, gpuWatts float64) float64 {
+// idleShareWindow is the period whose idle power draw is shared between the generations in it
+const idleShareWindow = 24 * time.Hour
+
+var (
+	idleShareGenerations []time.Time
+	idleShareMu          sync.Mutex
+	powerTrackingStart   = time.Now()
+)
+
+// recordGeneration notes a generation so it's given its share of the idle power draw
+func recordGeneration(now time.Time) {
+	idleShareMu.Lock()
+	defer idleShareMu.Unlock()
+
+	idleShareGenerations = append(idleShareGenerations, now)
+	pruneIdleShareGenerations(now)
+}
+
+// pruneIdleShareGenerations drops the generations older than the idle share window, the caller holds idleShareMu
+func pruneIdleShareGenerations(now time.Time) {
+	i := 0
+	for i < len(idleShareGenerations) && now.Sub(idleShareGenerations[i]) > idleShareWindow {
+		i++
+	}
+	idleShareGenerations = idleShareGenerations[i:]
+}
+
+// idleShareWh is the energy in Wh drawn at idle over the last day (or since starting, if that was more recent),
+// divided between the generations in that time
+func idleShareWh(now time.Time) float64 {
+	if config.PowerMetrics.IdleWatts <= 0 {
+		return 0
+	}
+
+	idleShareMu.Lock()
+	defer idleShareMu.Unlock()
+	pruneIdleShareGenerations(now)
+
+	window := min(now.Sub(powerTrackingStart), idleShareWindow)
+	return config.PowerMetrics.IdleWatts * window.Hours() / float64(max(len(idleShareGenerations), 1))
+}
+
+// calculatePowerConsumption estimates the energy in Wh of generations that took processingTimeMs altogether:
+// what the GPU and CPU draw above idle while processing, plus each generation's share of the idle draw
+func calculatePowerConsumption(processingTimeMs int64, generations int) float64 {
 	// Formula: Wh = (watts × processing_time_ms) ÷ (1000 × 3600)
-	// Convert milliseconds to hours and multiply by watts
-	return (gpuWatts * float64(processingTimeMs)) / (1000 * 3600)
+	activeWatts := config.PowerMetrics.GPUWatts + config.PowerMetrics.CPUWatts
+	activeWh := (activeWatts * float64(processingTimeMs)) / (1000 * 3600)
+	return activeWh + float64(generations)*idleShareWh(time.Now())
+}
+
+// decimalCommaLanguages write decimal numbers with a comma instead of a point
+var decimalCommaLanguages = map[string]bool{
+	"ru": true, "be": true, "es": true, "fr": true, "de": true, "it": true, "pt": true, "pl": true, "eu": true,
+}
+
+// formatLocalizedDecimal formats a number with the decimal separator used in the given language
+func formatLocalizedDecimal(lang string, value float64, precision int) string {
+	formatted := strconv.FormatFloat(value, 'f', precision, 64)
+	if decimalCommaLanguages[lang] {
+		formatted = strings.Replace(formatted, ".", ",", 1)
+	}
+	return formatted
+}
+
+// energyUsageMessage formats the energy used for a reply in the configured reply_unit,
+// "" when the message is disabled
+func energyUsageMessage(lang string, wattHours float64) string {
+	switch config.PowerMetrics.ReplyUnit {
+	case "none":
+		return ""
+	case "kWh":
+		return fmt.Sprintf(getLocalizedString(lang, "energyUsageMessageKWh", "response"), formatLocalizedDecimal(lang, wattHours/1000, 6))
+	case "co2e":
+		// Grid intensity is in grams of CO2e per kWh
+		grams := wattHours / 1000 * config.PowerMetrics.GridIntensity
+		return fmt.Sprintf(getLocalizedString(lang, "emissionsMessage", "response"), formatLocalizedDecimal(lang, grams, 3))
+	default:
+		return fmt.Sprintf(getLocalizedString(lang, "energyUsageMessage", "response"), formatLocalizedDecimal(lang, wattHours, 3))
+	}
 }
 
 // logSuccessfulGeneration logs a successful alt-text generation
@@ -148,7 +231,8 @@ func (mm *MetricsManager) logSuccessfulGeneration(userID, mediaType string, resp
 
 	// Add power consumption metrics if enabled and using a local model
 	if config.PowerMetrics.Enabled && config.LLM.Provider != "gemini" {
-		powerConsumption := calculatePowerConsumption(responseTimeMillis, config.PowerMetrics.GPUWatts)
+		recordGeneration(time.Now())
+		powerConsumption := calculatePowerConsumption(responseTimeMillis, 1)
 		details["powerConsumptionKWh"] = powerConsumption
 	}
 
@@ -201,38 +285,300 @@ func (mm *MetricsManager) saveToFile(lock bool) {
 
 	file, err := os.OpenFile(mm.filePath, os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		log.Printf("Error opening metrics file: %v", err)
+		logErrorf("Error opening metrics file: %v", err)
 		return
 	}
 	defer file.Close()
 
 	if err := file.Truncate(0); err != nil {
-		log.Printf("Error truncating metrics file: %v", err)
+		logErrorf("Error truncating metrics file: %v", err)
 		return
 	}
 
 	if _, err := file.Seek(0, 0); err != nil {
-		log.Printf("Error seeking in metrics file: %v", err)
+		logErrorf("Error seeking in metrics file: %v", err)
 		return
 	}
 
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
 	if err := encoder.Encode(mm.logs); err != nil {
-		log.Printf("Error writing metrics to file: %v", err)
+		logErrorf("Error writing metrics to file: %v", err)
 		return
 	}
 
 }
 
+// metricsDay returns the local date an event belongs to, as used in the names of rotated files
+func metricsDay(t time.Time) string {
+	return t.Local().Format("2006-01-02")
+}
+
+// rotatedMetricsPath returns the file the events of a day are rotated into, metrics-YYYY-MM-DD.json
+func rotatedMetricsPath(filePath string, day string) string {
+	return strings.TrimSuffix(filePath, ".json") + "-" + day + ".json"
+}
+
+// rotatedMetricsFiles returns the rotated files of a metrics file by day, oldest first
+func rotatedMetricsFiles(filePath string) (map[string]string, []string) {
+	prefix := strings.TrimSuffix(filePath, ".json") + "-"
+	matches, _ := filepath.Glob(prefix + "*.json")
+
+	files := make(map[string]string)
+	var days []string
+	for _, match := range matches {
+		day := strings.TrimSuffix(strings.TrimPrefix(match, prefix), ".json")
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			continue
+		}
+		files[day] = match
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	return files, days
+}
+
+// readMetricsEvents reads the events logged since the given time from the current metrics file and
+// the rotated files of the days since, oldest first
+func readMetricsEvents(filePath string, since time.Time) ([]MetricEvent, error) {
+	files, days := rotatedMetricsFiles(filePath)
+
+	var paths []string
+	for _, day := range days {
+		if day >= metricsDay(since) {
+			paths = append(paths, files[day])
+		}
+	}
+	paths = append(paths, filePath)
+
+	var events []MetricEvent
+	for _, path := range paths {
+		var fileEvents []MetricEvent
+		if err := readJSONIfExists(path, &fileEvents); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		for _, event := range fileEvents {
+			if !event.Timestamp.Before(since) {
+				events = append(events, event)
+			}
+		}
+	}
+	return events, nil
+}
+
+// LanguageStat is how many captions were generated in a language and how long they took on average
+type LanguageStat struct {
+	Count                 int   `json:"count"`
+	AverageResponseTimeMs int64 `json:"average_response_time_ms"`
+}
+
+// languageStats aggregates the successful generations among the events by language
+func languageStats(events []MetricEvent) map[string]LanguageStat {
+	counts := make(map[string]int)
+	totals := make(map[string]int64)
+	for _, event := range events {
+		if event.EventType != "successful_generation" {
+			continue
+		}
+		lang, _ := event.Details["lang"].(string)
+		if lang == "" {
+			continue
+		}
+		counts[lang]++
+
+		// The response time is an int64 when logged and a float64 once read back from a file
+		switch responseTime := event.Details["responseTime"].(type) {
+		case int64:
+			totals[lang] += responseTime
+		case float64:
+			totals[lang] += int64(responseTime)
+		}
+	}
+
+	stats := make(map[string]LanguageStat, len(counts))
+	for lang, count := range counts {
+		stats[lang] = LanguageStat{Count: count, AverageResponseTimeMs: totals[lang] / int64(count)}
+	}
+	return stats
+}
+
+// GetLanguageStats returns the per-language statistics of all the events still kept
+func (mm *MetricsManager) GetLanguageStats() map[string]LanguageStat {
+	return languageStats(mm.keptEvents())
+}
+
+// EnergyStats is the estimated energy used by a local model over all the events still kept
+type EnergyStats struct {
+	Generations  int     `json:"generations"`
+	TotalWh      float64 `json:"total_wh"`
+	PerRequestWh float64 `json:"per_request_wh"`
+}
+
+// GetEnergyStats returns the running total of the energy logged with the generations, and its average per generation
+func (mm *MetricsManager) GetEnergyStats() EnergyStats {
+	var stats EnergyStats
+	for _, event := range mm.keptEvents() {
+		// Despite its name the power consumption is logged in Wh
+		if wattHours, ok := event.Details["powerConsumptionKWh"].(float64); ok && event.EventType == "successful_generation" {
+			stats.Generations++
+			stats.TotalWh += wattHours
+		}
+	}
+	if stats.Generations > 0 {
+		stats.PerRequestWh = stats.TotalWh / float64(stats.Generations)
+	}
+	return stats
+}
+
+// keptEvents returns all the events still kept, today's and the rotated days'
+func (mm *MetricsManager) keptEvents() []MetricEvent {
+	mm.fileMutex.Lock()
+	events := append([]MetricEvent(nil), mm.logs...)
+	mm.fileMutex.Unlock()
+
+	files, days := rotatedMetricsFiles(mm.filePath)
+	for _, day := range days {
+		var dayEvents []MetricEvent
+		if err := readJSONIfExists(files[day], &dayEvents); err != nil {
+			logErrorf("Error reading %s: %v", files[day], err)
+			continue
+		}
+		events = append(events, dayEvents...)
+	}
+	return events
+}
+
+// DeleteUser removes a user's events from memory and the rotated files, returning how many there were.
+// The current file is rewritten from memory on the next save.
+func (mm *MetricsManager) DeleteUser(userID string) (int, error) {
+	mm.fileMutex.Lock()
+	defer mm.fileMutex.Unlock()
+
+	hashedID := hashUserID(userID)
+	isUser := func(event MetricEvent) bool { return event.UserID == hashedID || event.UserID == userID }
+
+	removed := 0
+	kept := mm.logs[:0]
+	for _, event := range mm.logs {
+		if isUser(event) {
+			removed++
+			continue
+		}
+		kept = append(kept, event)
+	}
+	mm.logs = kept
+
+	files, days := rotatedMetricsFiles(mm.filePath)
+	for _, day := range days {
+		var events []MetricEvent
+		if err := readJSONIfExists(files[day], &events); err != nil {
+			return removed, fmt.Errorf("failed to read %s: %v", files[day], err)
+		}
+
+		dayKept := events[:0]
+		for _, event := range events {
+			if !isUser(event) {
+				dayKept = append(dayKept, event)
+			}
+		}
+		if len(dayKept) == len(events) {
+			continue
+		}
+		removed += len(events) - len(dayKept)
+
+		data, err := json.MarshalIndent(dayKept, "", "  ")
+		if err == nil {
+			err = os.WriteFile(files[day]+".tmp", data, 0644)
+		}
+		if err == nil {
+			err = os.Rename(files[day]+".tmp", files[day])
+		}
+		if err != nil {
+			return removed, fmt.Errorf("failed to rewrite %s: %v", files[day], err)
+		}
+	}
+
+	return removed, nil
+}
+
+// rotate moves the events of earlier days out of memory into their day's file, and deletes the
+// rotated files that are older than the retention period
+func (mm *MetricsManager) rotate(now time.Time) {
+	mm.fileMutex.Lock()
+	defer mm.fileMutex.Unlock()
+
+	today := metricsDay(now)
+
+	// Events are appended in order, so the oldest one tells whether a day has ended
+	if len(mm.logs) > 0 && metricsDay(mm.logs[0].Timestamp) != today {
+		past := make(map[string][]MetricEvent)
+		var current []MetricEvent
+		for _, event := range mm.logs {
+			if day := metricsDay(event.Timestamp); day != today {
+				past[day] = append(past[day], event)
+			} else {
+				current = append(current, event)
+			}
+		}
+
+		for day, events := range past {
+			path := rotatedMetricsPath(mm.filePath, day)
+
+			// A day can already have a file if the bot was restarted before midnight
+			var existing []MetricEvent
+			if err := readJSONIfExists(path, &existing); err != nil {
+				logErrorf("Error reading rotated metrics file %s, keeping its events in memory: %v", path, err)
+				current = append(current, events...)
+				continue
+			}
+
+			data, err := json.MarshalIndent(append(existing, events...), "", "  ")
+			if err == nil {
+				err = os.WriteFile(path+".tmp", data, 0644)
+			}
+			if err == nil {
+				err = os.Rename(path+".tmp", path)
+			}
+			if err != nil {
+				logErrorf("Error writing rotated metrics file %s, keeping its events in memory: %v", path, err)
+				current = append(current, events...)
+				continue
+			}
+			logInfof("Rotated %d metrics events into %s", len(events), path)
+		}
+
+		sort.SliceStable(current, func(i, j int) bool { return current[i].Timestamp.Before(current[j].Timestamp) })
+		mm.logs = current
+		mm.saveToFile(false)
+	}
+
+	if mm.retentionDays <= 0 {
+		return
+	}
+	cutoff := metricsDay(now.AddDate(0, 0, -mm.retentionDays))
+	files, days := rotatedMetricsFiles(mm.filePath)
+	for _, day := range days {
+		if day >= cutoff {
+			break
+		}
+		if err := os.Remove(files[day]); err != nil {
+			logErrorf("Error removing old metrics file: %v", err)
+		} else {
+			logInfof("Removed metrics file %s older than %d days", files[day], mm.retentionDays)
+		}
+	}
+}
+
 func (mm *MetricsManager) run() {
 	defer mm.wg.Done()
 	for {
 		select {
 		case <-mm.ticker.C:
+			mm.rotate(time.Now())
 			mm.saveToFile(true)
 		case <-mm.stopChan:
 			mm.ticker.Stop()
+			mm.rotate(time.Now())
 			mm.saveToFile(true)
 			return
 		}
@@ -0,0 +1,221 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetricsRotateAtMidnight(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	mm := NewMetricsManager(false, path, time.Hour, 0)
+
+	midnight := time.Date(2025, 6, 15, 0, 0, 0, 0, time.Local)
+	beforeMidnight, afterMidnight := midnight.Add(-time.Second), midnight.Add(time.Second)
+	mm.logs = []MetricEvent{
+		{Timestamp: midnight.Add(-2 * time.Hour), EventType: "request"},
+		{Timestamp: beforeMidnight, EventType: "request"},
+		{Timestamp: afterMidnight, EventType: "request"},
+	}
+
+	// Nothing is rotated until the day is over
+	mm.rotate(beforeMidnight)
+	if len(mm.logs) != 3 {
+		t.Fatalf("%d events kept in memory before midnight, want 3", len(mm.logs))
+	}
+	if _, days := rotatedMetricsFiles(path); len(days) != 0 {
+		t.Fatalf("rotated %v before midnight", days)
+	}
+
+	mm.rotate(afterMidnight)
+	if len(mm.logs) != 1 || !mm.logs[0].Timestamp.Equal(afterMidnight) {
+		t.Fatalf("kept %+v in memory, want only the event after midnight", mm.logs)
+	}
+
+	var rotated, current []MetricEvent
+	readJSONFile(t, rotatedMetricsPath(path, "2025-06-14"), &rotated)
+	readJSONFile(t, path, &current)
+	if len(rotated) != 2 || !rotated[1].Timestamp.Equal(beforeMidnight) {
+		t.Errorf("metrics-2025-06-14.json has %+v, want the 2 events before midnight", rotated)
+	}
+	if len(current) != 1 {
+		t.Errorf("metrics.json has %d events, want 1", len(current))
+	}
+
+	// The rotated day still counts for everything kept
+	if events := mm.keptEvents(); len(events) != 3 {
+		t.Errorf("%d events kept overall, want 3", len(events))
+	}
+}
+
+func TestMetricsRotateAppendsToExistingDay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	mm := NewMetricsManager(false, path, time.Hour, 0)
+
+	yesterday := time.Date(2025, 6, 14, 12, 0, 0, 0, time.Local)
+	// Rotated before a restart earlier in the day
+	writeJSONFile(t, rotatedMetricsPath(path, "2025-06-14"), []MetricEvent{{Timestamp: yesterday, EventType: "request"}})
+	mm.logs = []MetricEvent{{Timestamp: yesterday.Add(time.Hour), EventType: "follow"}}
+
+	mm.rotate(yesterday.AddDate(0, 0, 1))
+
+	var rotated []MetricEvent
+	readJSONFile(t, rotatedMetricsPath(path, "2025-06-14"), &rotated)
+	if len(rotated) != 2 || rotated[0].EventType != "request" || rotated[1].EventType != "follow" {
+		t.Errorf("metrics-2025-06-14.json has %+v, want both events in order", rotated)
+	}
+}
+
+func TestMetricsRetentionPrunesOldFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "metrics.json")
+	for _, day := range []string{"2025-06-01", "2025-06-07", "2025-06-08", "2025-06-14"} {
+		writeJSONFile(t, rotatedMetricsPath(path, day), []MetricEvent{})
+	}
+	// Files that aren't a rotated day are left alone
+	writeJSONFile(t, filepath.Join(dir, "metrics-backup.json"), []MetricEvent{})
+
+	now := time.Date(2025, 6, 15, 9, 0, 0, 0, time.Local)
+	NewMetricsManager(false, path, time.Hour, 7).rotate(now)
+
+	if _, days := rotatedMetricsFiles(path); len(days) != 2 || days[0] != "2025-06-08" || days[1] != "2025-06-14" {
+		t.Errorf("kept %v, want the last 7 days", days)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "metrics-backup.json")); err != nil {
+		t.Errorf("unrelated file was removed: %v", err)
+	}
+
+	// A retention of 0 keeps everything
+	writeJSONFile(t, rotatedMetricsPath(path, "2024-01-01"), []MetricEvent{})
+	NewMetricsManager(false, path, time.Hour, 0).rotate(now)
+	if _, days := rotatedMetricsFiles(path); len(days) != 3 {
+		t.Errorf("kept %v without a retention period", days)
+	}
+}
+
+func TestGetLanguageStats(t *testing.T) {
+	useConfig(t)
+	config.PowerMetrics.Enabled = false
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	mm := NewMetricsManager(true, path, time.Hour, 0)
+	t.Cleanup(mm.stop)
+
+	// An earlier day, read back from its file
+	yesterday := time.Now().AddDate(0, 0, -1)
+	writeJSONFile(t, rotatedMetricsPath(path, metricsDay(yesterday)), []MetricEvent{
+		{Timestamp: yesterday, EventType: "successful_generation", Details: map[string]interface{}{"lang": "de", "responseTime": 3000}},
+		{Timestamp: yesterday, EventType: "request", Details: map[string]interface{}{"lang": "fr"}},
+	})
+
+	mm.logSuccessfulGeneration("alice", "image", 1000, "de")
+	mm.logSuccessfulGeneration("bob", "image", 500, "en")
+	mm.logSuccessfulGeneration("bob", "video", 1500, "en")
+	mm.logSuccessfulGeneration("carol", "image", 2000, "ja")
+
+	want := map[string]LanguageStat{
+		"de": {Count: 2, AverageResponseTimeMs: 2000},
+		"en": {Count: 2, AverageResponseTimeMs: 1000},
+		"ja": {Count: 1, AverageResponseTimeMs: 2000},
+	}
+	if stats := mm.GetLanguageStats(); !reflect.DeepEqual(stats, want) {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+}
+
+// usePowerTracking starts tracking the idle power draw at start, with no generations yet
+func usePowerTracking(t *testing.T, start time.Time) {
+	t.Helper()
+	idleShareMu.Lock()
+	previousStart, previousGenerations := powerTrackingStart, idleShareGenerations
+	powerTrackingStart, idleShareGenerations = start, nil
+	idleShareMu.Unlock()
+	t.Cleanup(func() {
+		idleShareMu.Lock()
+		powerTrackingStart, idleShareGenerations = previousStart, previousGenerations
+		idleShareMu.Unlock()
+	})
+}
+
+func TestCalculatePowerConsumption(t *testing.T) {
+	useConfig(t)
+	usePowerTracking(t, time.Now())
+
+	tests := []struct {
+		gpuWatts, cpuWatts float64
+		processingTimeMs   int64
+		generations        int
+		want               float64
+	}{
+		{200, 50, 3600000, 1, 250},
+		{300, 0, 1800, 1, 0.15},
+		{0, 65, 60000, 1, 65.0 / 60},
+		// A batch takes its processing time once
+		{150, 30, 7200, 3, 0.36},
+		{0, 0, 5000, 1, 0},
+	}
+	for _, test := range tests {
+		config.PowerMetrics.GPUWatts, config.PowerMetrics.CPUWatts, config.PowerMetrics.IdleWatts = test.gpuWatts, test.cpuWatts, 0
+		if got := calculatePowerConsumption(test.processingTimeMs, test.generations); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%vW GPU + %vW CPU for %dms: got %v Wh, want %v", test.gpuWatts, test.cpuWatts, test.processingTimeMs, got, test.want)
+		}
+	}
+}
+
+func TestIdleShareIsDividedBetweenGenerations(t *testing.T) {
+	useConfig(t)
+	config.PowerMetrics.IdleWatts = 30
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	// Two hours since starting and nothing generated yet: the whole idle draw
+	usePowerTracking(t, now.Add(-2*time.Hour))
+	if got := idleShareWh(now); math.Abs(got-60) > 1e-9 {
+		t.Errorf("no generations: got %v Wh, want 60", got)
+	}
+
+	// Shared between the generations of the window
+	for _, ago := range []time.Duration{time.Hour, 30 * time.Minute, time.Minute} {
+		recordGeneration(now.Add(-ago))
+	}
+	if got := idleShareWh(now); math.Abs(got-20) > 1e-9 {
+		t.Errorf("3 generations: got %v Wh, want 20", got)
+	}
+
+	// The window is at most a day, and generations older than it no longer count
+	usePowerTracking(t, now.AddDate(0, 0, -3))
+	recordGeneration(now.Add(-25 * time.Hour))
+	recordGeneration(now.Add(-time.Hour))
+	recordGeneration(now.Add(-time.Minute))
+	if got := idleShareWh(now); math.Abs(got-360) > 1e-9 {
+		t.Errorf("after a day: got %v Wh, want 30W × 24h / 2 = 360", got)
+	}
+
+	// Including the idle share in an estimate
+	config.PowerMetrics.GPUWatts, config.PowerMetrics.CPUWatts = 0, 0
+	usePowerTracking(t, time.Now().Add(-time.Hour))
+	if got := calculatePowerConsumption(0, 2); math.Abs(got-60) > 1e-3 {
+		t.Errorf("2 generations without any recorded: got %v Wh, want twice the hour's 30", got)
+	}
+}
+
+func TestGetEnergyStats(t *testing.T) {
+	mm := NewMetricsManager(false, filepath.Join(t.TempDir(), "metrics.json"), time.Hour, 0)
+	mm.logs = []MetricEvent{
+		{Timestamp: time.Now(), EventType: "successful_generation", Details: map[string]interface{}{"powerConsumptionKWh": 1.5}},
+		{Timestamp: time.Now(), EventType: "successful_generation", Details: map[string]interface{}{"powerConsumptionKWh": 0.5}},
+		// Generations by a hosted model aren't estimated
+		{Timestamp: time.Now(), EventType: "successful_generation", Details: map[string]interface{}{"lang": "en"}},
+	}
+
+	stats := mm.GetEnergyStats()
+	if stats.Generations != 2 || math.Abs(stats.TotalWh-2) > 1e-9 || math.Abs(stats.PerRequestWh-1) > 1e-9 {
+		t.Errorf("got %+v, want 2 Wh over 2 generations", stats)
+	}
+}
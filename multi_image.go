@@ -0,0 +1,112 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// numberedItem matches the start of an item in a numbered list, e.g. "1." "2)" or "**3:**"
+var numberedItem = regexp.MustCompile(`^[\s*#]*(\d+)[.):][\s*]*`)
+
+// generateCombinedImageAltText describes all images of a post in a single request so the model can use
+// the context of the whole series. The result maps attachment IDs to their description, images the model
+// skipped are left out so they can be described on their own. The name of the provider that described them
+// is returned with them.
+func generateCombinedImageAltText(attachments []mastodon.Attachment, lang string, acct string, opts altTextOptions) (map[mastodon.ID]string, string) {
+	var images [][]byte
+	var formats []string
+	var ids []mastodon.ID
+	for _, attachment := range attachments {
+		if attachment.Type != "image" || attachment.Description != "" {
+			continue
+		}
+
+		img, err := fetchImage(attachment.URL)
+		if err != nil {
+			logErrorf("Error fetching image for combined alt-text: %v", err)
+			continue
+		}
+		downscaledImg, format, err := downscaleImage(img, config.ImageProcessing.DownscaleWidth)
+		if err != nil {
+			logErrorf("Error downscaling image for combined alt-text: %v", err)
+			continue
+		}
+
+		images = append(images, downscaledImg)
+		formats = append(formats, format)
+		ids = append(ids, attachment.ID)
+	}
+
+	// A single image doesn't need the combined prompt
+	if len(images) < 2 {
+		return nil, ""
+	}
+
+	logInfof("Processing %d images in a combined request", len(images))
+
+	response, servedBy, err := generateWith(llmProvider, "multi-image", canMultiImage, func(name string, provider LLMProvider) (string, error) {
+		prompt := getPromptForUser(lang, altTextPromptKey(opts.Style), acct, name) + " " + fmt.Sprintf(getPromptHint(lang, "multiImageInstructions"), len(images))
+		prompt = withUserContext(prompt, lang, opts.UserContext)
+		return withLLMRetry(func() (string, error) {
+			return provider.GenerateMultiImageAltText(prompt, images, formats, lang)
+		})
+	})
+	if err != nil {
+		logWarnf("Error generating combined alt-text, describing images separately: %v", err)
+		return nil, ""
+	}
+
+	descriptions := splitNumberedList(response, len(images))
+	captions := make(map[mastodon.ID]string)
+	for i, description := range descriptions {
+		if description = postProcessAltText(description, lang); description != "" && usableAltText(description, lang) {
+			captions[ids[i]] = description
+		}
+	}
+
+	if len(captions) < len(images) {
+		logInfof("Combined alt-text only covered %d of %d images, describing the rest separately", len(captions), len(images))
+	}
+
+	return captions, servedBy
+}
+
+// splitNumberedList splits a "1. ... 2. ..." response into count items by their number, lines without
+// a number belong to the item before them. Only numbers from 1 to count that come after the previous one
+// start an item, so numbers in a description (a list of its own, a year) stay part of it.
+// Numbers that are missing leave an empty item.
+func splitNumberedList(text string, count int) []string {
+	items := make([]string, count)
+	current := -1
+
+	for _, line := range strings.Split(text, "\n") {
+		if match := numberedItem.FindStringSubmatch(line); match != nil {
+			if number, err := strconv.Atoi(match[1]); err == nil && number-1 > current && number <= count {
+				current = number - 1
+				line = line[len(match[0]):]
+			}
+		}
+
+		if current < 0 {
+			continue
+		}
+
+		if line = strings.TrimSpace(line); line != "" {
+			if items[current] != "" {
+				items[current] += "\n"
+			}
+			items[current] += line
+		}
+	}
+
+	return items
+}
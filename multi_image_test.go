@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitNumberedList(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		count int
+		want  []string
+	}{
+		{
+			name:  "plain",
+			text:  "1. A red barn.\n2. A tractor in a field.",
+			count: 2,
+			want:  []string{"A red barn.", "A tractor in a field."},
+		},
+		{
+			name:  "markdown and continuation lines",
+			text:  "Here are the descriptions:\n**1:** A sign reading\n\"Open\"\n**2)** A closed door.",
+			count: 2,
+			want:  []string{"A sign reading\n\"Open\"", "A closed door."},
+		},
+		{
+			name:  "missing item",
+			text:  "1. A cat.\n3. A bowl of milk.",
+			count: 3,
+			want:  []string{"A cat.", "", "A bowl of milk."},
+		},
+		{
+			name:  "repeated number inside a description",
+			text:  "1. A recipe card, step\n1. reads: sift the flour.\n2. A mixing bowl.",
+			count: 2,
+			want:  []string{"A recipe card, step\n1. reads: sift the flour.", "A mixing bowl."},
+		},
+		{
+			name:  "numbers out of range",
+			text:  "1. A poster from\n1984: the year of the concert.\n2. A ticket.",
+			count: 2,
+			want:  []string{"A poster from\n1984: the year of the concert.", "A ticket."},
+		},
+		{
+			name:  "descending numbers stay in the item",
+			text:  "2. A boat.\n1. An anchor.",
+			count: 2,
+			want:  []string{"", "A boat.\n1. An anchor."},
+		},
+	}
+
+	for _, test := range tests {
+		if got := splitNumberedList(test.text, test.count); !slices.Equal(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// orientedJPEG encodes a 40x20 JPEG, red on the left and blue on the right, with an EXIF orientation tag
+func orientedJPEG(t *testing.T, orientation uint16) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= 20 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A little-endian TIFF header with a single IFD entry: the orientation as a SHORT
+	tiff := []byte("II*\x00\x08\x00\x00\x00\x01\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, exifOrientationTag)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	data := encoded.Bytes()
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+func TestEXIFOrientationIsApplied(t *testing.T) {
+	data := orientedJPEG(t, 6)
+	if orientation := exifOrientation(data); orientation != 6 {
+		t.Fatalf("read orientation %d, want 6", orientation)
+	}
+
+	img, format, err := decodeImage(data)
+	if err != nil || format != "jpeg" {
+		t.Fatalf("decoding: %s, %v", format, err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 20 || bounds.Dy() != 40 {
+		t.Fatalf("decoded %dx%d, want the sides swapped to 20x40", bounds.Dx(), bounds.Dy())
+	}
+
+	// Turned clockwise, the left of the stored image ends up at the top
+	if r, _, b, _ := img.At(10, 5).RGBA(); r>>8 < 200 || b>>8 > 60 {
+		t.Errorf("top isn't red: r=%d b=%d", r>>8, b>>8)
+	}
+	if r, _, b, _ := img.At(10, 35).RGBA(); b>>8 < 200 || r>>8 > 60 {
+		t.Errorf("bottom isn't blue: r=%d b=%d", r>>8, b>>8)
+	}
+}
+
+func TestDownscaleKeepsUprightAspectRatio(t *testing.T) {
+	useConfig(t)
+
+	downscaled, _, err := downscaleImage(orientedJPEG(t, 6), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(downscaled))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 10 || bounds.Dy() != 20 {
+		t.Errorf("downscaled to %dx%d, want 10x20", bounds.Dx(), bounds.Dy())
+	}
+}
+
+func TestImagesWithoutOrientationAreUntouched(t *testing.T) {
+	img, _, err := decodeImage(orientedJPEG(t, 1))
+	if err != nil || img.Bounds().Dx() != 40 || img.Bounds().Dy() != 20 {
+		t.Errorf("orientation 1 gave %v, %v", img.Bounds(), err)
+	}
+
+	if orientation := exifOrientation(testPNG(t)); orientation != 1 {
+		t.Errorf("PNG has orientation %d", orientation)
+	}
+}
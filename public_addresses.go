@@ -0,0 +1,58 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// errAddressNotAllowed is returned when a URL from an API user leads to an address of the bot's own network
+var errAddressNotAllowed = errors.New("address not allowed")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which net.IP doesn't count as private
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublicIP reports whether ip is an address on the internet rather than a loopback, private,
+// link-local, multicast or unspecified one
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified() && !sharedAddressSpace.Contains(ip)
+}
+
+// publicTransport is the transport for URLs given by API users, the media URLs and job callbacks. It checks
+// the address of every connection it makes, so neither a redirect nor a hostname that resolves to an internal
+// address can reach services on the bot's network. [api] allow_private_urls turns the check off.
+var publicTransport http.RoundTripper = newPublicTransport()
+
+func newPublicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if config.API.AllowPrivateURLs {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("%w: %s", errAddressNotAllowed, host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be the address checked instead of the URL's
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// errUnusableAltText is returned when the model gave an unusable alt-text twice in a row
+var errUnusableAltText = errors.New("the model gave no usable alt-text")
+
+// altTextProblem says why an alt-text is unusable: empty, shorter than [llm] min_alt_text_chars,
+// the prompt echoed back or a refusal matching the refusal_patterns of its language or English.
+// It returns "" for alt-text that looks fine.
+func altTextProblem(altText string, prompt string, lang string) string {
+	text := strings.TrimSpace(altText)
+	if text == "" {
+		return "empty"
+	}
+	if min := config.LLM.MinAltTextChars; min > 0 && utf8.RuneCountInString(text) < min {
+		return "too short"
+	}
+	if prompt != "" && strings.EqualFold(text, strings.TrimSpace(prompt)) {
+		return "echoed the prompt"
+	}
+	for _, l := range []string{lang, "en"} {
+		for _, re := range refusalPatterns[l] {
+			if re.MatchString(text) {
+				return "refusal"
+			}
+		}
+	}
+	return ""
+}
+
+// usableAltText checks a post-processed alt-text from a request for several media, whose unusable
+// descriptions are generated again on their own (and guarded there) instead of being regenerated here
+func usableAltText(altText string, lang string) bool {
+	if !config.LLM.QualityGuard {
+		return true
+	}
+
+	if problem := altTextProblem(altText, "", lang); problem != "" {
+		logWarnf("Rejected alt-text (%s), describing the media on its own", problem)
+		LogEvent("alt_text_rejected")
+		return false
+	}
+	return true
+}
+
+// guardAltText checks a post-processed alt-text and generates it once more when it's unusable.
+// It returns errUnusableAltText if the second attempt is unusable too. Without [llm] quality_guard
+// the alt-text is returned as it is.
+func guardAltText(altText string, prompt string, lang string, regenerate func() (string, error)) (string, error) {
+	if !config.LLM.QualityGuard {
+		return altText, nil
+	}
+
+	problem := altTextProblem(altText, prompt, lang)
+	if problem == "" {
+		return altText, nil
+	}
+	logWarnf("Rejected alt-text (%s), generating it again", problem)
+	LogEvent("alt_text_rejected")
+
+	altText, err := regenerate()
+	if err != nil {
+		return "", err
+	}
+	altText = postProcessAltText(altText, lang)
+
+	if problem = altTextProblem(altText, prompt, lang); problem != "" {
+		logWarnf("Rejected alt-text again (%s), giving up", problem)
+		return "", errUnusableAltText
+	}
+	return altText, nil
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+const testRefusal = "I'm unable to describe this image."
+
+func TestAltTextProblem(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	config.LLM.MinAltTextChars = 10
+
+	tests := map[string]string{
+		"":      "empty",
+		"A cat": "too short",
+		"Describe this image for a blind person.": "echoed the prompt",
+		testRefusal:                           "refusal",
+		"A tabby cat asleep on a windowsill.": "",
+	}
+	for altText, want := range tests {
+		if got := altTextProblem(altText, "Describe this image for a blind person.", "de"); got != want {
+			t.Errorf("altTextProblem(%q) = %q, want %q", altText, got, want)
+		}
+	}
+}
+
+func TestGuardRegeneratesRefusal(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	config.LLM.QualityGuard = true
+
+	regenerations := 0
+	altText, err := guardAltText(testRefusal, "prompt", "en", func() (string, error) {
+		regenerations++
+		return "A tabby cat asleep on a windowsill.", nil
+	})
+	if err != nil || altText != "A tabby cat asleep on a windowsill." || regenerations != 1 {
+		t.Errorf("got %q, %v after %d regenerations", altText, err, regenerations)
+	}
+
+	_, err = guardAltText(testRefusal, "prompt", "en", func() (string, error) {
+		return testRefusal, nil
+	})
+	if !errors.Is(err, errUnusableAltText) {
+		t.Errorf("err = %v, want errUnusableAltText", err)
+	}
+}
+
+func TestGuardCoversAPIRequests(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	config.LLM.QualityGuard = true
+	config.LLM.Provider = "gemini"
+
+	provider := newStubProvider(stubResponse{text: testRefusal}, stubResponse{text: "A person waves at the camera."})
+	useProvider(t, provider)
+
+	request := APIRequest{ID: "test", MediaType: "video", Format: "mp4", Language: "en", ResultCh: make(chan APIResult, 1)}
+	(&APIServer{}).processRequest(request)
+
+	result := <-request.ResultCh
+	if result.Error != nil || result.AltText != "A person waves at the camera." {
+		t.Errorf("got %q, %v", result.AltText, result.Error)
+	}
+	if provider.calls() != 2 {
+		t.Errorf("calls = %d, want 2", provider.calls())
+	}
+}
+
+func TestUsableAltTextRejectsRefusalFromCombinedRequests(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+
+	if !usableAltText(testRefusal, "en") {
+		t.Error("refusal rejected without quality_guard")
+	}
+	config.LLM.QualityGuard = true
+	if usableAltText(testRefusal, "en") {
+		t.Error("refusal accepted")
+	}
+	if !usableAltText("A red barn.", "en") {
+		t.Error("description rejected")
+	}
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// resolveMediaStatus returns the status a mention under status is about: the boosted post of a boost,
+// or the quoted post of a quote post that has no media of its own. Other statuses are returned as they are.
+func resolveMediaStatus(c *mastodon.Client, status *mastodon.Status) *mastodon.Status {
+	if status.Reblog != nil {
+		logDebugf("Status %s is a boost, describing the boosted status %s", status.ID, status.Reblog.ID)
+		return status.Reblog
+	}
+	if len(status.MediaAttachments) > 0 {
+		return status
+	}
+
+	quoted, err := fetchQuotedStatus(c, status.ID)
+	if err != nil {
+		logWarnf("Error checking whether status %s quotes another: %v", status.ID, err)
+		return status
+	}
+	if quoted == nil || len(quoted.MediaAttachments) == 0 {
+		return status
+	}
+
+	logInfof("Status %s quotes status %s, describing its media", status.ID, quoted.ID)
+	return quoted
+}
+
+// fetchQuotedStatus fetches the status quoted by a status, or nil if it doesn't quote one.
+// The go-mastodon client doesn't know about quotes, so the status is read from the API directly.
+// Both Mastodon's quote object and the quoted status itself, as sent by Fedibird and others, are understood.
+func fetchQuotedStatus(c *mastodon.Client, id mastodon.ID) (*mastodon.Status, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(c.Config.Server, "/")+"/api/v1/statuses/"+url.PathEscape(string(id)), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Config.AccessToken)
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Quote json.RawMessage `json:"quote"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if len(body.Quote) == 0 || string(body.Quote) == "null" {
+		return nil, nil
+	}
+
+	// Mastodon wraps the quoted status with the state of the quote, only accepted quotes show it
+	var quote struct {
+		State        string           `json:"state"`
+		QuotedStatus *mastodon.Status `json:"quoted_status"`
+	}
+	if err := json.Unmarshal(body.Quote, &quote); err == nil && quote.State != "" {
+		if quote.State != "accepted" {
+			return nil, nil
+		}
+		return quote.QuotedStatus, nil
+	}
+
+	var quoted mastodon.Status
+	if err := json.Unmarshal(body.Quote, &quoted); err != nil {
+		return nil, err
+	}
+	if quoted.ID == "" {
+		return nil, nil
+	}
+	return &quoted, nil
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestResolveMediaStatusOfBoost(t *testing.T) {
+	var requests atomic.Int32
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	})
+
+	boosted := &mastodon.Status{ID: "1", MediaAttachments: []mastodon.Attachment{{ID: "m1", Type: "image"}}}
+	wrapper := &mastodon.Status{ID: "2", Reblog: boosted}
+	if got := resolveMediaStatus(c, wrapper); got != boosted {
+		t.Errorf("got status %s, want the boosted 1", got.ID)
+	}
+
+	// A post with media of its own is described itself, without looking for a quote
+	own := &mastodon.Status{ID: "3", MediaAttachments: []mastodon.Attachment{{ID: "m3", Type: "image"}}}
+	if got := resolveMediaStatus(c, own); got != own {
+		t.Errorf("got status %s, want 3 itself", got.ID)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("made %d requests", n)
+	}
+}
+
+func TestResolveMediaStatusOfQuote(t *testing.T) {
+	tests := []struct {
+		name  string
+		quote string
+		want  mastodon.ID
+		media int
+	}{
+		{"mastodon accepted", `{"state":"accepted","quoted_status":{"id":"1","media_attachments":[{"id":"m1","type":"image"},{"id":"m2","type":"image"}]}}`, "1", 2},
+		{"fedibird", `{"id":"1","media_attachments":[{"id":"m1","type":"image"}]}`, "1", 1},
+		// The wrapper is kept when there's nothing to describe in the quoted post
+		{"mastodon pending", `{"state":"pending","quoted_status":null}`, "2", 0},
+		{"quote without media", `{"state":"accepted","quoted_status":{"id":"1","media_attachments":[]}}`, "2", 0},
+		{"no quote", `null`, "2", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/statuses/2" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, `{"id":"2","content":"<p>look at this</p>","quote":`+test.quote+`}`)
+			})
+
+			got := resolveMediaStatus(c, &mastodon.Status{ID: "2"})
+			if got.ID != test.want || len(got.MediaAttachments) != test.media {
+				t.Errorf("got status %s with %d media, want %s with %d", got.ID, len(got.MediaAttachments), test.want, test.media)
+			}
+		})
+	}
+}
+
+func TestResolveMediaStatusKeepsWrapperOnError(t *testing.T) {
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	wrapper := &mastodon.Status{ID: "2"}
+	if got := resolveMediaStatus(c, wrapper); got != wrapper {
+		t.Errorf("got status %s, want the wrapper", got.ID)
+	}
+	if _, err := fetchQuotedStatus(c, "2"); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("got %v, want the status code", err)
+	}
+}
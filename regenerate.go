@@ -0,0 +1,105 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"github.com/mattn/go-mastodon"
+)
+
+// regenerateTemperatureBoost is how much the sampling temperature is raised for another attempt at a caption
+const regenerateTemperatureBoost = 0.3
+
+// isRegenerateRequest checks if a reply asks for another attempt, like "redo" or "again".
+// The patterns of the reply's language are tried along with the English ones.
+func isRegenerateRequest(status *mastodon.Status) bool {
+	content := stripHTMLTags(status.Content)
+
+	for _, lang := range []string{status.Language, "en"} {
+		for _, re := range regeneratePatterns[lang] {
+			if re.MatchString(content) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// originalForReply looks up the post that one of the bot's replies described.
+// Replies are remembered for an hour, see cleanupOldEntries.
+func originalForReply(replyID mastodon.ID) (mastodon.ID, bool) {
+	mapMutex.Lock()
+	defer mapMutex.Unlock()
+
+	origin, ok := replyOrigins[replyID]
+	return origin.OriginalID, ok
+}
+
+// handleRegenerateReply describes the media of a post again when the person a caption was written
+// for replies to it with a keyword like "redo". It returns false if the status isn't such a request.
+func handleRegenerateReply(c *mastodon.Client, status *mastodon.Status, botReply *mastodon.Status) bool {
+	if botReply.Account.ID != botAcct.ID || !isRegenerateRequest(status) {
+		return false
+	}
+
+	if isDNI(&status.Account) {
+		return true
+	}
+
+	originalID, ok := originalForReply(botReply.ID)
+	if !ok {
+		logInfof("No described post found for regeneration request from %s", status.Account.Acct)
+		return true
+	}
+
+	original, err := c.GetStatus(ctx, originalID)
+	if err != nil {
+		logErrorf("Error fetching described post for regeneration: %v", err)
+		return true
+	}
+
+	// Only the poster or whoever the caption was written for can ask for another one
+	allowed := original.Account.ID == status.Account.ID
+	for _, mention := range botReply.Mentions {
+		if mention.ID == status.Account.ID {
+			allowed = true
+		}
+	}
+	if !allowed {
+		logInfof("Ignoring regeneration request from %s, who the caption wasn't written for", status.Account.Acct)
+		return true
+	}
+
+	userID := string(status.Account.ID)
+	if original.Account.ID == status.Account.ID && !HasUserConsent(userID) {
+		_, err := RequestGDPRConsent(c, userID, status.Account.Acct, status.Language, status.ID, false)
+		if err != nil {
+			logErrorf("Error requesting GDPR consent: %v", err)
+		}
+		return true
+	}
+
+	// Skip if this post is already being described
+	processingIDsMu.Lock()
+	if processingIDs[originalID] {
+		processingIDsMu.Unlock()
+		logInfof("Already processing status %s, skipping regeneration request", originalID)
+		return true
+	}
+	processingIDs[originalID] = true
+	processingIDsMu.Unlock()
+	defer func() {
+		processingIDsMu.Lock()
+		delete(processingIDs, originalID)
+		processingIDsMu.Unlock()
+	}()
+
+	logInfof("Regenerating alt-text for status %s at the request of %s", originalID, status.Account.Acct)
+	LogEvent("alt_text_regenerated")
+
+	// The rate limit is applied per attachment like for any other request
+	generateAndPostAltText(c, original, status.ID, altTextOptions{Regenerate: true})
+	return true
+}
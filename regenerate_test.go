@@ -0,0 +1,136 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// useReplyMaps starts the test with no remembered replies
+func useReplyMaps(t *testing.T) {
+	t.Helper()
+	mapMutex.Lock()
+	previousReplies, previousOrigins := replyMap, replyOrigins
+	replyMap, replyOrigins = make(map[mastodon.ID]ReplyInfo), make(map[mastodon.ID]ReplyOrigin)
+	mapMutex.Unlock()
+
+	t.Cleanup(func() {
+		mapMutex.Lock()
+		replyMap, replyOrigins = previousReplies, previousOrigins
+		mapMutex.Unlock()
+	})
+}
+
+// useBotAccount makes account the bot's own for the rest of the test
+func useBotAccount(t *testing.T, account mastodon.Account) {
+	t.Helper()
+	previous := botAcct
+	botAcct = account
+	t.Cleanup(func() { botAcct = previous })
+}
+
+func TestIsRegenerateRequest(t *testing.T) {
+	loadTestLocalizations(t)
+
+	tests := []struct {
+		lang    string
+		content string
+		want    bool
+	}{
+		{"en", "<p>@altbot redo</p>", true},
+		{"en", "<p>Can you try again please?</p>", true},
+		{"en", "<p>AGAIN</p>", true},
+		{"de", "<p>Bitte nochmal!</p>", true},
+		// English keywords work whatever the language of the post
+		{"de", "<p>redo</p>", true},
+		{"en", "<p>Thanks, that's a great description</p>", false},
+		{"en", "<p>I redone my garden</p>", false},
+		{"de", "<p>Danke schön</p>", false},
+	}
+	for _, test := range tests {
+		if got := isRegenerateRequest(&mastodon.Status{Language: test.lang, Content: test.content}); got != test.want {
+			t.Errorf("%s %q: got %v, want %v", test.lang, test.content, got, test.want)
+		}
+	}
+}
+
+func TestOriginalForReply(t *testing.T) {
+	useReplyMaps(t)
+	replyOrigins["reply"] = ReplyOrigin{OriginalID: "original", Timestamp: time.Now()}
+
+	if originalID, ok := originalForReply("reply"); !ok || originalID != "original" {
+		t.Errorf("got %q, %v", originalID, ok)
+	}
+	if _, ok := originalForReply("unknown"); ok {
+		t.Error("found an original for a reply the bot didn't make")
+	}
+}
+
+func TestRegenerateReplyDescribesOriginalAgain(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	useBotState(t)
+	useReplyMaps(t)
+	useBotAccount(t, mastodon.Account{ID: "1", Acct: "altbot"})
+	config.ImageProcessing.MaxSizeMB = 10
+	provider := newStubProvider(stubResponse{text: "A second look at the bicycle."})
+	useProvider(t, provider)
+
+	media := mediaServer(t, "image/png", testPNG(t))
+	var mu sync.Mutex
+	var posted []string
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/statuses/100":
+			fmt.Fprintf(w, `{"id":"100","visibility":"public","language":"en","account":{"id":"20","acct":"bob"},`+
+				`"media_attachments":[{"id":"m1","type":"image","url":%q}]}`, media.URL+"/bicycle.png")
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/statuses/300":
+			io.WriteString(w, `{"id":"300","visibility":"public","language":"en","account":{"id":"10","acct":"alice"}}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/statuses":
+			r.ParseForm()
+			mu.Lock()
+			posted = append(posted, r.FormValue("in_reply_to_id")+": "+r.FormValue("status"))
+			mu.Unlock()
+			io.WriteString(w, `{"id":"400"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	// Alice asked for the first caption of Bob's post, so she may ask for another
+	replyOrigins["200"] = ReplyOrigin{OriginalID: "100", Timestamp: time.Now()}
+	botReply := &mastodon.Status{ID: "200", Account: botAcct, Mentions: []mastodon.Mention{{ID: "10", Acct: "alice"}}}
+	request := &mastodon.Status{ID: "300", Language: "en", Content: "<p>@altbot redo</p>", Account: mastodon.Account{ID: "10", Acct: "alice"}}
+
+	if !handleRegenerateReply(c, request, botReply) {
+		t.Fatal("regeneration request wasn't handled")
+	}
+	if provider.calls() != 1 {
+		t.Fatalf("provider called %d times, want once", provider.calls())
+	}
+	if len(posted) != 1 || posted[0] != "300: @alice A second look at the bicycle." {
+		t.Errorf("posted %q", posted)
+	}
+
+	// Someone the caption wasn't written for can't ask for another one
+	stranger := &mastodon.Status{ID: "301", Language: "en", Content: "<p>redo</p>", Account: mastodon.Account{ID: "30", Acct: "eve"}}
+	if !handleRegenerateReply(c, stranger, botReply) || provider.calls() != 1 {
+		t.Errorf("stranger's request described the post again")
+	}
+
+	// Replies that aren't regeneration requests are left to the other handlers
+	if handleRegenerateReply(c, &mastodon.Status{Content: "<p>thank you!</p>", Account: request.Account}, botReply) {
+		t.Error("a thank-you was taken for a regeneration request")
+	}
+}
@@ -92,6 +92,14 @@ func runSetupWizard(filePath string) {
 			gpuWattsStr := fmt.Sprintf("%.1f", config.PowerMetrics.GPUWatts)
 			gpuWattsInput := promptString(Yellow+"GPU Power Consumption (watts):"+Reset, gpuWattsStr)
 			config.PowerMetrics.GPUWatts = parseFloat(gpuWattsInput, config.PowerMetrics.GPUWatts)
+
+			cpuWattsStr := fmt.Sprintf("%.1f", config.PowerMetrics.CPUWatts)
+			cpuWattsInput := promptString(Yellow+"CPU Power Consumption while generating (watts):"+Reset, cpuWattsStr)
+			config.PowerMetrics.CPUWatts = parseFloat(cpuWattsInput, config.PowerMetrics.CPUWatts)
+
+			idleWattsStr := fmt.Sprintf("%.1f", config.PowerMetrics.IdleWatts)
+			idleWattsInput := promptString(Yellow+"Idle Power Consumption of the machine (watts):"+Reset, idleWattsStr)
+			config.PowerMetrics.IdleWatts = parseFloat(idleWattsInput, config.PowerMetrics.IdleWatts)
 		}
 	}
 
@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// botPIDFile holds the process ID of the running bot, so admin commands that change its stores can
+// tell they'd be overwritten from memory
+const botPIDFile = "altbot.pid"
+
+// shutdownTimeout is how long a shutdown waits for alt-text generations that are in progress
+const shutdownTimeout = 60 * time.Second
+
+// handleShutdownSignals stops the stream on SIGINT or SIGTERM, so no new mentions come in and main can
+// drain the work in progress. A second signal exits right away.
+func handleShutdownSignals(stopStream context.CancelFunc) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		logInfof("Received %v, finishing in-flight requests before shutting down (send it again to exit now)", sig)
+		stopStream()
+
+		<-signals
+		logWarnf("Exiting without waiting for in-flight requests")
+		os.Exit(1)
+	}()
+}
+
+// drainInFlight waits up to timeout for the generations in progress, returning false if some didn't finish
+func drainInFlight(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// shutdown waits for in-flight work, cancels the root context and saves the rate limiter.
+// Metrics are flushed and the provider closed by main's deferred calls once it returns.
+func shutdown(cancel context.CancelFunc) {
+	if drainInFlight(shutdownTimeout) {
+		logInfof("All in-flight requests finished")
+	} else {
+		logWarnf("Timed out after %v waiting for in-flight requests, shutting down anyway", shutdownTimeout)
+	}
+	cancel()
+
+	if config.RateLimit.Enabled && rateLimiter != nil {
+		if err := rateLimiter.SaveToFile("ratelimiter.json"); err != nil {
+			logErrorf("Error saving rate limiter state: %v", err)
+		}
+	}
+}
+
+// writeBotPIDFile records the process ID of the bot in botPIDFile
+func writeBotPIDFile() error {
+	return os.WriteFile(botPIDFile, []byte(strconv.Itoa(os.Getpid())), 0644)
+}
+
+// removeBotPIDFile removes botPIDFile on shutdown
+func removeBotPIDFile() {
+	if err := os.Remove(botPIDFile); err != nil && !os.IsNotExist(err) {
+		logWarnf("Error removing %s: %v", botPIDFile, err)
+	}
+}
+
+// runningBotPID returns the process ID of the running bot, if there is one. A PID file left behind
+// by a bot that was killed is ignored.
+func runningBotPID() (int, bool) {
+	data, err := os.ReadFile(botPIDFile)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid == os.Getpid() {
+		return 0, false
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return 0, false
+	}
+	return pid, process.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsInFlightAndSavesState(t *testing.T) {
+	useConfig(t)
+	useBotState(t)
+	config.RateLimit.Enabled = true
+	rateLimiter.Requests["user"] = []time.Time{time.Now()}
+
+	var finished atomic.Bool
+	inFlight.Add(1)
+	go func() {
+		defer inFlight.Done()
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+	}()
+
+	root, cancel := context.WithCancel(context.Background())
+	shutdown(cancel)
+	if !finished.Load() {
+		t.Error("shutdown returned before the generation in progress finished")
+	}
+	if root.Err() == nil {
+		t.Error("root context wasn't cancelled")
+	}
+
+	saved := NewRateLimiter()
+	if err := saved.LoadFromFile("ratelimiter.json"); err != nil || len(saved.Requests["user"]) != 1 {
+		t.Errorf("saved rate limiter has %v (%v)", saved.Requests, err)
+	}
+}
+
+func TestDrainInFlightTimesOut(t *testing.T) {
+	inFlight.Add(1)
+	defer inFlight.Done()
+
+	start := time.Now()
+	if drainInFlight(50 * time.Millisecond) {
+		t.Error("drain reported success with a generation still running")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("drain took %v", elapsed)
+	}
+}
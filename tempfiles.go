@@ -0,0 +1,65 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// staleTempAge is how old a temporary file has to be before the startup sweep removes it.
+// No generation takes this long, so anything older was left behind by a killed process.
+const staleTempAge = time.Hour
+
+// altbotTempDir is the subdirectory of the system temp directory all temporary files go in,
+// so the sweep only ever removes files Altbot created
+func altbotTempDir() string {
+	return filepath.Join(os.TempDir(), "altbot")
+}
+
+// createTempFile is os.CreateTemp in Altbot's temp directory
+func createTempFile(pattern string) (*os.File, error) {
+	if err := os.MkdirAll(altbotTempDir(), 0700); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(altbotTempDir(), pattern)
+}
+
+// createTempDir is os.MkdirTemp in Altbot's temp directory
+func createTempDir(pattern string) (string, error) {
+	if err := os.MkdirAll(altbotTempDir(), 0700); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(altbotTempDir(), pattern)
+}
+
+// sweepStaleTempFiles removes temporary files and directories older than staleTempAge
+func sweepStaleTempFiles() {
+	entries, err := os.ReadDir(altbotTempDir())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logErrorf("Error reading temp directory: %v", err)
+		}
+		return
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < staleTempAge {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(altbotTempDir(), entry.Name())); err != nil {
+			logErrorf("Error removing stale temp file %s: %v", entry.Name(), err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		logInfof("Removed %d stale temporary files", removed)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSweepRemovesStaleTempFiles(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	stale, err := createTempFile("video-*.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.Close()
+	staleDir, err := createTempDir("videoframes-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(staleDir, "frame_001.jpg"), []byte("frame"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := createTempFile("audio-*.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh.Close()
+
+	if !strings.HasPrefix(stale.Name(), altbotTempDir()) {
+		t.Fatalf("temp file %s is outside %s", stale.Name(), altbotTempDir())
+	}
+
+	old := time.Now().Add(-2 * staleTempAge)
+	for _, path := range []string{stale.Name(), staleDir} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Files outside Altbot's directory are never touched, however old
+	other := filepath.Join(os.TempDir(), "other-program.tmp")
+	if err := os.WriteFile(other, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(other, old, old)
+
+	sweepStaleTempFiles()
+
+	for _, path := range []string{stale.Name(), staleDir} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("stale %s wasn't removed", filepath.Base(path))
+		}
+	}
+	for _, path := range []string{fresh.Name(), other} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s was removed: %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestSweepWithoutTempDirectory(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	sweepStaleTempFiles()
+	if _, err := os.Stat(altbotTempDir()); !os.IsNotExist(err) {
+		t.Errorf("sweep created the temp directory: %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// ThreadLimiter caps how often the bot replies within a single thread, keyed by the thread's root status
+type ThreadLimiter struct {
+	mu       sync.Mutex
+	replies  map[mastodon.ID][]time.Time
+	notified map[mastodon.ID]time.Time
+	roots    map[mastodon.ID]threadRoot // Root of the statuses seen, so a thread is only fetched once
+}
+
+// threadRoot is the cached root of a status in a thread
+type threadRoot struct {
+	id   mastodon.ID
+	seen time.Time
+}
+
+var threadLimiter = &ThreadLimiter{
+	replies:  make(map[mastodon.ID][]time.Time),
+	notified: make(map[mastodon.ID]time.Time),
+	roots:    make(map[mastodon.ID]threadRoot),
+}
+
+// Allow reports whether the thread is still under the limit. When the limit is reached, notify is
+// true only for the first refused request in the window, so the bot explains itself once.
+// Replies are only counted once they are posted, with Record.
+func (tl *ThreadLimiter) Allow(rootID mastodon.ID, limit int, window time.Duration) (allowed bool, notify bool) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	now := time.Now()
+
+	// Drop replies that are outside the window
+	recent := tl.replies[rootID][:0]
+	for _, t := range tl.replies[rootID] {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	tl.replies[rootID] = recent
+
+	if len(recent) < limit {
+		return true, false
+	}
+
+	if last, ok := tl.notified[rootID]; ok && now.Sub(last) < window {
+		return false, false
+	}
+	tl.notified[rootID] = now
+	return false, true
+}
+
+// Record counts a reply the bot posted in the thread
+func (tl *ThreadLimiter) Record(rootID mastodon.ID) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	tl.replies[rootID] = append(tl.replies[rootID], time.Now())
+	delete(tl.notified, rootID)
+}
+
+// cachedRoot returns the root of a status whose thread was fetched before
+func (tl *ThreadLimiter) cachedRoot(statusID mastodon.ID) (mastodon.ID, bool) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	root, ok := tl.roots[statusID]
+	if !ok {
+		return "", false
+	}
+	root.seen = time.Now()
+	tl.roots[statusID] = root
+	return root.id, true
+}
+
+// rememberRoot caches rootID as the root of the given statuses
+func (tl *ThreadLimiter) rememberRoot(rootID mastodon.ID, statusIDs ...mastodon.ID) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range statusIDs {
+		tl.roots[id] = threadRoot{id: rootID, seen: now}
+	}
+}
+
+// Cleanup removes threads without recent replies
+func (tl *ThreadLimiter) Cleanup(window time.Duration) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	now := time.Now()
+	for rootID, replies := range tl.replies {
+		if len(replies) == 0 || now.Sub(replies[len(replies)-1]) >= window {
+			delete(tl.replies, rootID)
+		}
+	}
+	for rootID, last := range tl.notified {
+		if now.Sub(last) >= window {
+			delete(tl.notified, rootID)
+		}
+	}
+	for statusID, root := range tl.roots {
+		if now.Sub(root.seen) >= window {
+			delete(tl.roots, statusID)
+		}
+	}
+}
+
+// threadWindow returns the period over which thread replies are counted (default one hour)
+func threadWindow() time.Duration {
+	if config.RateLimit.ThreadWindowMinutes > 0 {
+		return time.Duration(config.RateLimit.ThreadWindowMinutes) * time.Minute
+	}
+	return time.Hour
+}
+
+// threadRootID returns the ID of the first post of the thread a status belongs to
+func threadRootID(c *mastodon.Client, status *mastodon.Status) mastodon.ID {
+	if status.InReplyToID == nil {
+		return status.ID
+	}
+
+	if rootID, ok := threadLimiter.cachedRoot(status.ID); ok {
+		return rootID
+	}
+
+	thread, err := c.GetStatusContext(ctx, status.ID)
+	if err != nil || len(thread.Ancestors) == 0 {
+		if err != nil {
+			logErrorf("Error fetching thread of status %s: %v", status.ID, err)
+		}
+		return status.ID
+	}
+
+	// The ancestors share the root, so mentions further up the thread don't fetch it again
+	rootID := thread.Ancestors[0].ID
+	ids := []mastodon.ID{status.ID}
+	for _, ancestor := range thread.Ancestors[1:] {
+		ids = append(ids, ancestor.ID)
+	}
+	threadLimiter.rememberRoot(rootID, ids...)
+	return rootID
+}
+
+// recordThreadReply counts a reply the bot posted about status against its thread's limit
+func recordThreadReply(c *mastodon.Client, status *mastodon.Status) {
+	if config.RateLimit.ThreadReplyLimit <= 0 {
+		return
+	}
+	threadLimiter.Record(threadRootID(c, status))
+}
+
+// checkThreadReplyLimit reports whether the bot may still reply in this thread, posting a single
+// note to the requester the first time the thread's reply limit is hit
+func checkThreadReplyLimit(c *mastodon.Client, status *mastodon.Status, notification *mastodon.Notification) bool {
+	limit := config.RateLimit.ThreadReplyLimit
+	if limit <= 0 {
+		return true
+	}
+
+	rootID := threadRootID(c, status)
+	allowed, notify := threadLimiter.Allow(rootID, limit, threadWindow())
+	if allowed {
+		return true
+	}
+
+	logInfof("Thread reply limit reached for thread %s, ignoring request from %s", rootID, notification.Account.Acct)
+	if !notify {
+		return false
+	}
+
+	message := fmt.Sprintf("@%s %s", notification.Account.Acct, getLocalizedString(notification.Status.Language, "threadReplyLimitReached", "response"))
+
+	visibility := "unlisted" // Don't clutter followers' timelines with the note
+	if notification.Status.Visibility == "private" || notification.Status.Visibility == "direct" {
+		visibility = "direct"
+	}
+
+	// Dev mode: print to terminal instead of posting
+	if devMode {
+		fmt.Printf("\n%s[DEV MODE - Would post thread limit note]%s\n", Yellow, Reset)
+		fmt.Printf("  To: @%s\n", notification.Account.Acct)
+		fmt.Printf("  Visibility: %s\n", visibility)
+		fmt.Printf("  Content: %s\n", message)
+		fmt.Println("---")
+		return false
+	}
+
+	if !allowReply(c) {
+		return false
+	}
+
+	_, err := c.PostStatus(ctx, &mastodon.Toot{
+		Status:      message,
+		InReplyToID: notification.Status.ID,
+		Visibility:  visibility,
+		Language:    notification.Status.Language,
+	})
+	if err != nil {
+		logErrorf("Error posting thread limit note: %v", err)
+	}
+
+	return false
+}
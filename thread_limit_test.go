@@ -0,0 +1,88 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// useMastodonServer returns a client for a fake instance served by handler
+func useMastodonServer(t *testing.T, handler http.HandlerFunc) *mastodon.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	previous := ctx
+	ctx = context.Background()
+	t.Cleanup(func() { ctx = previous })
+
+	return mastodon.NewClient(&mastodon.Config{Server: server.URL, AccessToken: "token"})
+}
+
+func TestThreadLimiterCountsOnlyPostedReplies(t *testing.T) {
+	tl := &ThreadLimiter{replies: map[mastodon.ID][]time.Time{}, notified: map[mastodon.ID]time.Time{}, roots: map[mastodon.ID]threadRoot{}}
+
+	// Requests that didn't lead to a reply don't use up the thread's limit
+	for i := 0; i < 5; i++ {
+		if allowed, _ := tl.Allow("root", 2, time.Hour); !allowed {
+			t.Fatalf("request %d refused before any reply was posted", i)
+		}
+	}
+
+	tl.Record("root")
+	tl.Record("root")
+	if allowed, notify := tl.Allow("root", 2, time.Hour); allowed || !notify {
+		t.Errorf("got allowed=%v notify=%v after the limit, want a single note", allowed, notify)
+	}
+	if allowed, notify := tl.Allow("root", 2, time.Hour); allowed || notify {
+		t.Errorf("got allowed=%v notify=%v, want no second note", allowed, notify)
+	}
+	if allowed, _ := tl.Allow("other", 2, time.Hour); !allowed {
+		t.Error("another thread was limited")
+	}
+}
+
+func TestThreadRootIsFetchedOnce(t *testing.T) {
+	previous := threadLimiter
+	threadLimiter = &ThreadLimiter{replies: map[mastodon.ID][]time.Time{}, notified: map[mastodon.ID]time.Time{}, roots: map[mastodon.ID]threadRoot{}}
+	t.Cleanup(func() { threadLimiter = previous })
+
+	var fetches atomic.Int32
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/statuses/3/context" {
+			http.NotFound(w, r)
+			return
+		}
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(mastodon.Context{Ancestors: []*mastodon.Status{{ID: "1"}, {ID: "2"}}})
+	})
+
+	status := &mastodon.Status{ID: "3", InReplyToID: "2"}
+	for i := 0; i < 3; i++ {
+		if rootID := threadRootID(c, status); rootID != "1" {
+			t.Fatalf("root = %s, want 1", rootID)
+		}
+	}
+	if rootID := threadRootID(c, &mastodon.Status{ID: "2", InReplyToID: "1"}); rootID != "1" {
+		t.Errorf("ancestor's root = %s, want 1", rootID)
+	}
+	if fetches.Load() != 1 {
+		t.Errorf("thread fetched %d times, want once", fetches.Load())
+	}
+
+	threadLimiter.Cleanup(0)
+	if _, ok := threadLimiter.cachedRoot("3"); ok {
+		t.Error("cleanup kept a root past the window")
+	}
+}
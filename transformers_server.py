@@ -17,6 +17,7 @@ app = Flask(__name__)
 model = None
 text_tokenizer = None
 visual_tokenizer = None
+transcriber = None
 
 
 def parse_args():
@@ -26,6 +27,7 @@ def parse_args():
     parser.add_argument("--device", type=str, default="cuda")
     parser.add_argument("--max-memory", type=float, default=0.9)
     parser.add_argument("--torch-dtype", type=str, default="bfloat16")
+    parser.add_argument("--whisper-model", type=str, default="")
     return parser.parse_args()
 
 
@@ -52,9 +54,45 @@ def load_model(args):
     logger.info("Model loaded successfully!")
 
 
+def load_transcriber(args):
+    global transcriber
+    if not args.whisper_model:
+        return
+    from transformers import pipeline
+
+    logger.info(f"Loading speech recognition model {args.whisper_model}...")
+    transcriber = pipeline(
+        "automatic-speech-recognition",
+        model=args.whisper_model,
+        device=args.device,
+        chunk_length_s=30,
+    )
+    logger.info("Speech recognition model loaded successfully!")
+
+
 @app.route("/health", methods=["GET"])
 def health():
-    return jsonify({"status": "healthy"}), 200
+    return jsonify({"status": "healthy", "audio": transcriber is not None}), 200
+
+
+@app.route("/v1/audio/transcriptions", methods=["POST"])
+def audio_transcriptions():
+    logger.info("Received request to /v1/audio/transcriptions")
+    if transcriber is None:
+        return jsonify({"error": "No speech recognition model loaded"}), 501
+    try:
+        data = request.json
+        audio = base64.b64decode(data.get("audio", ""))
+        if not audio:
+            return jsonify({"error": "No audio provided"}), 400
+
+        # The pipeline decodes the audio with ffmpeg
+        result = transcriber(audio)
+        return jsonify({"text": result["text"].strip()})
+
+    except Exception as e:
+        logger.error(f"Error transcribing audio: {str(e)}")
+        return jsonify({"error": str(e)}), 500
 
 
 @app.route("/v1/chat/completions", methods=["POST"])
@@ -89,17 +127,19 @@ def chat_completions():
 
                 logger.info(f"Received {len(images)} pre-extracted video frames")
 
-        if not prompt or not images:
-            return jsonify({"error": "Missing prompt or media"}), 400
+        if not prompt:
+            return jsonify({"error": "Missing prompt"}), 400
 
-        # Prepare query based on number of images
-        if media_type == "video" or len(images) > 1:
+        # Prepare query based on number of images, text-only prompts (translations, transcripts) have none
+        if not images:
+            query = prompt
+        elif media_type == "video" or len(images) > 1:
             query = "\n".join(["<image>"] * len(images)) + "\n" + prompt
         else:
             query = f"<image>\n{prompt}"
 
         prompt, input_ids, pixel_values = model.preprocess_inputs(
-            query, images, max_partition=9 if media_type == "image" else 1
+            query, images or None, max_partition=9 if media_type == "image" else 1
         )
 
         attention_mask = torch.ne(input_ids, text_tokenizer.pad_token_id)
@@ -112,13 +152,16 @@ def chat_completions():
             )
         pixel_values = [pixel_values]
 
+        # Decoding is greedy unless a temperature is asked for, e.g. to get another take on a caption
+        temperature = data.get("temperature")
+
         with torch.inference_mode():
             gen_kwargs = dict(
                 max_new_tokens=1024,
-                do_sample=False,
+                do_sample=bool(temperature),
                 top_p=None,
                 top_k=None,
-                temperature=None,
+                temperature=temperature or None,
                 repetition_penalty=None,
                 eos_token_id=model.generation_config.eos_token_id,
                 pad_token_id=text_tokenizer.pad_token_id,
@@ -142,5 +185,6 @@ def chat_completions():
 if __name__ == "__main__":
     args = parse_args()
     load_model(args)
+    load_transcriber(args)
     logger.info(f"Starting server on port {args.port}")
     app.run(host="0.0.0.0", port=args.port)
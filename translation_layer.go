@@ -6,32 +6,29 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"os/exec"
 	"strings"
-	"time"
 )
 
 // TranslationLayer handles the two-step process of generating alt-text in English
 // and then translating it to the target language
 type TranslationLayer struct {
 	provider LLMProvider
+	name     string // What the provider is configured by, for its image prompt
 }
 
 // NewTranslationLayer creates a new translation layer for the given provider
-func NewTranslationLayer(provider LLMProvider) *TranslationLayer {
+func NewTranslationLayer(provider LLMProvider, name string) *TranslationLayer {
 	return &TranslationLayer{
 		provider: provider,
+		name:     name,
 	}
 }
 
 // GenerateAndTranslateAltText first generates alt-text in English, then translates to target language
 func (t *TranslationLayer) GenerateAndTranslateAltText(prompt string, imageData []byte, format string, targetLanguageCode string) (string, error) {
-	englishPrompt := getLocalizedString("en", "generateAltText", "prompt")
+	englishPrompt := imageAltTextPrompt(imageData, format, "en", "", "", t.name)
 
 	englishAltText, err := t.provider.GenerateAltText(englishPrompt, imageData, format, "en")
 	if err != nil {
@@ -84,74 +81,15 @@ func (t *TranslationLayer) translateWithOllama(provider *OllamaProvider, prompt
 		keepAlive = provider.translationKeepAlive
 	}
 
-	cmd := exec.Command("ollama", "run", model, "--keepalive", keepAlive, prompt)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err := cmd.Run()
-	if err != nil {
-		return "", err
-	}
-	return out.String(), nil
+	ctx, cancel := context.WithTimeout(context.Background(), provider.timeout)
+	defer cancel()
+
+	return provider.generate(ctx, model, keepAlive, prompt, nil, nil)
 }
 
 // translateWithTransformers translates text using Transformers
 func (t *TranslationLayer) translateWithTransformers(provider *TransformersProvider, prompt string) (string, error) {
-	// Prepare the request payload for text-only input
-	payload := map[string]interface{}{
-		"model": provider.Model,
-		"messages": []map[string]interface{}{
-			{
-				"role": "user",
-				"content": []map[string]interface{}{
-					{
-						"type": "text",
-						"text": prompt,
-					},
-				},
-			},
-		},
-	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return "", fmt.Errorf("error marshaling JSON: %v", err)
-	}
-
-	fullURL := fmt.Sprintf("%s/v1/chat/completions", provider.ServerURL)
-	client := &http.Client{Timeout: 30 * time.Second}
-
-	resp, err := client.Post(fullURL, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("error making request to server: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("error reading response body: %v", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("error parsing JSON response: %s", string(body))
-	}
-
-	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response: %s", string(body))
-	}
-
-	return result.Choices[0].Message.Content, nil
+	return provider.generateText(prompt)
 }
 
 // GenerateAndTranslateVideoAltText first generates video alt-text in English, then translates to target language
@@ -0,0 +1,358 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// UserDataExport is everything the bot has stored about a user (GDPR right to access)
+type UserDataExport struct {
+	UserID         string              `json:"user_id"`
+	ExportedAt     time.Time           `json:"exported_at"`
+	Consent        *ConsentRecord      `json:"consent"`
+	PendingRequest *PendingGDPRRequest `json:"pending_consent_request"`
+	RateLimiter    UserRateLimitData   `json:"rate_limiter"`
+	Metrics        []MetricEvent       `json:"metrics"`
+	Corrections    []Correction        `json:"corrections"`
+}
+
+// UserRateLimitData is a user's state in the rate limiter
+type UserRateLimitData struct {
+	MinuteCount    int        `json:"minute_count"`
+	HourCount      int        `json:"hour_count"`
+	ExceededCount  int        `json:"exceeded_count"`
+	ShadowBanned   bool       `json:"shadow_banned"`
+	Whitelisted    bool       `json:"whitelisted"`
+	AccountCreated *time.Time `json:"account_created,omitempty"`
+}
+
+// ExportUser gathers what the bot has stored about a user from the same files forgetUserData erases
+// them from. Reply and reminder state only lives in memory for a short while and isn't included.
+func ExportUser(userID string) (UserDataExport, error) {
+	export := UserDataExport{
+		UserID:      userID,
+		ExportedAt:  time.Now(),
+		Metrics:     []MetricEvent{},
+		Corrections: []Correction{},
+	}
+
+	consents := make(map[string]ConsentRecord)
+	if err := readJSONIfExists("consent_database.json", &consents); err != nil {
+		return export, fmt.Errorf("failed to read consent database: %v", err)
+	}
+	if record, exists := consents[userID]; exists {
+		export.Consent = &record
+	}
+
+	pending := make(map[string]PendingGDPRRequest)
+	if err := readJSONIfExists(pendingGDPRRequestsFile, &pending); err != nil {
+		return export, fmt.Errorf("failed to read pending GDPR requests: %v", err)
+	}
+	if request, exists := pending[userID]; exists {
+		export.PendingRequest = &request
+	}
+
+	rl := NewRateLimiter()
+	if err := rl.LoadFromFile("ratelimiter.json"); err != nil {
+		return export, fmt.Errorf("failed to read rate limiter state: %v", err)
+	}
+	minuteCount, hourCount := rl.RequestCounts(userID)
+	export.RateLimiter = UserRateLimitData{
+		MinuteCount:   minuteCount,
+		HourCount:     hourCount,
+		ExceededCount: rl.ExceededCounts[userID],
+		ShadowBanned:  rl.ShadowBanned[userID],
+		Whitelisted:   rl.Whitelist[userID],
+	}
+	if created, exists := rl.AccountAges[userID]; exists {
+		export.RateLimiter.AccountCreated = &created
+	}
+
+	// Metrics are keyed by the hashed user ID
+	hashedID := hashUserID(userID)
+	events, err := readMetricsEvents("metrics.json", time.Time{})
+	if err != nil {
+		return export, fmt.Errorf("failed to read metrics: %v", err)
+	}
+	for _, event := range events {
+		if event.UserID == hashedID || event.UserID == userID {
+			export.Metrics = append(export.Metrics, event)
+		}
+	}
+
+	corrections, err := LoadCorrections()
+	if err != nil {
+		return export, fmt.Errorf("failed to read corrections: %v", err)
+	}
+	for _, correction := range corrections {
+		if correction.Source == "dm" && correction.Submitter == hashedID {
+			export.Corrections = append(export.Corrections, correction)
+		}
+	}
+
+	logGDPRAudit("export", hashedID, map[string]int{
+		"metrics":     len(export.Metrics),
+		"corrections": len(export.Corrections),
+	})
+	return export, nil
+}
+
+// dataSummary formats a user's data export as a short localized overview for a DM
+func dataSummary(export UserDataExport, lang string) string {
+	consent := getLocalizedString(lang, "dataSummaryNoConsent", "response")
+	if export.Consent != nil {
+		consent = export.Consent.Timestamp.Format("2006-01-02")
+	}
+
+	return fmt.Sprintf(getLocalizedString(lang, "dataSummary", "response"),
+		consent, len(export.Metrics), len(export.Corrections), export.RateLimiter.HourCount)
+}
+
+// isDataRequest checks if a message, besides the accounts it mentions, is only a request for the
+// sender's data like "send me my data", in its language or English. Messages that merely contain
+// those words, like "describe my data visualization", are mentions.
+func isDataRequest(status *mastodon.Status) bool {
+	content := strings.Join(strings.Fields(mentionPattern.ReplaceAllString(stripHTMLTags(status.Content), " ")), " ")
+
+	for _, lang := range []string{status.Language, "en"} {
+		for _, re := range dataRequestPatterns[lang] {
+			if re.MatchString(content) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleDataRequest answers a direct message asking for "my data" with a summary of what the bot stores
+// about the sender. Each request counts against the rate limit. It returns false for other messages.
+func handleDataRequest(c *mastodon.Client, status *mastodon.Status) bool {
+	if status.Visibility != "direct" || !isDataRequest(status) {
+		return false
+	}
+
+	userID := string(status.Account.ID)
+	var response string
+	if allowed, resetAt := rateLimiter.Increment(c, userID, status.Account.Acct); !allowed {
+		logWarnf("User @%s has exceeded their rate limit", status.Account.Acct)
+		metricsManager.logRateLimitHit(userID)
+		if resetAt.IsZero() {
+			return true
+		}
+		response = rateLimitNotice(status.Language, resetAt)
+	} else {
+		export, err := ExportUser(userID)
+		if err != nil {
+			logErrorf("Error exporting data of %s: %v", status.Account.Acct, err)
+			return true
+		}
+		logInfof("Sending %s a summary of their stored data", status.Account.Acct)
+		response = dataSummary(export, status.Language)
+	}
+
+	message := fmt.Sprintf("@%s %s", status.Account.Acct, response)
+
+	// Dev mode: print to terminal instead of posting
+	if devMode {
+		fmt.Printf("\n%s[DEV MODE - Would post data summary]%s\n", Yellow, Reset)
+		fmt.Printf("  To: @%s\n", status.Account.Acct)
+		fmt.Printf("  Content: %s\n", message)
+		fmt.Println("---")
+		return true
+	}
+
+	if !allowReply(c) {
+		return true
+	}
+
+	_, err := c.PostStatus(ctx, &mastodon.Toot{
+		Status:      message,
+		InReplyToID: status.ID,
+		Visibility:  "direct",
+		Language:    status.Language,
+	})
+	if err != nil {
+		logErrorf("Error posting data summary: %v", err)
+	}
+
+	return true
+}
+
+// isForgetRequest checks if a message asks the bot to forget the sender, in its language or English
+func isForgetRequest(status *mastodon.Status) bool {
+	content := stripHTMLTags(status.Content)
+
+	for _, lang := range []string{status.Language, "en"} {
+		for _, re := range forgetPatterns[lang] {
+			if re.MatchString(content) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// forgetUserData erases a user's data from the bot's stores, in memory and on disk. Unless keepBan
+// is false a shadow ban is kept, so users asking for this themselves can't shed it.
+func forgetUserData(userID string, keepBan bool) (map[string]int, error) {
+	removed := make(map[string]int)
+
+	consentDB.mu.Lock()
+	_, hasConsent := consentDB.Users[userID]
+	consentDB.mu.Unlock()
+	if hasConsent {
+		if err := RemoveUserConsent(userID); err != nil {
+			return removed, fmt.Errorf("failed to remove consent: %v", err)
+		}
+		removed["consent"] = 1
+	}
+
+	if GetPendingGDPRRequest(userID) != nil {
+		RemovePendingGDPRRequest(userID)
+		removed["pending_requests"] = 1
+	}
+
+	if rateLimiter != nil {
+		if n := rateLimiter.DeleteUser(userID, keepBan); n > 0 {
+			removed["rate_limiter"] = n
+		}
+	}
+
+	if forgetReminderState(userID) {
+		removed["reminders"] = 1
+	}
+
+	if n, err := metricsManager.DeleteUser(userID); err != nil {
+		return removed, fmt.Errorf("failed to remove metrics: %v", err)
+	} else if n > 0 {
+		removed["metrics"] = n
+	}
+
+	if replies, requests, err := forgetReplies(userID); err != nil {
+		return removed, fmt.Errorf("failed to remove consent requests: %v", err)
+	} else {
+		if replies > 0 {
+			removed["replies"] = replies
+		}
+		if requests > 0 {
+			removed["consent_requests"] = requests
+		}
+	}
+
+	hashedID := hashUserID(userID)
+	if n, err := deleteCorrectionsBy("dm", hashedID); err != nil {
+		return removed, fmt.Errorf("failed to remove corrections: %v", err)
+	} else if n > 0 {
+		removed["corrections"] = n
+	}
+
+	logGDPRAudit("forget", hashedID, removed)
+	return removed, nil
+}
+
+// forgetReplies drops the remembered replies and consent requests for posts by the user or asked for by them
+func forgetReplies(userID string) (replies, requests int, err error) {
+	mapMutex.Lock()
+	defer mapMutex.Unlock()
+
+	for originalID, info := range replyMap {
+		if info.AuthorID == userID || info.RequesterID == userID {
+			delete(replyMap, originalID)
+			replies++
+		}
+	}
+	for replyID, origin := range replyOrigins {
+		if origin.AuthorID == userID || origin.RequesterID == userID {
+			delete(replyOrigins, replyID)
+			replies++
+		}
+	}
+	for statusID, request := range consentRequests {
+		if request.AuthorID == userID || request.RequesterID == userID {
+			delete(consentRequests, statusID)
+			requests++
+		}
+	}
+
+	if requests > 0 {
+		err = saveConsentRequestsToFile("consent_requests.json")
+	}
+	return replies, requests, err
+}
+
+// forgetUserOffline loads the stores of a bot that isn't running and erases a user's data from them,
+// shadow ban included
+func forgetUserOffline(userID string) (map[string]int, error) {
+	consentDB.Users = make(map[string]ConsentRecord)
+	if err := loadConsentDatabase("consent_database.json"); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read consent database: %v", err)
+	}
+	if err := InitializePendingGDPRRequests(); err != nil {
+		return nil, fmt.Errorf("failed to read pending GDPR requests: %v", err)
+	}
+
+	rateLimiter = NewRateLimiter()
+	if err := rateLimiter.LoadFromFile("ratelimiter.json"); err != nil {
+		return nil, fmt.Errorf("failed to read rate limiter state: %v", err)
+	}
+	if err := loadConsentRequestsFromFile("consent_requests.json"); err != nil {
+		return nil, fmt.Errorf("failed to read consent requests: %v", err)
+	}
+
+	// Stopping the metrics manager writes the current file back without the user's events
+	metricsManager = NewMetricsManager(true, "metrics.json", time.Hour, 0)
+	defer metricsManager.stop()
+
+	return forgetUserData(userID, false)
+}
+
+// handleForgetRequest erases the data of someone who sends the bot a direct message saying "forget me"
+// (or the same in their language) and confirms it. It returns false for other messages.
+func handleForgetRequest(c *mastodon.Client, status *mastodon.Status) bool {
+	if status.Visibility != "direct" || !isForgetRequest(status) {
+		return false
+	}
+
+	removed, err := forgetUserData(string(status.Account.ID), true)
+	if err != nil {
+		logErrorf("Error forgetting %s: %v", status.Account.Acct, err)
+		return true
+	}
+	logInfof("Forgot %s at their request: %v", status.Account.Acct, removed)
+
+	message := fmt.Sprintf("@%s %s", status.Account.Acct, getLocalizedString(status.Language, "forgetMeConfirmation", "response"))
+
+	// Dev mode: print to terminal instead of posting
+	if devMode {
+		fmt.Printf("\n%s[DEV MODE - Would post forget me confirmation]%s\n", Yellow, Reset)
+		fmt.Printf("  To: @%s\n", status.Account.Acct)
+		fmt.Printf("  Content: %s\n", message)
+		fmt.Println("---")
+		return true
+	}
+
+	if !allowReply(c) {
+		return true
+	}
+
+	_, err = c.PostStatus(ctx, &mastodon.Toot{
+		Status:      message,
+		InReplyToID: status.ID,
+		Visibility:  "direct",
+		Language:    status.Language,
+	})
+	if err != nil {
+		logErrorf("Error posting forget me confirmation: %v", err)
+	}
+
+	return true
+}
@@ -0,0 +1,428 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func writeJSONFile(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readJSONFile(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	if err := readJSONIfExists(path, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useConsentRequests starts the test without any consent requests
+func useConsentRequests(t *testing.T) {
+	t.Helper()
+	previous := consentRequests
+	consentRequests = make(map[mastodon.ID]ConsentRequest)
+	t.Cleanup(func() { consentRequests = previous })
+}
+
+func TestForgetUserOfflineErasesEveryStore(t *testing.T) {
+	t.Chdir(t.TempDir())
+	useConsentRequests(t)
+
+	const userID, otherID = "1001", "1002"
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+
+	writeJSONFile(t, "consent_database.json", map[string]ConsentRecord{
+		userID:  {UserID: userID, Timestamp: now},
+		otherID: {UserID: otherID, Timestamp: now},
+	})
+	writeJSONFile(t, pendingGDPRRequestsFile, map[string]PendingGDPRRequest{
+		userID: {UserID: userID, Timestamp: now},
+	})
+
+	rl := NewRateLimiter()
+	rl.Requests[userID] = []time.Time{now}
+	rl.ShadowBanned[userID] = true
+	rl.ExceededCounts[userID] = 3
+	rl.Requests[otherID] = []time.Time{now}
+	writeJSONFile(t, "ratelimiter.json", rl)
+
+	writeJSONFile(t, "metrics.json", []MetricEvent{
+		{Timestamp: now, UserID: hashUserID(userID), EventType: "request"},
+		{Timestamp: now, UserID: hashUserID(otherID), EventType: "request"},
+	})
+	writeJSONFile(t, rotatedMetricsPath("metrics.json", metricsDay(yesterday)), []MetricEvent{
+		{Timestamp: yesterday, UserID: hashUserID(userID), EventType: "request"},
+	})
+	writeJSONFile(t, correctionsFile, []Correction{
+		{Source: "dm", Submitter: hashUserID(userID), Correction: "mine"},
+		{Source: "dm", Submitter: hashUserID(otherID), Correction: "theirs"},
+	})
+	writeJSONFile(t, "consent_requests.json", map[mastodon.ID]ConsentRequest{
+		"10": {RequestID: "11", Timestamp: now, AuthorID: otherID, RequesterID: userID},
+		"20": {RequestID: "21", Timestamp: now, AuthorID: otherID, RequesterID: "1003"},
+	})
+
+	altTextReminderTracker.mu.Lock()
+	altTextReminderTracker.LastReminded[userID] = now
+	altTextReminderTracker.mu.Unlock()
+
+	removed, err := forgetUserOffline(userID)
+	if err != nil {
+		t.Fatalf("forgetUserOffline: %v", err)
+	}
+	for _, store := range []string{"consent", "pending_requests", "rate_limiter", "reminders", "metrics", "corrections", "consent_requests"} {
+		if removed[store] == 0 {
+			t.Errorf("nothing removed from %s: %v", store, removed)
+		}
+	}
+
+	consents := make(map[string]ConsentRecord)
+	readJSONFile(t, "consent_database.json", &consents)
+	if _, exists := consents[userID]; exists {
+		t.Error("consent kept")
+	}
+	if _, exists := consents[otherID]; !exists {
+		t.Error("another user's consent removed")
+	}
+
+	pending := make(map[string]PendingGDPRRequest)
+	readJSONFile(t, pendingGDPRRequestsFile, &pending)
+	if _, exists := pending[userID]; exists {
+		t.Error("pending request kept")
+	}
+
+	saved := NewRateLimiter()
+	if err := saved.LoadFromFile("ratelimiter.json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := saved.Requests[userID]; exists || saved.ShadowBanned[userID] || saved.ExceededCounts[userID] != 0 {
+		t.Error("rate limiter state kept")
+	}
+	if _, exists := saved.Requests[otherID]; !exists {
+		t.Error("another user's rate limiter state removed")
+	}
+
+	for _, path := range []string{"metrics.json", rotatedMetricsPath("metrics.json", metricsDay(yesterday))} {
+		var events []MetricEvent
+		readJSONFile(t, path, &events)
+		for _, event := range events {
+			if event.UserID == hashUserID(userID) {
+				t.Errorf("event kept in %s", path)
+			}
+		}
+	}
+
+	var corrections []Correction
+	readJSONFile(t, correctionsFile, &corrections)
+	if len(corrections) != 1 || corrections[0].Correction != "theirs" {
+		t.Errorf("corrections = %+v, want only the other user's", corrections)
+	}
+
+	requests := make(map[mastodon.ID]ConsentRequest)
+	readJSONFile(t, "consent_requests.json", &requests)
+	if _, exists := requests["20"]; len(requests) != 1 || !exists {
+		t.Errorf("consent requests = %+v, want only the one the user didn't ask for", requests)
+	}
+
+	if _, err := os.Stat(gdprAuditLogFile); err != nil {
+		t.Errorf("no audit entry: %v", err)
+	}
+}
+
+func TestRunningBotPIDIgnoresStaleFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	if _, running := runningBotPID(); running {
+		t.Error("running without a PID file")
+	}
+
+	// A PID far above the kernel's limit can't belong to a live process
+	if err := os.WriteFile(botPIDFile, []byte("999999999"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, running := runningBotPID(); running {
+		t.Error("running with a stale PID file")
+	}
+}
+
+func TestExportUserCollectsEveryStore(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	const userID, otherID = "1001", "1002"
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+	created := now.AddDate(0, 0, -3).Truncate(time.Second)
+
+	writeJSONFile(t, "consent_database.json", map[string]ConsentRecord{
+		userID:  {UserID: userID, Timestamp: yesterday, ConsentMethod: "reply"},
+		otherID: {UserID: otherID, Timestamp: now},
+	})
+	writeJSONFile(t, pendingGDPRRequestsFile, map[string]PendingGDPRRequest{
+		userID:  {UserID: userID, RequestStatusID: "500", Timestamp: now},
+		otherID: {UserID: otherID, RequestStatusID: "501", Timestamp: now},
+	})
+
+	rl := NewRateLimiter()
+	rl.Requests[userID] = []time.Time{now.Add(-30 * time.Minute), now.Add(-10 * time.Second)}
+	rl.ExceededCounts[userID] = 2
+	rl.ShadowBanned[userID] = true
+	rl.AccountAges[userID] = created
+	rl.Requests[otherID] = []time.Time{now}
+	writeJSONFile(t, "ratelimiter.json", rl)
+
+	writeJSONFile(t, "metrics.json", []MetricEvent{
+		{Timestamp: now, UserID: hashUserID(userID), EventType: "request"},
+		{Timestamp: now, UserID: hashUserID(otherID), EventType: "request"},
+	})
+	writeJSONFile(t, rotatedMetricsPath("metrics.json", metricsDay(yesterday)), []MetricEvent{
+		{Timestamp: yesterday, UserID: hashUserID(userID), EventType: "follow"},
+	})
+	writeJSONFile(t, correctionsFile, []Correction{
+		{Source: "dm", Submitter: hashUserID(userID), Correction: "mine"},
+		{Source: "dm", Submitter: hashUserID(otherID), Correction: "theirs"},
+	})
+
+	output := captureStdout(t, func() { handleExportUser([]string{userID, "--output", "export.json"}) })
+	if !strings.Contains(output, "2 metrics events, 1 corrections") {
+		t.Errorf("printed %q", output)
+	}
+
+	var export UserDataExport
+	readJSONFile(t, "export.json", &export)
+	if export.UserID != userID || export.Consent == nil || export.Consent.ConsentMethod != "reply" {
+		t.Errorf("consent = %+v", export.Consent)
+	}
+	if export.PendingRequest == nil || export.PendingRequest.RequestStatusID != "500" {
+		t.Errorf("pending request = %+v", export.PendingRequest)
+	}
+	limits := export.RateLimiter
+	if limits.MinuteCount != 1 || limits.HourCount != 2 || limits.ExceededCount != 2 || !limits.ShadowBanned || limits.Whitelisted ||
+		limits.AccountCreated == nil || !limits.AccountCreated.Equal(created) {
+		t.Errorf("rate limiter = %+v", limits)
+	}
+	if len(export.Metrics) != 2 || export.Metrics[0].EventType != "follow" || export.Metrics[1].EventType != "request" {
+		t.Errorf("metrics = %+v, want the user's events of both days", export.Metrics)
+	}
+	if len(export.Corrections) != 1 || export.Corrections[0].Correction != "mine" {
+		t.Errorf("corrections = %+v", export.Corrections)
+	}
+
+	// Exports are audited like deletions
+	if _, err := os.Stat(gdprAuditLogFile); err != nil {
+		t.Errorf("no audit entry: %v", err)
+	}
+}
+
+func TestDataRequestSendsSummary(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	useBotState(t)
+	writeJSONFile(t, "consent_database.json", map[string]ConsentRecord{
+		"1001": {UserID: "1001", Timestamp: time.Date(2025, 5, 4, 12, 0, 0, 0, time.UTC)},
+	})
+	writeJSONFile(t, "metrics.json", []MetricEvent{
+		{Timestamp: time.Now(), UserID: hashUserID("1001"), EventType: "request"},
+	})
+
+	posts := make(chan url.Values, 1)
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posts <- r.Form
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"3"}`)
+	})
+
+	request := &mastodon.Status{ID: "2", Visibility: "direct", Language: "en", Content: "<p>@altbot my data please</p>", Account: mastodon.Account{ID: "1001", Acct: "alice"}}
+	if !handleDataRequest(c, request) {
+		t.Fatal("data request wasn't handled")
+	}
+	form := <-posts
+	want := fmt.Sprintf("@alice "+getLocalizedString("en", "dataSummary", "response"), "2025-05-04", 1, 0, 0)
+	if form.Get("status") != want || form.Get("visibility") != "direct" || form.Get("in_reply_to_id") != "2" {
+		t.Errorf("posted %v, want a direct reply with %q", form, want)
+	}
+
+	// Only direct messages are answered
+	request.Visibility = "public"
+	if handleDataRequest(c, request) {
+		t.Error("a public mention asking for data was handled")
+	}
+}
+
+func TestDataRequestIsRateLimited(t *testing.T) {
+	loadTestLocalizations(t)
+	useRateLimit(t, "1001")
+	config.RateLimit.NotifyLimitedUsers = true
+
+	posts := make(chan url.Values, 1)
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posts <- r.Form
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"3"}`)
+	})
+
+	rateLimiter.Requests["1001"] = requestsAgo(50*time.Second, 40*time.Second, 30*time.Second, 20*time.Second, 10*time.Second)
+	request := &mastodon.Status{ID: "2", Visibility: "direct", Language: "en", Content: "<p>@altbot send me my data</p>", Account: mastodon.Account{ID: "1001", Acct: "alice"}}
+	if !handleDataRequest(c, request) {
+		t.Fatal("data request wasn't handled")
+	}
+	if form := <-posts; !strings.Contains(form.Get("status"), fmt.Sprintf(getLocalizedString("en", "rateLimitReached", "response"), 1)) {
+		t.Errorf("posted %q, want the rate limit notice", form.Get("status"))
+	}
+
+	// Once told, further requests over the limit go unanswered
+	if !handleDataRequest(c, request) {
+		t.Fatal("data request wasn't handled")
+	}
+	if len(posts) != 0 {
+		t.Errorf("posted %v after the user was told about the limit", <-posts)
+	}
+}
+
+func TestIsDataRequest(t *testing.T) {
+	loadTestLocalizations(t)
+
+	tests := []struct {
+		language, content string
+		want              bool
+	}{
+		{"en", "<p>@altbot my data please</p>", true},
+		{"en", `<p><span class="h-card"><a href="https://example.social/@altbot">@<span>altbot</span></a></span> Send me my data!</p>`, true},
+		{"en", "<p>@altbot@example.social what data do you store about me?</p>", true},
+		{"de", "<p>@altbot Schick mir meine Daten</p>", true},
+		// English works whatever the language of the post
+		{"fr", "<p>@altbot my data</p>", true},
+		{"en", "<p>@altbot describe my data visualization</p>", false},
+		{"en", "<p>@altbot is my data safe with you?</p>", false},
+		{"de", "<p>@altbot meine Daten sind hier im Diagramm</p>", false},
+	}
+	for _, test := range tests {
+		if got := isDataRequest(&mastodon.Status{Language: test.language, Content: test.content}); got != test.want {
+			t.Errorf("%s %q: got %v, want %v", test.language, test.content, got, test.want)
+		}
+	}
+}
+
+func TestForgetMeRequestErasesUser(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	useBotState(t)
+	useConsentDB(t)
+	useReplyMaps(t)
+	useConsentRequests(t)
+
+	const userID = "1001"
+	now := time.Now()
+	// Replies to the user's posts and to their mentions under someone else's, and a consent request they caused
+	replyMap["100"] = ReplyInfo{ReplyID: "101", Timestamp: now, AuthorID: userID, RequesterID: userID}
+	replyMap["110"] = ReplyInfo{ReplyID: "111", Timestamp: now, AuthorID: "1002", RequesterID: userID}
+	replyMap["120"] = ReplyInfo{ReplyID: "121", Timestamp: now, AuthorID: "1002", RequesterID: "1002"}
+	replyOrigins["101"] = ReplyOrigin{OriginalID: "100", Timestamp: now, AuthorID: userID, RequesterID: userID}
+	replyOrigins["111"] = ReplyOrigin{OriginalID: "110", Timestamp: now, AuthorID: "1002", RequesterID: userID}
+	replyOrigins["121"] = ReplyOrigin{OriginalID: "120", Timestamp: now, AuthorID: "1002", RequesterID: "1002"}
+	consentRequests["130"] = ConsentRequest{RequestID: "131", Timestamp: now, AuthorID: userID, RequesterID: "1002"}
+	consentRequests["140"] = ConsentRequest{RequestID: "141", Timestamp: now, AuthorID: "1002", RequesterID: "1003"}
+
+	consentDB.Users[userID] = ConsentRecord{UserID: userID, Timestamp: time.Now()}
+	consentDB.Users["1002"] = ConsentRecord{UserID: "1002", Timestamp: time.Now()}
+	AddPendingGDPRRequest(userID, "500")
+	rateLimiter.Requests[userID] = []time.Time{time.Now()}
+	rateLimiter.ShadowBanned[userID] = true
+	altTextReminderTracker.mu.Lock()
+	altTextReminderTracker.LastReminded[userID] = time.Now()
+	altTextReminderTracker.mu.Unlock()
+
+	posts := make(chan url.Values, 1)
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posts <- r.Form
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"3"}`)
+	})
+
+	// Not a direct message, or not asking to be forgotten
+	account := mastodon.Account{ID: userID, Acct: "anna"}
+	if handleForgetRequest(c, &mastodon.Status{Visibility: "public", Language: "de", Content: "<p>Bitte vergiss mich!</p>", Account: account}) ||
+		handleForgetRequest(c, &mastodon.Status{Visibility: "direct", Language: "de", Content: "<p>Danke für die Beschreibung!</p>", Account: account}) {
+		t.Fatal("handled a message that isn't a forget me request")
+	}
+
+	if !handleForgetRequest(c, &mastodon.Status{ID: "2", Visibility: "direct", Language: "de", Content: "<p>@altbot Bitte vergiss mich!</p>", Account: account}) {
+		t.Fatal("forget me request wasn't handled")
+	}
+
+	if HasUserConsent(userID) || !HasUserConsent("1002") {
+		t.Error("consent wasn't removed from only the user")
+	}
+	if GetPendingGDPRRequest(userID) != nil {
+		t.Error("pending request kept")
+	}
+	if _, exists := rateLimiter.Requests[userID]; exists {
+		t.Error("rate limiter requests kept")
+	}
+	if !rateLimiter.ShadowBanned[userID] {
+		t.Error("users asking to be forgotten shed their shadow ban")
+	}
+	if forgetReminderState(userID) {
+		t.Error("reminder state kept")
+	}
+	if _, exists := replyMap["120"]; len(replyMap) != 1 || !exists {
+		t.Errorf("replies %v, want only the one the user had no part in", replyMap)
+	}
+	if _, exists := replyOrigins["121"]; len(replyOrigins) != 1 || !exists {
+		t.Errorf("reply origins %v, want only the one the user had no part in", replyOrigins)
+	}
+	if _, exists := consentRequests["140"]; len(consentRequests) != 1 || !exists {
+		t.Errorf("consent requests %v, want only the other users'", consentRequests)
+	}
+
+	form := <-posts
+	if form.Get("status") != "@anna "+getLocalizedString("de", "forgetMeConfirmation", "response") || form.Get("visibility") != "direct" {
+		t.Errorf("posted %v, want a direct confirmation in German", form)
+	}
+}
+
+func TestIsForgetRequest(t *testing.T) {
+	loadTestLocalizations(t)
+
+	tests := []struct {
+		language, content string
+		want              bool
+	}{
+		{"en", "<p>@altbot please forget me</p>", true},
+		{"en", "<p>Delete my data.</p>", true},
+		{"es", "<p>olvídame, por favor</p>", true},
+		// English works whatever the language of the post
+		{"fr", "<p>forget me</p>", true},
+		{"de", "<p>Vergissmeinnicht im Garten</p>", false},
+		{"en", "<p>thanks for the caption</p>", false},
+	}
+	for _, test := range tests {
+		if got := isForgetRequest(&mastodon.Status{Language: test.language, Content: test.content}); got != test.want {
+			t.Errorf("%s %q: got %v, want %v", test.language, test.content, got, test.want)
+		}
+	}
+}
@@ -14,7 +14,7 @@ import (
 // ExtractVideoFrames extracts frames from a video at a specified FPS
 func ExtractVideoFrames(videoData []byte, framesPerSecond float64, maxFrames int) ([]string, error) {
 	// Create a temporary directory to store frames
-	tempDir, err := os.MkdirTemp("", "videoframes")
+	tempDir, err := createTempDir("videoframes-*")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp dir: %v", err)
 	}
@@ -0,0 +1,273 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const webhookMessagesFile = "webhook_messages.json"
+
+// webhookMessageRetention is how long processed message IDs are remembered, well past any retries
+const webhookMessageRetention = 90 * 24 * time.Hour
+
+var webhookMessagesMu sync.Mutex
+
+// verifyKofiSignature checks the hex HMAC-SHA256 of the raw body, optionally prefixed with "sha256="
+func verifyKofiSignature(body []byte, signature string, secret string) bool {
+	return verifyHMAC(sha256.New, body, strings.TrimPrefix(strings.TrimSpace(signature), "sha256="), secret)
+}
+
+// verifyPatreonSignature checks the hex HMAC-MD5 of the raw body that Patreon sends in X-Patreon-Signature
+func verifyPatreonSignature(body []byte, signature string, secret string) bool {
+	return verifyHMAC(md5.New, body, strings.TrimSpace(signature), secret)
+}
+
+func verifyHMAC(h func() hash.Hash, body []byte, signature string, secret string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// grantAPIKey extends the active key of a supporter or creates a new one, and emails it to them
+func grantAPIKey(email string, duration int, note string) error {
+	existingKey := FindAPIKeyByEmail(email)
+	if existingKey != nil && existingKey.Active {
+		// Extend existing key instead of creating new one
+		if err := ExtendAPIKey(existingKey.Key, duration); err != nil {
+			return fmt.Errorf("error extending API key for %s: %v", email, err)
+		}
+		logInfof("Extended API key for %s by %d days", email, duration)
+
+		fmt.Printf("\n%s=== API KEY EXTENDED ===%s\n", Cyan, Reset)
+		fmt.Printf("Email: %s\n", email)
+		fmt.Printf("Key: %s\n", existingKey.Key)
+		fmt.Printf("Extended by: %d days\n", duration)
+		fmt.Printf("%s=========================%s\n\n", Cyan, Reset)
+
+		if err := SendAPIKeyExtendedEmail(email, existingKey, duration); err != nil {
+			logErrorf("Error sending extension email to %s: %v", email, err)
+		}
+		return nil
+	}
+
+	// Create new key
+	apiKey, err := GenerateAPIKey(email, duration, note)
+	if err != nil {
+		return fmt.Errorf("error generating API key for %s: %v", email, err)
+	}
+	logInfof("Generated new API key for %s", email)
+
+	fmt.Printf("\n%s=== NEW API KEY PURCHASE ===%s\n", Green, Reset)
+	fmt.Printf("Email: %s\n", email)
+	fmt.Printf("Note: %s\n", note)
+	fmt.Printf("Key: %s\n", apiKey.Key)
+	fmt.Printf("Expires: %s\n", apiKey.ExpiresAt.Format("2006-01-02"))
+	fmt.Printf("%s=============================%s\n\n", Green, Reset)
+
+	if err := SendAPIKeyEmail(email, apiKey); err != nil {
+		logErrorf("Error sending key email to %s: %v", email, err)
+	}
+	return nil
+}
+
+// patreonMemberEvent is the part of Patreon's members:* webhook payload the bot uses
+type patreonMemberEvent struct {
+	Data struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Email                        string `json:"email"`
+			FullName                     string `json:"full_name"`
+			PatronStatus                 string `json:"patron_status"` // "active_patron", "declined_patron" or "former_patron"
+			LastChargeStatus             string `json:"last_charge_status"`
+			LastChargeDate               string `json:"last_charge_date"`
+			CurrentlyEntitledAmountCents int    `json:"currently_entitled_amount_cents"`
+		} `json:"attributes"`
+		Relationships struct {
+			CurrentlyEntitledTiers struct {
+				Data []struct {
+					ID string `json:"id"`
+				} `json:"data"`
+			} `json:"currently_entitled_tiers"`
+		} `json:"relationships"`
+	} `json:"data"`
+	Included []struct {
+		ID         string `json:"id"`
+		Type       string `json:"type"`
+		Attributes struct {
+			Title string `json:"title"`
+		} `json:"attributes"`
+	} `json:"included"`
+}
+
+// entitledToAPI reports whether the member is entitled to the configured tier, matched by title or ID
+func (e *patreonMemberEvent) entitledToAPI() bool {
+	var tierIDs []string
+	for _, tier := range e.Data.Relationships.CurrentlyEntitledTiers.Data {
+		tierIDs = append(tierIDs, tier.ID)
+	}
+	if slices.Contains(tierIDs, config.API.PatreonTierName) {
+		return true
+	}
+
+	for _, included := range e.Included {
+		if included.Type == "tier" && included.Attributes.Title == config.API.PatreonTierName && slices.Contains(tierIDs, included.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// handlePatreonWebhook handles Patreon member webhooks for automatic key generation
+func (s *APIServer) handlePatreonWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if config.API.PatreonWebhookSecret == "" {
+		logWarnf("Patreon webhook: no webhook secret configured")
+		s.jsonError(w, "Webhook not configured", http.StatusNotImplemented)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		logErrorf("Patreon webhook: failed to read body: %v", err)
+		s.jsonError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if !verifyPatreonSignature(body, r.Header.Get("X-Patreon-Signature"), config.API.PatreonWebhookSecret) {
+		logWarnf("Patreon webhook: invalid signature")
+		s.jsonError(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var event patreonMemberEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		logErrorf("Patreon webhook: failed to parse JSON: %v", err)
+		s.jsonError(w, "Invalid JSON data", http.StatusBadRequest)
+		return
+	}
+
+	eventType := r.Header.Get("X-Patreon-Event")
+	member := event.Data.Attributes
+	logInfof("Patreon webhook parsed: event=%s, from=%s, email=%s, status=%s, charge=%s",
+		eventType, member.FullName, member.Email, member.PatronStatus, member.LastChargeStatus)
+
+	// Only paid pledges of the API tier grant a key
+	isPledge := strings.HasPrefix(eventType, "members:") && !strings.HasSuffix(eventType, ":delete")
+	if !isPledge || member.PatronStatus != "active_patron" || member.LastChargeStatus != "Paid" || !event.entitledToAPI() {
+		logInfof("Patreon webhook: not a paid API tier pledge - ignoring")
+		s.jsonResponse(w, map[string]string{"status": "ok", "action": "ignored"})
+		return
+	}
+
+	if member.Email == "" {
+		logWarnf("Patreon webhook: member %s has no email, check the client's scopes - ignoring", event.Data.ID)
+		s.jsonResponse(w, map[string]string{"status": "ok", "action": "ignored"})
+		return
+	}
+
+	// Patreon sends several events for one charge, only the first one extends the key
+	messageID := event.Data.ID + ":" + member.LastChargeDate
+	if !claimWebhookMessage("patreon", messageID) {
+		logInfof("Patreon webhook: charge %s already processed - ignoring", messageID)
+		s.jsonResponse(w, map[string]string{"status": "ok", "action": "duplicate"})
+		return
+	}
+
+	note := fmt.Sprintf("Patreon pledge from %s (%d.%02d)", member.FullName, member.CurrentlyEntitledAmountCents/100, member.CurrentlyEntitledAmountCents%100)
+	if err := grantAPIKey(member.Email, 31, note); err != nil {
+		logErrorf("Patreon webhook: %v", err)
+		releaseWebhookMessage("patreon", messageID)
+		s.jsonError(w, "Failed to generate key", http.StatusInternalServerError)
+		return
+	}
+
+	s.jsonResponse(w, map[string]string{"status": "ok", "action": "key_generated"})
+}
+
+// claimWebhookMessage records a webhook message ID, returning false if it was processed before.
+// Messages without an ID can't be deduplicated and are always processed.
+func claimWebhookMessage(source, messageID string) bool {
+	if messageID == "" {
+		return true
+	}
+	messageID = source + ":" + messageID
+
+	webhookMessagesMu.Lock()
+	defer webhookMessagesMu.Unlock()
+
+	seen := make(map[string]time.Time)
+	if err := readJSONIfExists(webhookMessagesFile, &seen); err != nil {
+		logErrorf("Error loading webhook message IDs: %v", err)
+	}
+	if _, ok := seen[messageID]; ok {
+		return false
+	}
+
+	for id, at := range seen {
+		if time.Since(at) > webhookMessageRetention {
+			delete(seen, id)
+		}
+	}
+	seen[messageID] = time.Now()
+
+	if err := saveWebhookMessagesUnlocked(seen); err != nil {
+		logErrorf("Error saving webhook message IDs: %v", err)
+	}
+	return true
+}
+
+// releaseWebhookMessage forgets a message ID that failed to process, so a retry goes through
+func releaseWebhookMessage(source, messageID string) {
+	if messageID == "" {
+		return
+	}
+	messageID = source + ":" + messageID
+
+	webhookMessagesMu.Lock()
+	defer webhookMessagesMu.Unlock()
+
+	seen := make(map[string]time.Time)
+	if err := readJSONIfExists(webhookMessagesFile, &seen); err != nil {
+		logErrorf("Error loading webhook message IDs: %v", err)
+		return
+	}
+	delete(seen, messageID)
+
+	if err := saveWebhookMessagesUnlocked(seen); err != nil {
+		logErrorf("Error saving webhook message IDs: %v", err)
+	}
+}
+
+func saveWebhookMessagesUnlocked(seen map[string]time.Time) error {
+	data, err := json.MarshalIndent(seen, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(webhookMessagesFile, data, 0644)
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// kofiRequest builds a Ko-fi shop order webhook, signed with secret unless it is empty
+func kofiRequest(messageID, secret string) *http.Request {
+	data := `{"verification_token":"token","message_id":"` + messageID + `","email":"supporter@example.com",` +
+		`"type":"Shop Order","from_name":"Supporter","amount":"5.00","currency":"EUR","shop_items":[{"direct_link_code":"apikey","quantity":1}]}`
+	body := url.Values{"data": {data}}.Encode()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/webhook/kofi", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		req.Header.Set("X-Kofi-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return req
+}
+
+// useKofiWebhook configures the Ko-fi webhook with an empty key store
+func useKofiWebhook(t *testing.T) *APIServer {
+	t.Helper()
+	useConfig(t)
+	useAPIKeyStore(t)
+	config.API.KofiVerificationToken = "token"
+	config.API.KofiWebhookSecret = "secret"
+	config.API.KofiShopItemCode = "apikey"
+	return &APIServer{}
+}
+
+func TestKofiReplayedMessageDoesNotExtendTwice(t *testing.T) {
+	server := useKofiWebhook(t)
+
+	rec := httptest.NewRecorder()
+	server.handleKofiWebhook(rec, kofiRequest("message-1", "secret"))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "key_generated") {
+		t.Fatalf("first delivery: %d %s", rec.Code, rec.Body)
+	}
+	apiKey := FindAPIKeyByEmail("supporter@example.com")
+	if apiKey == nil {
+		t.Fatal("no key was generated")
+	}
+	expiresAt := apiKey.ExpiresAt
+
+	rec = httptest.NewRecorder()
+	server.handleKofiWebhook(rec, kofiRequest("message-1", "secret"))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "duplicate") {
+		t.Errorf("replay: %d %s", rec.Code, rec.Body)
+	}
+	if !FindAPIKeyByEmail("supporter@example.com").ExpiresAt.Equal(expiresAt) {
+		t.Error("replayed message extended the key")
+	}
+
+	// A new payment does extend it
+	server.handleKofiWebhook(httptest.NewRecorder(), kofiRequest("message-2", "secret"))
+	if extended := FindAPIKeyByEmail("supporter@example.com").ExpiresAt; extended.Sub(expiresAt) < 29*24*time.Hour {
+		t.Errorf("second payment extended the key by %v", extended.Sub(expiresAt))
+	}
+}
+
+func TestKofiRejectsBadSignature(t *testing.T) {
+	server := useKofiWebhook(t)
+
+	for name, req := range map[string]*http.Request{
+		"wrong secret": kofiRequest("message-1", "other secret"),
+		"unsigned":     kofiRequest("message-1", ""),
+	} {
+		rec := httptest.NewRecorder()
+		server.handleKofiWebhook(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: got %d, want 401", name, rec.Code)
+		}
+	}
+	if FindAPIKeyByEmail("supporter@example.com") != nil {
+		t.Error("a key was generated for an unverified webhook")
+	}
+
+	// A rejected delivery doesn't claim its message ID
+	rec := httptest.NewRecorder()
+	server.handleKofiWebhook(rec, kofiRequest("message-1", "secret"))
+	if !strings.Contains(rec.Body.String(), "key_generated") {
+		t.Errorf("signed retry: %d %s", rec.Code, rec.Body)
+	}
+}
+
+// patreonRequest builds a members:pledge:create webhook, signed with secret
+func patreonRequest(patronStatus, tierTitle, secret string) *http.Request {
+	body := `{"data":{"id":"member-1","attributes":{"email":"patron@example.com","full_name":"Patron",` +
+		`"patron_status":"` + patronStatus + `","last_charge_status":"Paid","last_charge_date":"2025-06-01T00:00:00.000+00:00",` +
+		`"currently_entitled_amount_cents":500},"relationships":{"currently_entitled_tiers":{"data":[{"id":"tier-1"}]}}},` +
+		`"included":[{"id":"tier-1","type":"tier","attributes":{"title":"` + tierTitle + `"}}]}`
+
+	mac := hmac.New(md5.New, []byte(secret))
+	mac.Write([]byte(body))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/webhook/patreon", strings.NewReader(body))
+	req.Header.Set("X-Patreon-Event", "members:pledge:create")
+	req.Header.Set("X-Patreon-Signature", hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func TestPatreonSignedPledgeGeneratesKey(t *testing.T) {
+	useConfig(t)
+	useAPIKeyStore(t)
+	config.API.PatreonWebhookSecret = "secret"
+	config.API.PatreonTierName = "API Access"
+	server := &APIServer{}
+
+	rec := httptest.NewRecorder()
+	server.handlePatreonWebhook(rec, patreonRequest("active_patron", "API Access", "secret"))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "key_generated") {
+		t.Fatalf("got %d %s", rec.Code, rec.Body)
+	}
+	apiKey := FindAPIKeyByEmail("patron@example.com")
+	if apiKey == nil || !strings.Contains(apiKey.Note, "Patreon pledge from Patron (5.00)") {
+		t.Fatalf("generated key %+v", apiKey)
+	}
+
+	// Patreon sends members:update for the same charge, it mustn't extend the key again
+	rec = httptest.NewRecorder()
+	server.handlePatreonWebhook(rec, patreonRequest("active_patron", "API Access", "secret"))
+	if !strings.Contains(rec.Body.String(), "duplicate") {
+		t.Errorf("same charge again: %s", rec.Body)
+	}
+}
+
+func TestPatreonIgnoresOtherPledges(t *testing.T) {
+	useConfig(t)
+	useAPIKeyStore(t)
+	config.API.PatreonWebhookSecret = "secret"
+	config.API.PatreonTierName = "API Access"
+	server := &APIServer{}
+
+	for name, req := range map[string]*http.Request{
+		"other tier":    patreonRequest("active_patron", "Supporter", "secret"),
+		"former patron": patreonRequest("former_patron", "API Access", "secret"),
+	} {
+		rec := httptest.NewRecorder()
+		server.handlePatreonWebhook(rec, req)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "ignored") {
+			t.Errorf("%s: %d %s", name, rec.Code, rec.Body)
+		}
+	}
+	if FindAPIKeyByEmail("patron@example.com") != nil {
+		t.Error("a key was generated without a paid API tier pledge")
+	}
+}
+
+func TestPatreonRejectsBadSignature(t *testing.T) {
+	useConfig(t)
+	useAPIKeyStore(t)
+	config.API.PatreonWebhookSecret = "secret"
+	config.API.PatreonTierName = "API Access"
+
+	rec := httptest.NewRecorder()
+	(&APIServer{}).handlePatreonWebhook(rec, patreonRequest("active_patron", "API Access", "other secret"))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("got %d, want 401", rec.Code)
+	}
+	if FindAPIKeyByEmail("patron@example.com") != nil {
+		t.Error("a key was generated for an unverified webhook")
+	}
+}
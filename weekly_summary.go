@@ -9,8 +9,8 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"math/rand"
 	"os"
 	"sort"
@@ -21,23 +21,64 @@ import (
 )
 
 type WeeklySummary struct {
-	AltTextCount int
-	NewUserCount int
+	AltTextCount  int
+	NewUserCount  int
+	ImageCount    int     // Images described, from the metrics
+	FollowerCount int     // Follows of the bot, from the metrics
+	EnergyKWh     float64 // Energy used by a local model, from the metrics
+	Languages     map[string]LanguageStat
 }
 
+// errQuietWeek is returned when nothing happened during the week and there's no quiet_week_message to post
+var errQuietWeek = errors.New("no activity this week")
+
 func GenerateWeeklySummary(c *mastodon.Client, ctx context.Context) {
 	if !config.WeeklySummary.Enabled {
 		return
 	}
 
+	message, err := renderWeeklySummary()
+	if errors.Is(err, errQuietWeek) {
+		logInfof("Skipping weekly summary, there was no activity this week")
+		return
+	} else if err != nil {
+		logErrorf("Error reading log entries: %v", err)
+		return
+	}
+
+	// Dev mode: print to terminal instead of posting
+	if devMode {
+		fmt.Printf("\n%s[DEV MODE - Would post weekly summary]%s\n", Yellow, Reset)
+		fmt.Printf("  Visibility: public\n")
+		fmt.Printf("  Content:\n%s\n", message)
+		fmt.Println("---")
+		return
+	}
+
+	if err := postWeeklySummary(c, ctx, message); err != nil {
+		logErrorf("Error posting weekly summary: %v", err)
+		return
+	}
+	metricsManager.logWeeklySummary(config.Server.Username)
+}
+
+// renderWeeklySummary fills the message template with the past week's numbers, the leaderboard and a random tip.
+// A week without any activity gets the quiet_week_message instead, or errQuietWeek if there is none.
+func renderWeeklySummary() (string, error) {
 	// Fetch data for the past week
 	summary := fetchWeeklyData()
 
-	// Calculate leaderboard
+	if summary.AltTextCount == 0 && summary.NewUserCount == 0 && summary.ImageCount == 0 && summary.FollowerCount == 0 {
+		if config.WeeklySummary.QuietWeekMessage == "" {
+			return "", errQuietWeek
+		}
+		return config.WeeklySummary.QuietWeekMessage, nil
+	}
+
+	// Calculate leaderboard, a week of metrics-only activity may not have a log yet
 	entries, err := readLogEntries()
-	if err != nil {
-		log.Printf("Error reading log entries: %v", err)
-		return
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
 	}
 	userScores := calculateLeaderboard(entries)
 	topUsers := getTopUsers(userScores)
@@ -50,34 +91,57 @@ func GenerateWeeklySummary(c *mastodon.Client, ctx context.Context) {
 	leaderboard := leaderboardBuilder.String()
 
 	// Select a random tip from the list
-	tipOfTheWeek := config.WeeklySummary.Tips[rand.Intn(len(config.WeeklySummary.Tips))]
+	var tipOfTheWeek string
+	if len(config.WeeklySummary.Tips) > 0 {
+		tipOfTheWeek = config.WeeklySummary.Tips[rand.Intn(len(config.WeeklySummary.Tips))]
+	}
 
 	// Create the summary message using the template
 	message := strings.ReplaceAll(config.WeeklySummary.MessageTemplate, "{{alt_text_count}}", fmt.Sprintf("%d", summary.AltTextCount))
 	message = strings.ReplaceAll(message, "{{new_user_count}}", fmt.Sprintf("%d", summary.NewUserCount))
 	message = strings.ReplaceAll(message, "{{tip_of_the_week}}", tipOfTheWeek)
 	message = strings.ReplaceAll(message, "{{leaderboard}}", leaderboard)
+	message = strings.ReplaceAll(message, "{{images}}", fmt.Sprintf("%d", summary.ImageCount))
+	message = strings.ReplaceAll(message, "{{followers}}", fmt.Sprintf("%d", summary.FollowerCount))
+	message = strings.ReplaceAll(message, "{{energy_kwh}}", formatLocalizedDecimal(config.Localization.DefaultLanguage, summary.EnergyKWh, 3))
+	message = strings.ReplaceAll(message, "{{languages}}", formatLanguageStats(summary.Languages))
 
-	// Dev mode: print to terminal instead of posting
-	if devMode {
-		fmt.Printf("\n%s[DEV MODE - Would post weekly summary]%s\n", Yellow, Reset)
-		fmt.Printf("  Visibility: public\n")
-		fmt.Printf("  Content:\n%s\n", message)
-		fmt.Println("---")
-		return
-	}
+	return message, nil
+}
 
-	// Post the summary
+// postWeeklySummary posts a rendered summary publicly
+func postWeeklySummary(c *mastodon.Client, ctx context.Context, message string) error {
 	post, err := c.PostStatus(ctx, &mastodon.Toot{
 		Status:     message,
 		Visibility: "public",
 	})
 	if err != nil {
-		log.Printf("Error posting weekly summary: %v", err)
-	} else {
-		log.Printf("Weekly summary posted! \nLink: %s", post.URL)
-		metricsManager.logWeeklySummary(config.Server.Username)
+		return err
+	}
+
+	logInfof("Weekly summary posted! \nLink: %s", post.URL)
+	return nil
+}
+
+// formatLanguageStats lists the languages captions were generated in, most used first, with their average response time
+func formatLanguageStats(stats map[string]LanguageStat) string {
+	langs := make([]string, 0, len(stats))
+	for lang := range stats {
+		langs = append(langs, lang)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if stats[langs[i]].Count != stats[langs[j]].Count {
+			return stats[langs[i]].Count > stats[langs[j]].Count
+		}
+		return langs[i] < langs[j]
+	})
+
+	var builder strings.Builder
+	for _, lang := range langs {
+		seconds := float64(stats[lang].AverageResponseTimeMs) / 1000
+		builder.WriteString(fmt.Sprintf("%s: %d (⌀ %s s)\n", lang, stats[lang].Count, formatLocalizedDecimal(config.Localization.DefaultLanguage, seconds, 1)))
 	}
+	return builder.String()
 }
 
 func calculateLeaderboard(entries []LogEntry) map[string]int {
@@ -109,7 +173,8 @@ func getTopUsers(userScores map[string]int) []string {
 
 	var topUsers []string
 	for i := 0; i < len(scores) && i < 3; i++ {
-		topUsers = append(topUsers, fmt.Sprintf("%d. @%s (%d alt-texts)", i+1, scores[i].Username, scores[i].Score))
+		entry := getLocalizedPluralString(config.Localization.DefaultLanguage, "leaderboardEntry", scores[i].Score)
+		topUsers = append(topUsers, fmt.Sprintf(entry, i+1, scores[i].Username, scores[i].Score))
 	}
 
 	return topUsers
@@ -123,7 +188,7 @@ func startWeeklySummaryScheduler(c *mastodon.Client) {
 		durationUntilNext := nextScheduledTime.Sub(now)
 
 		time.Sleep(1 * time.Second)
-		fmt.Printf("Next weekly summary scheduled for %s\n", nextScheduledTime.Format("2006-01-02 15:04:05"))
+		logInfof("Next weekly summary scheduled for %s", nextScheduledTime.Format("2006-01-02 15:04:05"))
 
 		// Sleep until the next scheduled time
 		time.Sleep(durationUntilNext)
@@ -171,31 +236,48 @@ func parseDayOfWeek(day string) time.Weekday {
 }
 
 func fetchWeeklyData() WeeklySummary {
+	oneWeekAgo := time.Now().AddDate(0, 0, -7)
+	var summary WeeklySummary
+
 	entries, err := readLogEntries()
 	if err != nil {
-		log.Printf("Error reading log entries: %v", err)
-		return WeeklySummary{}
+		logErrorf("Error reading log entries: %v", err)
 	}
 
-	oneWeekAgo := time.Now().AddDate(0, 0, -7)
-	altTextCount := 0
-	newUserCount := 0
-
 	for _, entry := range entries {
 		if entry.Timestamp.After(oneWeekAgo) {
 			switch entry.EventType {
 			case "alt_text_generated":
-				altTextCount++
+				summary.AltTextCount++
 			case "new_follower":
-				newUserCount++
+				summary.NewUserCount++
 			}
 		}
 	}
 
-	return WeeklySummary{
-		AltTextCount: altTextCount,
-		NewUserCount: newUserCount,
+	// The images, followers and energy come from the metrics, which only exist when they are enabled
+	events, err := readMetricsEvents("metrics.json", oneWeekAgo)
+	if err != nil {
+		logErrorf("Error reading metrics: %v", err)
 	}
+
+	summary.Languages = languageStats(events)
+	for _, event := range events {
+		switch event.EventType {
+		case "successful_generation":
+			if event.Details["mediaType"] == "image" {
+				summary.ImageCount++
+			}
+			// Despite its name the power consumption is logged in Wh, see calculatePowerConsumption
+			if wattHours, ok := event.Details["powerConsumptionKWh"].(float64); ok {
+				summary.EnergyKWh += wattHours / 1000
+			}
+		case "follow":
+			summary.FollowerCount++
+		}
+	}
+
+	return summary
 }
 
 func readLogEntries() ([]LogEntry, error) {
@@ -210,7 +292,7 @@ func readLogEntries() ([]LogEntry, error) {
 	for scanner.Scan() {
 		var entry LogEntry
 		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
-			log.Printf("Error decoding log entry: %v", err)
+			logErrorf("Error decoding log entry: %v", err)
 			continue
 		}
 		entries = append(entries, entry)
@@ -241,14 +323,14 @@ func LogEvent(eventType string) {
 
 	file, err := os.OpenFile("altbot_log.json", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		log.Printf("Error opening log file: %v", err)
+		logErrorf("Error opening log file: %v", err)
 		return
 	}
 	defer file.Close()
 
 	encoder := json.NewEncoder(file)
 	if err := encoder.Encode(entry); err != nil {
-		log.Printf("Error writing log entry: %v", err)
+		logErrorf("Error writing log entry: %v", err)
 	}
 }
 
@@ -264,13 +346,13 @@ func LogEventWithUsername(eventType, username string) {
 
 	file, err := os.OpenFile("altbot_log.json", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		log.Printf("Error opening log file: %v", err)
+		logErrorf("Error opening log file: %v", err)
 		return
 	}
 	defer file.Close()
 
 	encoder := json.NewEncoder(file)
 	if err := encoder.Encode(entry); err != nil {
-		log.Printf("Error writing log entry: %v", err)
+		logErrorf("Error writing log entry: %v", err)
 	}
 }
@@ -0,0 +1,170 @@
+/*
+ * Copyright (C) 2025 Micr0Byte <micr0@micr0.dev>
+ * Licensed under the GNU AFFERO GENERAL PUBLIC LICENSE Version 3 (AGPLv3)
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeLogEntries writes altbot_log.json, one entry per line
+func writeLogEntries(t *testing.T, entries ...LogEntry) {
+	t.Helper()
+	var lines []string
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(line))
+	}
+	if err := os.WriteFile("altbot_log.json", []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// seedWeek logs two captions, a new follower and alice's two alt-texts this week, and a caption before it
+func seedWeek(t *testing.T) {
+	t.Helper()
+	now := time.Now()
+	writeLogEntries(t,
+		LogEntry{Timestamp: now.AddDate(0, 0, -8), EventType: "alt_text_generated"},
+		LogEntry{Timestamp: now.AddDate(0, 0, -3), EventType: "alt_text_generated"},
+		LogEntry{Timestamp: now.Add(-time.Hour), EventType: "alt_text_generated"},
+		LogEntry{Timestamp: now.Add(-time.Hour), EventType: "new_follower"},
+		LogEntry{Timestamp: now.Add(-time.Hour), EventType: "human_written_alt_text", Username: "alice"},
+		LogEntry{Timestamp: now.Add(-time.Hour), EventType: "human_written_alt_text", Username: "alice"},
+	)
+}
+
+func TestRenderWeeklySummaryFillsTemplate(t *testing.T) {
+	loadTestLocalizations(t)
+	useConfig(t)
+	useBotState(t)
+	config.Localization.DefaultLanguage = "en"
+	config.WeeklySummary.MessageTemplate = "{{alt_text_count}} captions, {{new_user_count}} new users\n{{leaderboard}}Tip: {{tip_of_the_week}}"
+	config.WeeklySummary.Tips = []string{"Describe the text in screenshots."}
+	seedWeek(t)
+
+	message, err := renderWeeklySummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2 captions, 1 new users\n1. @alice (2 alt-texts)\nTip: Describe the text in screenshots."
+	if message != want {
+		t.Errorf("got %q, want %q", message, want)
+	}
+}
+
+func TestWeeklySummaryPreviewDoesNotPost(t *testing.T) {
+	loadTestLocalizations(t)
+	localizationsFile, err := os.ReadFile("localizations.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useConfig(t)
+	useBotState(t)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unexpected", http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	configFile := `[server]
+mastodon_server = "` + server.URL + `"
+
+[localization]
+default_language = "en"
+
+[weekly_summary]
+message_template = "This week: {{alt_text_count}} captions"
+`
+	if err := os.WriteFile("config.toml", []byte(configFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("localizations.json", localizationsFile, 0644); err != nil {
+		t.Fatal(err)
+	}
+	seedWeek(t)
+
+	output := captureStdout(t, func() { handleWeeklySummary([]string{"--preview"}) })
+	if !strings.Contains(output, "This week: 2 captions") {
+		t.Errorf("preview printed %q", output)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("preview made %d requests to the server", requests.Load())
+	}
+}
+
+func TestRenderWeeklySummaryUsesMetrics(t *testing.T) {
+	useConfig(t)
+	useBotState(t)
+	config.Localization.DefaultLanguage = "de"
+	config.WeeklySummary.MessageTemplate = "{{images}} images, {{followers}} followers, {{energy_kwh}} kWh\n{{languages}}"
+
+	now := time.Now()
+	writeJSONFile(t, "metrics.json", []MetricEvent{
+		{Timestamp: now.Add(-time.Hour), EventType: "successful_generation",
+			Details: map[string]interface{}{"mediaType": "image", "lang": "de", "responseTime": 1000, "powerConsumptionKWh": 250.0}},
+		{Timestamp: now.Add(-time.Hour), EventType: "successful_generation",
+			Details: map[string]interface{}{"mediaType": "image", "lang": "de", "responseTime": 2000, "powerConsumptionKWh": 500.0}},
+		{Timestamp: now.Add(-time.Hour), EventType: "successful_generation",
+			Details: map[string]interface{}{"mediaType": "video", "lang": "en", "responseTime": 4000}},
+		{Timestamp: now.Add(-time.Hour), EventType: "follow"},
+		// Before the week
+		{Timestamp: now.AddDate(0, 0, -8), EventType: "follow"},
+	})
+
+	message, err := renderWeeklySummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2 images, 1 followers, 0,750 kWh\nde: 2 (⌀ 1,5 s)\nen: 1 (⌀ 4,0 s)\n"
+	if message != want {
+		t.Errorf("got %q, want %q", message, want)
+	}
+}
+
+func TestQuietWeek(t *testing.T) {
+	useConfig(t)
+	useBotState(t)
+	config.WeeklySummary.Enabled = true
+	config.WeeklySummary.MessageTemplate = "{{alt_text_count}} captions"
+	// Only activity from before the week
+	writeLogEntries(t, LogEntry{Timestamp: time.Now().AddDate(0, 0, -8), EventType: "alt_text_generated"})
+
+	if _, err := renderWeeklySummary(); err != errQuietWeek {
+		t.Errorf("got %v without a quiet_week_message, want errQuietWeek", err)
+	}
+
+	var posts atomic.Int32
+	c := useMastodonServer(t, func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+		w.Write([]byte(`{"id":"1"}`))
+	})
+	GenerateWeeklySummary(c, ctx)
+	if posts.Load() != 0 {
+		t.Error("a summary was posted for a quiet week")
+	}
+
+	config.WeeklySummary.QuietWeekMessage = "A quiet week, remember to describe your images!"
+	message, err := renderWeeklySummary()
+	if err != nil || message != config.WeeklySummary.QuietWeekMessage {
+		t.Errorf("got %q, %v, want the quiet_week_message", message, err)
+	}
+	GenerateWeeklySummary(c, ctx)
+	if posts.Load() != 1 {
+		t.Errorf("posted %d times with a quiet_week_message, want once", posts.Load())
+	}
+}